@@ -0,0 +1,9 @@
+// Command rabbithole captures text selections and opens them in research
+// windows; see rabbithole(1).
+package main
+
+import "rabbithole/internal/app"
+
+func main() {
+	app.Main()
+}
@@ -5,7 +5,9 @@ go 1.23.0
 toolchain go1.24.4
 
 require (
+	github.com/jezek/xgb v1.3.1
 	github.com/spf13/cobra v1.9.1
+	go.starlark.net v0.0.0-20250417143717-f57e51f710eb
 	modernc.org/sqlite v1.37.1
 )
 
@@ -0,0 +1,37 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strings"
+)
+
+const (
+	defaultAccessibilityFont     = "monospace"
+	defaultAccessibilityFontSize = 20
+)
+
+// announce speaks text through speech-dispatcher when accessibility speech
+// is enabled. It doesn't wait for speech to finish.
+func announce(text string) {
+	a := config.Interface.Accessibility
+	if !a.Enabled || !a.Speak {
+		return
+	}
+	cmd := exec.Command("spd-say", "--", text)
+	if err := startCommand(cmd); err != nil {
+		slog.Warn("Failed to announce via spd-say", "err", err)
+		return
+	}
+	go waitCommand(cmd)
+}
+
+func announceMenu(prompt string, options []string) {
+	if len(options) == 0 {
+		announce(prompt + " Type your text and press Enter.")
+		return
+	}
+	preview := options[:min(len(options), 3)]
+	announce(fmt.Sprintf("%s %d options. %s", prompt, len(options), strings.Join(preview, ". ")))
+}
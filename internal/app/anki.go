@@ -0,0 +1,114 @@
+package app
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultAnkiURL   = "http://localhost:8765"
+	defaultAnkiDeck  = "Default"
+	defaultAnkiModel = "Basic"
+)
+
+var ankiClient = &http.Client{Timeout: 10 * time.Second}
+
+// ankiRequest calls an AnkiConnect action (https://foosoft.net/projects/anki-connect/)
+// and decodes its result into result.
+func ankiRequest(action string, params any, result any) error {
+	body, err := json.Marshal(map[string]any{"action": action, "version": 6, "params": params})
+	if err != nil {
+		return err
+	}
+	resp, err := ankiClient.Post(config.Anki.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to reach AnkiConnect at %s (is Anki running?): %w", config.Anki.URL, err)
+	}
+	defer resp.Body.Close()
+
+	var reply struct {
+		Result json.RawMessage `json:"result"`
+		Error  *string         `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return fmt.Errorf("failed to parse AnkiConnect reply: %w", err)
+	}
+	if reply.Error != nil {
+		return fmt.Errorf("AnkiConnect %s failed: %s", action, *reply.Error)
+	}
+	if result != nil {
+		return json.Unmarshal(reply.Result, result)
+	}
+	return nil
+}
+
+// latestNote returns the newest note of today's session, which is what
+// "rabbithole note" just attached.
+func latestNote() (string, error) {
+	var text string
+	err := db.QueryRow(
+		"SELECT text FROM notes WHERE session_id = ? ORDER BY id DESC LIMIT 1", time.Now().Format("2006-01-02"),
+	).Scan(&text)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("no note in today's session")
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read latest note: %w", err)
+	}
+	return unseal(text), nil
+}
+
+// ankiSource links the card back to the research it came from, using the
+// latest search of the session.
+func ankiSource() string {
+	e, err := latestSearchEntry()
+	if err != nil || e.When.Local().Format("2006-01-02") != time.Now().Format("2006-01-02") {
+		return ""
+	}
+	label := e.Title
+	if label == "" {
+		label = e.Query
+	}
+	if e.URL == "" {
+		return html.EscapeString(label)
+	}
+	return fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(e.URL), html.EscapeString(label))
+}
+
+// addAnkiCard creates a note with the configured note type, whose first
+// two fields are the front and back. It returns the new note's id.
+func addAnkiCard(front, back string) (int64, error) {
+	var fields []string
+	if err := ankiRequest("modelFieldNames", map[string]string{"modelName": config.Anki.Model}, &fields); err != nil {
+		return 0, err
+	}
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("note type %q needs at least two fields", config.Anki.Model)
+	}
+
+	toHTML := func(text string) string {
+		return strings.ReplaceAll(html.EscapeString(text), "\n", "<br>")
+	}
+	backHTML := toHTML(back)
+	if source := ankiSource(); source != "" {
+		backHTML += "<br><br><small>" + source + "</small>"
+	}
+
+	note := map[string]any{
+		"deckName":  config.Anki.Deck,
+		"modelName": config.Anki.Model,
+		"fields":    map[string]string{fields[0]: toHTML(front), fields[1]: backHTML},
+		"tags":      []string{appName},
+	}
+	var id int64
+	if err := ankiRequest("addNote", map[string]any{"note": note}, &id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
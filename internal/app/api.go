@@ -0,0 +1,93 @@
+package app
+
+import (
+	"fmt"
+)
+
+// embedded is set when rabbithole runs inside another program through
+// pkg/rabbithole rather than as the rabbithole command.
+var embedded bool
+
+// The functions below back pkg/rabbithole. They go through the same code
+// as the commands, so redactions, encryption, hooks and webhooks apply to
+// embedding programs too. Only pkg/rabbithole's API is kept stable.
+
+// Open loads the config and database of a profile ("" for the default) for
+// use from another program.
+func Open(profileName string) error {
+	embedded = true
+	if err := selectProfile(profileName); err != nil {
+		return err
+	}
+	return ensureConfigAndDB()
+}
+
+// Engines lists the configured engines followed by the bundles, in config
+// order.
+func Engines() []SearchEngine {
+	return menuEngines()
+}
+
+// EngineByKey looks up an engine or bundle by its key.
+func EngineByKey(key string) (SearchEngine, error) {
+	return engineByKey(key)
+}
+
+// BuildSearchURL fills the query into an engine's URL template.
+func BuildSearchURL(engine SearchEngine, query string) string {
+	return buildSearchURL(engine.URL, query)
+}
+
+// Search logs a search and opens its research windows, as rabbithole
+// search --no-menu does.
+func Search(engineKey, query, trigger string, tags []string) error {
+	if query == "" {
+		return fmt.Errorf("empty query")
+	}
+	engine, err := engineByKey(engineKey)
+	if err != nil {
+		return err
+	}
+	return dispatchSearch(engine, query, trigger, searchOptions{Tags: tags, EngineKey: engineKey, NoMenu: true})
+}
+
+// LogSearch records a search without opening a browser and returns its id.
+func LogSearch(engineKey, query, trigger string, tags []string) (int64, error) {
+	engine, err := engineByKey(engineKey)
+	if err != nil {
+		return 0, err
+	}
+	finalURL := buildSearchURL(engine.URL, query)
+	loggedQuery, loggedURL := redactSearch(query, engine.URL, finalURL)
+	return logSearch(loggedQuery, engine.Name, engine.URL, loggedURL, trigger, normalizeTags(append(projectTags(), tags...)))
+}
+
+// HistoryEntry is a logged search, newest first from History.
+type HistoryEntry = historyEntry
+
+// History returns the most recent searches.
+func History(limit int) ([]HistoryEntry, error) {
+	return loadHistory(limit)
+}
+
+// ResearchWindow is a browser window opened for a search.
+type ResearchWindow = researchWindow
+
+// OpenWindows lists the research windows that are still on screen.
+func OpenWindows() ([]ResearchWindow, error) {
+	windows, err := openResearchWindows()
+	for i := range windows {
+		unsealAll(&windows[i].URL, &windows[i].Title)
+	}
+	return windows, err
+}
+
+// CloseWindows closes the active research window, or every open one.
+func CloseWindows(all bool) ([]ResearchWindow, error) {
+	return closeResearchWindows(all)
+}
+
+// ParkWindow minimizes a research window and marks it parked.
+func ParkWindow(w ResearchWindow) error {
+	return parkWindow(w)
+}
@@ -0,0 +1,2425 @@
+// Package app is everything behind the rabbithole command. Its parts share
+// the loaded config, the database handle and the command runner as package
+// state, which is why they live in one package. The parts that don't need
+// them are split out: the config file's types (internal/config), opening
+// the database (internal/store), launcher, browser and selection command
+// lines (internal/launcher, internal/browser, internal/capture), window
+// matching and geometry (internal/wm) and the X11 client (internal/x11).
+// Other programs should use pkg/rabbithole instead.
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"rabbithole/internal/capture"
+	appconfig "rabbithole/internal/config"
+	"rabbithole/internal/store"
+	"rabbithole/internal/wm"
+)
+
+// The config file's types are internal/config's; this package knows them
+// by these names.
+type (
+	Config         = appconfig.Config
+	SearchEngine   = appconfig.SearchEngine
+	EngineBundle   = appconfig.EngineBundle
+	queryTemplate  = appconfig.Template
+	suggestionRule = appconfig.SuggestionRule
+	redactionRule  = appconfig.RedactionRule
+)
+
+var (
+	config Config
+	db     *sql.DB
+	configPath string  // Track which config file was loaded
+)
+
+const (
+	appName    = "rabbithole"
+	appVersion = "0.1.1"
+	defaultAutoDelayMs = 500  // Even longer for reliability
+	defaultMaxWindows = 5
+	defaultWindowWidth = 650   // Smaller window
+	defaultWindowHeight = 900  // Even taller
+	defaultWindowTimeoutMs = 5000
+)
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Window ID normalization functions
+func normalizeWindowID(wid string) string {
+	if strings.HasPrefix(wid, "0x") {
+		return wid
+	}
+	// Convert decimal to hex
+	if val, err := strconv.Atoi(wid); err == nil {
+		return fmt.Sprintf("0x%08x", val)
+	}
+	return wid
+}
+
+// wmctrlWindow is a window as listed by wmctrl -lpx.
+type wmctrlWindow struct {
+	pid   int
+	class string
+}
+
+// listWindows lists all windows by ID with their _NET_WM_PID (0 when
+// unknown) and WM_CLASS.
+func listWindows() (map[string]wmctrlWindow, error) {
+	out, err := commandOutput(exec.Command("wmctrl", "-lpx"))
+	if err != nil {
+		return nil, err
+	}
+	
+	windows := make(map[string]wmctrlWindow)
+	for _, line := range strings.Split(string(out), "\n") {
+		// ID DESKTOP PID INSTANCE.CLASS HOST TITLE
+		parts := strings.Fields(line)
+		if len(parts) < 4 {
+			continue
+		}
+		pid, _ := strconv.Atoi(parts[2])
+		windows[normalizeWindowID(parts[0])] = wmctrlWindow{pid: pid, class: strings.Replace(parts[3], ".", " ", 1)}
+	}
+	return windows, nil
+}
+
+func waitForNewBrowserWindow(m *wm.Matcher, before map[string]wmctrlWindow) (string, error) {
+	timeout := time.Now().Add(windowDetectTimeout())
+	for time.Now().Before(timeout) {
+		windows, err := listWindows()
+		if err == nil {
+			for wid, w := range windows {
+				if _, known := before[wid]; !known && m.Matches(w.pid, w.class) {
+					return wid, nil
+				}
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return "", fmt.Errorf("timeout waiting for new %s window", m.Class)
+}
+
+func getDatabasePath() (string, error) {
+	var targetUser string
+	
+	// If running under sudo, use the original user
+	if sudoUser := os.Getenv("SUDO_USER"); sudoUser != "" {
+		targetUser = sudoUser
+	} else {
+		// Normal execution, use current user
+		usr, err := user.Current()
+		if err != nil {
+			return "", err
+		}
+		targetUser = usr.Username
+	}
+	
+	// Look up the target user to get their home directory
+	usr, err := user.Lookup(targetUser)
+	if err != nil {
+		return "", err
+	}
+	
+	dbPath := filepath.Join(usr.HomeDir, ".local", "share", "rabbithole", profileSubdir(), "searches.db")
+	
+	// Test if we can create the directory
+	dbDir := filepath.Dir(dbPath)
+	if err := os.MkdirAll(dbDir, 0755); err != nil {
+		// Fallback to system directory
+		systemDir := "/var/lib/rabbithole"
+		if err := os.MkdirAll(systemDir, 0755); err != nil {
+			return "", fmt.Errorf("cannot create database directory in user home (%s) or system location (%s): %w", dbDir, systemDir, err)
+		}
+		return filepath.Join(systemDir, profileSubdir(), "searches.db"), nil
+	}
+	
+	return dbPath, nil
+}
+
+func ensureConfigAndDB() error {
+	if err := loadConfig(); err != nil {
+		return err
+	}
+	return initDatabase()
+}
+
+func saveConfig() error {
+	if err := checkConfigWritable(); err != nil {
+		return err
+	}
+	
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+	
+	if err := os.WriteFile(configPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write config file %s: %w", configPath, err)
+	}
+	
+	return nil
+}
+
+// checkConfigWritable tells whether saveConfig can write the loaded config
+// back, so interactive edits can be refused before any questions.
+func checkConfigWritable() error {
+	if configPath == "" {
+		return fmt.Errorf("no config file path known - config may not have been loaded")
+	}
+	if projectConfigPath != "" {
+		// The merged config would leak the project's settings into the global file
+		return fmt.Errorf("project config %s is active, run this outside the project to change %s", projectConfigPath, configPath)
+	}
+	if len(envOverridden) > 0 {
+		// Same for values that only came from the environment
+		return fmt.Errorf("%s overrides the config, unset it to change %s", strings.Join(envOverridden, ", "), configPath)
+	}
+	return nil
+}
+
+// configFilePath is the one place the config is looked for - the standard
+// user config location, per profile
+func configFilePath() string {
+	return filepath.Join(os.Getenv("HOME"), ".config", "rabbithole", profileSubdir(), "config.json")
+}
+
+func loadConfig() error {
+	configPath = configFilePath()
+	
+	file, err := os.ReadFile(configPath)
+	if err != nil && profile != "" {
+		return fmt.Errorf("can't read config file at %s: %w\nCopy a config there to set up the %q profile", configPath, err, profile)
+	}
+	if err != nil {
+		return fmt.Errorf("can't read config file at %s: %w\nRun 'make install-config' to create it", configPath, err)
+	}
+	
+	// Start over so a reload (serve-editor, daemon) drops stale overlay values
+	config = Config{}
+	if err := json.Unmarshal(file, &config); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", configPath, err)
+	}
+	if err := applyProjectConfig(); err != nil {
+		return err
+	}
+	if err := applyEnvOverrides(); err != nil {
+		return err
+	}
+	if err := compileRedactions(); err != nil {
+		return err
+	}
+
+	// Set defaults for any missing values
+	if config.Database.Path == "" {
+		dbPath, err := getDatabasePath()
+		if err != nil {
+			return fmt.Errorf("couldn't determine database path: %w", err)
+		}
+		config.Database.Path = dbPath
+	}
+	
+	if config.Behavior.AutoCopyDelayMs == 0 {
+		config.Behavior.AutoCopyDelayMs = defaultAutoDelayMs
+	}
+	
+	if config.Behavior.MaxWindows == 0 {
+		config.Behavior.MaxWindows = defaultMaxWindows
+	}
+	
+	if config.Behavior.WindowWidth == 0 {
+		config.Behavior.WindowWidth = defaultWindowWidth
+	}
+	
+	if config.Behavior.WindowHeight == 0 {
+		config.Behavior.WindowHeight = defaultWindowHeight
+	}
+	
+	if config.Behavior.SelectionMethod == "" {
+		config.Behavior.SelectionMethod = "auto"
+	}
+	
+	if config.Behavior.SelectionTimeoutMs == 0 {
+		config.Behavior.SelectionTimeoutMs = 1000
+	}
+	
+	if config.Behavior.WindowTimeoutMs == 0 {
+		config.Behavior.WindowTimeoutMs = defaultWindowTimeoutMs
+	}
+	
+	if config.Behavior.SelectionMaxLength == 0 {
+		config.Behavior.SelectionMaxLength = capture.DefaultMaxLength
+	}
+	
+	if config.Interface.Accessibility.Font == "" {
+		config.Interface.Accessibility.Font = defaultAccessibilityFont
+	}
+	
+	if config.Interface.Accessibility.FontSize == 0 {
+		config.Interface.Accessibility.FontSize = defaultAccessibilityFontSize
+	}
+	
+	if config.Obsidian.DailyNote == "" {
+		config.Obsidian.DailyNote = defaultObsidianDailyNote
+	}
+	
+	if config.Obsidian.TopicFolder == "" {
+		config.Obsidian.TopicFolder = defaultObsidianTopicDir
+	}
+	
+	if config.Obsidian.Template == "" {
+		config.Obsidian.Template = defaultObsidianTemplate
+	}
+	
+	if config.Hotkeys.Search == "" {
+		config.Hotkeys.Search = defaultSearchHotkey
+	}
+	if config.Hotkeys.ManualSearch == "" {
+		config.Hotkeys.ManualSearch = defaultManualSearchHotkey
+	}
+	
+	if config.Daemon.Listen == "" {
+		config.Daemon.Listen = defaultDaemonListen
+	}
+	
+	if config.Anki.URL == "" {
+		config.Anki.URL = defaultAnkiURL
+	}
+	if config.Anki.Deck == "" {
+		config.Anki.Deck = defaultAnkiDeck
+	}
+	if config.Anki.Model == "" {
+		config.Anki.Model = defaultAnkiModel
+	}
+	
+	if config.Behavior.ConcurrentSearch == "" {
+		config.Behavior.ConcurrentSearch = "queue"
+	}
+	
+	if len(config.ImageSearch.Engines) == 0 {
+		config.ImageSearch.Engines = defaultImageEngines
+	}
+	
+	if config.Behavior.OpenMode == "" {
+		config.Behavior.OpenMode = "window"
+	}
+	
+	if config.Behavior.IdleAction == "" {
+		config.Behavior.IdleAction = idleActionClose
+	}
+
+	if config.Behavior.DuplicateSearch == "" {
+		config.Behavior.DuplicateSearch = duplicateAsk
+	}
+	if config.Behavior.DuplicateMinutes == 0 {
+		config.Behavior.DuplicateMinutes = defaultDuplicateMinutes
+	}
+	
+	if config.Cite.Style == "" {
+		config.Cite.Style = "bibtex"
+	}
+	
+	if config.Interface.MenuOrder == "" {
+		config.Interface.MenuOrder = "frecency"
+	}
+
+	return nil
+}
+
+
+func readXSelection(selectionType string) (string, error) {
+	if selectionType != "tmux" && onMacOS() {
+		return readPasteboard(selectionType)
+	}
+	if selectionType != "tmux" && onWindows() {
+		return readWindowsClipboard(selectionType)
+	}
+	
+	command, err := capture.Command(selectionType)
+	if err != nil {
+		return "", err
+	}
+	output, err := commandOutput(exec.Command(command[0], command[1:]...))
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %w", strings.Join(command, " "), err)
+	}
+	
+	return string(output), nil
+}
+
+func captureSelectionSafely() (string, error) {
+	method := config.Behavior.SelectionMethod
+	
+	switch method {
+	case "manual":
+		return "", fmt.Errorf("selection method set to manual")
+	case "primary":
+		return captureFromSelection("primary")
+	case "clipboard":
+		return captureFromSelection("clipboard")
+	case "tmux":
+		return captureFromSelection("tmux")
+	case "auto":
+		fallthrough
+	default:
+		// Try PRIMARY selection first (highlighted text)
+		if text, err := captureFromSelection("primary"); err == nil {
+			return text, nil
+		} else if errors.Is(err, capture.ErrRefused) {
+			// Don't search something else than what was highlighted
+			return "", err
+		}
+		
+		// Fallback to CLIPBOARD selection (Ctrl+C'd text)
+		if text, err := captureFromSelection("clipboard"); err == nil {
+			return text, nil
+		} else if errors.Is(err, capture.ErrRefused) {
+			return "", err
+		}
+		
+		// Inside tmux without X selections (e.g. over SSH), use the paste buffer
+		if os.Getenv("TMUX") != "" {
+			if text, err := captureFromSelection("tmux"); err == nil {
+				return text, nil
+			}
+			return "", fmt.Errorf("no text in PRIMARY, CLIPBOARD or the tmux buffer")
+		}
+		
+		return "", fmt.Errorf("no text in PRIMARY or CLIPBOARD selections")
+	}
+}
+
+func captureFromSelection(selectionType string) (string, error) {
+	text, err := readXSelection(selectionType)
+	if err != nil {
+		return "", err
+	}
+	
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return "", fmt.Errorf("%s selection is empty", selectionType)
+	}
+	
+	trimmed, err = guardSelection(trimmed, selectionType)
+	if err != nil {
+		return "", err
+	}
+	
+	if config.Behavior.LogSelections {
+		slog.Info("Auto-captured selection", "selection", strings.ToUpper(selectionType),
+			"chars", len(trimmed), "preview", trimmed[:min(30, len(trimmed))])
+	} else {
+		slog.Info("Auto-captured selection", "selection", strings.ToUpper(selectionType), "chars", len(trimmed))
+	}
+	
+	return trimmed, nil
+}
+
+func getScreenDimensions() (width, height int) {
+	if onMacOS() {
+		if width, height, err := macScreenSize(); err == nil {
+			return width, height
+		}
+		return 1920, 1080
+	}
+	if onWindows() {
+		if width, height, err := win32ScreenSize(); err == nil {
+			return width, height
+		}
+		return 1920, 1080
+	}
+	if x, err := nativeX11(); err == nil {
+		return x.ScreenSize()
+	}
+	
+	cmd := exec.Command("xdpyinfo")
+	output, err := commandOutput(cmd)
+	if err != nil {
+		return 1920, 1080 // reasonable defaults
+	}
+	
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.Contains(line, "dimensions:") {
+			fmt.Sscanf(line, "  dimensions:    %dx%d", &width, &height)
+			return
+		}
+	}
+	return 1920, 1080
+}
+
+// showSearchMenu asks for the engine. Unless a template was already given,
+// configured templates are offered at the end of the menu; picking one
+// uses its engine, or asks for the engine again. Past searches similar to
+// the query follow; picking one searches it again, so the query returned
+// may differ from the one given.
+func showSearchMenu(query string, template *queryTemplate) (SearchEngine, string, *queryTemplate, error) {
+	engines := orderEngines(menuEngines(), query)
+	var extra []string
+	if template == nil && len(config.Templates) > 0 {
+		extra = append(extra, templatesOption)
+	}
+	related := make(map[string]relatedSearch)
+	if template == nil {
+		for _, r := range relatedSearches(query) {
+			related[r.menuOption()] = r
+			extra = append(extra, r.menuOption())
+		}
+	}
+	slog.Debug("Showing engine menu", "since_start", time.Since(processStart).Round(time.Microsecond))
+
+	// Keep prompt clean and consistent
+	engine, selected, err := chooseEngine("Search with:", engines, extra...)
+	if err != nil {
+		return SearchEngine{}, "", nil, err
+	}
+	if r, ok := related[selected]; ok {
+		slog.Info("Searching a related past search again", "engine", r.EngineName)
+		return r.engine(), r.Query, nil, nil
+	}
+	if selected != templatesOption {
+		return engine, query, template, nil
+	}
+	if template, err = chooseTemplate(); err != nil {
+		return SearchEngine{}, "", nil, err
+	}
+	if template.EngineKey != "" {
+		engine, err = engineByKey(template.EngineKey)
+		return engine, query, template, err
+	}
+	engine, _, err = chooseEngine(template.Name+" with:", engines)
+	return engine, query, template, err
+}
+
+// chooseEngine shows engines as "key: name" in the launcher and returns the
+// chosen one along with the raw selection. Picking one of the extra options
+// listed after the engines returns it with no engine.
+func chooseEngine(prompt string, engines []SearchEngine, extra ...string) (SearchEngine, string, error) {
+	// Build menu options - just show engines, not the query
+	var options, icons []string
+	for _, engine := range engines {
+		options = append(options, engine.MenuOption())
+		icons = append(icons, engine.MenuIcon())
+	}
+	options = append(options, extra...)
+
+	selected, err := runLauncherWithIcons(prompt, options, icons, config.Interface.Lines)
+	if err != nil {
+		return SearchEngine{}, "", err
+	}
+	
+	if selected == "" {
+		return SearchEngine{}, "", fmt.Errorf("no selection made")
+	}
+	if slices.Contains(extra, selected) {
+		return SearchEngine{}, selected, nil
+	}
+	
+	// Parse selection - could be "k: Kagi", just "k" for oneshot, an alias
+	// or the start of a longer key
+	engine, err := resolveEngineKey(menuKey(selected), engines)
+	if err != nil {
+		return SearchEngine{}, "", fmt.Errorf("invalid selection: %w", err)
+	}
+	
+	return engine, selected, nil
+}
+
+func buildSearchURL(searchURL, query string) string {
+	if clean := siteQueries[siteOf(searchURL)]; clean != nil {
+		query = clean(query)
+	}
+	encodedQuery := url.QueryEscape(query)
+	return strings.ReplaceAll(searchURL, "%s", encodedQuery)
+}
+
+// openBrowserInSideWindow opens the URL in a new positioned browser window
+// and returns the window's ID.
+func openBrowserInSideWindow(l browserLaunch) (string, error) {
+	backend, err := currentPlacement()
+	if err != nil {
+		return "", err
+	}
+	detect := backend.detect
+	if detect == nil {
+		detect = detectWithX11
+	}
+	if headless {
+		detect = detectHeadless
+	}
+	launch := func() (int, error) {
+		if err := l.Prepare(); err != nil {
+			return 0, err
+		}
+		command := l.Command(false)
+		cmd := exec.Command(command[0], command[1:]...)
+		if err := startCommand(cmd); err != nil {
+			return 0, fmt.Errorf("failed to start %s (is it installed?): %w", l.Browser, err)
+		}
+		return commandPID(cmd), nil
+	}
+	
+	geometry := l.Geometry
+	if geometry.Width == 0 {
+		geometry = sideWindowGeometry()
+	}
+	if backend.placeNew != nil && !headless {
+		return backend.placeNew(l, geometry, launch)
+	}
+	
+	// Launch the browser and wait for its new window to appear
+	firefoxWID, err := detect(l.WindowClass(), launch)
+	if err != nil {
+		return "", err
+	}
+	
+	slog.Debug("Detected new Firefox window", "window", firefoxWID)
+	
+	if err := backend.place(firefoxWID, geometry); err != nil {
+		slog.Warn("Failed to place research window", "window", firefoxWID, "err", err)
+	}
+	
+	return firefoxWID, nil
+}
+
+// detectWithWmctrl launches the browser and polls the window list for a
+// window of the launched browser that wasn't there before.
+func detectWithWmctrl(class string, launch func() (int, error)) (string, error) {
+	// Get current windows before launching
+	before, err := listWindows()
+	if err != nil {
+		before = make(map[string]wmctrlWindow)
+	}
+	m := wm.NewMatcher(class)
+	
+	m.PID, err = launch()
+	if err != nil {
+		return "", err
+	}
+	
+	browserWID, err := waitForNewBrowserWindow(m, before)
+	if err != nil {
+		return "", fmt.Errorf("failed to detect new browser window: %w", err)
+	}
+	return browserWID, nil
+}
+
+
+
+
+
+
+func initDatabase() error {
+	// A search may already be opening it in the background
+	databaseMu.Lock()
+	defer databaseMu.Unlock()
+	return openDatabase()
+}
+
+// openDatabase opens and migrates the database unless it's open already.
+// The caller holds databaseMu.
+func openDatabase() error {
+	if db != nil {
+		return nil
+	}
+	
+	conn, err := store.Open(config.Database.Path)
+	if err != nil {
+		return err
+	}
+	db = conn
+	
+	// Dry runs open the database for remembered geometry but change nothing
+	if !dryRun {
+		replaySpilledSearches()
+		pruneHistory()
+	}
+	return nil
+}
+
+// databaseMu guards db, which is only set once the schema is migrated.
+var databaseMu sync.Mutex
+
+// database returns the open database, or nil if it isn't open, waiting for
+// an open in progress. Code that may run while openDatabaseInBackground is
+// opening it gets the handle here rather than reading db.
+func database() *sql.DB {
+	databaseMu.Lock()
+	defer databaseMu.Unlock()
+	return db
+}
+
+func logSearch(query, engineName, engineURL, finalURL, triggerMethod, tags string) (int64, error) {
+	if database() == nil {
+		return 0, fmt.Errorf("database not initialized")
+	}
+
+	r := newSearchRecord(seal(query), engineName, engineURL, seal(finalURL), triggerMethod, tags)
+	searchID, err := insertSearch(r)
+	if err != nil {
+		return 0, err
+	}
+	journalSearch(searchID, query, finalURL, r)
+	return searchID, nil
+}
+
+// engineByKey looks up a configured engine, or the menu entry of a bundle,
+// by its hotkey.
+func engineByKey(key string) (SearchEngine, error) {
+	return resolveEngineKey(key, menuEngines())
+}
+
+// editQuery lets a captured query be trimmed before it's searched. It's
+// put on one line, since that's all the launcher's input holds.
+func editQuery(query string) (string, error) {
+	edited, err := editInLauncher("Edit query:", strings.Join(strings.Fields(query), " "))
+	if err != nil {
+		return "", fmt.Errorf("query editing failed: %w", err)
+	}
+	return strings.TrimSpace(edited), nil
+}
+
+// chooseEngineAndQuery asks for whatever of the engine and query isn't
+// known yet, returning the template too when one was picked in the menu.
+func chooseEngineAndQuery(query, engineKey string, template *queryTemplate) (SearchEngine, string, *queryTemplate, error) {
+	var engine SearchEngine
+	var err error
+	if engineKey == "" && template != nil {
+		engineKey = template.EngineKey
+	}
+	if engineKey != "" {
+		engine, err = engineByKey(engineKey)
+		if err != nil {
+			return SearchEngine{}, "", nil, err
+		}
+	} else {
+		engine, query, template, err = showSearchMenu(query, template)
+		if err != nil {
+			return SearchEngine{}, "", nil, fmt.Errorf("menu selection failed: %w", err)
+		}
+	}
+	
+	if query == "" {
+		// Prompt for manual query input with paste support
+		query, err = runLauncher("Enter search query:", nil, 0)
+		if err != nil {
+			return SearchEngine{}, "", nil, fmt.Errorf("query input failed: %w", err)
+		}
+		if query == "" {
+			return SearchEngine{}, "", nil, fmt.Errorf("empty query, aborting")
+		}
+	}
+	
+	return engine, query, template, nil
+}
+
+// searchOptions are the search command's flags that shape how a query is
+// turned into research windows.
+type searchOptions struct {
+	Tags      []string
+	EngineKey string
+	NoMenu    bool
+	Batch     bool
+	// Edit shows the query in the launcher to be trimmed before searching
+	Edit bool
+	// Template, if set, is filled in with the query (each line of a batch)
+	Template *queryTemplate
+	// Geometry places the research window, unless it's zero
+	Geometry windowGeometry
+}
+
+// handleSearch picks the engine and query, then runs the search. With
+// NoMenu it never opens the launcher: the query must already be known and
+// the engine comes from EngineKey, or the first configured engine.
+func handleSearch(query string, triggerMethod string, opts searchOptions) error {
+	query, opts = routeSearch(query, triggerMethod, opts)
+	if opts.EngineKey == "" {
+		if engine, rest, ok := parseBang(query); ok {
+			slog.Info("Engine picked by bang", "engine", engine.Name)
+			opts.EngineKey, query = engine.Key, rest
+		}
+	}
+	engineKey := opts.EngineKey
+	if opts.NoMenu {
+		if query == "" {
+			return fmt.Errorf("no query given and --no-menu is set")
+		}
+		if engineKey == "" && opts.Template != nil {
+			engineKey = opts.Template.EngineKey
+		}
+		if engineKey == "" {
+			if len(config.SearchEngines) == 0 {
+				return fmt.Errorf("no search engines configured")
+			}
+			engineKey = config.SearchEngines[0].Key
+		}
+		engine, err := engineByKey(engineKey)
+		if err != nil {
+			return err
+		}
+		return dispatchSearch(engine, query, triggerMethod, opts)
+	}
+
+	// Only one invocation shows menus at a time; the lock is released once
+	// the engine and query are chosen so queued searches replay quickly
+	lock, err := acquireMenuLock()
+	if err != nil {
+		return err
+	}
+	activeMenuLock = lock
+	if opts.Edit && query != "" && !opts.Batch {
+		query, err = editQuery(query)
+		if err != nil || query == "" {
+			activeMenuLock = nil
+			lock.release()
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("empty query, aborting")
+		}
+		// A bang may have been typed while editing
+		if engineKey == "" {
+			if engine, rest, ok := parseBang(query); ok {
+				engineKey, query = engine.Key, rest
+			}
+		}
+	}
+	// Arithmetic and unit conversions are answered without a browser
+	if line, result, ok := calculate(query); ok && engineKey == "" && opts.Template == nil && !opts.Batch && !config.Behavior.DisableCalculator {
+		search, err := showCalculation(line, result)
+		if !search {
+			activeMenuLock = nil
+			lock.release()
+			return err
+		}
+	}
+	engine, query, template, err := chooseEngineAndQuery(query, engineKey, opts.Template)
+	activeMenuLock = nil
+	lock.release()
+	if err != nil {
+		return err
+	}
+	opts.Template = template
+	return dispatchSearch(engine, query, triggerMethod, opts)
+}
+
+// runSearch logs the search and opens its research window, at geometry
+// unless that is zero.
+func runSearch(engine SearchEngine, query string, triggerMethod string, tags []string, geometry windowGeometry) error {
+	finalURL := buildSearchURL(engine.URL, query)
+	tagList := normalizeTags(append(projectTags(), tags...))
+	// Before launchFor, which looks up remembered geometry, so dry runs
+	// show the same window position
+	if err := initDatabase(); err != nil {
+		slog.Error("Failed to open database", "err", err)
+	}
+	launch := launchFor(engine, tagList, finalURL)
+	if geometry != (windowGeometry{}) {
+		launch.Geometry = geometry
+	}
+	if dryRun {
+		printDryRun(engine, query, launch)
+		return nil
+	}
+	
+	// Log the search with the exact URL we're about to open, minus
+	// anything the redaction rules mask
+	loggedQuery, loggedURL := redactSearch(query, engine.URL, finalURL)
+	if loggedQuery != query {
+		notifyUser("Search logged with redactions", loggedQuery)
+	}
+	runHook("pre_search", config.Hooks.PreSearch,
+		"QUERY", loggedQuery, "ENGINE", engine.Name, "URL", loggedURL, "TRIGGER", triggerMethod, "TAGS", tagList)
+	// A slow or locked database mustn't hold up the browser; the search's
+	// id is only needed once its window is open
+	searchLog := startSearchLog(loggedQuery, engine.Name, engine.URL, loggedURL, triggerMethod, tagList)
+	
+	sendSearchWebhook(webhookPayload{
+		Event:     "search",
+		Query:     loggedQuery,
+		Engine:    engine.Name,
+		URL:       loggedURL,
+		Timestamp: time.Now(),
+		Session:   time.Now().Format("2006-01-02"),
+		Tags:      splitTags(tagList),
+	})
+	
+	announce(fmt.Sprintf("Opening %s search for %s.", engine.Name, query))
+	
+	// Open browser in side window
+	searchID, err := openLoggedResearchWindow(searchLog, launch)
+	// Like the search log, the clipboard history waits for the browser
+	if triggerMethod == "selection" && database() != nil {
+		if err := recordClipboard(loggedQuery, "search"); err != nil {
+			slog.Warn("Failed to add selection to clipboard history", "err", err)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if searchID != 0 && config.Behavior.CaptureEnvironment {
+		if err := recordEnvironment(searchID, engine); err != nil {
+			slog.Warn("Failed to record search environment", "err", err)
+		}
+	}
+
+	return nil
+}
+
+func createRootCmd() *cobra.Command {
+	rootCmd := &cobra.Command{
+		Use:     appName,
+		Version: appVersion,
+		Short:   "Rabbit Hole - Fast research tool with auto-copy",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			verbose, _ := cmd.Flags().GetBool("verbose")
+			quiet, _ := cmd.Flags().GetBool("quiet")
+			dryRun, _ = cmd.Flags().GetBool("dry-run")
+			jsonOutput, _ = cmd.Flags().GetBool("json")
+			profileName, _ := cmd.Flags().GetString("profile")
+			if err := selectProfile(profileName); err != nil {
+				return err
+			}
+			if err := initLogging(logLevelFromFlags(verbose, quiet)); err != nil {
+				return err
+			}
+			if on, _ := cmd.Flags().GetBool("headless"); on {
+				answers, _ := cmd.Flags().GetStringArray("answer")
+				startHeadless(answers)
+			}
+			return nil
+		},
+	}
+	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "Log debug details")
+	rootCmd.PersistentFlags().BoolP("quiet", "q", false, "Only log warnings and errors")
+	rootCmd.PersistentFlags().Bool("json", false, "Print machine-readable JSON (history, query, stats, digest, status, list-engines, test-engine, paths, doctor, bench)")
+	rootCmd.PersistentFlags().Bool("dry-run", false, "Print the browser and window commands instead of running them")
+	rootCmd.PersistentFlags().Bool("headless", false, "Run without a desktop for tests: answer the launcher from --answer and stdin, simulate the browser and window tools")
+	rootCmd.PersistentFlags().StringArray("answer", nil, "In headless mode, what to answer the next launcher prompt with (repeatable)")
+	rootCmd.PersistentFlags().String("profile", "", "Use a separate config, database and log (default: $RABBITHOLE_PROFILE)")
+
+	searchCmd := &cobra.Command{
+		Use:   "search",
+		Short: "Search with auto-copy or manual input",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Hot-reload config; the database is opened for menu ordering,
+			// which only reads the schema version unless rabbithole was upgraded
+			if err := loadConfig(); err != nil {
+				return err
+			}
+			// Menu ordering needs the database; open it while the
+			// selection is read
+			if !dryRun {
+				openDatabaseInBackground()
+			}
+			
+			empty, _ := cmd.Flags().GetBool("empty")
+			fromHistory, _ := cmd.Flags().GetBool("from-clipboard-history")
+			ocr, _ := cmd.Flags().GetBool("ocr")
+			fromStdin, _ := cmd.Flags().GetBool("stdin")
+			batch, _ := cmd.Flags().GetBool("batch")
+			var query string
+			var triggerMethod string
+			// The captured text as it was, for the snippet archive
+			var captured string
+
+			if cmd.Flags().Changed("query") {
+				query, _ = cmd.Flags().GetString("query")
+				query = strings.TrimSpace(query)
+				if query == "" {
+					return fmt.Errorf("--query is empty")
+				}
+				triggerMethod = "argument"
+			} else if fromStdin {
+				data, err := io.ReadAll(os.Stdin)
+				if err != nil {
+					return fmt.Errorf("failed to read query from stdin: %w", err)
+				}
+				captured = strings.TrimSpace(string(data))
+				// Batch searches need the line breaks
+				if batch {
+					query = strings.TrimSpace(string(data))
+				} else {
+					query = strings.Join(strings.Fields(string(data)), " ")
+				}
+				if query == "" {
+					return fmt.Errorf("no query on stdin")
+				}
+				triggerMethod = "stdin"
+			} else if fromHistory {
+				if err := initDatabase(); err != nil {
+					return err
+				}
+				var err error
+				query, err = pickFromClipboardHistory()
+				if err != nil {
+					return err
+				}
+				triggerMethod = "clipboard-history"
+			} else if ocr {
+				var err error
+				query, err = ocrScreenRegion()
+				if err != nil {
+					return err
+				}
+				slog.Info("Recognized text in screenshot", "chars", len(query))
+				triggerMethod = "ocr"
+				captured = query
+			} else if empty {
+				query = ""
+				triggerMethod = "manual"
+			} else {
+				// Try safe selection capture first, fall back to manual entry
+				var err error
+				query, err = captureSelectionSafely()
+				if err != nil {
+					slog.Info("Selection capture failed, falling back to manual entry", "err", err)
+					notifyUser("Opening manual entry", "Couldn't use the selection: "+err.Error())
+					query = ""
+					triggerMethod = "manual"
+				} else {
+					triggerMethod = "selection"
+					captured = query
+				}
+			}
+
+			// Deliveries run in the background; give them a chance to finish
+			// even when opening the browser fails
+			defer waitForWebhooks(15 * time.Second)
+			
+			var opts searchOptions
+			opts.Tags, _ = cmd.Flags().GetStringSlice("tag")
+			opts.EngineKey, _ = cmd.Flags().GetString("engine")
+			opts.NoMenu, _ = cmd.Flags().GetBool("no-menu")
+			opts.Batch = batch
+			opts.Edit, _ = cmd.Flags().GetBool("edit")
+			opts.Edit = opts.Edit || config.Behavior.EditQuery
+			if name, _ := cmd.Flags().GetString("template"); name != "" {
+				template, err := templateByName(name)
+				if err != nil {
+					return err
+				}
+				opts.Template = template
+			}
+			if err := handleSearch(query, triggerMethod, opts); err != nil {
+				announce("Search cancelled.")
+				return err
+			}
+
+			if captured != "" && config.Behavior.ArchiveSnippets && database() != nil && !dryRun {
+				if err := archiveSnippet(captured, triggerMethod); err != nil {
+					slog.Warn("Failed to archive snippet", "err", err)
+				}
+			}
+
+			// The research window is already open, so the weekly check
+			// doesn't add to hotkey latency
+			if database() != nil {
+				runHealthReportIfDue()
+			}
+			return nil
+		},
+	}
+	searchCmd.Flags().BoolP("empty", "e", false, "Start with empty query")
+	searchCmd.Flags().Bool("from-clipboard-history", false, "Pick the query from earlier copied snippets")
+	searchCmd.Flags().Bool("ocr", false, "Select a screen region and use its recognized text as the query")
+	searchCmd.Flags().String("query", "", "Search for this text instead of the selection")
+	searchCmd.Flags().Bool("stdin", false, "Read the query from standard input")
+	searchCmd.Flags().String("engine", "", "Search with the engine with this key instead of asking")
+	searchCmd.Flags().Bool("no-menu", false, "Never open the launcher; use --engine (or the first engine) and fail without a query")
+	searchCmd.Flags().Bool("batch", false, "Search each line of the query separately, one tiled window per line")
+	searchCmd.Flags().Bool("edit", false, "Show the query in the launcher to edit before picking the engine")
+	searchCmd.Flags().String("template", "", "Fill in the query template with this name")
+	searchCmd.MarkFlagsMutuallyExclusive("empty", "from-clipboard-history", "ocr", "query", "stdin")
+	searchCmd.Flags().StringSliceP("tag", "t", nil, "Tag the search (repeatable or comma-separated)")
+
+	healthCmd := &cobra.Command{
+		Use:   "health",
+		Short: "Check database integrity, back it up and verify the backup",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ensureConfigAndDB(); err != nil {
+				return err
+			}
+			
+			report, err := runHealthReport()
+			if err != nil {
+				return err
+			}
+			
+			fmt.Printf("Database:  %s (%d bytes)\n", config.Database.Path, report.SizeBytes)
+			fmt.Printf("Integrity: %s\n", report.Integrity)
+			if report.BackupOK {
+				fmt.Printf("Backup:    %s (verified)\n", report.BackupPath)
+			} else {
+				fmt.Printf("Backup:    %s (FAILED)\n", report.BackupPath)
+			}
+			
+			if len(report.Issues) == 0 {
+				fmt.Println("\n✅ No issues found")
+				return nil
+			}
+			fmt.Println("\n❌ Issues:")
+			for _, issue := range report.Issues {
+				fmt.Printf("   %s\n", issue)
+			}
+			return nil
+		},
+	}
+
+	trackWindowCmd := &cobra.Command{
+		Use:    "track-window [research-window-id]",
+		Short:  "Record the trail of pages visited in a research window",
+		Hidden: true, // started automatically by search
+		Args:   cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ensureConfigAndDB(); err != nil {
+				return err
+			}
+			
+			id, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid research window id: %s", args[0])
+			}
+			return trackWindow(id)
+		},
+	}
+
+	treeCmd := &cobra.Command{
+		Use:   "tree",
+		Short: "Show the rabbit hole: searches and the pages they led to",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ensureConfigAndDB(); err != nil {
+				return err
+			}
+			
+			session, _ := cmd.Flags().GetString("session")
+			if session == "" {
+				session = time.Now().Format("2006-01-02")
+			}
+			return printTree(session)
+		},
+	}
+	treeCmd.Flags().StringP("session", "s", "", "Session to show (default: today, e.g. 2025-06-12)")
+
+	bookmarkCmd := &cobra.Command{
+		Use:   "bookmark",
+		Short: "Bookmark the page in the active research window",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ensureConfigAndDB(); err != nil {
+				return err
+			}
+			
+			tags, _ := cmd.Flags().GetStringSlice("tag")
+			if ask, _ := cmd.Flags().GetBool("ask-tags"); ask {
+				answer, err := runLauncher("Tags:", nil, 0)
+				if err != nil {
+					return fmt.Errorf("tag input failed: %w", err)
+				}
+				tags = append(tags, answer)
+			}
+			
+			b, err := bookmarkActiveWindow(tags)
+			if err != nil {
+				return err
+			}
+			slog.Info("Bookmarked page", "url", b.URL, "title", b.Title)
+			fmt.Printf("🔖 Bookmarked: %s\n", b.Title)
+			
+			if wayback, _ := cmd.Flags().GetBool("wayback"); wayback || config.Archive.WaybackOnBookmark {
+				if err := startWaybackSnapshot(b.ID); err != nil {
+					slog.Error("Failed to start Wayback Machine snapshot", "err", err)
+				}
+			}
+			return nil
+		},
+	}
+	bookmarkCmd.Flags().StringSliceP("tag", "t", nil, "Tag the bookmark (repeatable or comma-separated)")
+	bookmarkCmd.Flags().Bool("ask-tags", false, "Prompt for tags in the launcher (for hotkey use)")
+	bookmarkCmd.Flags().Bool("wayback", false, "Also save the page to the Wayback Machine")
+
+	waybackBookmarkCmd := &cobra.Command{
+		Use:    "wayback-bookmark [bookmark-id]",
+		Short:  "Save a bookmarked page to the Wayback Machine",
+		Hidden: true, // started in the background by bookmark
+		Args:   cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ensureConfigAndDB(); err != nil {
+				return err
+			}
+			
+			id, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid bookmark id: %s", args[0])
+			}
+			return snapshotBookmark(id)
+		},
+	}
+
+	archiveCmd := &cobra.Command{
+		Use:   "archive",
+		Short: "Save a local copy of the page in the active research window",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ensureConfigAndDB(); err != nil {
+				return err
+			}
+			
+			path, err := archiveActiveWindow()
+			if err != nil {
+				return err
+			}
+			slog.Info("Archived page", "path", path)
+			fmt.Printf("📦 Archived to %s\n", path)
+			return nil
+		},
+	}
+
+	citeCmd := &cobra.Command{
+		Use:   "cite [URL]",
+		Short: "Print a citation for the active research window's page",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ensureConfigAndDB(); err != nil {
+				return err
+			}
+			
+			var pageURL string
+			fromBookmark, _ := cmd.Flags().GetBool("bookmark")
+			switch {
+			case len(args) > 0:
+				pageURL = args[0]
+			case fromBookmark:
+				b, err := pickBookmark()
+				if err != nil {
+					return err
+				}
+				pageURL = b.URL
+			default:
+				w, err := activeResearchWindow()
+				if err != nil {
+					return err
+				}
+				// The page being read, not the search results it came from
+				if pageURL, err = currentPageURL(w); err != nil {
+					return fmt.Errorf("couldn't cite the current page: %w (pass its URL instead)", err)
+				}
+			}
+			
+			style, _ := cmd.Flags().GetString("style")
+			if style == "" {
+				style = config.Cite.Style
+			}
+			c, err := fetchCitation(pageURL)
+			if err != nil {
+				return err
+			}
+			text, err := formatCitation(c, style)
+			if err != nil {
+				return err
+			}
+			fmt.Println(text)
+			
+			output, _ := cmd.Flags().GetString("output")
+			if output == "" {
+				output = config.Cite.Bibliography
+			}
+			if output != "" {
+				if err := appendCitation(output, text); err != nil {
+					return err
+				}
+				fmt.Fprintf(os.Stderr, "📚 Appended to %s\n", output)
+			}
+			return nil
+		},
+	}
+	citeCmd.Flags().StringP("style", "s", "", "Citation style: bibtex, apa or mla (default: cite.style)")
+	citeCmd.Flags().BoolP("bookmark", "b", false, "Cite a bookmark chosen in the launcher")
+	citeCmd.Flags().StringP("output", "o", "", "Append the citation to this file (default: cite.bibliography)")
+
+	bookmarksCmd := &cobra.Command{
+		Use:   "bookmarks",
+		Short: "Browse bookmarks in the launcher and reopen one",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ensureConfigAndDB(); err != nil {
+				return err
+			}
+			return browseBookmarks()
+		},
+	}
+
+	noteCmd := &cobra.Command{
+		Use:   "note [text]",
+		Short: "Attach a note to the latest search of today's session",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ensureConfigAndDB(); err != nil {
+				return err
+			}
+			
+			text := strings.TrimSpace(strings.Join(args, " "))
+			if text == "" {
+				// Invoked from a hotkey, ask in the launcher
+				var err error
+				text, err = runLauncher("Note:", nil, 0)
+				if err != nil {
+					return fmt.Errorf("note input failed: %w", err)
+				}
+				if text == "" {
+					return fmt.Errorf("empty note, aborting")
+				}
+			}
+			return addNote(text)
+		},
+	}
+
+	reportCmd := &cobra.Command{
+		Use:   "report",
+		Short: "Export a session as a report",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ensureConfigAndDB(); err != nil {
+				return err
+			}
+			
+			format, _ := cmd.Flags().GetString("format")
+			if format != "markdown" && format != "md" {
+				return fmt.Errorf("unsupported report format %q (supported: markdown)", format)
+			}
+			
+			session, _ := cmd.Flags().GetString("session")
+			if session == "" {
+				session = time.Now().Format("2006-01-02")
+			}
+			
+			output, _ := cmd.Flags().GetString("output")
+			if output == "" {
+				return writeMarkdownReport(os.Stdout, session)
+			}
+			
+			file, err := os.Create(output)
+			if err != nil {
+				return fmt.Errorf("failed to create report file: %w", err)
+			}
+			defer file.Close()
+			if err := writeMarkdownReport(file, session); err != nil {
+				return err
+			}
+			fmt.Printf("✅ Wrote report for %s to %s\n", session, output)
+			return nil
+		},
+	}
+	reportCmd.Flags().StringP("session", "s", "", "Session to report on (default: today, e.g. 2025-06-12)")
+	reportCmd.Flags().StringP("format", "f", "markdown", "Report format (markdown)")
+	reportCmd.Flags().StringP("output", "o", "", "Write the report to a file instead of stdout")
+
+	graphCmd := &cobra.Command{
+		Use:   "graph",
+		Short: "Export sessions and their follow-up searches as a graph",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ensureConfigAndDB(); err != nil {
+				return err
+			}
+			
+			format, _ := cmd.Flags().GetString("format")
+			var write func(io.Writer, researchGraph) error
+			switch format {
+			case "dot":
+				write = writeDOT
+			case "json", "canvas":
+				write = writeCanvas
+			default:
+				return fmt.Errorf("unsupported graph format %q (supported: dot, json)", format)
+			}
+			
+			session, _ := cmd.Flags().GetString("session")
+			if session == "" {
+				session = time.Now().Format("2006-01-02")
+			}
+			var since time.Time
+			if value, _ := cmd.Flags().GetString("since"); value != "" {
+				var err error
+				if since, err = parsePurgeTime(value); err != nil {
+					return err
+				}
+			}
+			g, err := loadResearchGraph(session, since)
+			if err != nil {
+				return err
+			}
+			
+			output, _ := cmd.Flags().GetString("output")
+			if output == "" {
+				return write(os.Stdout, g)
+			}
+			
+			file, err := os.Create(output)
+			if err != nil {
+				return fmt.Errorf("failed to create graph file: %w", err)
+			}
+			defer file.Close()
+			if err := write(file, g); err != nil {
+				return err
+			}
+			fmt.Printf("✅ Wrote graph of %d searches to %s\n", len(g.Edges), output)
+			return nil
+		},
+	}
+	graphCmd.Flags().StringP("session", "s", "", "Session to export (default: today, e.g. 2025-06-12)")
+	graphCmd.Flags().String("since", "", "Export every session from this date on instead (YYYY-MM-DD, or an age like 7d)")
+	graphCmd.Flags().StringP("format", "f", "dot", "Graph format: dot for Graphviz, json for a JSON Canvas (Obsidian)")
+	graphCmd.Flags().StringP("output", "o", "", "Write the graph to a file instead of stdout")
+	graphCmd.MarkFlagsMutuallyExclusive("session", "since")
+
+	exportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export searches with derived columns for pandas, DuckDB or a spreadsheet",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ensureConfigAndDB(); err != nil {
+				return err
+			}
+			
+			format, _ := cmd.Flags().GetString("format")
+			output, _ := cmd.Flags().GetString("output")
+			var write func(io.Writer, []exportRow) error
+			switch format {
+			case "csv":
+				write = writeExportCSV
+			case "jsonl":
+				write = writeExportJSONLines
+			case "parquet":
+				if output == "" {
+					return fmt.Errorf("parquet export needs --output")
+				}
+			default:
+				return fmt.Errorf("unsupported export format %q (supported: csv, jsonl, parquet)", format)
+			}
+			
+			var since time.Time
+			if value, _ := cmd.Flags().GetString("since"); value != "" {
+				var err error
+				if since, err = parsePurgeTime(value); err != nil {
+					return err
+				}
+			}
+			rows, err := loadExportRows(since)
+			if err != nil {
+				return err
+			}
+			
+			if format == "parquet" {
+				if err := writeExportParquet(output, rows); err != nil {
+					return err
+				}
+			} else if output == "" {
+				return write(os.Stdout, rows)
+			} else {
+				file, err := os.Create(output)
+				if err != nil {
+					return fmt.Errorf("failed to create export file: %w", err)
+				}
+				defer file.Close()
+				if err := write(file, rows); err != nil {
+					return fmt.Errorf("failed to write export: %w", err)
+				}
+			}
+			fmt.Printf("✅ Exported %d searches to %s\n", len(rows), output)
+			return nil
+		},
+	}
+	exportCmd.Flags().StringP("format", "f", "csv", "Export format: csv, jsonl, or parquet (needs duckdb)")
+	exportCmd.Flags().String("since", "", "Only export searches from this date on (YYYY-MM-DD, or an age like 30d)")
+	exportCmd.Flags().StringP("output", "o", "", "Write the export to a file instead of stdout")
+
+	captureObsidianCmd := &cobra.Command{
+		Use:   "capture-to-obsidian",
+		Short: "Append the latest search or bookmark to your Obsidian vault",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ensureConfigAndDB(); err != nil {
+				return err
+			}
+			
+			var entry obsidianEntry
+			var err error
+			if fromBookmark, _ := cmd.Flags().GetBool("bookmark"); fromBookmark {
+				entry, err = latestBookmarkEntry()
+			} else {
+				entry, err = latestSearchEntry()
+			}
+			if err != nil {
+				return err
+			}
+			
+			topic, _ := cmd.Flags().GetString("topic")
+			if ask, _ := cmd.Flags().GetBool("ask-topic"); ask {
+				topic, err = runLauncher("Topic (empty for daily note):", nil, 0)
+				if err != nil {
+					return fmt.Errorf("topic input failed: %w", err)
+				}
+			}
+			
+			notePath, err := captureToObsidian(entry, topic)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("📝 Captured to %s\n", notePath)
+			return nil
+		},
+	}
+	captureObsidianCmd.Flags().Bool("bookmark", false, "Capture the latest bookmark instead of the latest search")
+	captureObsidianCmd.Flags().String("topic", "", "Append to a per-topic note instead of the daily note")
+	captureObsidianCmd.Flags().Bool("ask-topic", false, "Prompt for the topic in the launcher (for hotkey use)")
+
+	ankiCmd := &cobra.Command{
+		Use:   "anki",
+		Short: "Turn the selection into an Anki card via AnkiConnect",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ensureConfigAndDB(); err != nil {
+				return err
+			}
+			
+			front, _ := cmd.Flags().GetString("front")
+			if front == "" {
+				var err error
+				front, err = captureSelectionSafely()
+				if err != nil {
+					return fmt.Errorf("no front for the card: %w", err)
+				}
+			}
+			
+			back, _ := cmd.Flags().GetString("back")
+			fromNote, _ := cmd.Flags().GetBool("note")
+			if back == "" && fromNote {
+				var err error
+				back, err = latestNote()
+				if err != nil {
+					return err
+				}
+			}
+			if back == "" {
+				// Invoked from a hotkey, ask in the launcher
+				var err error
+				back, err = runLauncher("Back of \""+snippetPreview(front)+"\":", nil, 0)
+				if err != nil {
+					return fmt.Errorf("card input failed: %w", err)
+				}
+				if strings.TrimSpace(back) == "" {
+					return fmt.Errorf("empty card back, aborting")
+				}
+			}
+			
+			if deck, _ := cmd.Flags().GetString("deck"); deck != "" {
+				config.Anki.Deck = deck
+			}
+			if _, err := addAnkiCard(front, back); err != nil {
+				return err
+			}
+			notifyUser("Anki card added", snippetPreview(front))
+			fmt.Printf("🃏 Added card to %s\n", config.Anki.Deck)
+			return nil
+		},
+	}
+	ankiCmd.Flags().String("front", "", "Front of the card (default: the current selection)")
+	ankiCmd.Flags().String("back", "", "Back of the card (default: ask in the launcher)")
+	ankiCmd.Flags().Bool("note", false, "Use the latest note of today's session as the back")
+	ankiCmd.Flags().String("deck", "", "Deck to add the card to (default: anki.deck)")
+
+	historyCmd := &cobra.Command{
+		Use:   "history",
+		Short: "Show recent searches with the URLs they opened",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ensureConfigAndDB(); err != nil {
+				return err
+			}
+			
+			limit, _ := cmd.Flags().GetInt("limit")
+			entries, err := loadHistory(limit)
+			if err != nil {
+				return err
+			}
+			return printHistory(entries)
+		},
+	}
+	historyCmd.Flags().IntP("limit", "n", 20, "Number of searches to show")
+
+	queryCmd := &cobra.Command{
+		Use:          "query SQL",
+		Short:        "Run SQL against the database and print the results",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ensureConfigAndDB(); err != nil {
+				return err
+			}
+
+			write, _ := cmd.Flags().GetBool("write")
+			result, err := runQuery(args[0], write)
+			if err != nil {
+				return err
+			}
+			return printQueryResult(result)
+		},
+	}
+	queryCmd.Flags().Bool("write", false, "Allow statements that change the database")
+
+	statsCmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Show search counts and most used engines and queries",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ensureConfigAndDB(); err != nil {
+				return err
+			}
+			
+			stats, err := loadStats()
+			if err != nil {
+				return err
+			}
+			return printStats(stats)
+		},
+	}
+
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Print a one-line summary for polybar or waybar",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ensureConfigAndDB(); err != nil {
+				return err
+			}
+			
+			follow, _ := cmd.Flags().GetBool("follow")
+			return printStatus(follow)
+		},
+	}
+	statusCmd.Flags().BoolP("follow", "f", false, "Keep running and print a new line whenever the status changes")
+
+	parkCmd := &cobra.Command{
+		Use:   "park",
+		Short: "Minimize the active research window to pick it up later",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ensureConfigAndDB(); err != nil {
+				return err
+			}
+			
+			w, err := parkActiveWindow()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("🅿️  Parked: %s\n", unseal(w.Title))
+			return nil
+		},
+	}
+
+	closeCmd := &cobra.Command{
+		Use:   "close",
+		Short: "Close the active research window",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ensureConfigAndDB(); err != nil {
+				return err
+			}
+			
+			all, _ := cmd.Flags().GetBool("all")
+			windows, err := closeResearchWindows(all)
+			for _, w := range windows {
+				fmt.Printf("❎ Closed: %s\n", unseal(w.Title))
+			}
+			return err
+		},
+	}
+	closeCmd.Flags().BoolP("all", "a", false, "Close every open research window")
+
+	unparkCmd := &cobra.Command{
+		Use:   "unpark",
+		Short: "Restore a parked research window, chosen in the launcher",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ensureConfigAndDB(); err != nil {
+				return err
+			}
+			
+			w, err := unparkWindow()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("✅ Restored: %s\n", unseal(w.Title))
+			return nil
+		},
+	}
+
+	reopenLastCmd := &cobra.Command{
+		Use:   "reopen-last",
+		Short: "Reopen the most recently closed research window where it was",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ensureConfigAndDB(); err != nil {
+				return err
+			}
+			
+			w, err := reopenLastClosed()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("✅ Reopened: %s\n", w.URL)
+			return nil
+		},
+	}
+
+	serveEditorCmd := &cobra.Command{
+		Use:   "serve-editor",
+		Short: "Run searches sent by an editor plugin, one per line on stdin",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := loadConfig(); err != nil {
+				return err
+			}
+			
+			defer waitForWebhooks(15 * time.Second)
+			return serveEditor(os.Stdin, os.Stdout)
+		},
+	}
+
+	daemonCmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Serve the localhost HTTP API for other tools",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ensureConfigAndDB(); err != nil {
+				return err
+			}
+			
+			listen, _ := cmd.Flags().GetString("listen")
+			if listen == "" {
+				listen = config.Daemon.Listen
+			}
+			return runDaemon(listen)
+		},
+	}
+	daemonCmd.Flags().String("listen", "", "Address to listen on (default: daemon.listen)")
+
+	installServiceCmd := &cobra.Command{
+		Use:   "install-service",
+		Short: "Install systemd user units for the daemon and hotkeys",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := loadConfig(); err != nil {
+				return err
+			}
+			
+			if remove, _ := cmd.Flags().GetBool("remove"); remove {
+				return removeService()
+			}
+			noSocket, _ := cmd.Flags().GetBool("no-socket")
+			noHotkeys, _ := cmd.Flags().GetBool("no-hotkeys")
+			if err := installService(!noSocket, !noHotkeys); err != nil {
+				return err
+			}
+			fmt.Println("🐇 Installed. Manage with: rabbithole service start|stop|status")
+			return nil
+		},
+	}
+	installServiceCmd.Flags().Bool("no-socket", false, "Start the daemon with the session instead of on the first API request")
+	installServiceCmd.Flags().Bool("no-hotkeys", false, "Don't install the sxhkd unit")
+	installServiceCmd.Flags().Bool("remove", false, "Disable and remove the installed units")
+
+	serviceCmd := &cobra.Command{
+		Use:       "service start|stop|status",
+		Short:     "Start, stop or show the installed systemd user units",
+		Args:      cobra.ExactValidArgs(1),
+		ValidArgs: []string{"start", "stop", "status"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return manageService(args[0])
+		},
+	}
+
+	imageSearchCmd := &cobra.Command{
+		Use:   "image-search",
+		Short: "Reverse image search a screen region",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := loadConfig(); err != nil {
+				return err
+			}
+			
+			defer waitForWebhooks(15 * time.Second)
+			return imageSearch()
+		},
+	}
+
+	snippetsCmd := &cobra.Command{
+		Use:   "snippets [TEXT]",
+		Short: "Browse archived selections to copy or search them again",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ensureConfigAndDB(); err != nil {
+				return err
+			}
+			
+			filter := ""
+			if len(args) > 0 {
+				filter = args[0]
+			}
+			list, _ := cmd.Flags().GetBool("list")
+			if list || jsonOutput {
+				snippets, err := loadSnippets(filter)
+				if err != nil {
+					return err
+				}
+				return printSnippets(snippets)
+			}
+			
+			defer waitForWebhooks(15 * time.Second)
+			return browseSnippets(filter)
+		},
+	}
+	snippetsCmd.Flags().BoolP("list", "l", false, "Print the snippets instead of opening the launcher")
+
+	watchClipboardCmd := &cobra.Command{
+		Use:   "watch-clipboard",
+		Short: "Record PRIMARY and CLIPBOARD changes for search --from-clipboard-history",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ensureConfigAndDB(); err != nil {
+				return err
+			}
+			return watchClipboard()
+		},
+	}
+
+	pathsCmd := &cobra.Command{
+		Use:   "paths",
+		Short: "Show where rabbithole keeps its config, database and logs",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := loadConfig(); err != nil {
+				return err
+			}
+			
+			logPath, _ := logFilePath()
+			paths := map[string]string{
+				"config":    configPath,
+				"database":  config.Database.Path,
+				"backups":   backupDir(),
+				"log":       logPath,
+				"menu_lock": menuLockPath(),
+				"project":   projectConfigPath,
+				"api_token": daemonTokenPath(),
+			}
+			if jsonOutput {
+				return printJSON(paths)
+			}
+			for _, name := range []string{"config", "project", "database", "backups", "log", "menu_lock", "api_token"} {
+				if paths[name] == "" {
+					continue
+				}
+				fmt.Printf("%-10s %s\n", name+":", paths[name])
+			}
+			return nil
+		},
+	}
+
+	purgeCmd := &cobra.Command{
+		Use:   "purge",
+		Short: "Delete searches from the history",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ensureConfigAndDB(); err != nil {
+				return err
+			}
+			
+			var f purgeFilter
+			for flag, t := range map[string]*time.Time{"since": &f.Since, "before": &f.Before} {
+				if value, _ := cmd.Flags().GetString(flag); value != "" {
+					parsed, err := parsePurgeTime(value)
+					if err != nil {
+						return err
+					}
+					*t = parsed
+				}
+			}
+			f.Engine, _ = cmd.Flags().GetString("engine")
+			if engine, ok := searchEngineByKey(f.Engine); ok {
+				f.Engine = engine.Name
+			}
+			all, _ := cmd.Flags().GetBool("all")
+			filtered := !f.Since.IsZero() || !f.Before.IsZero() || f.Engine != ""
+			if all == filtered {
+				return fmt.Errorf("pass --since, --before and/or --engine, or --all to delete the whole history")
+			}
+			
+			if dryRun {
+				n, err := countPurge(f)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("Would purge %d searches\n", n)
+				return nil
+			}
+			purged, err := purgeHistory(f)
+			if err != nil {
+				return err
+			}
+			// Deleted rows linger in free pages until the file is rebuilt
+			if _, err := db.Exec("VACUUM"); err != nil {
+				slog.Warn("Failed to vacuum database after purge", "err", err)
+			}
+			fmt.Printf("🗑️  Purged %d searches\n", purged)
+			return nil
+		},
+	}
+	purgeCmd.Flags().String("since", "", "Only searches from this date on (YYYY-MM-DD, or an age like 7d)")
+	purgeCmd.Flags().String("before", "", "Only searches before this date (YYYY-MM-DD, or an age like 90d)")
+	purgeCmd.Flags().String("engine", "", "Only searches with this engine (name or key)")
+	purgeCmd.Flags().Bool("all", false, "Delete the whole history")
+
+	syncCmd := &cobra.Command{
+		Use:   "sync [TARGET]",
+		Short: "Merge searches, bookmarks and notes with your other machines",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ensureConfigAndDB(); err != nil {
+				return err
+			}
+			
+			targetName := config.Sync.Target
+			if len(args) > 0 {
+				targetName = args[0]
+			}
+			target, err := parseSyncTarget(targetName)
+			if err != nil {
+				return err
+			}
+			result, err := syncHistory(target)
+			if err != nil {
+				return err
+			}
+			slog.Info("Synced history", "target", target.String(), "machines", result.Machines,
+				"searches", result.Searches, "bookmarks", result.Bookmarks, "notes", result.Notes, "deleted", result.Deleted)
+			if len(result.Machines) == 0 {
+				fmt.Printf("🔄 Synced, no other machines there yet\n")
+				return nil
+			}
+			fmt.Printf("🔄 Synced with %s: %d new searches, %d bookmarks, %d notes\n",
+				strings.Join(result.Machines, ", "), result.Searches, result.Bookmarks, result.Notes)
+			if result.Deleted > 0 {
+				fmt.Printf("🗑️  Deleted %d searches purged on other machines\n", result.Deleted)
+			}
+			return nil
+		},
+	}
+
+	gcCmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Close or park research windows that haven't had focus for a while",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ensureConfigAndDB(); err != nil {
+				return err
+			}
+			
+			minutes := config.Behavior.IdleMinutes
+			if cmd.Flags().Changed("minutes") {
+				minutes, _ = cmd.Flags().GetInt("minutes")
+			}
+			if minutes <= 0 {
+				return fmt.Errorf("pass --minutes or set behavior.idle_minutes in %s", configPath)
+			}
+			action := config.Behavior.IdleAction
+			if park, _ := cmd.Flags().GetBool("park"); park {
+				action = idleActionPark
+			}
+			idleFor := time.Duration(minutes) * time.Minute
+			
+			if dryRun {
+				windows, err := idleWindows(idleFor)
+				if err != nil {
+					return err
+				}
+				for _, w := range windows {
+					fmt.Printf("Would %s: %s\n", action, unseal(w.Title))
+				}
+				return nil
+			}
+			windows, err := collectIdleWindows(idleFor, action)
+			for _, w := range windows {
+				if action == idleActionPark {
+					fmt.Printf("🅿️  Parked: %s\n", unseal(w.Title))
+				} else {
+					fmt.Printf("❎ Closed: %s\n", unseal(w.Title))
+				}
+			}
+			if err == nil && len(windows) == 0 {
+				fmt.Printf("No research windows idle for %d minutes\n", minutes)
+			}
+			return err
+		},
+	}
+	gcCmd.Flags().Int("minutes", 0, "Idle time after which windows go (default: behavior.idle_minutes)")
+	gcCmd.Flags().Bool("park", false, "Park idle windows instead of closing them")
+
+	digestCmd := &cobra.Command{
+		Use:   "digest",
+		Short: "Summarize a week of research",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ensureConfigAndDB(); err != nil {
+				return err
+			}
+			
+			from, to := lastWeek()
+			if week, _ := cmd.Flags().GetString("week"); week != "" {
+				var err error
+				if from, to, err = parseDigestWeek(week, time.Now()); err != nil {
+					return err
+				}
+			}
+			d, err := buildDigest(from, to)
+			if err != nil {
+				return err
+			}
+			if jsonOutput {
+				return printJSON(d)
+			}
+			
+			output, _ := cmd.Flags().GetString("output")
+			if output == "" {
+				writeDigestText(os.Stdout, d)
+				return nil
+			}
+			file, err := os.Create(output)
+			if err != nil {
+				return fmt.Errorf("failed to create digest file: %w", err)
+			}
+			defer file.Close()
+			writeDigestMarkdown(file, d)
+			fmt.Printf("✅ Wrote digest for %s to %s\n", d.period(), output)
+			return nil
+		},
+	}
+	digestCmd.Flags().String("week", "", "Summarize this calendar week (e.g. 2025-W07, or last) instead of the past seven days")
+	digestCmd.Flags().StringP("output", "o", "", "Write the digest to a Markdown file instead of the terminal")
+
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Edit the config or read and change single settings",
+	}
+	configCmd.AddCommand(&cobra.Command{
+		Use:   "edit",
+		Short: "Open the config file in $EDITOR and check it afterwards",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// No loadConfig first: a broken config is a reason to edit it
+			configPath = configFilePath()
+			return editConfig()
+		},
+	}, &cobra.Command{
+		Use:   "get [PATH]",
+		Short: "Print a setting in effect, e.g. behavior.window_width",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := loadConfig(); err != nil {
+				return err
+			}
+			
+			path := ""
+			if len(args) == 1 {
+				path = args[0]
+			}
+			return getConfigValue(path)
+		},
+	}, &cobra.Command{
+		Use:   "set PATH VALUE",
+		Short: "Change a setting in the config file, e.g. behavior.max_windows 8",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := loadConfig(); err != nil {
+				return err
+			}
+			
+			return setConfigValue(args[0], args[1])
+		},
+	})
+
+	doctorCmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check dependencies, session, config and database",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return printDoctor(runDoctor())
+		},
+	}
+
+	benchCmd := &cobra.Command{
+		Use:   "bench",
+		Short: "Time each stage of the hotkey path up to the engine menu",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			runs, _ := cmd.Flags().GetInt("runs")
+			if runs < 1 {
+				return fmt.Errorf("--runs must be at least 1")
+			}
+			report, err := runBench(runs)
+			if err != nil {
+				return err
+			}
+			return printBench(report)
+		},
+	}
+	benchCmd.Flags().IntP("runs", "n", 10, "Number of times to run each stage")
+
+	logsCmd := &cobra.Command{
+		Use:   "logs",
+		Short: "Show the rabbithole log",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tail, _ := cmd.Flags().GetInt("tail")
+			follow, _ := cmd.Flags().GetBool("follow")
+			return tailLogs(tail, follow)
+		},
+	}
+	logsCmd.Flags().IntP("tail", "n", 50, "Number of lines to show")
+	logsCmd.Flags().BoolP("follow", "f", false, "Keep printing new log lines")
+
+	setupCmd := &cobra.Command{
+		Use:   "setup",
+		Short: "Set up hotkeys for sxhkd, i3, sway or Hyprland",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := loadConfig(); err != nil {
+				return err
+			}
+			
+			remove, _ := cmd.Flags().GetBool("remove")
+			if backend, _ := cmd.Flags().GetString("placement"); backend != "" {
+				if remove {
+					return removePlacement(backend)
+				}
+				return setupPlacement(backend)
+			}
+			wm, _ := cmd.Flags().GetString("wm")
+			if remove {
+				return removeHotkeys(wm)
+			}
+			return setupHotkeys(wm)
+		},
+	}
+	setupCmd.Flags().String("wm", defaultHotkeyTarget(), "Where to bind the hotkeys: sxhkd, i3, sway, hypr or ahk (AutoHotkey, Windows)")
+	setupCmd.Flags().String("placement", "", "Set up window placement instead: gnome (installs a GNOME Shell extension) or kwin")
+	setupCmd.Flags().Bool("remove", false, "Remove the rabbithole bindings again")
+
+
+	addEngineCmd := &cobra.Command{
+		Use:   "add-engine [name] [url] [key]",
+		Short: "Add a new search engine",
+		Long: `Add a new search engine.
+
+With --from-url, pass a URL copied from the engine's results page instead of
+a template: add-engine --from-url "https://example.com/search?q=golang" Example e`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if fromURL, _ := cmd.Flags().GetString("from-url"); fromURL != "" {
+				return cobra.ExactArgs(2)(cmd, args)
+			}
+			return cobra.ExactArgs(3)(cmd, args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Hot-reload config first
+			if err := loadConfig(); err != nil {
+				return err
+			}
+			
+			var name, url, key string
+			if fromURL, _ := cmd.Flags().GetString("from-url"); fromURL != "" {
+				template, err := templateFromSearchURL(fromURL)
+				if err != nil {
+					return err
+				}
+				name, url, key = args[0], template, args[1]
+			} else {
+				name, url, key = args[0], args[1], args[2]
+			}
+			
+			aliases, _ := cmd.Flags().GetStringSlice("alias")
+			
+			// Validate inputs
+			if err := validateEngineKey(key, ""); err != nil {
+				return err
+			}
+			
+			if err := validateEngineURL(url); err != nil {
+				return err
+			}
+			
+			// Add the new engine
+			newEngine := SearchEngine{
+				Name:    name,
+				URL:     url,
+				Key:     key,
+				Aliases: aliases,
+			}
+			if err := validateEngineAliases(newEngine); err != nil {
+				return err
+			}
+			config.SearchEngines = append(config.SearchEngines, newEngine)
+			
+			// Save the config
+			if err := saveConfig(); err != nil {
+				return fmt.Errorf("failed to save config: %w", err)
+			}
+			
+			fmt.Printf("✅ Added search engine: %s (%s) -> %s\n", name, strings.Join(newEngine.Keys(), ", "), url)
+			return nil
+		},
+	}
+
+	addEngineCmd.Flags().String("from-url", "", "Infer the URL template from a search results URL")
+	addEngineCmd.Flags().StringSlice("alias", nil, "Another key the engine can be picked with (repeatable or comma-separated)")
+
+	listEnginesCmd := &cobra.Command{
+		Use:   "list-engines",
+		Short: "List all configured search engines",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Hot-reload config first
+			if err := loadConfig(); err != nil {
+				return err
+			}
+			
+			if jsonOutput {
+				engines := config.SearchEngines
+				if engines == nil {
+					engines = []SearchEngine{}
+				}
+				return printJSON(engines)
+			}
+			
+			if len(config.SearchEngines) == 0 {
+				fmt.Println("No search engines configured.")
+				return nil
+			}
+			
+			fmt.Printf("Configured search engines (%d):\n\n", len(config.SearchEngines))
+			for _, engine := range config.SearchEngines {
+				fmt.Printf("  %s\n", engine.MenuOption())
+				fmt.Printf("     %s\n\n", engine.URL)
+			}
+			return nil
+		},
+	}
+
+	enginesCmd := &cobra.Command{
+		Use:   "engines",
+		Short: "Add, edit, remove and reorder engines through the launcher",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := loadConfig(); err != nil {
+				return err
+			}
+			return manageEngines()
+		},
+	}
+
+	removeEngineCmd := &cobra.Command{
+		Use:   "remove-engine [key]",
+		Short: "Remove a search engine by key",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Hot-reload config first
+			if err := loadConfig(); err != nil {
+				return err
+			}
+			
+			key := args[0]
+			
+			// Find and remove the engine
+			found := false
+			newEngines := []SearchEngine{}
+			var removedEngine SearchEngine
+			
+			for _, engine := range config.SearchEngines {
+				if engine.Key == key {
+					found = true
+					removedEngine = engine
+				} else {
+					newEngines = append(newEngines, engine)
+				}
+			}
+			
+			if !found {
+				return fmt.Errorf("no search engine found with key '%s'", key)
+			}
+			
+			config.SearchEngines = newEngines
+			
+			// Save the config
+			if err := saveConfig(); err != nil {
+				return fmt.Errorf("failed to save config: %w", err)
+			}
+			
+			fmt.Printf("✅ Removed search engine: %s (%s)\n", removedEngine.Name, key)
+			return nil
+		},
+	}
+
+	testEngineCmd := &cobra.Command{
+		Use:   "test-engine <key> [query]",
+		Short: "Check that an engine's search URL still works",
+		Args:  cobra.RangeArgs(1, 2),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := loadConfig(); err != nil {
+				return err
+			}
+			
+			query := defaultTestQuery
+			if len(args) == 2 {
+				query = args[1]
+			}
+			results, engines, err := testEngines(args[0], query)
+			if err != nil {
+				return err
+			}
+			if err := printEngineTests(results); err != nil {
+				return err
+			}
+			
+			if open, _ := cmd.Flags().GetBool("open"); open {
+				if err := initDatabase(); err != nil {
+					slog.Error("Failed to open database", "err", err)
+				}
+				// Not logged: it's a test, not research
+				for _, engine := range engines {
+					if err := openResearchWindow(0, launchFor(engine, "", buildSearchURL(engine.URL, query))); err != nil {
+						return err
+					}
+				}
+			}
+			
+			failed := 0
+			for _, r := range results {
+				if !r.ok() {
+					failed++
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d engine(s) failed the check", failed)
+			}
+			return nil
+		},
+	}
+	testEngineCmd.Flags().Bool("open", false, "Also open the search in a research window (not logged)")
+
+	editEngineCmd := &cobra.Command{
+		Use:   "edit-engine [key] [name] [url] [new-key]",
+		Short: "Edit an existing search engine",
+		Args:  cobra.ExactArgs(4),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Hot-reload config first
+			if err := loadConfig(); err != nil {
+				return err
+			}
+			
+			oldKey := args[0]
+			newName := args[1]
+			newURL := args[2]
+			newKey := args[3]
+			
+			// Validate inputs
+			if err := validateEngineURL(newURL); err != nil {
+				return err
+			}
+			
+			// Find the engine to edit
+			found := false
+			for i, engine := range config.SearchEngines {
+				if engine.Key == oldKey {
+					found = true
+					
+					// Check if new key conflicts with other engines (except current one)
+					if err := validateEngineKey(newKey, oldKey); err != nil {
+						return err
+					}
+					
+					// Update the engine
+					oldEngine := config.SearchEngines[i]
+					// Browser, profile, container and inline settings are kept
+					config.SearchEngines[i].Name = newName
+					config.SearchEngines[i].URL = newURL
+					config.SearchEngines[i].Key = newKey
+					
+					// Save the config
+					if err := saveConfig(); err != nil {
+						return fmt.Errorf("failed to save config: %w", err)
+					}
+					
+					fmt.Printf("✅ Updated search engine:\n")
+					fmt.Printf("   Old: %s (%s) -> %s\n", oldEngine.Name, oldEngine.Key, oldEngine.URL)
+					fmt.Printf("   New: %s (%s) -> %s\n", newName, newKey, newURL)
+					return nil
+				}
+			}
+			
+			if !found {
+				return fmt.Errorf("no search engine found with key '%s'", oldKey)
+			}
+			
+			return nil
+		},
+	}
+
+	debugSelectionsCmd := &cobra.Command{
+		Use:   "debug-selections",
+		Short: "Show current X11 selections for troubleshooting",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fmt.Println("Current X11 selections:")
+			fmt.Println("=======================")
+			
+			// Check PRIMARY selection
+			if primary, err := readXSelection("primary"); err == nil && strings.TrimSpace(primary) != "" {
+				fmt.Printf("PRIMARY:   '%s' (%d chars)\n", strings.TrimSpace(primary), len(strings.TrimSpace(primary)))
+			} else {
+				fmt.Printf("PRIMARY:   (empty or error: %v)\n", err)
+			}
+			
+			// Check CLIPBOARD selection  
+			if clipboard, err := readXSelection("clipboard"); err == nil && strings.TrimSpace(clipboard) != "" {
+				fmt.Printf("CLIPBOARD: '%s' (%d chars)\n", strings.TrimSpace(clipboard), len(strings.TrimSpace(clipboard)))
+			} else {
+				fmt.Printf("CLIPBOARD: (empty or error: %v)\n", err)
+			}
+			
+			if os.Getenv("TMUX") != "" {
+				if buffer, err := readXSelection("tmux"); err == nil && strings.TrimSpace(buffer) != "" {
+					fmt.Printf("TMUX:      '%s' (%d chars)\n", strings.TrimSpace(buffer), len(strings.TrimSpace(buffer)))
+				} else {
+					fmt.Printf("TMUX:      (empty or error: %v)\n", err)
+				}
+			}
+			
+			return nil
+		},
+	}
+
+	rootCmd.AddCommand(searchCmd, imageSearchCmd, serveEditorCmd, daemonCmd, installServiceCmd, serviceCmd, historyCmd, queryCmd, purgeCmd, syncCmd, statsCmd, digestCmd, statusCmd, parkCmd, unparkCmd, closeCmd, gcCmd, reopenLastCmd, snippetsCmd, watchClipboardCmd, pathsCmd, configCmd, trackWindowCmd, treeCmd, bookmarkCmd, waybackBookmarkCmd, bookmarksCmd, archiveCmd, citeCmd, noteCmd, ankiCmd, reportCmd, graphCmd, exportCmd, captureObsidianCmd, healthCmd, doctorCmd, benchCmd, logsCmd, setupCmd, addEngineCmd, listEnginesCmd, enginesCmd, removeEngineCmd, editEngineCmd, testEngineCmd, debugSelectionsCmd)
+	return rootCmd
+}
+
+// Main runs the rabbithole command line and exits with status 1 if the
+// command fails.
+func Main() {
+	rootCmd := createRootCmd()
+	
+	cmd, err := rootCmd.ExecuteC()
+	if headless {
+		printHeadlessCommands(os.Stderr)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		runHook("on_error", config.Hooks.OnError, "COMMAND", cmd.Name(), "ERROR", redact(err.Error()))
+		os.Exit(1)
+	}
+}
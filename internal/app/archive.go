@@ -0,0 +1,136 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"rabbithole/internal/launcher"
+)
+
+func archiveDir() string {
+	if config.Archive.Dir != "" {
+		return expandHome(config.Archive.Dir)
+	}
+	return filepath.Join(filepath.Dir(config.Database.Path), "archive")
+}
+
+var nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// archiveFileName names a snapshot after the date and page title, falling
+// back to the host.
+func archiveFileName(rawURL, title string) string {
+	name := title
+	if name == "" {
+		name = siteOf(rawURL)
+	}
+	slug := strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if len(slug) > 60 {
+		slug = strings.TrimRight(slug[:60], "-")
+	}
+	return fmt.Sprintf("%s-%s.html", time.Now().Format("2006-01-02-150405"), slug)
+}
+
+// savePage writes a snapshot of rawURL to path. archive.command runs with
+// {url} and {file} substituted; without {file} its output is saved. With no
+// command, monolith is used if installed, else the raw HTML is fetched.
+func savePage(rawURL, path string) error {
+	template := config.Archive.Command
+	if template == "" {
+		if _, err := exec.LookPath("monolith"); err != nil {
+			return fetchPage(rawURL, path)
+		}
+		template = "monolith {url} -o {file}"
+	}
+
+	words := launcher.SplitCommandLine(template)
+	if len(words) == 0 {
+		return fmt.Errorf("archive.command is blank")
+	}
+	toFile := strings.Contains(template, "{file}")
+	for i, word := range words {
+		words[i] = strings.NewReplacer("{url}", rawURL, "{file}", path).Replace(word)
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command(words[0], words[1:]...)
+	cmd.Stderr = &stderr
+	if !toFile {
+		out, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("failed to create archive file: %w", err)
+		}
+		defer out.Close()
+		cmd.Stdout = out
+	}
+	if err := runCommand(cmd); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("archive command failed: %w: %s", err, msg)
+		}
+		return fmt.Errorf("archive command failed: %w", err)
+	}
+	return nil
+}
+
+func fetchPage(rawURL, path string) error {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", appName+"/"+appVersion)
+	resp, err := (&http.Client{Timeout: 30 * time.Second}).Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch page: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch page: %s", resp.Status)
+	}
+
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create archive file: %w", err)
+	}
+	defer out.Close()
+	if _, err := io.Copy(out, resp.Body); err != nil {
+		return fmt.Errorf("failed to save page: %w", err)
+	}
+	return nil
+}
+
+// archiveActiveWindow saves the focused research window's page into the
+// archive directory and records where it went.
+func archiveActiveWindow() (string, error) {
+	w, err := activeResearchWindow()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(archiveDir(), 0755); err != nil {
+		return "", fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	// The page the window shows now, not the one the search opened
+	if w.URL, err = currentPageURL(w); err != nil {
+		return "", fmt.Errorf("couldn't archive the current page: %w", err)
+	}
+	path := filepath.Join(archiveDir(), archiveFileName(w.URL, w.Title))
+	if err := savePage(w.URL, path); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+
+	_, err = db.Exec(
+		"INSERT INTO page_archives (search_id, window_id, url, title, path) VALUES (?, ?, ?, ?, ?)",
+		w.SearchID, w.ID, seal(w.URL), seal(w.Title), path,
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to record archived page: %w", err)
+	}
+	return path, nil
+}
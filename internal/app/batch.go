@@ -0,0 +1,104 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// searchJob is one research window of a multi-window search.
+type searchJob struct {
+	engine SearchEngine
+	query  string
+}
+
+// batchQueries splits a multi-line selection into one query per non-empty
+// line.
+func batchQueries(text string) []string {
+	var queries []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			queries = append(queries, line)
+		}
+	}
+	return queries
+}
+
+// dispatchSearch runs the chosen engine, or every engine of a bundle, on
+// the query, or with Batch on each of its lines. Anything beyond a single
+// window is tiled.
+func dispatchSearch(engine SearchEngine, query string, triggerMethod string, opts searchOptions) error {
+	engines := []SearchEngine{engine}
+	if bundle, ok := bundleByKey(engine.Key); ok {
+		var err error
+		if engines, err = resolveBundle(bundle); err != nil {
+			return err
+		}
+	}
+	// Only a single translator asks for languages; several in one go use
+	// what each remembers
+	ask := len(engines) == 1 && !opts.NoMenu
+	for i, e := range engines {
+		if isTranslator(e) {
+			var err error
+			if engines[i], err = translationEngine(e, ask); err != nil {
+				return err
+			}
+		}
+	}
+
+	if opts.Template != nil {
+		query = opts.Template.Fill(query, opts.Batch)
+	}
+	engines, query, err := modifySearch(engines, query)
+	if err != nil {
+		return err
+	}
+
+	queries := []string{query}
+	if opts.Batch {
+		queries = batchQueries(query)
+	}
+	if len(engines) == 1 && len(queries) == 1 {
+		if engines[0].Inline != "" && !opts.NoMenu {
+			return runInlineSearch(engines[0], queries[0], triggerMethod, opts.Tags)
+		}
+		if focused, err := openDuplicateSearch(engines[0], queries[0], opts.NoMenu); focused || err != nil {
+			return err
+		}
+		return runSearch(engines[0], queries[0], triggerMethod, opts.Tags, opts.Geometry)
+	}
+
+	var jobs []searchJob
+	for _, q := range queries {
+		for _, e := range engines {
+			jobs = append(jobs, searchJob{engine: e, query: q})
+		}
+	}
+	if max := config.Behavior.MaxWindows; len(jobs) > max {
+		slog.Warn("Search needs more windows than max_windows, dropping the rest",
+			"windows", len(jobs), "max_windows", max)
+		notifyUser("Too many windows", fmt.Sprintf("Opening %d of %d searches (behavior.max_windows)", max, len(jobs)))
+		jobs = jobs[:max]
+	}
+	return runSearches(jobs, triggerMethod, opts.Tags)
+}
+
+// runSearches opens one tiled research window per job. A failed search
+// doesn't stop the others.
+func runSearches(jobs []searchJob, triggerMethod string, tags []string) error {
+	tiles := tiledGeometries(len(jobs))
+	slog.Info("Starting multi-window search", "windows", len(jobs))
+
+	failed := 0
+	for i, job := range jobs {
+		if err := runSearch(job.engine, job.query, triggerMethod, tags, tiles[i]); err != nil {
+			slog.Error("Search failed", "engine", job.engine.Name, "query", job.query, "err", err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d searches failed", failed, len(jobs))
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package app
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"time"
+)
+
+// menuLatencyTarget is how long the hotkey path may take to show the menu.
+const menuLatencyTarget = 50 * time.Millisecond
+
+// benchStage is one step of the hotkey path, timed over several runs.
+type benchStage struct {
+	Name string `json:"name"`
+	// Parallel stages overlap with selection capture
+	Parallel bool    `json:"parallel"`
+	MedianMs float64 `json:"median_ms"`
+	MaxMs    float64 `json:"max_ms"`
+	Error    string  `json:"error,omitempty"`
+
+	median time.Duration
+}
+
+// benchReport is what bench measured. TotalMs follows the critical path
+// from the hotkey to the menu.
+type benchReport struct {
+	Runs     int          `json:"runs"`
+	Stages   []benchStage `json:"stages"`
+	TotalMs  float64      `json:"total_ms"`
+	TargetMs float64      `json:"target_ms"`
+}
+
+// runBench times each stage of the hotkey path runs times, in process
+// except for the start of a fresh rabbithole.
+func runBench(runs int) (benchReport, error) {
+	if err := loadConfig(); err != nil {
+		return benchReport{}, err
+	}
+	logFile, err := logFilePath()
+	if err != nil {
+		return benchReport{}, err
+	}
+	self, err := executable()
+	if err != nil {
+		return benchReport{}, fmt.Errorf("failed to find rabbithole executable: %w", err)
+	}
+
+	stages := []struct {
+		name     string
+		parallel bool
+		run      func() error
+	}{
+		{"process start", false, func() error {
+			return runCommand(exec.Command(self, "--version"))
+		}},
+		{"config", false, loadConfig},
+		{"log file", true, func() error {
+			file, err := openLogFile(logFile)
+			if err != nil {
+				return err
+			}
+			return file.Close()
+		}},
+		{"database", true, func() error {
+			closeDatabase()
+			return initDatabase()
+		}},
+		{"selection", false, func() error {
+			_, err := readXSelection("primary")
+			return err
+		}},
+		{"menu order", false, func() error {
+			orderEngines(menuEngines(), "")
+			return nil
+		}},
+	}
+
+	report := benchReport{Runs: runs, TargetMs: milliseconds(menuLatencyTarget)}
+	var total, parallel, selection time.Duration
+	for _, s := range stages {
+		stage := benchStage{Name: s.name, Parallel: s.parallel}
+		var times []time.Duration
+		for i := 0; i < runs; i++ {
+			start := time.Now()
+			if err := s.run(); err != nil {
+				stage.Error = err.Error()
+				break
+			}
+			times = append(times, time.Since(start))
+		}
+		if len(times) > 0 {
+			sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+			stage.median = times[len(times)/2]
+			stage.MedianMs = milliseconds(stage.median)
+			stage.MaxMs = milliseconds(times[len(times)-1])
+		}
+		switch {
+		case s.parallel:
+			parallel = max(parallel, stage.median)
+		case s.name == "selection":
+			selection = stage.median
+		default:
+			total += stage.median
+		}
+		report.Stages = append(report.Stages, stage)
+	}
+	report.TotalMs = milliseconds(total + max(selection, parallel))
+	return report, nil
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func printBench(report benchReport) error {
+	if jsonOutput {
+		return printJSON(report)
+	}
+	fmt.Printf("⏱️  Hotkey to menu, median of %d runs:\n\n", report.Runs)
+	for _, s := range report.Stages {
+		note := ""
+		if s.Parallel {
+			note = "  (alongside selection)"
+		}
+		if s.Error != "" {
+			fmt.Printf("   %-14s ❌ %s\n", s.Name, s.Error)
+			continue
+		}
+		fmt.Printf("   %-14s %7.1f ms  (max %.1f)%s\n", s.Name, s.MedianMs, s.MaxMs, note)
+	}
+	fmt.Println()
+	if report.TotalMs <= report.TargetMs {
+		fmt.Printf("✅ %.1f ms, under the %.0f ms target\n", report.TotalMs, report.TargetMs)
+	} else {
+		fmt.Printf("⚠️  %.1f ms, over the %.0f ms target\n", report.TotalMs, report.TargetMs)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type bookmark struct {
+	ID        int64
+	SearchID  int64
+	URL       string
+	Title     string
+	Tags      string
+	CreatedAt time.Time
+	// WaybackURL is the Internet Archive snapshot, if one was taken
+	WaybackURL string
+}
+
+// normalizeTags turns user input like "go, generics  papers" into a
+// canonical comma-separated list.
+func normalizeTags(tags []string) string {
+	var clean []string
+	for _, tag := range tags {
+		for _, t := range strings.FieldsFunc(tag, func(r rune) bool { return r == ',' || r == ' ' }) {
+			clean = append(clean, strings.ToLower(t))
+		}
+	}
+	return strings.Join(clean, ",")
+}
+
+// bookmarkActiveWindow saves the focused research window's page.
+func bookmarkActiveWindow(tags []string) (bookmark, error) {
+	w, err := activeResearchWindow()
+	if err != nil {
+		return bookmark{}, err
+	}
+
+	// The page the window shows now, not the one the search opened
+	url, err := currentPageURL(w)
+	if err != nil {
+		return bookmark{}, fmt.Errorf("couldn't bookmark the current page: %w", err)
+	}
+	b := bookmark{SearchID: w.SearchID, URL: url, Title: w.Title, Tags: normalizeTags(tags)}
+	result, err := db.Exec(
+		"INSERT INTO bookmarks (search_id, window_id, url, title, tags) VALUES (?, ?, ?, ?, ?)",
+		b.SearchID, w.ID, seal(b.URL), seal(b.Title), b.Tags,
+	)
+	if err != nil {
+		return bookmark{}, fmt.Errorf("failed to save bookmark: %w", err)
+	}
+	b.ID, _ = result.LastInsertId()
+	writeJournal(journalEvent{
+		Event: "bookmark", BookmarkID: b.ID, SearchID: b.SearchID, ResearchWindowID: w.ID,
+		URL: b.URL, Title: b.Title, Tags: splitTags(b.Tags),
+	})
+	return b, nil
+}
+
+func listBookmarks() ([]bookmark, error) {
+	rows, err := db.Query("SELECT id, COALESCE(search_id, 0), url, title, tags, created_at, wayback_url FROM bookmarks ORDER BY created_at DESC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query bookmarks: %w", err)
+	}
+	defer rows.Close()
+
+	var bookmarks []bookmark
+	for rows.Next() {
+		var b bookmark
+		if err := rows.Scan(&b.ID, &b.SearchID, &b.URL, &b.Title, &b.Tags, &b.CreatedAt, &b.WaybackURL); err != nil {
+			return nil, fmt.Errorf("failed to read bookmark: %w", err)
+		}
+		unsealAll(&b.URL, &b.Title, &b.WaybackURL)
+		bookmarks = append(bookmarks, b)
+	}
+	return bookmarks, rows.Err()
+}
+
+// pickBookmark lets the user choose a bookmark in the launcher.
+func pickBookmark() (bookmark, error) {
+	bookmarks, err := listBookmarks()
+	if err != nil {
+		return bookmark{}, err
+	}
+	if len(bookmarks) == 0 {
+		return bookmark{}, fmt.Errorf("no bookmarks yet - use 'rabbithole bookmark' on a research window")
+	}
+
+	options := make([]string, len(bookmarks))
+	for i, b := range bookmarks {
+		label := b.Title
+		if label == "" {
+			label = b.URL
+		}
+		if b.Tags != "" {
+			label += " [" + b.Tags + "]"
+		}
+		options[i] = fmt.Sprintf("%d: %s", b.ID, label)
+	}
+
+	selected, err := runLauncher("Bookmark:", options, 15)
+	if err != nil {
+		return bookmark{}, err
+	}
+	if selected == "" {
+		return bookmark{}, fmt.Errorf("no selection made")
+	}
+
+	idStr, _, _ := strings.Cut(selected, ":")
+	id, err := strconv.ParseInt(strings.TrimSpace(idStr), 10, 64)
+	if err != nil {
+		return bookmark{}, fmt.Errorf("invalid selection: %s", selected)
+	}
+	for _, b := range bookmarks {
+		if b.ID == id {
+			return b, nil
+		}
+	}
+	return bookmark{}, fmt.Errorf("invalid selection: %s", selected)
+}
+
+// browseBookmarks shows bookmarks in the launcher and reopens the chosen
+// one in a research window.
+func browseBookmarks() error {
+	b, err := pickBookmark()
+	if err != nil {
+		return err
+	}
+	return openResearchWindow(b.SearchID, launchFor(engineForSearch(b.SearchID), b.Tags, b.URL))
+}
@@ -0,0 +1,96 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"os/user"
+	"path/filepath"
+
+	"rabbithole/internal/browser"
+)
+
+// browserLaunch is how one URL is opened, filled in from the config by
+// launchFor.
+type browserLaunch = browser.Launch
+
+// launchFor applies the engine's browser, profile and container overrides
+// on top of the global defaults. Without an engine container, the first of
+// the comma-separated tags with a configured container picks one.
+func launchFor(engine SearchEngine, tags, rawURL string) browserLaunch {
+	l := browserLaunch{Browser: engine.Browser, Profile: engine.Profile, Container: engine.Container, URL: rawURL}
+	if l.Container == "" {
+		l.Container = containerForTags(tags)
+	}
+	if config.Behavior.RememberGeometry {
+		if g, ok := engineGeometry(engine.Name); ok {
+			l.Geometry = g
+		}
+	}
+	if l.Browser == "" {
+		l.Browser = config.Behavior.Browser
+	}
+	if l.Browser == "" {
+		l.Browser = browser.Default
+	}
+	if l.Profile == "" && !l.Chromium() {
+		l.Profile = config.Behavior.FirefoxProfile
+	}
+
+	l.Zoom = engine.Zoom
+	if l.Zoom == 0 {
+		l.Zoom = config.Behavior.Zoom
+	}
+	l.Reader = engine.ReaderMode || config.Behavior.ReaderMode
+
+	// Tabs need the tab strip, so the tab mode window keeps its UI
+	ui := engine.WindowUI
+	if ui == "" {
+		ui = config.Behavior.WindowUI
+	}
+	l.Minimal = ui == "minimal" && config.Behavior.OpenMode != "tab"
+	if l.Minimal && !l.Chromium() {
+		dir, err := minimalFirefoxProfileDir()
+		if err != nil {
+			slog.Warn("Opening a normal window instead of a minimal one", "err", err)
+			l.Minimal = false
+		} else {
+			l.Profile = dir
+		}
+	}
+	return l
+}
+
+func containerForTags(tags string) string {
+	for _, tag := range splitTags(tags) {
+		if container := config.Behavior.TagContainers[tag]; container != "" {
+			return container
+		}
+	}
+	return ""
+}
+
+// engineForSearch finds the configured engine a logged search used, so
+// reopening its pages keeps the engine's browser, profile and container.
+// Engines that were since removed yield the defaults.
+func engineForSearch(searchID int64) SearchEngine {
+	var name string
+	if err := db.QueryRow("SELECT engine_name FROM searches WHERE id = ?", searchID).Scan(&name); err != nil {
+		return SearchEngine{}
+	}
+	for _, engine := range config.SearchEngines {
+		if engine.Name == name {
+			return engine
+		}
+	}
+	return SearchEngine{}
+}
+
+// minimalFirefoxProfileDir is where the profile of minimal Firefox windows
+// is kept, next to the other profiles' data.
+func minimalFirefoxProfileDir() (string, error) {
+	usr, err := user.Current()
+	if err != nil {
+		return "", fmt.Errorf("couldn't determine user home directory for the minimal Firefox profile: %w", err)
+	}
+	return filepath.Join(usr.HomeDir, ".local", "share", "rabbithole", profileSubdir(), "minimal-firefox"), nil
+}
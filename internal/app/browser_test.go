@@ -0,0 +1,51 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLaunchForOverrides(t *testing.T) {
+	useConfig(t)
+	config.Behavior.Browser = "firefox"
+	config.Behavior.FirefoxProfile = "default-release"
+	config.Behavior.TagContainers = map[string]string{"work": "Work"}
+
+	l := launchFor(SearchEngine{Name: "Docs"}, "misc,work", "https://example.com")
+	if l.Browser != "firefox" || l.Profile != "default-release" || l.Container != "Work" {
+		t.Errorf("defaults not applied: %+v", l)
+	}
+
+	// Chromium profiles are directory names, not Firefox's
+	l = launchFor(SearchEngine{Name: "Docs", Browser: "chromium", Container: "Personal"}, "work", "https://example.com")
+	if l.Browser != "chromium" || l.Profile != "" || l.Container != "Personal" {
+		t.Errorf("engine overrides not applied: %+v", l)
+	}
+}
+
+// TestOpenBrowserInSideWindow runs a launch through the wmctrl backend with
+// the window simulated, as in headless mode.
+func TestOpenBrowserInSideWindow(t *testing.T) {
+	if onMacOS() || onWindows() {
+		t.Skip("browsers are started through open/start here")
+	}
+	useConfig(t)
+	useHeadless(t)
+	config.Placement.Backend = "wmctrl"
+	fake := useFakeRunner(t, nil)
+
+	g := windowGeometry{X: 1000, Y: 80, Width: 800, Height: 900}
+	windowID, err := openBrowserInSideWindow(browserLaunch{Browser: "firefox", URL: "https://example.com", Geometry: g})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(windowID, "0x7f") {
+		t.Errorf("window %q isn't a simulated one", windowID)
+	}
+	assertCommands(t, fake,
+		"firefox --new-window https://example.com",
+		fmt.Sprintf("wmctrl -i -r %s -b remove,maximized_vert,maximized_horz", windowID),
+		fmt.Sprintf("wmctrl -i -r %s -e 0,1000,80,800,900", windowID),
+	)
+}
@@ -0,0 +1,49 @@
+package app
+
+import "fmt"
+
+// resolveBundle looks up the bundle's engines by key.
+func resolveBundle(b EngineBundle) ([]SearchEngine, error) {
+	engines := make([]SearchEngine, 0, len(b.Engines))
+	for _, key := range b.Engines {
+		engine, ok := searchEngineByKey(key)
+		if !ok {
+			return nil, fmt.Errorf("bundle %q: no engine with key '%s'", b.Name, key)
+		}
+		engines = append(engines, engine)
+	}
+	if len(engines) == 0 {
+		return nil, fmt.Errorf("bundle %q has no engines", b.Name)
+	}
+	return engines, nil
+}
+
+func searchEngineByKey(key string) (SearchEngine, bool) {
+	for _, engine := range config.SearchEngines {
+		for _, k := range engine.Keys() {
+			if k == key {
+				return engine, true
+			}
+		}
+	}
+	return SearchEngine{}, false
+}
+
+func bundleByKey(key string) (EngineBundle, bool) {
+	for _, bundle := range config.Bundles {
+		if bundle.Key == key {
+			return bundle, true
+		}
+	}
+	return EngineBundle{}, false
+}
+
+// menuEngines lists the engines followed by the bundles, as shown in the
+// search menu.
+func menuEngines() []SearchEngine {
+	engines := append([]SearchEngine(nil), config.SearchEngines...)
+	for _, bundle := range config.Bundles {
+		engines = append(engines, bundle.MenuEntry())
+	}
+	return engines
+}
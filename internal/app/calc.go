@@ -0,0 +1,296 @@
+package app
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The calculator answers arithmetic ("3*(4+5)^2") and unit conversions
+// ("10 km to mi") in the launcher before any engine is asked for.
+
+// calcParser is a recursive descent parser over expression:
+//
+//	expr   = term { ("+" | "-") term }
+//	term    = factor { ("*" | "/" | "%") factor }
+//	factor  = "-" factor | primary [ "^" factor ]
+//	primary = number | "(" expr ")" | "sqrt" "(" expr ")" | "pi"
+type calcParser struct {
+	input string
+	pos   int
+}
+
+var calcReplacer = strings.NewReplacer("×", "*", "÷", "/", "−", "-", "**", "^", ",", "")
+
+// evalExpression evaluates an arithmetic expression.
+func evalExpression(s string) (float64, error) {
+	p := &calcParser{input: strings.ToLower(calcReplacer.Replace(s))}
+	v, err := p.expr()
+	if err != nil {
+		return 0, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.input) {
+		return 0, fmt.Errorf("unexpected %q", p.input[p.pos:])
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("result is not a number")
+	}
+	return v, nil
+}
+
+func (p *calcParser) skipSpace() {
+	for p.pos < len(p.input) && p.input[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// accept consumes tok if it comes next.
+func (p *calcParser) accept(tok string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.input[p.pos:], tok) {
+		p.pos += len(tok)
+		return true
+	}
+	return false
+}
+
+func (p *calcParser) expr() (float64, error) {
+	v, err := p.term()
+	for err == nil {
+		var rhs float64
+		switch {
+		case p.accept("+"):
+			rhs, err = p.term()
+			v += rhs
+		case p.accept("-"):
+			rhs, err = p.term()
+			v -= rhs
+		default:
+			return v, nil
+		}
+	}
+	return 0, err
+}
+
+func (p *calcParser) term() (float64, error) {
+	v, err := p.factor()
+	for err == nil {
+		var rhs float64
+		switch {
+		case p.accept("*"):
+			rhs, err = p.factor()
+			v *= rhs
+		case p.accept("/"):
+			rhs, err = p.factor()
+			v /= rhs
+		case p.accept("%"):
+			rhs, err = p.factor()
+			v = math.Mod(v, rhs)
+		default:
+			return v, nil
+		}
+	}
+	return 0, err
+}
+
+func (p *calcParser) factor() (float64, error) {
+	// Binds looser than ^, so -3^2 is -9
+	if p.accept("-") {
+		v, err := p.factor()
+		return -v, err
+	}
+	base, err := p.primary()
+	if err != nil {
+		return 0, err
+	}
+	if p.accept("^") {
+		exp, err := p.factor()
+		if err != nil {
+			return 0, err
+		}
+		return math.Pow(base, exp), nil
+	}
+	return base, nil
+}
+
+func (p *calcParser) primary() (float64, error) {
+	switch {
+	case p.accept("("):
+		return p.group()
+	case p.accept("sqrt("):
+		v, err := p.group()
+		return math.Sqrt(v), err
+	case p.accept("pi"):
+		return math.Pi, nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.input) && (p.input[p.pos] >= '0' && p.input[p.pos] <= '9' || p.input[p.pos] == '.') {
+		p.pos++
+	}
+	if start == p.pos {
+		if p.pos == len(p.input) {
+			return 0, fmt.Errorf("unexpected end of expression")
+		}
+		return 0, fmt.Errorf("unexpected %q", p.input[p.pos:])
+	}
+	return strconv.ParseFloat(p.input[start:p.pos], 64)
+}
+
+// group parses the rest of a parenthesised expression.
+func (p *calcParser) group() (float64, error) {
+	v, err := p.expr()
+	if err != nil {
+		return 0, err
+	}
+	if !p.accept(")") {
+		return 0, fmt.Errorf("missing )")
+	}
+	return v, nil
+}
+
+var calcCharsPattern = regexp.MustCompile(`^[\d\s.,+\-*/^%()×÷−]+$`)
+
+// looksLikeExpression keeps ordinary queries away from the calculator: it
+// needs calculator characters only, a number and an operation. Dates and
+// phone numbers ("2024-01-15", "555-1234") aren't subtractions.
+func looksLikeExpression(s string) bool {
+	s = strings.ToLower(s)
+	bare := strings.NewReplacer("sqrt", "", "pi", "1").Replace(s)
+	if !calcCharsPattern.MatchString(bare) || !strings.ContainsAny(bare, "0123456789") {
+		return false
+	}
+	if strings.Contains(s, "sqrt") || strings.ContainsAny(s, "+*/^%×÷") {
+		return true
+	}
+	return strings.ContainsAny(s, "-−") && strings.Contains(s, " ")
+}
+
+// unit is a unit's size in its dimension's base unit. Temperatures are
+// converted separately since they have offsets.
+type unit struct {
+	dimension string
+	factor    float64
+}
+
+var units = map[string]unit{
+	"mm": {"length", 0.001}, "cm": {"length", 0.01}, "m": {"length", 1}, "km": {"length", 1000},
+	"in": {"length", 0.0254}, "ft": {"length", 0.3048}, "yd": {"length", 0.9144}, "mi": {"length", 1609.344},
+	"mg": {"mass", 0.001}, "g": {"mass", 1}, "kg": {"mass", 1000}, "t": {"mass", 1e6},
+	"oz": {"mass", 28.349523125}, "lb": {"mass", 453.59237}, "st": {"mass", 6350.29318},
+	"ml": {"volume", 0.001}, "l": {"volume", 1}, "floz": {"volume", 0.0295735295625},
+	"cup": {"volume", 0.2365882365}, "pt": {"volume", 0.473176473}, "gal": {"volume", 3.785411784},
+	"s": {"time", 1}, "min": {"time", 60}, "h": {"time", 3600}, "d": {"time", 86400}, "wk": {"time", 604800},
+	"b": {"data", 1}, "kb": {"data", 1e3}, "mb": {"data", 1e6}, "gb": {"data", 1e9}, "tb": {"data", 1e12},
+	"kib": {"data", 1024}, "mib": {"data", 1 << 20}, "gib": {"data", 1 << 30}, "tib": {"data", 1 << 40},
+	"c": {"temperature", 0}, "f": {"temperature", 0}, "k": {"temperature", 0},
+	"km/h": {"speed", 1 / 3.6}, "m/s": {"speed", 1}, "mph": {"speed", 0.44704}, "kn": {"speed", 0.514444},
+}
+
+// unitAliases maps spelled-out and plural forms to the keys of units.
+var unitAliases = map[string]string{
+	"meter": "m", "meters": "m", "metre": "m", "metres": "m", "kilometer": "km", "kilometers": "km",
+	"inch": "in", "inches": "in", "foot": "ft", "feet": "ft", "yard": "yd", "yards": "yd",
+	"mile": "mi", "miles": "mi", "gram": "g", "grams": "g", "kilogram": "kg", "kilograms": "kg",
+	"kilo": "kg", "kilos": "kg", "ounce": "oz", "ounces": "oz", "pound": "lb", "pounds": "lb", "lbs": "lb",
+	"liter": "l", "liters": "l", "litre": "l", "litres": "l", "cups": "cup", "pint": "pt", "pints": "pt",
+	"gallon": "gal", "gallons": "gal", "sec": "s", "second": "s", "seconds": "s", "minute": "min",
+	"minutes": "min", "hour": "h", "hours": "h", "hr": "h", "day": "d", "days": "d", "week": "wk", "weeks": "wk",
+	"°c": "c", "celsius": "c", "°f": "f", "fahrenheit": "f", "kelvin": "k", "kph": "km/h", "knots": "kn",
+}
+
+var conversionPattern = regexp.MustCompile(`^(-?[\d.,]+)\s*([a-z°/]+)\s+(?:to|in)\s+([a-z°/]+)$`)
+
+func lookupUnit(name string) (string, unit, bool) {
+	if alias, ok := unitAliases[name]; ok {
+		name = alias
+	}
+	u, ok := units[name]
+	return name, u, ok
+}
+
+// convertUnits evaluates "10 km to mi"; ok is false if s isn't a
+// conversion between two known units of the same dimension.
+func convertUnits(s string) (value float64, target string, ok bool) {
+	m := conversionPattern.FindStringSubmatch(strings.ToLower(strings.TrimSpace(s)))
+	if m == nil {
+		return 0, "", false
+	}
+	v, err := strconv.ParseFloat(strings.ReplaceAll(m[1], ",", ""), 64)
+	if err != nil {
+		return 0, "", false
+	}
+	fromName, from, ok1 := lookupUnit(m[2])
+	toName, to, ok2 := lookupUnit(m[3])
+	if !ok1 || !ok2 || from.dimension != to.dimension {
+		return 0, "", false
+	}
+
+	if from.dimension == "temperature" {
+		// Through Kelvin
+		switch fromName {
+		case "c":
+			v += 273.15
+		case "f":
+			v = (v-32)*5/9 + 273.15
+		}
+		switch toName {
+		case "c":
+			v -= 273.15
+		case "f":
+			v = (v-273.15)*9/5 + 32
+		}
+		return v, strings.ToUpper(toName), true
+	}
+	return v * from.factor / to.factor, toName, true
+}
+
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'g', 12, 64)
+}
+
+// calculate returns the answer line and the bare result for a query that
+// is arithmetic or a unit conversion.
+func calculate(query string) (line, result string, ok bool) {
+	if v, target, ok := convertUnits(query); ok {
+		result = formatNumber(v)
+		return fmt.Sprintf("%s = %s %s", query, result, target), result, true
+	}
+	if !looksLikeExpression(query) {
+		return "", "", false
+	}
+	v, err := evalExpression(query)
+	if err != nil {
+		return "", "", false
+	}
+	result = formatNumber(v)
+	return fmt.Sprintf("%s = %s", query, result), result, true
+}
+
+// searchInstead lets a query that only looked like a calculation go on to
+// the engine menu.
+const searchInstead = "→ Search instead"
+
+// showCalculation offers the answer in the launcher; picking it copies the
+// bare result. search reports whether the user wants to search after all.
+func showCalculation(line, result string) (search bool, err error) {
+	selected, err := runLauncher("Calculator:", []string{line, searchInstead}, config.Interface.Lines)
+	if err != nil {
+		return false, err
+	}
+	switch selected {
+	case "":
+		return false, nil
+	case searchInstead:
+		return true, nil
+	}
+	if dryRun {
+		fmt.Printf("Copy to clipboard: %s\n", result)
+		return false, nil
+	}
+	announce("Copied " + result)
+	return false, copyToClipboard(result)
+}
@@ -0,0 +1,371 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"rabbithole/internal/browser"
+)
+
+// The cdp backend drives a Chromium-based browser over the Chrome DevTools
+// Protocol: rabbithole starts it with --remote-debugging-port, creates each
+// research window with Target.createTarget, which returns its target ID
+// right away, and sizes it with Browser.setWindowBounds. No window manager
+// tools are involved, so it also works on Wayland. Research windows get IDs
+// like "cdp:<target id>", which titles, geometry and closing understand.
+
+const (
+	cdpWindowPrefix      = "cdp:"
+	defaultDebuggingPort = 9222
+)
+
+var cdpHTTPClient = &http.Client{Timeout: 2 * time.Second}
+
+func cdpBackend() placementBackend {
+	return placementBackend{place: placeWithCDP, describe: describeCDP, placeNew: placeNewWithCDP}
+}
+
+func isCDPWindow(windowID string) bool {
+	return strings.HasPrefix(windowID, cdpWindowPrefix)
+}
+
+func cdpPort() int {
+	if config.Placement.DebuggingPort > 0 {
+		return config.Placement.DebuggingPort
+	}
+	return defaultDebuggingPort
+}
+
+// cdpUserDataDir is the browser profile rabbithole starts the browser
+// with. Chrome refuses remote debugging on the default one.
+func cdpUserDataDir() (string, error) {
+	usr, err := user.Current()
+	if err != nil {
+		return "", fmt.Errorf("couldn't determine user home directory for the browser profile: %w", err)
+	}
+	return filepath.Join(usr.HomeDir, ".local", "share", "rabbithole", profileSubdir(), "cdp-browser"), nil
+}
+
+// cdpClient is a connection to the browser-wide DevTools endpoint.
+type cdpClient struct {
+	ws     *wsConn
+	nextID int
+}
+
+// openCDP connects to the browser listening on the debugging port.
+func openCDP() (*cdpClient, error) {
+	resp, err := cdpHTTPClient.Get(fmt.Sprintf("http://127.0.0.1:%d/json/version", cdpPort()))
+	if err != nil {
+		return nil, fmt.Errorf("no browser listens on debugging port %d: %w", cdpPort(), err)
+	}
+	defer resp.Body.Close()
+	var version struct {
+		WebSocketDebuggerURL string `json:"webSocketDebuggerUrl"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&version); err != nil || version.WebSocketDebuggerURL == "" {
+		return nil, fmt.Errorf("unexpected answer from debugging port %d", cdpPort())
+	}
+	ws, err := dialWebSocket(version.WebSocketDebuggerURL, 2*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the browser's DevTools endpoint: %w", err)
+	}
+	return &cdpClient{ws: ws}, nil
+}
+
+func (c *cdpClient) Close() error {
+	return c.ws.Close()
+}
+
+// call sends a command to the browser and waits for its reply, skipping
+// the events that arrive in between.
+func (c *cdpClient) call(method string, params any, result any) error {
+	return c.callSession("", method, params, result)
+}
+
+// callSession sends a command to a page through the session attached to
+// it, or to the browser without one.
+func (c *cdpClient) callSession(sessionID, method string, params any, result any) error {
+	c.nextID++
+	id := c.nextID
+	command := map[string]any{"id": id, "method": method, "params": params}
+	if sessionID != "" {
+		command["sessionId"] = sessionID
+	}
+	message, err := json.Marshal(command)
+	if err != nil {
+		return err
+	}
+	c.ws.SetDeadline(time.Now().Add(5 * time.Second))
+	defer c.ws.SetDeadline(time.Time{})
+	if err := c.ws.WriteText(message); err != nil {
+		return fmt.Errorf("%s failed: %w", method, err)
+	}
+	for {
+		data, err := c.ws.ReadText()
+		if err != nil {
+			return fmt.Errorf("%s failed: %w", method, err)
+		}
+		var reply struct {
+			ID     int             `json:"id"`
+			Result json.RawMessage `json:"result"`
+			Error  *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(data, &reply); err != nil || reply.ID != id {
+			continue
+		}
+		if reply.Error != nil {
+			return fmt.Errorf("%s failed: %s", method, reply.Error.Message)
+		}
+		if result == nil {
+			return nil
+		}
+		return json.Unmarshal(reply.Result, result)
+	}
+}
+
+// cdpCall runs a single command on a fresh connection.
+func cdpCall(method string, params any, result any) error {
+	c, err := openCDP()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	return c.call(method, params, result)
+}
+
+type cdpTarget struct {
+	TargetID string `json:"targetId"`
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	URL      string `json:"url"`
+}
+
+type cdpBounds struct {
+	Left        int    `json:"left"`
+	Top         int    `json:"top"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	WindowState string `json:"windowState"`
+}
+
+func cdpTargetID(windowID string) string {
+	return strings.TrimPrefix(windowID, cdpWindowPrefix)
+}
+
+// placeNewWithCDP opens the URL in a new window of the browser and sizes
+// it. When no browser listens on the debugging port yet, it is started with
+// one, and the window it opens with the URL is the research window.
+func placeNewWithCDP(l browserLaunch, g windowGeometry, _ func() (int, error)) (string, error) {
+	if !l.Chromium() {
+		return "", fmt.Errorf("the cdp placement backend needs a Chromium-based browser, not %s", l.Browser)
+	}
+	if config.Placement.Mode != "" && config.Placement.Mode != "float" {
+		return "", fmt.Errorf("unsupported placement mode %q for cdp (use float)", config.Placement.Mode)
+	}
+
+	var targetID string
+	c, err := openCDP()
+	if err == nil {
+		var created struct {
+			TargetID string `json:"targetId"`
+		}
+		err = c.call("Target.createTarget", map[string]any{"url": l.OpenURL(), "newWindow": true}, &created)
+		targetID = created.TargetID
+	} else {
+		slog.Debug("Starting browser with remote debugging", "port", cdpPort(), "reason", err)
+		c, targetID, err = startCDPBrowser(l)
+	}
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+
+	windowID := cdpWindowPrefix + targetID
+	if err := setCDPWindowBounds(c, targetID, g); err != nil {
+		slog.Warn("Failed to place research window", "window", windowID, "err", err)
+	}
+	if l.Zoom > 0 {
+		if err := setCDPZoom(c, targetID, l.Zoom); err != nil {
+			slog.Warn("Failed to zoom research window", "window", windowID, "err", err)
+		}
+	}
+	return windowID, nil
+}
+
+// setCDPZoom zooms the target's pages with CSS zoom, which the protocol
+// offers no browser zoom in place of: for the page that is loading now and,
+// through a script run in every new document, for the pages after it.
+func setCDPZoom(c *cdpClient, targetID string, zoom float64) error {
+	var attached struct {
+		SessionID string `json:"sessionId"`
+	}
+	if err := c.call("Target.attachToTarget", map[string]any{"targetId": targetID, "flatten": true}, &attached); err != nil {
+		return err
+	}
+	script := fmt.Sprintf(`(() => {
+	const zoom = () => { if (document.documentElement) document.documentElement.style.zoom = "%g"; };
+	zoom();
+	document.addEventListener("DOMContentLoaded", zoom);
+})();`, zoom)
+	if err := c.callSession(attached.SessionID, "Page.addScriptToEvaluateOnNewDocument", map[string]any{"source": script}, nil); err != nil {
+		return err
+	}
+	return c.callSession(attached.SessionID, "Runtime.evaluate", map[string]any{"expression": script}, nil)
+}
+
+// startCDPBrowser starts the browser with the URL and waits for the
+// debugging port to show its page.
+func startCDPBrowser(l browserLaunch) (*cdpClient, string, error) {
+	dir, err := cdpUserDataDir()
+	if err != nil {
+		return nil, "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, "", fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	args := []string{fmt.Sprintf("--remote-debugging-port=%d", cdpPort()), "--user-data-dir=" + dir,
+		"--no-first-run", "--no-default-browser-check"}
+	if l.Profile != "" {
+		args = append(args, "--profile-directory="+l.Profile)
+	}
+	args = append(args, l.OpenURL())
+
+	command := browser.StartCommand(l.Browser, args)
+	if err := startCommand(exec.Command(command[0], command[1:]...)); err != nil {
+		return nil, "", fmt.Errorf("failed to start %s (is it installed?): %w", l.Browser, err)
+	}
+
+	deadline := time.Now().Add(windowDetectTimeout())
+	for time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+		c, err := openCDP()
+		if err != nil {
+			continue
+		}
+		var targets struct {
+			TargetInfos []cdpTarget `json:"targetInfos"`
+		}
+		if err := c.call("Target.getTargets", struct{}{}, &targets); err == nil {
+			for _, target := range targets.TargetInfos {
+				if target.Type == "page" {
+					return c, target.TargetID, nil
+				}
+			}
+		}
+		c.Close()
+	}
+	return nil, "", fmt.Errorf("%s didn't open debugging port %d in time", l.Browser, cdpPort())
+}
+
+// setCDPWindowBounds gives the target's window the geometry. A maximized
+// window has to be made normal before it takes bounds.
+func setCDPWindowBounds(c *cdpClient, targetID string, g windowGeometry) error {
+	var window struct {
+		WindowID int       `json:"windowId"`
+		Bounds   cdpBounds `json:"bounds"`
+	}
+	if err := c.call("Browser.getWindowForTarget", map[string]any{"targetId": targetID}, &window); err != nil {
+		return err
+	}
+	if window.Bounds.WindowState != "" && window.Bounds.WindowState != "normal" {
+		if err := c.call("Browser.setWindowBounds", map[string]any{"windowId": window.WindowID,
+			"bounds": map[string]any{"windowState": "normal"}}, nil); err != nil {
+			return err
+		}
+	}
+	return c.call("Browser.setWindowBounds", map[string]any{"windowId": window.WindowID,
+		"bounds": map[string]any{"left": g.X, "top": g.Y, "width": g.Width, "height": g.Height}}, nil)
+}
+
+func placeWithCDP(windowID string, g windowGeometry) error {
+	if !isCDPWindow(windowID) {
+		return fmt.Errorf("%s is not a window opened over CDP", windowID)
+	}
+	c, err := openCDP()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	return setCDPWindowBounds(c, cdpTargetID(windowID), g)
+}
+
+func describeCDP(windowID string, g windowGeometry) []string {
+	lines := []string{
+		fmt.Sprintf("# over the DevTools endpoint on port %d, starting the browser with it if needed", cdpPort()),
+		`Target.createTarget {"url": <url>, "newWindow": true}`,
+		fmt.Sprintf(`Browser.setWindowBounds {"windowId": <window of %s>, "bounds": {"left": %d, "top": %d, "width": %d, "height": %d}}`,
+			windowID, g.X, g.Y, g.Width, g.Height),
+	}
+	if zoom := launchFor(SearchEngine{}, "", "").Zoom; zoom > 0 {
+		lines = append(lines, fmt.Sprintf("Page.addScriptToEvaluateOnNewDocument <document.documentElement.style.zoom = %g>", zoom))
+	}
+	return lines
+}
+
+// cdpWindowTitle is the title of the target's page, which fails once the
+// target is closed.
+func cdpWindowTitle(windowID string) (string, error) {
+	var info struct {
+		TargetInfo cdpTarget `json:"targetInfo"`
+	}
+	if err := cdpCall("Target.getTargetInfo", map[string]any{"targetId": cdpTargetID(windowID)}, &info); err != nil {
+		return "", err
+	}
+	return info.TargetInfo.Title, nil
+}
+
+func cdpWindowURL(windowID string) (string, error) {
+	var info struct {
+		TargetInfo cdpTarget `json:"targetInfo"`
+	}
+	if err := cdpCall("Target.getTargetInfo", map[string]any{"targetId": cdpTargetID(windowID)}, &info); err != nil {
+		return "", err
+	}
+	return info.TargetInfo.URL, nil
+}
+
+func cdpWindowGeometry(windowID string) (windowGeometry, error) {
+	var window struct {
+		Bounds cdpBounds `json:"bounds"`
+	}
+	if err := cdpCall("Browser.getWindowForTarget", map[string]any{"targetId": cdpTargetID(windowID)}, &window); err != nil {
+		return windowGeometry{}, err
+	}
+	b := window.Bounds
+	return windowGeometry{X: b.Left, Y: b.Top, Width: b.Width, Height: b.Height}, nil
+}
+
+func closeCDPWindow(windowID string) error {
+	return cdpCall("Target.closeTarget", map[string]any{"targetId": cdpTargetID(windowID)}, nil)
+}
+
+func activateCDPWindow(windowID string) error {
+	return cdpCall("Target.activateTarget", map[string]any{"targetId": cdpTargetID(windowID)}, nil)
+}
+
+// cdpWindowIDs lists the browser's open pages as window IDs.
+func cdpWindowIDs() (map[string]bool, error) {
+	var targets struct {
+		TargetInfos []cdpTarget `json:"targetInfos"`
+	}
+	if err := cdpCall("Target.getTargets", struct{}{}, &targets); err != nil {
+		return nil, err
+	}
+	ids := make(map[string]bool)
+	for _, target := range targets.TargetInfos {
+		if target.Type == "page" {
+			ids[cdpWindowPrefix+target.TargetID] = true
+		}
+	}
+	return ids, nil
+}
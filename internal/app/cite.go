@@ -0,0 +1,353 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// citation is the metadata a reference needs, from a Zotero translation
+// server or the page's own meta tags.
+type citation struct {
+	Title     string
+	Authors   []string // "Last, First" or an organisation name
+	Date      string   // YYYY, YYYY-MM or YYYY-MM-DD as published
+	Container string   // journal or website
+	DOI       string
+	URL       string
+	Accessed  time.Time
+}
+
+var citeClient = &http.Client{Timeout: 20 * time.Second}
+
+// fetchCitation gathers metadata for rawURL, preferring the translation
+// server when one is configured.
+func fetchCitation(rawURL string) (citation, error) {
+	if server := config.Cite.TranslationServer; server != "" {
+		c, err := fetchZoteroCitation(server, rawURL)
+		if err == nil {
+			return c, nil
+		}
+		slog.Warn("Translation server failed, falling back to meta tags", "err", err)
+	}
+	return fetchMetaCitation(rawURL)
+}
+
+// fetchZoteroCitation asks a Zotero translation server
+// (github.com/zotero/translation-server) to translate the page.
+func fetchZoteroCitation(server, rawURL string) (citation, error) {
+	resp, err := citeClient.Post(strings.TrimRight(server, "/")+"/web", "text/plain", strings.NewReader(rawURL))
+	if err != nil {
+		return citation{}, fmt.Errorf("failed to reach translation server: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return citation{}, fmt.Errorf("translation server failed: %s", resp.Status)
+	}
+
+	var items []struct {
+		Title            string
+		Date             string
+		DOI              string
+		URL              string
+		PublicationTitle string
+		WebsiteTitle     string
+		Creators         []struct {
+			FirstName string
+			LastName  string
+			Name      string
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&items); err != nil {
+		return citation{}, fmt.Errorf("failed to parse translation: %w", err)
+	}
+	if len(items) == 0 {
+		return citation{}, fmt.Errorf("translation server found no item")
+	}
+
+	item := items[0]
+	c := citation{Title: item.Title, Date: normalizeDate(item.Date), DOI: item.DOI, URL: rawURL, Accessed: time.Now()}
+	c.Container = item.PublicationTitle
+	if c.Container == "" {
+		c.Container = item.WebsiteTitle
+	}
+	for _, creator := range item.Creators {
+		if creator.Name != "" {
+			c.Authors = append(c.Authors, creator.Name)
+		} else {
+			c.Authors = append(c.Authors, creator.LastName+", "+creator.FirstName)
+		}
+	}
+	return c, nil
+}
+
+var (
+	metaTagPattern   = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	metaAttrPattern  = regexp.MustCompile(`(?is)(name|property|content)\s*=\s*("[^"]*"|'[^']*')`)
+	titleTagPattern  = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	yearPattern      = regexp.MustCompile(`\b(1[5-9]|20)\d\d\b`)
+	datePrefixLength = len("2006-01-02")
+)
+
+// fetchMetaCitation reads Highwire (citation_*), Open Graph and plain meta
+// tags, which most journals, news sites and blogs provide.
+func fetchMetaCitation(rawURL string) (citation, error) {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return citation{}, err
+	}
+	req.Header.Set("User-Agent", appName+"/"+appVersion)
+	resp, err := citeClient.Do(req)
+	if err != nil {
+		return citation{}, fmt.Errorf("failed to fetch page: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return citation{}, fmt.Errorf("failed to fetch page: %s", resp.Status)
+	}
+	page, err := io.ReadAll(io.LimitReader(resp.Body, 2<<20))
+	if err != nil {
+		return citation{}, fmt.Errorf("failed to read page: %w", err)
+	}
+
+	meta := make(map[string][]string)
+	for _, tag := range metaTagPattern.FindAll(page, -1) {
+		var name, content string
+		for _, attr := range metaAttrPattern.FindAllSubmatch(tag, -1) {
+			value := html.UnescapeString(strings.TrimSpace(string(attr[2][1 : len(attr[2])-1])))
+			if strings.EqualFold(string(attr[1]), "content") {
+				content = value
+			} else {
+				name = strings.ToLower(value)
+			}
+		}
+		if name != "" && content != "" {
+			meta[name] = append(meta[name], content)
+		}
+	}
+	first := func(names ...string) string {
+		for _, name := range names {
+			if values := meta[name]; len(values) > 0 {
+				return values[0]
+			}
+		}
+		return ""
+	}
+
+	c := citation{URL: rawURL, Accessed: time.Now()}
+	c.Title = first("citation_title", "dc.title", "og:title")
+	if c.Title == "" {
+		if m := titleTagPattern.FindSubmatch(page); m != nil {
+			c.Title = strings.Join(strings.Fields(html.UnescapeString(string(m[1]))), " ")
+		}
+	}
+	c.Authors = meta["citation_author"]
+	if len(c.Authors) == 0 {
+		c.Authors = meta["dc.creator"]
+	}
+	if len(c.Authors) == 0 {
+		if author := first("author", "article:author"); author != "" && !strings.HasPrefix(author, "http") {
+			c.Authors = []string{author}
+		}
+	}
+	c.Date = normalizeDate(first("citation_publication_date", "citation_date", "dc.date", "article:published_time", "date"))
+	c.Container = first("citation_journal_title", "og:site_name")
+	if c.Container == "" {
+		c.Container = siteOf(rawURL)
+	}
+	c.DOI = first("citation_doi", "dc.identifier")
+	if !strings.HasPrefix(c.DOI, "10.") {
+		c.DOI = strings.TrimPrefix(c.DOI, "doi:")
+		if !strings.HasPrefix(c.DOI, "10.") {
+			c.DOI = ""
+		}
+	}
+	return c, nil
+}
+
+// normalizeDate turns "2021/03/04", "2021-03-04T10:00:00Z" and similar
+// into YYYY-MM-DD (or the leading year when that's all there is).
+func normalizeDate(date string) string {
+	date = strings.ReplaceAll(strings.TrimSpace(date), "/", "-")
+	if len(date) >= datePrefixLength {
+		if _, err := time.Parse("2006-01-02", date[:datePrefixLength]); err == nil {
+			return date[:datePrefixLength]
+		}
+	}
+	if year := yearPattern.FindString(date); year != "" {
+		return year
+	}
+	return ""
+}
+
+func (c citation) year() string {
+	if len(c.Date) >= 4 {
+		return c.Date[:4]
+	}
+	return ""
+}
+
+// splitAuthor returns last and first names; single names (often
+// organisations) have no first name.
+func splitAuthor(author string) (last, first string) {
+	if last, first, ok := strings.Cut(author, ","); ok {
+		return strings.TrimSpace(last), strings.TrimSpace(first)
+	}
+	fields := strings.Fields(author)
+	if len(fields) < 2 {
+		return author, ""
+	}
+	return fields[len(fields)-1], strings.Join(fields[:len(fields)-1], " ")
+}
+
+func initials(first string) string {
+	var out []string
+	for _, name := range strings.Fields(first) {
+		r := []rune(name)
+		out = append(out, string(r[0])+".")
+	}
+	return strings.Join(out, " ")
+}
+
+// formatCitation renders a citation as bibtex, apa or mla.
+func formatCitation(c citation, style string) (string, error) {
+	switch style {
+	case "bibtex":
+		return formatBibTeX(c), nil
+	case "apa":
+		return formatAPA(c), nil
+	case "mla":
+		return formatMLA(c), nil
+	}
+	return "", fmt.Errorf("unsupported citation style %q (use bibtex, apa or mla)", style)
+}
+
+func formatBibTeX(c citation) string {
+	// Key like smith2021attention: first author, year, first title word
+	key := "ref"
+	if len(c.Authors) > 0 {
+		last, _ := splitAuthor(c.Authors[0])
+		key = last
+	}
+	key += c.year()
+	for _, word := range strings.Fields(c.Title) {
+		if len(word) > 3 {
+			key += word
+			break
+		}
+	}
+	key = strings.ToLower(strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return r
+		}
+		return -1
+	}, key))
+
+	entryType, containerField := "misc", "howpublished"
+	if c.DOI != "" {
+		entryType, containerField = "article", "journal"
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "@%s{%s,\n", entryType, key)
+	field := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "  %s = {%s},\n", name, value)
+		}
+	}
+	field("title", c.Title)
+	field("author", strings.Join(c.Authors, " and "))
+	field("year", c.year())
+	field(containerField, c.Container)
+	field("doi", c.DOI)
+	field("url", c.URL)
+	field("urldate", c.Accessed.Format("2006-01-02"))
+	b.WriteString("}")
+	return b.String()
+}
+
+func formatAPA(c citation) string {
+	var names []string
+	for _, author := range c.Authors {
+		last, first := splitAuthor(author)
+		if first == "" {
+			names = append(names, last)
+		} else {
+			names = append(names, last+", "+initials(first))
+		}
+	}
+	var parts []string
+	switch len(names) {
+	case 0:
+	case 1:
+		parts = append(parts, names[0])
+	default:
+		parts = append(parts, strings.Join(names[:len(names)-1], ", ")+", & "+names[len(names)-1])
+	}
+
+	year := c.year()
+	if year == "" {
+		year = "n.d."
+	}
+	parts = append(parts, "("+year+").", strings.TrimSuffix(c.Title, ".")+".")
+	if c.Container != "" {
+		parts = append(parts, c.Container+".")
+	}
+	if c.DOI != "" {
+		parts = append(parts, "https://doi.org/"+c.DOI)
+	} else {
+		parts = append(parts, c.URL)
+	}
+	return strings.Join(parts, " ")
+}
+
+func formatMLA(c citation) string {
+	var parts []string
+	switch len(c.Authors) {
+	case 0:
+	case 1:
+		parts = append(parts, strings.TrimSuffix(c.Authors[0], ".")+".")
+	case 2:
+		last, first := splitAuthor(c.Authors[1])
+		parts = append(parts, c.Authors[0]+", and "+strings.TrimSpace(first+" "+last)+".")
+	default:
+		parts = append(parts, c.Authors[0]+", et al.")
+	}
+	parts = append(parts, "\""+strings.TrimSuffix(c.Title, ".")+".\"")
+
+	published := c.Container
+	if t, err := time.Parse("2006-01-02", c.Date); err == nil {
+		published += ", " + t.Format("2 Jan. 2006")
+	} else if c.year() != "" {
+		published += ", " + c.year()
+	}
+	published = strings.TrimPrefix(published, ", ")
+	if published != "" {
+		parts = append(parts, published+",")
+	}
+	parts = append(parts, c.URL+".", "Accessed "+c.Accessed.Format("2 Jan. 2006")+".")
+	return strings.Join(parts, " ")
+}
+
+// appendCitation adds a formatted citation to the bibliography file.
+func appendCitation(path, text string) error {
+	path = expandHome(path)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open bibliography: %w", err)
+	}
+	defer file.Close()
+	if _, err := fmt.Fprintf(file, "%s\n\n", text); err != nil {
+		return fmt.Errorf("failed to write bibliography: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	clipboardHistorySize   = 50
+	clipboardMaxLength     = 1000 // longer snippets are rarely queries
+	clipboardWatchInterval = time.Second
+	clipboardPreviewLength = 80
+)
+
+// recordClipboard adds a snippet to the rolling history, moving it to the
+// top if it was copied before, and drops the oldest beyond the limit. The
+// redaction rules apply, as the watcher records copies never searched.
+func recordClipboard(text, source string) error {
+	text = strings.TrimSpace(text)
+	if text == "" || len(text) > clipboardMaxLength {
+		return nil
+	}
+	text = redact(text)
+
+	// The watcher records every copy, so the insert and the trim share a
+	// transaction
+	return inTransaction(func(tx *sql.Tx) error {
+		// REPLACE gives a re-copied snippet a new id, which orders the history.
+		// Sealed text never repeats, so the picker drops older copies instead
+		_, err := tx.Exec("INSERT OR REPLACE INTO clipboard_history (text, source) VALUES (?, ?)", seal(text), source)
+		if err != nil {
+			return fmt.Errorf("failed to record clipboard: %w", err)
+		}
+
+		_, err = tx.Exec(`
+			DELETE FROM clipboard_history WHERE id NOT IN (
+				SELECT id FROM clipboard_history ORDER BY id DESC LIMIT ?
+			)`, clipboardHistorySize)
+		if err != nil {
+			return fmt.Errorf("failed to prune clipboard history: %w", err)
+		}
+		return nil
+	})
+}
+
+// watchClipboard polls PRIMARY and CLIPBOARD and records every new snippet,
+// so the history also covers text that was never searched.
+func watchClipboard() error {
+	last := map[string]string{}
+	for {
+		for _, selection := range []string{"primary", "clipboard"} {
+			text, err := readXSelection(selection)
+			if err != nil {
+				continue
+			}
+			text = strings.TrimSpace(text)
+			if text == "" || text == last[selection] {
+				continue
+			}
+			last[selection] = text
+			if text, err = guardSelection(text, selection); err != nil {
+				slog.Debug("Not recording clipboard", "err", err)
+				continue
+			}
+			if err := recordClipboard(text, selection); err != nil {
+				slog.Error("Failed to record clipboard", "err", err)
+			}
+		}
+		time.Sleep(clipboardWatchInterval)
+	}
+}
+
+// pickFromClipboardHistory lets the user choose an earlier snippet in the
+// launcher, newest first.
+func pickFromClipboardHistory() (string, error) {
+	snippets, err := queryStrings("SELECT text FROM clipboard_history ORDER BY id DESC")
+	if err != nil {
+		return "", err
+	}
+	if len(snippets) == 0 {
+		return "", fmt.Errorf("clipboard history is empty")
+	}
+
+	var options []string
+	byOption := make(map[string]string)
+	seen := make(map[string]bool)
+	for _, snippet := range snippets {
+		snippet = unseal(snippet)
+		if seen[snippet] {
+			continue
+		}
+		seen[snippet] = true
+		option := fmt.Sprintf("%d: %s", len(options)+1, snippetPreview(snippet))
+		options = append(options, option)
+		byOption[option] = snippet
+	}
+
+	selected, err := runLauncher("Clipboard:", options, 15)
+	if err != nil {
+		return "", err
+	}
+	snippet, ok := byOption[selected]
+	if !ok {
+		return "", fmt.Errorf("invalid selection: %s", selected)
+	}
+	return snippet, nil
+}
+
+// snippetPreview shows text on one launcher line, shortened if needed.
+func snippetPreview(text string) string {
+	preview := []rune(strings.Join(strings.Fields(text), " "))
+	if len(preview) > clipboardPreviewLength {
+		return string(preview[:clipboardPreviewLength]) + "…"
+	}
+	return string(preview)
+}
+
+// copyToClipboard puts text on the CLIPBOARD selection.
+func copyToClipboard(text string) error {
+	cmd := exec.Command("xsel", "-ib")
+	switch sessionType() {
+	case "wayland":
+		cmd = exec.Command("wl-copy")
+	case "macos":
+		cmd = exec.Command("pbcopy")
+	case "windows":
+		cmd = windowsCopyCommand()
+	}
+	cmd.Stdin = strings.NewReader(text)
+	if err := runCommand(cmd); err != nil {
+		return fmt.Errorf("failed to copy to clipboard: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// configTarget is the setting a dot path like "behavior.max_windows" or
+// "search_engines.0.url" points at. Map entries can't be set in place, so
+// set goes through the map.
+type configTarget struct {
+	value reflect.Value
+	set   func(reflect.Value)
+}
+
+// lookupConfigPath walks the config by JSON names. Slices are indexed by
+// number, maps by key.
+func lookupConfigPath(cfg *Config, path string) (configTarget, error) {
+	v := reflect.ValueOf(cfg).Elem()
+	target := configTarget{value: v, set: v.Set}
+	if path == "" {
+		return target, nil
+	}
+	walked, inMap := "", false
+	for _, part := range strings.Split(path, ".") {
+		parent := walked
+		if walked != "" {
+			walked += "."
+		}
+		walked += part
+
+		v := target.value
+		switch kind := v.Kind(); {
+		case inMap || kind != reflect.Struct && kind != reflect.Slice && kind != reflect.Map:
+			return configTarget{}, fmt.Errorf("%s has no settings below it", parent)
+		case kind == reflect.Struct:
+			field, ok := fieldByJSONName(v, part)
+			if !ok {
+				return configTarget{}, fmt.Errorf("unknown setting %s (choose from %s)", walked, strings.Join(jsonFieldNames(v.Type()), ", "))
+			}
+			target = configTarget{value: field, set: field.Set}
+		case kind == reflect.Slice:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= v.Len() {
+				return configTarget{}, fmt.Errorf("%s has no entry %s (it has %d)", parent, part, v.Len())
+			}
+			elem := v.Index(i)
+			target = configTarget{value: elem, set: elem.Set}
+		default:
+			inMap = true
+			key := reflect.ValueOf(part)
+			elem := v.MapIndex(key)
+			if !elem.IsValid() {
+				elem = reflect.Zero(v.Type().Elem())
+			}
+			target = configTarget{value: elem, set: func(value reflect.Value) {
+				if v.IsNil() {
+					v.Set(reflect.MakeMap(v.Type()))
+				}
+				v.SetMapIndex(key, value)
+			}}
+		}
+	}
+	return target, nil
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	return name
+}
+
+func fieldByJSONName(v reflect.Value, name string) (reflect.Value, bool) {
+	for i := 0; i < v.NumField(); i++ {
+		if jsonName(v.Type().Field(i)) == name {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func jsonFieldNames(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		if name := jsonName(t.Field(i)); name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseConfigValue converts the command line value to the setting's type.
+// Strings are taken as they are; anything else is JSON, so numbers and
+// booleans are written plainly and lists as ["a","b"].
+func parseConfigValue(t reflect.Type, raw string) (reflect.Value, error) {
+	value := reflect.New(t)
+	if t.Kind() == reflect.String {
+		value.Elem().SetString(raw)
+		return value.Elem(), nil
+	}
+	if err := json.Unmarshal([]byte(raw), value.Interface()); err != nil {
+		return reflect.Value{}, fmt.Errorf("%q is not a valid %s", raw, describeType(t))
+	}
+	return value.Elem(), nil
+}
+
+func describeType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int:
+		return "number"
+	case reflect.Bool:
+		return "boolean (true or false)"
+	case reflect.Slice:
+		return "JSON list"
+	default:
+		return "JSON object"
+	}
+}
+
+// getConfigValue prints a setting of the config in use, i.e. with defaults,
+// project config and environment overrides applied.
+func getConfigValue(path string) error {
+	target, err := lookupConfigPath(&config, path)
+	if err != nil {
+		return err
+	}
+	if s, ok := target.value.Interface().(string); ok && !jsonOutput {
+		fmt.Println(s)
+		return nil
+	}
+	return printJSON(target.value.Interface())
+}
+
+// setConfigValue changes one setting in the config file itself, so values
+// from defaults, a project config or the environment stay out of it.
+func setConfigValue(path, raw string) error {
+	if path == "" {
+		return fmt.Errorf("no setting given")
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("can't read config file at %s: %w", configPath, err)
+	}
+	var file Config
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", configPath, err)
+	}
+
+	target, err := lookupConfigPath(&file, path)
+	if err != nil {
+		return err
+	}
+	value, err := parseConfigValue(target.value.Type(), raw)
+	if err != nil {
+		return fmt.Errorf("can't set %s: %w", path, err)
+	}
+	target.set(value)
+
+	data, err = json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+	if err := os.WriteFile(configPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write config file %s: %w", configPath, err)
+	}
+	fmt.Printf("✅ Set %s in %s\n", path, configPath)
+	if projectConfigPath != "" || len(envOverridden) > 0 {
+		fmt.Println("ℹ️  A project config or RABBITHOLE_* variable may still override it, see 'rabbithole config get'")
+	}
+	return nil
+}
+
+// editConfig opens the config file in $VISUAL or $EDITOR and checks that it
+// still loads afterwards.
+func editConfig() error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	// The editor may come with arguments, e.g. "code --wait"
+	args := append(strings.Fields(editor), configPath)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := runCommand(cmd); err != nil {
+		return fmt.Errorf("editor %s failed: %w", args[0], err)
+	}
+
+	if err := loadConfig(); err != nil {
+		return fmt.Errorf("the config no longer loads, run 'rabbithole config edit' again to fix it: %w", err)
+	}
+	if problems := validateConfig(); len(problems) > 0 {
+		fmt.Printf("⚠️  %s\n", strings.Join(problems, "\n⚠️  "))
+		return nil
+	}
+	fmt.Printf("✅ %s looks good\n", configPath)
+	return nil
+}
@@ -0,0 +1,76 @@
+package app
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Editors often save in several steps (write a temp file, rename it over
+// the config), so changes are only acted on once things settle.
+const configReloadDelay = 200 * time.Millisecond
+
+// loadedConfig is the config along with what loadConfig derives from it,
+// so a rejected reload can put all of it back.
+type loadedConfig struct {
+	config        Config
+	path          string
+	redactions    []compiledRedaction
+	envOverridden []string
+	projectPath   string
+	projectName   string
+	projectTags   []string
+}
+
+func currentConfig() loadedConfig {
+	return loadedConfig{
+		config:        config,
+		path:          configPath,
+		redactions:    redactions,
+		envOverridden: envOverridden,
+		projectPath:   projectConfigPath,
+		projectName:   projectName,
+		projectTags:   projectExtraTags,
+	}
+}
+
+func (c loadedConfig) restore() {
+	config, configPath, redactions, envOverridden = c.config, c.path, c.redactions, c.envOverridden
+	projectConfigPath, projectName, projectExtraTags = c.projectPath, c.projectName, c.projectTags
+}
+
+// reloadConfig swaps in the changed config between requests. A config that
+// doesn't load or validate is logged and the running one kept, so a typo
+// mid-edit doesn't take the daemon down.
+func (api *daemonAPI) reloadConfig() {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	running := currentConfig()
+	previous := running.config
+	if err := loadConfig(); err != nil {
+		running.restore()
+		slog.Error("Config reload failed, keeping the running config", "err", err)
+		notifyUser("Config not reloaded", err.Error())
+		return
+	}
+	if problems := validateConfig(); len(problems) > 0 {
+		running.restore()
+		slog.Error("Config reload rejected, keeping the running config", "problems", problems)
+		notifyUser("Config not reloaded", strings.Join(problems, "\n"))
+		return
+	}
+
+	// These are bound when the daemon starts
+	if config.Database.Path != previous.Database.Path {
+		slog.Warn("database.path changed, restart the daemon to use it", "path", config.Database.Path)
+		config.Database.Path = previous.Database.Path
+	}
+	if config.Daemon.Listen != previous.Daemon.Listen {
+		slog.Warn("daemon.listen changed, restart the daemon to use it", "listen", config.Daemon.Listen)
+	}
+	if config.Hotkeys.ScopedClose != previous.Hotkeys.ScopedClose || config.Hotkeys.Close != previous.Hotkeys.Close {
+		slog.Warn("Close hotkey changed, restart the daemon to use it")
+	}
+	slog.Info("Reloaded config", "path", configPath, "engines", len(config.SearchEngines))
+}
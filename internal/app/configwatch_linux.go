@@ -0,0 +1,53 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// watchFile calls onChange after the file is written or replaced. It
+// watches the directory rather than the file, since a rename-on-save
+// replaces the inode a file watch would be attached to. This is what
+// fsnotify does on Linux, without the dependency.
+func watchFile(path string, onChange func()) error {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return fmt.Errorf("failed to start inotify: %w", err)
+	}
+	defer syscall.Close(fd)
+
+	dir, name := filepath.Split(path)
+	if _, err := syscall.InotifyAddWatch(fd, dir, syscall.IN_CLOSE_WRITE|syscall.IN_MOVED_TO); err != nil {
+		return fmt.Errorf("failed to watch %s: %w", dir, err)
+	}
+
+	var pending *time.Timer
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := syscall.Read(fd, buf)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read inotify events: %w", err)
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameBytes := buf[offset+syscall.SizeofInotifyEvent : offset+syscall.SizeofInotifyEvent+int(event.Len)]
+			offset += syscall.SizeofInotifyEvent + int(event.Len)
+
+			if string(bytes.TrimRight(nameBytes, "\x00")) != name {
+				continue
+			}
+			if pending != nil {
+				pending.Stop()
+			}
+			pending = time.AfterFunc(configReloadDelay, onChange)
+		}
+	}
+}
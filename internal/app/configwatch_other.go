@@ -0,0 +1,36 @@
+//go:build !linux
+
+package app
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// configPollInterval is how often the config's modification time is
+// checked where there is no inotify.
+const configPollInterval = time.Second
+
+// watchFile calls onChange after the file is written or replaced, by
+// polling its modification time and size.
+func watchFile(path string, onChange func()) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to watch %s: %w", path, err)
+	}
+	modTime, size := info.ModTime(), info.Size()
+	for range time.Tick(configPollInterval) {
+		info, err := os.Stat(path)
+		if err != nil {
+			// Mid-save, or removed until the editor writes it back
+			continue
+		}
+		if info.ModTime().Equal(modTime) && info.Size() == size {
+			continue
+		}
+		modTime, size = info.ModTime(), info.Size()
+		time.AfterFunc(configReloadDelay, onChange)
+	}
+	return nil
+}
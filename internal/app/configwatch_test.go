@@ -0,0 +1,43 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestReloadConfigKeepsRedactions checks that a rejected config leaves the
+// running one's redaction rules in place, not the ones it compiled.
+func TestReloadConfigKeepsRedactions(t *testing.T) {
+	running := currentConfig()
+	t.Cleanup(running.restore)
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	path := configFilePath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	valid := `{"search_engines": [{"name": "Google", "key": "g", "url": "https://www.google.com/search?q=%s"}],
+		"redactions": [{"name": "token", "pattern": "tok_[a-z]+"}]}`
+	if err := os.WriteFile(path, []byte(valid), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Loads, but has no engines to validate
+	rejected := `{"redactions": [{"name": "token", "pattern": "tok_[a-z]+", "replacement": "[token]"}]}`
+	if err := os.WriteFile(path, []byte(rejected), 0644); err != nil {
+		t.Fatal(err)
+	}
+	(&daemonAPI{}).reloadConfig()
+
+	if got := redact("tok_abc"); got != defaultRedactionReplacement {
+		t.Errorf("redact after a rejected reload = %q, want %q", got, defaultRedactionReplacement)
+	}
+	if len(config.SearchEngines) != 1 {
+		t.Errorf("running config lost its engines: %+v", config.SearchEngines)
+	}
+}
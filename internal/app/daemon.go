@@ -0,0 +1,285 @@
+package app
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const defaultDaemonListen = "127.0.0.1:7373"
+
+// daemonAPI serves the HTTP API. Requests are handled one at a time since
+// searches share the global config and database handle.
+type daemonAPI struct {
+	mu    sync.Mutex
+	token string
+}
+
+type daemonSearchRequest struct {
+	Query  string   `json:"query"`
+	Engine string   `json:"engine"`
+	Tags   []string `json:"tags"`
+}
+
+// isLoopbackHost reports whether host, with or without a port, is
+// localhost or a loopback address.
+func isLoopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	ip := net.ParseIP(host)
+	return host == "localhost" || (ip != nil && ip.IsLoopback())
+}
+
+// checkListenAddress only accepts loopback addresses since the API opens
+// windows and reads history.
+func checkListenAddress(listen string) error {
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil {
+		return fmt.Errorf("invalid listen address %q: %w", listen, err)
+	}
+	if !isLoopbackHost(host) {
+		return fmt.Errorf("refusing to listen on %s, use a loopback address", listen)
+	}
+	return nil
+}
+
+// daemonTokenPath is the file with the token API clients have to send,
+// next to the profile's config.
+func daemonTokenPath() string {
+	return filepath.Join(filepath.Dir(configPath), "daemon-token")
+}
+
+// loadDaemonToken reads the API token, creating one on first use. Only
+// the user can read it, which keeps other local users out of the API.
+func loadDaemonToken() (string, error) {
+	path := daemonTokenPath()
+	if data, err := os.ReadFile(path); err == nil && len(strings.TrimSpace(string(data))) > 0 {
+		if err := os.Chmod(path, 0600); err != nil {
+			return "", fmt.Errorf("failed to protect %s: %w", path, err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate API token: %w", err)
+	}
+	token := hex.EncodeToString(secret)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		return "", fmt.Errorf("failed to write API token: %w", err)
+	}
+	slog.Info("Created API token", "path", path)
+	return token, nil
+}
+
+// runDaemon serves the HTTP API on listen, or on the socket systemd passed
+// in, until it fails.
+func runDaemon(listen string) error {
+	listener, activated, err := activationListener()
+	if err != nil {
+		return err
+	}
+	if activated {
+		// The socket unit may have been edited since install-service
+		if err := checkListenAddress(listener.Addr().String()); err != nil {
+			listener.Close()
+			return fmt.Errorf("activation socket: %w", err)
+		}
+	} else {
+		if err := checkListenAddress(listen); err != nil {
+			return err
+		}
+		listener, err = net.Listen("tcp", listen)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", listen, err)
+		}
+	}
+	token, err := loadDaemonToken()
+	if err != nil {
+		listener.Close()
+		return err
+	}
+	slog.Info("Daemon listening", "addr", listener.Addr().String())
+	fmt.Fprintf(os.Stderr, "🐇 API listening on http://%s\n", listener.Addr())
+
+	api := &daemonAPI{token: token}
+	searchLogQueue = make(chan *searchLog, searchLogQueueSize)
+	go runSearchLogWriter()
+	go func() {
+		if err := watchFile(configPath, api.reloadConfig); err != nil {
+			slog.Error("Config watching stopped", "err", err)
+		}
+	}()
+	go api.scheduleDigests()
+	go api.collectIdleWindowsPeriodically()
+	go func() {
+		if err := api.trackFocus(); err != nil {
+			slog.Warn("Focus time tracking stopped", "err", err)
+		}
+	}()
+	if config.Hotkeys.ScopedClose && config.Hotkeys.Close != "" {
+		go func() {
+			if err := api.watchScopedClose(config.Hotkeys.Close); err != nil {
+				slog.Error("Scoped close hotkey stopped", "err", err)
+			}
+		}()
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /search", api.search)
+	mux.HandleFunc("GET /history", api.history)
+	mux.HandleFunc("GET /windows", api.windows)
+	mux.HandleFunc("DELETE /windows/{id}", api.closeWindow)
+	return http.Serve(listener, api.guard(mux))
+}
+
+// guard lets requests through that carry the token and were addressed to
+// a loopback host. Checking the Host header keeps web pages from reaching
+// the API through DNS rebinding, the token keeps out other local users.
+func (api *daemonAPI) guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isLoopbackHost(r.Host) {
+			writeAPIError(w, http.StatusForbidden, fmt.Errorf("unexpected Host %q", r.Host))
+			return
+		}
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(api.token)) != 1 {
+			writeAPIError(w, http.StatusUnauthorized, fmt.Errorf("missing or wrong token (see %s)", daemonTokenPath()))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeAPIJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Warn("Failed to write API response", "err", err)
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	writeAPIJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// search runs a search without the launcher. Requiring a JSON body means
+// web pages can't trigger searches with a plain cross-site form post.
+func (api *daemonAPI) search(w http.ResponseWriter, r *http.Request) {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		writeAPIError(w, http.StatusUnsupportedMediaType, fmt.Errorf("expected an application/json body"))
+		return
+	}
+	var req daemonSearchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	if req.Query == "" {
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf("query is required"))
+		return
+	}
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	urls, err := searchByKey(req.Engine, req.Query, "api", req.Tags)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, map[string][]string{"urls": urls})
+}
+
+func (api *daemonAPI) history(w http.ResponseWriter, r *http.Request) {
+	limit := 20
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			writeAPIError(w, http.StatusBadRequest, fmt.Errorf("limit must be a positive number"))
+			return
+		}
+		limit = n
+	}
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	entries, err := loadHistory(limit)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if entries == nil {
+		entries = []historyEntry{}
+	}
+	writeAPIJSON(w, http.StatusOK, entries)
+}
+
+func (api *daemonAPI) windows(w http.ResponseWriter, r *http.Request) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	windows, err := openResearchWindows()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if windows == nil {
+		windows = []researchWindow{}
+	}
+	for i := range windows {
+		unsealAll(&windows[i].URL, &windows[i].Title)
+	}
+	writeAPIJSON(w, http.StatusOK, windows)
+}
+
+// closeWindow closes the research window with the id from GET /windows.
+func (api *daemonAPI) closeWindow(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf("invalid window id %q", r.PathValue("id")))
+		return
+	}
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	var windowID string
+	err = db.QueryRow("SELECT window_id FROM research_windows WHERE id = ?", id).Scan(&windowID)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeAPIError(w, http.StatusNotFound, fmt.Errorf("no research window %d", id))
+		return
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, fmt.Errorf("failed to find research window: %w", err))
+		return
+	}
+	open, err := openWindowIDs()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !open[windowID] {
+		writeAPIError(w, http.StatusNotFound, fmt.Errorf("research window %d is already closed", id))
+		return
+	}
+	if err := closeWindow(windowID); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, fmt.Errorf("failed to close window: %w", err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
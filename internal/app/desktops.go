@@ -0,0 +1,84 @@
+package app
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"rabbithole/internal/x11"
+)
+
+// desktopNamesList returns the EWMH desktop names, natively or via wmctrl -d.
+func desktopNamesList() ([]string, error) {
+	if x, err := nativeX11(); err == nil {
+		return x.DesktopNames()
+	}
+
+	out, err := commandOutput(exec.Command("wmctrl", "-d"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list desktops: %w", err)
+	}
+	// 0  * DG: 1920x1080  VP: 0,0  WA: 0,0 1920x1080  name
+	var names []string
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 10 {
+			names = append(names, "")
+			continue
+		}
+		names = append(names, strings.Join(fields[9:], " "))
+	}
+	return names, nil
+}
+
+// desktopIndex resolves a workspace setting to an EWMH desktop index. Names
+// are matched first; otherwise a number counts from 1, as pagers show it.
+func desktopIndex(workspace string) (int, error) {
+	names, err := desktopNamesList()
+	if err == nil {
+		for i, name := range names {
+			if name == workspace {
+				return i, nil
+			}
+		}
+	}
+	if n, convErr := strconv.Atoi(workspace); convErr == nil && n > 0 {
+		return n - 1, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("no desktop named %q", workspace)
+}
+
+// moveToDesktop sends a window to the named or numbered desktop and, with
+// follow, switches there too.
+func moveToDesktop(windowID, workspace string, follow bool) error {
+	index, err := desktopIndex(workspace)
+	if err != nil {
+		return err
+	}
+
+	if x, err := nativeX11(); err == nil {
+		window, err := x11.ParseWindowID(windowID)
+		if err != nil {
+			return err
+		}
+		if err := x.MoveToDesktop(window, index); err != nil {
+			return fmt.Errorf("failed to move window to desktop %q: %w", workspace, err)
+		}
+		if follow {
+			return x.SwitchDesktop(index)
+		}
+		return nil
+	}
+
+	if err := runCommand(exec.Command("wmctrl", "-i", "-r", windowID, "-t", strconv.Itoa(index))); err != nil {
+		return fmt.Errorf("failed to move window to desktop %q: %w", workspace, err)
+	}
+	if follow {
+		return runCommand(exec.Command("wmctrl", "-s", strconv.Itoa(index)))
+	}
+	return nil
+}
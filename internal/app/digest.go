@@ -0,0 +1,223 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const digestTopTags = 5
+
+type researchDigest struct {
+	From            time.Time    `json:"from"`
+	To              time.Time    `json:"to"`
+	Searches        int          `json:"searches"`
+	RabbitHoles     int          `json:"rabbit_holes"`
+	PagesRead       int          `json:"pages_read"`
+	TopTags         []countEntry `json:"top_tags"`
+	DeepestSession  string       `json:"deepest_session"`
+	DeepestPages    int          `json:"deepest_session_pages"`
+	BusiestDay      string       `json:"busiest_day"`
+	BusiestSearches int          `json:"busiest_day_searches"`
+}
+
+// buildDigest summarizes the searches in [from, to). A rabbit hole is a
+// research window that was opened; pages read are the trail entries
+// recorded while following them.
+func buildDigest(from, to time.Time) (researchDigest, error) {
+	d := researchDigest{From: from, To: to, TopTags: []countEntry{}}
+	start, end := sqliteTime(from), sqliteTime(to)
+
+	err := db.QueryRow("SELECT COUNT(*) FROM searches WHERE timestamp >= ? AND timestamp < ?", start, end).Scan(&d.Searches)
+	if err != nil {
+		return d, fmt.Errorf("failed to count searches: %w", err)
+	}
+	err = db.QueryRow(`
+		SELECT COUNT(DISTINCT w.id), COUNT(n.id) FROM research_windows w
+		JOIN searches s ON s.id = w.search_id
+		LEFT JOIN navigations n ON n.window_id = w.id
+		WHERE s.timestamp >= ? AND s.timestamp < ?`, start, end).Scan(&d.RabbitHoles, &d.PagesRead)
+	if err != nil {
+		return d, fmt.Errorf("failed to count research windows: %w", err)
+	}
+
+	tags, err := queryStrings("SELECT tags FROM searches WHERE tags != '' AND timestamp >= ? AND timestamp < ?", start, end)
+	if err != nil {
+		return d, err
+	}
+	byTag := make(map[string]int)
+	for _, list := range tags {
+		for _, tag := range splitTags(list) {
+			byTag[tag]++
+		}
+	}
+	for tag, count := range byTag {
+		d.TopTags = append(d.TopTags, countEntry{Name: tag, Count: count})
+	}
+	sort.Slice(d.TopTags, func(i, j int) bool {
+		if d.TopTags[i].Count != d.TopTags[j].Count {
+			return d.TopTags[i].Count > d.TopTags[j].Count
+		}
+		return d.TopTags[i].Name < d.TopTags[j].Name
+	})
+	if len(d.TopTags) > digestTopTags {
+		d.TopTags = d.TopTags[:digestTopTags]
+	}
+
+	// Without searches or trail pages these find no rows and stay empty
+	db.QueryRow(`
+		SELECT s.session_id, COUNT(*) AS pages FROM navigations n
+		JOIN research_windows w ON w.id = n.window_id
+		JOIN searches s ON s.id = w.search_id
+		WHERE s.timestamp >= ? AND s.timestamp < ?
+		GROUP BY s.session_id ORDER BY pages DESC, s.session_id LIMIT 1`, start, end).Scan(&d.DeepestSession, &d.DeepestPages)
+	db.QueryRow(`
+		SELECT date(timestamp, 'localtime') AS day, COUNT(*) AS n FROM searches
+		WHERE timestamp >= ? AND timestamp < ?
+		GROUP BY day ORDER BY n DESC, day LIMIT 1`, start, end).Scan(&d.BusiestDay, &d.BusiestSearches)
+	return d, nil
+}
+
+// lastWeek is the seven days up to now.
+func lastWeek() (time.Time, time.Time) {
+	now := time.Now()
+	return now.AddDate(0, 0, -7), now
+}
+
+// previousCalendarWeek is the last complete Monday to Sunday week, which
+// the daemon writes a digest for.
+func previousCalendarWeek(now time.Time) (time.Time, time.Time) {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	monday := midnight.AddDate(0, 0, -((int(midnight.Weekday()) + 6) % 7))
+	return monday.AddDate(0, 0, -7), monday
+}
+
+// parseDigestWeek selects an ISO week, e.g. "2025-W07", or "last" for
+// the last complete calendar week.
+func parseDigestWeek(value string, now time.Time) (time.Time, time.Time, error) {
+	if value == "last" {
+		from, to := previousCalendarWeek(now)
+		return from, to, nil
+	}
+	var year, week int
+	if _, err := fmt.Sscanf(value, "%d-W%d", &year, &week); err != nil || week < 1 || week > 53 {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid week %q, expected e.g. 2025-W07 or last", value)
+	}
+	// January 4th is always in week 1
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, now.Location())
+	monday := jan4.AddDate(0, 0, -((int(jan4.Weekday())+6)%7)+7*(week-1))
+	if y, w := monday.ISOWeek(); y != year || w != week {
+		return time.Time{}, time.Time{}, fmt.Errorf("%d has no week %d", year, week)
+	}
+	return monday, monday.AddDate(0, 0, 7), nil
+}
+
+func (d researchDigest) period() string {
+	return fmt.Sprintf("%s – %s", d.From.Local().Format("Mon 2006-01-02"), d.To.Local().Add(-time.Second).Format("Mon 2006-01-02"))
+}
+
+func dayLabel(day string) string {
+	t, err := time.ParseInLocation("2006-01-02", day, time.Local)
+	if err != nil {
+		return day
+	}
+	return t.Format("Mon 2006-01-02")
+}
+
+func writeDigestText(w io.Writer, d researchDigest) {
+	fmt.Fprintf(w, "📅 %s\n\n", d.period())
+	fmt.Fprintf(w, "Searches:        %d\n", d.Searches)
+	fmt.Fprintf(w, "Rabbit holes:    %d (%d pages read)\n", d.RabbitHoles, d.PagesRead)
+	if d.BusiestDay != "" {
+		fmt.Fprintf(w, "Busiest day:     %s (%d searches)\n", dayLabel(d.BusiestDay), d.BusiestSearches)
+	}
+	if d.DeepestSession != "" {
+		fmt.Fprintf(w, "Deepest session: %s (%d pages)\n", d.DeepestSession, d.DeepestPages)
+	}
+	if len(d.TopTags) > 0 {
+		fmt.Fprintln(w, "\nTop topics:")
+		for _, c := range d.TopTags {
+			fmt.Fprintf(w, "  %4d  %s\n", c.Count, c.Name)
+		}
+	}
+}
+
+func writeDigestMarkdown(w io.Writer, d researchDigest) {
+	fmt.Fprintf(w, "# Research digest %s\n\n", d.period())
+	fmt.Fprintf(w, "- **Searches:** %d\n", d.Searches)
+	fmt.Fprintf(w, "- **Rabbit holes:** %d (%d pages read)\n", d.RabbitHoles, d.PagesRead)
+	if d.BusiestDay != "" {
+		fmt.Fprintf(w, "- **Busiest day:** %s (%d searches)\n", dayLabel(d.BusiestDay), d.BusiestSearches)
+	}
+	if d.DeepestSession != "" {
+		fmt.Fprintf(w, "- **Deepest session:** %s (%d pages, see `rabbithole report --session %s`)\n",
+			d.DeepestSession, d.DeepestPages, d.DeepestSession)
+	}
+	if len(d.TopTags) > 0 {
+		fmt.Fprintln(w, "\n## Top topics")
+		fmt.Fprintln(w)
+		for _, c := range d.TopTags {
+			fmt.Fprintf(w, "- %s (%d)\n", escapeMarkdown(c.Name), c.Count)
+		}
+	}
+}
+
+func digestDir() string {
+	if config.Digest.Dir != "" {
+		return expandHome(config.Digest.Dir)
+	}
+	return filepath.Join(filepath.Dir(config.Database.Path), "digests")
+}
+
+// scheduleDigests checks hourly for a weekly digest to write, so one is
+// written soon after Monday starts or the daemon does.
+func (api *daemonAPI) scheduleDigests() {
+	for {
+		api.mu.Lock()
+		if config.Digest.Weekly {
+			writeWeeklyDigestIfDue()
+		}
+		api.mu.Unlock()
+		time.Sleep(time.Hour)
+	}
+}
+
+// writeWeeklyDigestIfDue writes last calendar week's digest into
+// digest.dir unless it's already there or the week had no searches.
+func writeWeeklyDigestIfDue() {
+	from, to := previousCalendarWeek(time.Now())
+	year, week := from.ISOWeek()
+	path := filepath.Join(digestDir(), fmt.Sprintf("digest-%d-W%02d.md", year, week))
+	if _, err := os.Stat(path); err == nil {
+		return
+	}
+
+	d, err := buildDigest(from, to)
+	if err != nil {
+		slog.Error("Failed to build weekly digest", "err", err)
+		return
+	}
+	if d.Searches == 0 {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		slog.Error("Failed to create digest directory", "err", err)
+		return
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		slog.Error("Failed to write weekly digest", "err", err)
+		return
+	}
+	writeDigestMarkdown(file, d)
+	if err := file.Close(); err != nil {
+		slog.Error("Failed to write weekly digest", "err", err)
+		return
+	}
+	slog.Info("Wrote weekly digest", "path", path, "searches", d.Searches)
+	notifyUser("Rabbithole weekly digest", fmt.Sprintf("%d searches, %d rabbit holes\n%s", d.Searches, d.RabbitHoles, path))
+}
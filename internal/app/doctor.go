@@ -0,0 +1,458 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"rabbithole/internal/browser"
+)
+
+type doctorCheck struct {
+	Name     string `json:"name"`
+	OK       bool   `json:"ok"`
+	Optional bool   `json:"optional"`
+	Detail   string `json:"detail"`
+	Hint     string `json:"hint,omitempty"`
+}
+
+// aptPackages maps binaries to the Debian/Ubuntu package providing them.
+var aptPackages = map[string]string{
+	"xsel":        "xsel",
+	"wl-paste":    "wl-clipboard",
+	"wmctrl":      "wmctrl",
+	"xdotool":     "xdotool",
+	"xdpyinfo":    "x11-utils",
+	"firefox":     "firefox",
+	"sxhkd":       "sxhkd",
+	"notify-send": "libnotify-bin",
+	"dmenu":       "suckless-tools",
+	"rofi":        "rofi",
+	"wofi":        "wofi",
+	"fuzzel":      "fuzzel",
+	"bemenu":      "bemenu",
+	"maim":        "maim",
+	"grim":        "grim",
+	"slurp":       "slurp",
+	"tesseract":   "tesseract-ocr",
+	"monolith":    "monolith",
+}
+
+func checkBinary(name, purpose string, optional bool) doctorCheck {
+	check := doctorCheck{Name: name, Optional: optional}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		check.Detail = "not found (" + purpose + ")"
+		if pkg, ok := aptPackages[name]; ok && !onMacOS() && !onWindows() {
+			check.Hint = "sudo apt install " + pkg
+		} else {
+			check.Hint = "install " + name + " and make sure it is in PATH"
+		}
+		return check
+	}
+	check.OK = true
+	check.Detail = path
+	return check
+}
+
+// checkBrowser checks for a browser command, or on macOS for the
+// application open starts for it.
+func checkBrowser(command, purpose string) doctorCheck {
+	if onWindows() {
+		// start also finds browsers registered under App Paths
+		check := checkBinary(command, purpose, true)
+		if !check.OK {
+			check.Detail = "not in PATH (" + purpose + "); fine if start can still open it"
+		}
+		return check
+	}
+	if !onMacOS() {
+		return checkBinary(command, purpose, false)
+	}
+	app := command
+	if !strings.Contains(command, "/") {
+		app = browser.MacAppName(command)
+	}
+	check := doctorCheck{Name: command, Detail: app}
+	if err := runCommand(exec.Command("open", "-Ra", app)); err != nil {
+		check.Detail = app + " not found (" + purpose + ")"
+		check.Hint = "install " + app + " or set behavior.browser"
+		return check
+	}
+	check.OK = true
+	return check
+}
+
+func sessionType() string {
+	switch {
+	case onMacOS():
+		return "macos"
+	case onWindows():
+		return "windows"
+	case os.Getenv("WAYLAND_DISPLAY") != "":
+		return "wayland"
+	case os.Getenv("DISPLAY") != "":
+		return "x11"
+	default:
+		return os.Getenv("XDG_SESSION_TYPE")
+	}
+}
+
+// runDoctor checks the environment, dependencies, config and database.
+// Config problems stop the config-dependent checks but not the rest.
+func runDoctor() []doctorCheck {
+	var checks []doctorCheck
+
+	session := sessionType()
+	sessionCheck := doctorCheck{Name: "display session", OK: session == "x11" || session == "wayland" || session == "macos" || session == "windows", Detail: session}
+	switch session {
+	case "x11", "macos", "windows":
+	case "wayland":
+		sessionCheck.Detail = "wayland (selections are read through XWayland)"
+	default:
+		sessionCheck.Detail = "no DISPLAY or WAYLAND_DISPLAY set"
+		sessionCheck.Hint = "run rabbithole from inside your graphical session"
+	}
+	checks = append(checks, sessionCheck)
+
+	switch session {
+	case "macos":
+		checks = append(checks, macOSChecks()...)
+	case "windows":
+		checks = append(checks, windowsChecks()...)
+	default:
+		checks = append(checks, desktopChecks(session)...)
+	}
+
+	configCheck := doctorCheck{Name: "config"}
+	if err := loadConfig(); err != nil {
+		configCheck.Detail = err.Error()
+		configCheck.Hint = "run 'make install-config' or fix the JSON syntax"
+		return append(checks, configCheck)
+	}
+	if problems := validateConfig(); len(problems) > 0 {
+		configCheck.Detail = strings.Join(problems, "; ")
+		configCheck.Hint = "fix the engines with edit-engine/remove-engine or edit " + configPath
+	} else {
+		configCheck.OK = true
+		configCheck.Detail = fmt.Sprintf("%s (%d engines)", configPath, len(config.SearchEngines))
+	}
+	checks = append(checks, configCheck)
+
+	seenBrowsers := map[string]bool{browser.Default: true}
+	if command := config.Behavior.Browser; command != "" && command != browser.Default {
+		seenBrowsers[command] = true
+		checks = append(checks, checkBrowser(command, "default browser"))
+	}
+	for _, engine := range config.SearchEngines {
+		if engine.Browser != "" && !seenBrowsers[engine.Browser] {
+			seenBrowsers[engine.Browser] = true
+			checks = append(checks, checkBrowser(engine.Browser, "browser for "+engine.Name))
+		}
+	}
+
+	if driver, err := currentLauncher(); err != nil {
+		checks = append(checks, doctorCheck{Name: "launcher", Detail: err.Error(), Hint: "set interface.launcher to a supported launcher"})
+	} else {
+		check := checkBinary(driver.Command, "configured launcher", false)
+		check.Name = "launcher " + driver.Command
+		checks = append(checks, check)
+	}
+
+	checks = append(checks, checkPlacement(), checkDatabase())
+	return checks
+}
+
+// desktopChecks checks the tools capture and window placement use under X11
+// and Wayland.
+func desktopChecks(session string) []doctorCheck {
+	var checks []doctorCheck
+	if session == "wayland" {
+		checks = append(checks, checkBinary("wl-paste", "Wayland selection capture", true))
+	}
+	// With a direct X connection the window tools are only a fallback
+	x11Check := doctorCheck{Name: "X11 connection", Optional: true}
+	if _, err := nativeX11(); err != nil {
+		x11Check.Detail = err.Error() + " (falling back to wmctrl/xdotool/xdpyinfo)"
+		x11Check.Hint = "make sure DISPLAY is set and the X server accepts connections"
+	} else {
+		x11Check.OK = true
+		x11Check.Detail = "native window queries"
+	}
+	windowToolsOptional := x11Check.OK
+	checks = append(checks, x11Check)
+
+	checks = append(checks,
+		checkBinary("xsel", "selection capture", false),
+		checkBinary("wmctrl", "window detection and placement", windowToolsOptional ||
+			config.Placement.Backend == "cdp" || config.Placement.Backend == "marionette"),
+		checkBinary("xdotool", "window titles and active window", windowToolsOptional),
+		checkBinary("xdpyinfo", "screen size", windowToolsOptional),
+		checkBinary("firefox", "research windows", false),
+		checkBinary("sxhkd", "hotkeys", true),
+		checkBinary("notify-send", "desktop notifications", true),
+		checkBinary("tesseract", "search --ocr", true),
+		checkBinary("monolith", "complete page archives", true),
+	)
+	if session == "wayland" {
+		checks = append(checks, checkBinary("grim", "screen regions", true), checkBinary("slurp", "screen regions", true))
+	} else {
+		checks = append(checks, checkBinary("maim", "screen regions", true))
+	}
+	return checks
+}
+
+// macOSChecks checks the tools capture and window placement use on macOS.
+func macOSChecks() []doctorCheck {
+	return []doctorCheck{
+		checkBinary("pbpaste", "selection capture", false),
+		checkBinary("osascript", "screen size and applescript placement", false),
+		checkBinary("yabai", "window detection and placement", true),
+		checkBrowser(browser.Default, "research windows"),
+		checkBinary("tesseract", "search --ocr", true),
+		checkBinary("monolith", "complete page archives", true),
+	}
+}
+
+// windowsChecks checks the tools capture and hotkeys use on Windows; the
+// clipboard and window placement need nothing beyond the Win32 API.
+func windowsChecks() []doctorCheck {
+	return []doctorCheck{
+		checkBinary("powershell", "copying to the clipboard", false),
+		checkBrowser(browser.Default, "research windows"),
+		checkBinary("AutoHotkey64", "hotkeys (rabbithole setup --wm ahk)", true),
+		checkBinary("monolith", "complete page archives", true),
+	}
+}
+
+// validateConfig reports problems that would make searches fail.
+func validateConfig() []string {
+	var problems []string
+	if len(config.SearchEngines) == 0 {
+		problems = append(problems, "no search engines configured")
+	}
+	seen := make(map[string]string)
+	for _, engine := range config.SearchEngines {
+		for _, key := range engine.Keys() {
+			if problem := engineKeyProblem(key); problem != "" {
+				problems = append(problems, fmt.Sprintf("engine %q has key %q, which %s", engine.Name, key, problem))
+			}
+			if other, dup := seen[key]; dup {
+				problems = append(problems, fmt.Sprintf("key %q is used by both %q and %q", key, other, engine.Name))
+			}
+			seen[key] = engine.Name
+		}
+		if !strings.Contains(engine.URL, "%s") {
+			problems = append(problems, fmt.Sprintf("engine %q URL has no %%s placeholder", engine.Name))
+		}
+		for name, param := range engine.Modifiers {
+			if !modifierNamePattern.MatchString(name) || param == "" {
+				problems = append(problems, fmt.Sprintf("engine %q has modifier %q -> %q, expected lowercase letters mapped to a URL parameter", engine.Name, name, param))
+			}
+		}
+		if _, ok := inlineProviders[engine.Inline]; engine.Inline != "" && !ok {
+			problems = append(problems, fmt.Sprintf("engine %q has unknown inline provider %q", engine.Name, engine.Inline))
+		}
+	}
+	if _, err := configuredHotkeys(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	for _, bundle := range config.Bundles {
+		if other, dup := seen[bundle.Key]; dup {
+			problems = append(problems, fmt.Sprintf("key %q is used by both %q and bundle %q", bundle.Key, other, bundle.Name))
+		}
+		seen[bundle.Key] = bundle.Name
+		if _, err := resolveBundle(bundle); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	problems = append(problems, validateTemplates()...)
+	if config.Behavior.WindowTimeoutMs < 0 {
+		problems = append(problems, fmt.Sprintf("behavior.window_timeout_ms is %d, expected a positive number", config.Behavior.WindowTimeoutMs))
+	}
+	if action := config.Behavior.IdleAction; action != idleActionClose && action != idleActionPark {
+		problems = append(problems, fmt.Sprintf("behavior.idle_action is %q, expected %q or %q", action, idleActionClose, idleActionPark))
+	}
+	switch config.Behavior.DuplicateSearch {
+	case duplicateAsk, duplicateFocus, duplicateOpen:
+	default:
+		problems = append(problems, fmt.Sprintf("behavior.duplicate_search is %q, expected %q, %q or %q",
+			config.Behavior.DuplicateSearch, duplicateAsk, duplicateFocus, duplicateOpen))
+	}
+	switch config.Database.Encryption {
+	case "", encryptionKeyring, encryptionPassphrase:
+	default:
+		problems = append(problems, fmt.Sprintf("database.encryption is %q, expected %q or %q",
+			config.Database.Encryption, encryptionKeyring, encryptionPassphrase))
+	}
+	return problems
+}
+
+// waylandPlacementBackends can place windows in a Wayland session; sway only
+// those of browsers running under XWayland.
+var waylandPlacementBackends = map[string]bool{
+	"gnome": true, "kwin": true, "hyprland": true, "sway": true, "cdp": true, "marionette": true,
+}
+
+func checkPlacement() doctorCheck {
+	backend := config.Placement.Backend
+	if backend == "" {
+		backend = defaultPlacementBackend()
+	}
+	check := doctorCheck{Name: "placement", Detail: backend}
+	if _, err := currentPlacement(); err != nil {
+		check.Detail = err.Error()
+		check.Hint = "set placement.backend in " + configPath
+		return check
+	}
+	if sessionType() == "wayland" && !waylandPlacementBackends[backend] {
+		check.Detail = backend + " can't see or move Wayland windows"
+		check.Hint = "set placement.backend to gnome, kwin, hyprland, sway, cdp or marionette"
+		return check
+	}
+	if backend == "i3" || backend == "sway" {
+		path, err := i3SocketPath()
+		if err != nil {
+			check.Detail = err.Error()
+			check.Hint = "start rabbithole from inside your " + backend + " session"
+			return check
+		}
+		check.Detail = backend + " via " + path
+	}
+	if backend == "hyprland" {
+		if _, err := hyprlandSocket2(); err != nil {
+			check.Detail = err.Error()
+			check.Hint = "start rabbithole from inside your Hyprland session"
+			return check
+		}
+	}
+	clients := map[string]string{"bspwm": "bspc", "herbstluftwm": "herbstclient", "hyprland": "hyprctl",
+		"gnome": "gdbus", "kwin": "gdbus", "yabai": "yabai", "applescript": "osascript"}
+	if client, ok := clients[backend]; ok {
+		if _, err := exec.LookPath(client); err != nil {
+			check.Detail = client + " not found"
+			missing := backend
+			if client == "gdbus" {
+				missing = "gdbus (libglib2.0-bin)"
+			}
+			fallback := "wmctrl"
+			if onMacOS() {
+				fallback = "applescript"
+			}
+			check.Hint = "install " + missing + " or set placement.backend to " + fallback
+			return check
+		}
+	}
+	if backend == "gnome" {
+		if _, err := gnomeWindows(); err != nil {
+			check.Detail = "the rabbithole GNOME Shell extension doesn't answer"
+			check.Hint = "run: rabbithole setup --placement gnome, then log out and back in"
+			return check
+		}
+	}
+	if backend == "kwin" {
+		if err := kwinReachable(); err != nil {
+			check.Detail = err.Error()
+			check.Hint = "start rabbithole from inside your KDE Plasma session"
+			return check
+		}
+	}
+	if backend == "cdp" {
+		if l := launchFor(SearchEngine{}, "", ""); !l.Chromium() {
+			check.Detail = "cdp needs a Chromium-based browser, not " + l.Browser
+			check.Hint = "set behavior.browser to chromium or google-chrome"
+			return check
+		}
+		check.Detail = fmt.Sprintf("cdp on port %d (the browser starts with the first search)", cdpPort())
+		if c, err := openCDP(); err == nil {
+			c.Close()
+			check.Detail = fmt.Sprintf("cdp on port %d (browser connected)", cdpPort())
+		}
+	}
+	if backend == "marionette" {
+		if l := launchFor(SearchEngine{}, "", ""); l.Chromium() {
+			check.Detail = "marionette needs Firefox, not " + l.Browser
+			check.Hint = "set behavior.browser to firefox or use the cdp backend"
+			return check
+		}
+		check.Detail = fmt.Sprintf("marionette on port %d (Firefox starts with the first search, once any Firefox without it is quit)", marionettePort())
+		if c, err := openMarionette(); err == nil {
+			c.Close()
+			check.Detail = fmt.Sprintf("marionette on port %d (Firefox connected)", marionettePort())
+		}
+	}
+	check.OK = true
+	return check
+}
+
+func checkDatabase() doctorCheck {
+	check := doctorCheck{Name: "database", Detail: config.Database.Path}
+	if err := initDatabase(); err != nil {
+		check.Detail = err.Error()
+		check.Hint = "check permissions on " + filepath.Dir(config.Database.Path)
+		return check
+	}
+
+	// A write inside a rolled-back transaction proves the file is writable
+	tx, err := db.Begin()
+	if err == nil {
+		_, err = tx.Exec("CREATE TABLE doctor_write_test (id INTEGER)")
+		tx.Rollback()
+	}
+	if err != nil {
+		check.Detail = fmt.Sprintf("%s is not writable: %v", config.Database.Path, err)
+		check.Hint = "check permissions on " + config.Database.Path
+		return check
+	}
+
+	result, err := integrityCheck(db)
+	if err != nil || result != "ok" {
+		check.Detail = fmt.Sprintf("integrity check failed: %v %s", err, result)
+		check.Hint = "run 'rabbithole health' and restore from a backup in " + backupDir()
+		return check
+	}
+
+	check.OK = true
+	return check
+}
+
+func printDoctor(checks []doctorCheck) error {
+	failed := 0
+	for _, check := range checks {
+		if !check.OK && !check.Optional {
+			failed++
+		}
+	}
+
+	if jsonOutput {
+		if err := printJSON(checks); err != nil {
+			return err
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d check(s) failed", failed)
+		}
+		return nil
+	}
+
+	for _, check := range checks {
+		icon := "✅"
+		if !check.OK {
+			icon = "❌"
+			if check.Optional {
+				icon = "⚠️ "
+			}
+		}
+		fmt.Printf("%s %-16s %s\n", icon, check.Name, check.Detail)
+		if !check.OK && check.Hint != "" {
+			fmt.Printf("   → %s\n", check.Hint)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d check(s) failed", failed)
+	}
+	fmt.Println("\nEverything looks good.")
+	return nil
+}
@@ -0,0 +1,58 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+)
+
+// dryRun is set by --dry-run: searches go through selection capture, the
+// menus and URL construction, then print what would run instead of
+// logging the search and spawning windows.
+var dryRun bool
+
+func printDryRun(engine SearchEngine, query string, launch browserLaunch) {
+	fmt.Printf("Engine: %s (%s)\n", engine.Name, engine.Key)
+	fmt.Printf("Query:  %s\n", query)
+	fmt.Printf("URL:    %s\n", launch.URL)
+	if launch.Container != "" && !launch.Chromium() {
+		fmt.Printf("Container: %s\n", launch.Container)
+	}
+	fmt.Println()
+	if config.Behavior.OpenMode == "tab" {
+		if windowID, ok := researchContainer(); ok {
+			fmt.Printf("# focus research window %s\n", windowID)
+			fmt.Println(shellJoin(launch.Command(true)))
+			return
+		}
+	}
+	fmt.Println(shellJoin(launch.Command(false)))
+	backend, err := currentPlacement()
+	if err != nil {
+		fmt.Printf("# %v\n", err)
+	} else {
+		geometry := launch.Geometry
+		if geometry == (windowGeometry{}) {
+			geometry = sideWindowGeometry()
+		}
+		// A real window ID is only known once the browser has opened one
+		for _, line := range backend.describe("<new-window-id>", geometry) {
+			fmt.Println(line)
+		}
+	}
+	if config.Behavior.WebhookURL != "" {
+		fmt.Printf("\nWebhook: POST %s\n", config.Behavior.WebhookURL)
+	}
+}
+
+// shellJoin quotes args so the printed command can be pasted into a shell.
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg != "" && !strings.ContainsAny(arg, " \t\n'\"\\$`&;|<>()*?[]#~!{}") {
+			quoted[i] = arg
+		} else {
+			quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,134 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// What happens when a search's window from earlier is still open, set by
+// behavior.duplicate_search.
+const (
+	duplicateAsk   = "ask"
+	duplicateFocus = "focus"
+	duplicateOpen  = "open"
+)
+
+// defaultDuplicateMinutes is how far back an identical search counts as a
+// duplicate.
+const defaultDuplicateMinutes = 60
+
+const (
+	focusExistingOption = "→ Focus open window"
+	openNewOption       = "→ Open new window"
+)
+
+// duplicateWindow finds the open research window of the same search (same
+// engine and query) made within behavior.duplicate_minutes, newest first.
+func duplicateWindow(engine SearchEngine, query string) (researchWindow, bool) {
+	conn := database()
+	switch {
+	case dryRun, conn == nil, config.Behavior.DuplicateSearch == duplicateOpen,
+		// A tab can't be brought to front, only the window holding all of them
+		config.Behavior.OpenMode == "tab",
+		// Comparing queries would ask for the passphrase on every search
+		config.Database.Encryption == encryptionPassphrase:
+		return researchWindow{}, false
+	}
+
+	loggedQuery, _ := redactSearch(query, engine.URL, buildSearchURL(engine.URL, query))
+	since := time.Now().Add(-time.Duration(config.Behavior.DuplicateMinutes) * time.Minute)
+	rows, err := conn.Query(`
+		SELECT w.id, w.search_id, w.window_id, w.url, w.title, s.query, w.parked_at IS NOT NULL
+		FROM research_windows w JOIN searches s ON s.id = w.search_id
+		WHERE s.engine_name = ? AND s.timestamp >= ? AND w.closed_at IS NULL
+		AND w.id IN (SELECT MAX(id) FROM research_windows GROUP BY window_id)
+		ORDER BY w.id DESC`, engine.Name, sqliteTime(since))
+	if err != nil {
+		slog.Warn("Failed to look for a duplicate search", "err", err)
+		return researchWindow{}, false
+	}
+	defer rows.Close()
+
+	var candidates []researchWindow
+	for rows.Next() {
+		var w researchWindow
+		var searched string
+		var parked bool
+		if err := rows.Scan(&w.ID, &w.SearchID, &w.WindowID, &w.URL, &w.Title, &searched, &parked); err != nil {
+			slog.Warn("Failed to read research window", "err", err)
+			return researchWindow{}, false
+		}
+		if unseal(searched) == loggedQuery {
+			unsealAll(&w.URL, &w.Title)
+			candidates = append(candidates, w)
+		}
+	}
+	if len(candidates) == 0 {
+		return researchWindow{}, false
+	}
+
+	// Only now, as listing windows means asking the X server
+	open, err := openWindowIDs()
+	if err != nil {
+		return researchWindow{}, false
+	}
+	for _, w := range candidates {
+		if open[w.WindowID] {
+			return w, true
+		}
+	}
+	return researchWindow{}, false
+}
+
+// focusDuplicate decides whether to bring back the open window of a
+// duplicate search instead of opening another. Without a menu only
+// duplicate_search "focus" does.
+func focusDuplicate(w researchWindow, query string, noMenu bool) (bool, error) {
+	if config.Behavior.DuplicateSearch != duplicateFocus {
+		if noMenu {
+			return false, nil
+		}
+		name := w.Title
+		if name == "" {
+			name = query
+		}
+		selected, err := runLauncher("Already open: "+name, []string{focusExistingOption, openNewOption}, 2)
+		if err != nil {
+			return false, err
+		}
+		switch selected {
+		case focusExistingOption:
+		case openNewOption:
+			return false, nil
+		default:
+			return false, fmt.Errorf("no selection made")
+		}
+	}
+
+	// Activating a parked (iconified) window maps it again
+	if err := activateWindow(w.WindowID); err != nil {
+		return false, fmt.Errorf("failed to focus window: %w", err)
+	}
+	if _, err := db.Exec("UPDATE research_windows SET parked_at = NULL WHERE id = ?", w.ID); err != nil {
+		slog.Warn("Failed to record restored window", "err", err)
+	}
+	slog.Info("Focused the window of a duplicate search", "window", w.WindowID, "search_id", w.SearchID)
+	announce("Switched to the open window.")
+	return true, nil
+}
+
+// openDuplicateSearch is run before a single search opens its window, and
+// reports whether an open window was focused instead.
+func openDuplicateSearch(engine SearchEngine, query string, noMenu bool) (bool, error) {
+	if !dryRun {
+		if err := initDatabase(); err != nil {
+			return false, nil
+		}
+	}
+	w, ok := duplicateWindow(engine, query)
+	if !ok {
+		return false, nil
+	}
+	return focusDuplicate(w, query, noMenu)
+}
@@ -0,0 +1,75 @@
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// serveEditor runs searches for an editor plugin over a line protocol on
+// stdin/stdout. Each request is "KEY QUERY" (or ". QUERY" for the first
+// engine) and gets one reply line: "ok URL..." or "error MESSAGE". Nothing is
+// ever asked in the launcher, so the editor keeps keyboard focus.
+func serveEditor(in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		key, query, _ := strings.Cut(line, " ")
+		if key == "." {
+			key = ""
+		}
+		// Pick up engines added since the server started
+		var urls []string
+		err := loadConfig()
+		if err == nil {
+			urls, err = searchByKey(key, strings.TrimSpace(query), "editor", nil)
+		}
+		if err != nil {
+			slog.Warn("Editor search failed", "request", line, "err", err)
+			fmt.Fprintf(out, "error %s\n", strings.ReplaceAll(err.Error(), "\n", " "))
+			continue
+		}
+		fmt.Fprintf(out, "ok %s\n", strings.Join(urls, " "))
+	}
+	return scanner.Err()
+}
+
+// searchByKey runs a search without any launcher interaction, with the
+// first engine when key is empty, and returns the URLs it opened. It serves
+// clients that drive rabbithole programmatically.
+func searchByKey(key, query, triggerMethod string, tags []string) ([]string, error) {
+	if query == "" {
+		return nil, fmt.Errorf("expected 'KEY QUERY'")
+	}
+
+	if key == "" {
+		if len(config.SearchEngines) == 0 {
+			return nil, fmt.Errorf("no search engines configured")
+		}
+		key = config.SearchEngines[0].Key
+	}
+	engine, err := engineByKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := dispatchSearch(engine, query, triggerMethod, searchOptions{Tags: tags, NoMenu: true}); err != nil {
+		return nil, err
+	}
+	// A bundle opens the URL of each of its engines
+	if bundle, ok := bundleByKey(key); ok {
+		engines, _ := resolveBundle(bundle)
+		urls := make([]string, len(engines))
+		for i, e := range engines {
+			urls[i] = buildSearchURL(e.URL, query)
+		}
+		return urls, nil
+	}
+	return []string{buildSearchURL(engine.URL, query)}, nil
+}
@@ -0,0 +1,533 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Sealed values are stored as this prefix plus base64 of the ephemeral
+// public key, the nonce and the AES-GCM ciphertext.
+const sealedPrefix = "rh1:"
+
+// sealedPlaceholder stands in for text that can't be decrypted, e.g. when
+// the passphrase prompt was cancelled.
+const sealedPlaceholder = "[encrypted]"
+
+const (
+	encryptionKeyring    = "keyring"
+	encryptionPassphrase = "passphrase"
+
+	// OWASP's recommendation for PBKDF2-HMAC-SHA256
+	passphraseIterations = 600000
+)
+
+// Searches are sealed to a public key, so logging one never needs the
+// passphrase; only reading history unlocks the private key. Both are
+// loaded once per process, under encryptionMu: the daemon's search log
+// writer and its API handlers seal at the same time.
+var (
+	encryptionMu sync.Mutex
+	sealKey      *ecdh.PublicKey
+	openKey      *ecdh.PrivateKey
+	openKeyErr   error
+	openKeyDone  bool
+)
+
+// seal encrypts text for storage when database.encryption is set. Empty
+// and already sealed text is returned as it is.
+func seal(text string) string {
+	sealed, err := sealIn(database(), text)
+	if err != nil {
+		// Storing the text in the clear would defeat the point
+		slog.Error("Failed to encrypt, storing a placeholder", "err", err)
+		return sealedPlaceholder
+	}
+	return sealed
+}
+
+// sealIn is seal for the key pair kept in conn, which is nil while the
+// database isn't open.
+func sealIn(conn *sql.DB, text string) (string, error) {
+	if config.Database.Encryption == "" || text == "" || isSealed(text) {
+		return text, nil
+	}
+	key, err := encryptionPublicKey(conn)
+	if err != nil {
+		return "", err
+	}
+	return sealWith(key, text)
+}
+
+// unseal decrypts a stored value, unlocking the private key the first time
+// it's needed. Plain text is returned as it is.
+func unseal(text string) string {
+	if !isSealed(text) {
+		return text
+	}
+	key, err := encryptionPrivateKey()
+	if err != nil {
+		return sealedPlaceholder
+	}
+	plain, err := openWith(key, text)
+	if err != nil {
+		slog.Warn("Failed to decrypt stored text", "err", err)
+		return sealedPlaceholder
+	}
+	return plain
+}
+
+func isSealed(text string) bool {
+	return strings.HasPrefix(text, sealedPrefix)
+}
+
+// unsealAll decrypts several scanned columns in place.
+func unsealAll(fields ...*string) {
+	for _, field := range fields {
+		*field = unseal(*field)
+	}
+}
+
+func sealWith(key *ecdh.PublicKey, text string) (string, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	shared, err := ephemeral.ECDH(key)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newSealCipher(shared, ephemeral.PublicKey().Bytes(), key.Bytes())
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	out := append(ephemeral.PublicKey().Bytes(), nonce...)
+	out = aead.Seal(out, nonce, []byte(text), nil)
+	return sealedPrefix + base64.RawStdEncoding.EncodeToString(out), nil
+}
+
+func openWith(key *ecdh.PrivateKey, sealed string) (string, error) {
+	data, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(sealed, sealedPrefix))
+	if err != nil || len(data) < 32 {
+		return "", fmt.Errorf("malformed encrypted value")
+	}
+	ephemeral, err := ecdh.X25519().NewPublicKey(data[:32])
+	if err != nil {
+		return "", err
+	}
+	shared, err := key.ECDH(ephemeral)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newSealCipher(shared, data[:32], key.PublicKey().Bytes())
+	if err != nil {
+		return "", err
+	}
+	rest := data[32:]
+	if len(rest) < aead.NonceSize() {
+		return "", fmt.Errorf("malformed encrypted value")
+	}
+	plain, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("wrong key or corrupted value: %w", err)
+	}
+	return string(plain), nil
+}
+
+// newSealCipher derives the AES-256-GCM key for one value from the X25519
+// shared secret, bound to both public keys.
+func newSealCipher(shared, ephemeralPublic, recipientPublic []byte) (cipher.AEAD, error) {
+	h := sha256.New()
+	h.Write([]byte("rabbithole seal v1"))
+	h.Write(shared)
+	h.Write(ephemeralPublic)
+	h.Write(recipientPublic)
+	return newGCM(h.Sum(nil))
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// pbkdf2SHA256 derives a 32-byte key from a passphrase (RFC 8018).
+func pbkdf2SHA256(passphrase, salt []byte, iterations int) []byte {
+	prf := hmac.New(sha256.New, passphrase)
+	prf.Write(salt)
+	prf.Write(binary.BigEndian.AppendUint32(nil, 1))
+	u := prf.Sum(nil)
+	key := bytes.Clone(u)
+	for i := 1; i < iterations; i++ {
+		prf.Reset()
+		prf.Write(u)
+		u = prf.Sum(u[:0])
+		for j := range key {
+			key[j] ^= u[j]
+		}
+	}
+	return key
+}
+
+// encryptionPublicKey loads the public key from conn, creating the key pair
+// on first use.
+func encryptionPublicKey(conn *sql.DB) (*ecdh.PublicKey, error) {
+	encryptionMu.Lock()
+	defer encryptionMu.Unlock()
+	if sealKey != nil {
+		return sealKey, nil
+	}
+	if conn == nil {
+		return nil, fmt.Errorf("failed to load encryption key: database not initialized")
+	}
+	var encoded string
+	err := conn.QueryRow("SELECT public_key FROM encryption_keys WHERE id = 1").Scan(&encoded)
+	if errors.Is(err, sql.ErrNoRows) {
+		return createEncryptionKeys(conn)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load encryption key: %w", err)
+	}
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("malformed encryption key: %w", err)
+	}
+	sealKey, err = ecdh.X25519().NewPublicKey(raw)
+	return sealKey, err
+}
+
+// createEncryptionKeys generates the key pair and puts the private key in
+// the keyring, or stores it encrypted with a passphrase. History recorded
+// before is encrypted right away. The caller holds encryptionMu.
+func createEncryptionKeys(conn *sql.DB) (*ecdh.PublicKey, error) {
+	private, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	var storedPrivate, storedSalt string
+	switch config.Database.Encryption {
+	case encryptionKeyring:
+		if err := storeKeyringSecret(base64.StdEncoding.EncodeToString(private.Bytes())); err != nil {
+			return nil, err
+		}
+	case encryptionPassphrase:
+		passphrase, err := readPassphrase(true)
+		if err != nil {
+			return nil, err
+		}
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		aead, err := newGCM(pbkdf2SHA256([]byte(passphrase), salt, passphraseIterations))
+		if err != nil {
+			return nil, err
+		}
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, err
+		}
+		storedPrivate = base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, private.Bytes(), nil))
+		storedSalt = base64.StdEncoding.EncodeToString(salt)
+	default:
+		return nil, fmt.Errorf("unknown database.encryption %q (use keyring or passphrase)", config.Database.Encryption)
+	}
+
+	_, err = conn.Exec("INSERT INTO encryption_keys (id, public_key, private_key, salt) VALUES (1, ?, ?, ?)",
+		base64.StdEncoding.EncodeToString(private.PublicKey().Bytes()), storedPrivate, storedSalt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store encryption key: %w", err)
+	}
+	sealKey = private.PublicKey()
+	openKey, openKeyDone = private, true
+	slog.Info("Created history encryption key", "mode", config.Database.Encryption)
+
+	if err := sealExistingHistory(conn, sealKey); err != nil {
+		slog.Error("Failed to encrypt existing history", "err", err)
+	}
+	return sealKey, nil
+}
+
+// sealedColumns are the places query text ends up: the query and URL
+// themselves, the titles and URLs of the pages it led to, and what was
+// kept of them - bookmarks, archives, notes, snippets and clipboard
+// history.
+var sealedColumns = map[string][]string{
+	"searches":          {"query", "final_url", "page_title"},
+	"research_windows":  {"url", "title"},
+	"navigations":       {"title", "url"},
+	"bookmarks":         {"url", "title", "wayback_url"},
+	"page_archives":     {"url", "title"},
+	"notes":             {"text"},
+	"snippets":          {"text"},
+	"clipboard_history": {"text"},
+}
+
+func sealExistingHistory(conn *sql.DB, key *ecdh.PublicKey) error {
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for table, columns := range sealedColumns {
+		rows, err := tx.Query(fmt.Sprintf("SELECT id, %s FROM %s", strings.Join(columns, ", "), table))
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", table, err)
+		}
+		updates := make(map[int64][]any)
+		for rows.Next() {
+			var id int64
+			values := make([]sql.NullString, len(columns))
+			dest := []any{&id}
+			for i := range values {
+				dest = append(dest, &values[i])
+			}
+			if err := rows.Scan(dest...); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to read %s: %w", table, err)
+			}
+			var sealed []any
+			for _, v := range values {
+				if v.String == "" || isSealed(v.String) {
+					sealed = append(sealed, v.String)
+					continue
+				}
+				value, err := sealWith(key, v.String)
+				if err != nil {
+					rows.Close()
+					return fmt.Errorf("failed to encrypt %s: %w", table, err)
+				}
+				sealed = append(sealed, value)
+			}
+			updates[id] = append(sealed, id)
+		}
+		rows.Close()
+
+		assignments := strings.Join(columns, " = ?, ") + " = ?"
+		for _, args := range updates {
+			if _, err := tx.Exec(fmt.Sprintf("UPDATE %s SET %s WHERE id = ?", table, assignments), args...); err != nil {
+				return fmt.Errorf("failed to encrypt %s: %w", table, err)
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	// The plain text would otherwise linger in free pages
+	_, err = conn.Exec("VACUUM")
+	return err
+}
+
+// encryptionPrivateKey unlocks the private key once per process; a failed
+// unlock isn't retried, so a cancelled prompt doesn't come back per row.
+func encryptionPrivateKey() (*ecdh.PrivateKey, error) {
+	// Taken first, as opening the database seals spilled searches with
+	// databaseMu held
+	conn := database()
+	encryptionMu.Lock()
+	defer encryptionMu.Unlock()
+	if openKeyDone {
+		return openKey, openKeyErr
+	}
+	openKeyDone = true
+	openKey, openKeyErr = unlockPrivateKey(conn)
+	if openKeyErr != nil {
+		slog.Warn("History stays encrypted", "err", openKeyErr)
+		fmt.Fprintf(os.Stderr, "🔒 History stays encrypted: %v\n", openKeyErr)
+	}
+	return openKey, openKeyErr
+}
+
+func unlockPrivateKey(conn *sql.DB) (*ecdh.PrivateKey, error) {
+	if conn == nil {
+		return nil, fmt.Errorf("no encryption key: database not initialized")
+	}
+	var storedPrivate, storedSalt string
+	err := conn.QueryRow("SELECT private_key, salt FROM encryption_keys WHERE id = 1").Scan(&storedPrivate, &storedSalt)
+	if err != nil {
+		return nil, fmt.Errorf("no encryption key in the database: %w", err)
+	}
+
+	var raw []byte
+	if storedPrivate == "" {
+		secret, err := lookupKeyringSecret()
+		if err != nil {
+			return nil, err
+		}
+		if raw, err = base64.StdEncoding.DecodeString(secret); err != nil {
+			return nil, fmt.Errorf("malformed key in the keyring: %w", err)
+		}
+	} else {
+		sealed, err := base64.StdEncoding.DecodeString(storedPrivate)
+		if err != nil {
+			return nil, fmt.Errorf("malformed encryption key: %w", err)
+		}
+		salt, err := base64.StdEncoding.DecodeString(storedSalt)
+		if err != nil {
+			return nil, fmt.Errorf("malformed encryption salt: %w", err)
+		}
+		passphrase, err := readPassphrase(false)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := newGCM(pbkdf2SHA256([]byte(passphrase), salt, passphraseIterations))
+		if err != nil {
+			return nil, err
+		}
+		if len(sealed) < aead.NonceSize() {
+			return nil, fmt.Errorf("malformed encryption key")
+		}
+		raw, err = aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+		if err != nil {
+			return nil, fmt.Errorf("wrong passphrase")
+		}
+	}
+	return ecdh.X25519().NewPrivateKey(raw)
+}
+
+// keyringAttributes identify the key in the Secret Service, one per
+// profile.
+func keyringAttributes() []string {
+	name := profile
+	if name == "" {
+		name = "default"
+	}
+	return []string{"application", appName, "profile", name}
+}
+
+func storeKeyringSecret(secret string) error {
+	args := append([]string{"store", "--label=Rabbithole history key"}, keyringAttributes()...)
+	cmd := exec.Command("secret-tool", args...)
+	cmd.Stdin = strings.NewReader(secret)
+	if output, err := combinedOutput(cmd); err != nil {
+		return fmt.Errorf("failed to store the key with secret-tool (is a keyring running?): %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func lookupKeyringSecret() (string, error) {
+	output, err := commandOutput(exec.Command("secret-tool", append([]string{"lookup"}, keyringAttributes()...)...))
+	if err != nil {
+		return "", fmt.Errorf("failed to read the key with secret-tool (is the keyring unlocked?): %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// readPassphrase takes the passphrase from RABBITHOLE_PASSPHRASE or asks
+// with pinentry, which also works when started from a hotkey.
+func readPassphrase(confirm bool) (string, error) {
+	if passphrase := os.Getenv("RABBITHOLE_PASSPHRASE"); passphrase != "" {
+		return passphrase, nil
+	}
+	description := "Enter the passphrase for your rabbithole history."
+	if confirm {
+		description = "Choose a passphrase to encrypt your rabbithole history."
+	}
+	commands := []string{"SETTITLE Rabbithole", "SETDESC " + description, "SETPROMPT Passphrase:"}
+	if confirm {
+		commands = append(commands, "SETREPEAT Repeat:", "SETREPEATERROR Passphrases don't match")
+	}
+	return pinentry(append(commands, "GETPIN"))
+}
+
+// pinentry runs the Assuan commands and returns the data of the last
+// one, i.e. the PIN. The conversation needs a live process on both pipes,
+// so it doesn't go through runner.
+func pinentry(commands []string) (string, error) {
+	cmd := exec.Command("pinentry")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return "", err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("failed to start pinentry (install it or set RABBITHOLE_PASSPHRASE): %w", err)
+	}
+	defer cmd.Wait()
+	defer stdin.Close()
+
+	reader := bufio.NewReader(stdout)
+	readReply := func() (string, error) {
+		var data string
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				if err == io.EOF {
+					return "", fmt.Errorf("pinentry exited")
+				}
+				return "", err
+			}
+			line = strings.TrimRight(line, "\n")
+			switch {
+			case strings.HasPrefix(line, "D "):
+				data += unescapeAssuan(line[2:])
+			case line == "OK" || strings.HasPrefix(line, "OK "):
+				return data, nil
+			case strings.HasPrefix(line, "ERR "):
+				return "", fmt.Errorf("pinentry: %s", line[4:])
+			}
+		}
+	}
+
+	if _, err := readReply(); err != nil {
+		return "", err
+	}
+	var data string
+	for _, command := range commands {
+		if _, err := fmt.Fprintln(stdin, command); err != nil {
+			return "", err
+		}
+		if data, err = readReply(); err != nil {
+			return "", err
+		}
+	}
+	fmt.Fprintln(stdin, "BYE")
+	if data == "" {
+		return "", fmt.Errorf("no passphrase entered")
+	}
+	return data, nil
+}
+
+// unescapeAssuan decodes the %XX escapes Assuan uses in data lines.
+func unescapeAssuan(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,276 @@
+package app
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+const (
+	engineMenuAdd  = "+ Add engine"
+	engineMenuDone = "✓ Done"
+)
+
+// Actions offered for an engine picked in the engines menu.
+const (
+	engineActionName   = "Rename"
+	engineActionURL    = "Change URL"
+	engineActionKey    = "Change key"
+	engineActionAlias  = "Change aliases"
+	engineActionIcon   = "Change icon"
+	engineActionUp     = "Move up"
+	engineActionDown   = "Move down"
+	engineActionRemove = "Remove"
+	engineActionBack   = "Back"
+)
+
+// validateEngineKey checks that key (or an alias) can be typed and isn't
+// used by a bundle or another engine than the one with key except.
+func validateEngineKey(key, except string) error {
+	if problem := engineKeyProblem(key); problem != "" {
+		return fmt.Errorf("key %q %s", key, problem)
+	}
+	for _, engine := range config.SearchEngines {
+		if engine.Key == except && except != "" {
+			continue
+		}
+		for _, k := range engine.Keys() {
+			if k == key {
+				return fmt.Errorf("key '%s' already exists for engine '%s'", key, engine.Name)
+			}
+		}
+	}
+	for _, bundle := range config.Bundles {
+		if bundle.Key == key {
+			return fmt.Errorf("key '%s' already exists for bundle '%s'", key, bundle.Name)
+		}
+	}
+	return nil
+}
+
+// validateEngineAliases checks an engine's aliases like its key.
+func validateEngineAliases(engine SearchEngine) error {
+	for i, alias := range engine.Aliases {
+		if alias == engine.Key || slices.Contains(engine.Aliases[:i], alias) {
+			return fmt.Errorf("alias '%s' is given twice", alias)
+		}
+		if err := validateEngineKey(alias, engine.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateEngineURL(url string) error {
+	if !strings.Contains(url, "%s") {
+		return fmt.Errorf("URL must contain %%s placeholder for query substitution")
+	}
+	return nil
+}
+
+// manageEngines lets the engines be added, edited, removed and reordered
+// through launcher prompts. Every change is saved right away; escaping the
+// main menu ends it. Invalid input is reported and the menu shown again,
+// while failing to save ends it.
+func manageEngines() error {
+	if err := checkConfigWritable(); err != nil {
+		return err
+	}
+	for {
+		options := make([]string, 0, len(config.SearchEngines)+2)
+		icons := make([]string, 0, len(config.SearchEngines))
+		for _, engine := range config.SearchEngines {
+			options = append(options, engine.MenuOption())
+			icons = append(icons, engine.MenuIcon())
+		}
+		options = append(options, engineMenuAdd, engineMenuDone)
+
+		selected, err := runLauncherWithIcons("Engines:", options, icons, min(len(options), 15))
+		if err != nil || selected == "" || selected == engineMenuDone {
+			return nil
+		}
+		if selected == engineMenuAdd {
+			if err := addEngineInteractively(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		index := engineIndex(menuKey(selected))
+		if index < 0 {
+			continue
+		}
+		if err := editEngineInteractively(index); err != nil {
+			return err
+		}
+	}
+}
+
+func engineIndex(key string) int {
+	for i, engine := range config.SearchEngines {
+		if engine.Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// promptEngineField asks for one value. An empty answer keeps current, so
+// escaping a prompt never blanks a field.
+func promptEngineField(prompt, current string) (string, error) {
+	if current != "" {
+		prompt = fmt.Sprintf("%s (%s)", prompt, current)
+	}
+	value, err := runLauncher(prompt+":", nil, 0)
+	if err != nil || value == "" {
+		return current, err
+	}
+	return value, nil
+}
+
+// addEngineInteractively asks for a new engine's name, URL and key. Only
+// saving errors are returned.
+func addEngineInteractively() error {
+	name, err := promptEngineField("Name", "")
+	if err != nil || name == "" {
+		return nil
+	}
+	url, err := promptEngineField("URL with %s for the query", "")
+	if err != nil || url == "" {
+		return nil
+	}
+	if err := validateEngineURL(url); err != nil {
+		reportEngineMenuError(err)
+		return nil
+	}
+	key, err := promptEngineField("Key", "")
+	if err != nil || key == "" {
+		return nil
+	}
+	if err := validateEngineKey(key, ""); err != nil {
+		reportEngineMenuError(err)
+		return nil
+	}
+
+	config.SearchEngines = append(config.SearchEngines, SearchEngine{Name: name, URL: url, Key: key})
+	if err := saveConfig(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	fmt.Printf("✅ Added search engine: %s (%s) -> %s\n", name, key, url)
+	return nil
+}
+
+// editEngineInteractively offers the actions for the engine at index until
+// it's removed or the user goes back. Only saving errors are returned.
+func editEngineInteractively(index int) error {
+	for {
+		engine := config.SearchEngines[index]
+		actions := []string{engineActionName, engineActionURL, engineActionKey, engineActionAlias, engineActionIcon}
+		if index > 0 {
+			actions = append(actions, engineActionUp)
+		}
+		if index < len(config.SearchEngines)-1 {
+			actions = append(actions, engineActionDown)
+		}
+		actions = append(actions, engineActionRemove, engineActionBack)
+
+		action, err := runLauncher(fmt.Sprintf("%s (%s):", engine.Name, engine.Key), actions, len(actions))
+		if err != nil || action == "" || action == engineActionBack {
+			return nil
+		}
+
+		updated := engine
+		switch action {
+		case engineActionName:
+			if updated.Name, err = promptEngineField("Name", engine.Name); err != nil {
+				return nil
+			}
+		case engineActionURL:
+			if updated.URL, err = promptEngineField("URL", engine.URL); err != nil {
+				return nil
+			}
+			if err := validateEngineURL(updated.URL); err != nil {
+				reportEngineMenuError(err)
+				continue
+			}
+		case engineActionKey:
+			if updated.Key, err = promptEngineField("Key", engine.Key); err != nil {
+				return nil
+			}
+			if err := validateEngineKey(updated.Key, engine.Key); err != nil {
+				reportEngineMenuError(err)
+				continue
+			}
+			if err := validateEngineAliases(updated); err != nil {
+				reportEngineMenuError(err)
+				continue
+			}
+		case engineActionAlias:
+			answer, err := promptEngineField("Aliases, comma-separated (- for none)", strings.Join(engine.Aliases, ","))
+			if err != nil {
+				return nil
+			}
+			updated.Aliases = nil
+			for _, alias := range strings.Split(answer, ",") {
+				if alias = strings.TrimSpace(alias); alias != "" && alias != "-" {
+					updated.Aliases = append(updated.Aliases, alias)
+				}
+			}
+			if err := validateEngineAliases(updated); err != nil {
+				reportEngineMenuError(err)
+				continue
+			}
+		case engineActionIcon:
+			answer, err := promptEngineField("Emoji or icon name (- for none)", engine.Icon)
+			if err != nil {
+				return nil
+			}
+			updated.Icon = answer
+			if answer == "-" {
+				updated.Icon = ""
+			}
+		case engineActionUp, engineActionDown:
+			other := index - 1
+			if action == engineActionDown {
+				other = index + 1
+			}
+			engines := config.SearchEngines
+			engines[index], engines[other] = engines[other], engines[index]
+			if err := saveConfig(); err != nil {
+				return fmt.Errorf("failed to save config: %w", err)
+			}
+			fmt.Printf("✅ Moved %s (%s) to position %d\n", engine.Name, engine.Key, other+1)
+			index = other
+			continue
+		case engineActionRemove:
+			confirm, err := runLauncher(fmt.Sprintf("Remove %s?", engine.Name), []string{"no", "yes"}, 0)
+			if err != nil || confirm != "yes" {
+				continue
+			}
+			config.SearchEngines = append(config.SearchEngines[:index], config.SearchEngines[index+1:]...)
+			if err := saveConfig(); err != nil {
+				return fmt.Errorf("failed to save config: %w", err)
+			}
+			fmt.Printf("✅ Removed search engine: %s (%s)\n", engine.Name, engine.Key)
+			return nil
+		default:
+			continue
+		}
+
+		if reflect.DeepEqual(updated, engine) {
+			continue
+		}
+		config.SearchEngines[index] = updated
+		if err := saveConfig(); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+		fmt.Printf("✅ Updated search engine: %s (%s) -> %s\n", updated.Name, updated.Key, updated.URL)
+	}
+}
+
+// reportEngineMenuError shows a rejected change without leaving the menu.
+func reportEngineMenuError(err error) {
+	fmt.Printf("❌ %v\n", err)
+	notifyUser("Engine not changed", err.Error())
+}
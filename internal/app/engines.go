@@ -0,0 +1,171 @@
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Query parameter names commonly used by search pages, in rough order of
+// popularity. Used to pick the query param without asking when possible.
+var knownQueryParams = map[string]bool{
+	"q": true, "query": true, "search": true, "search_query": true,
+	"s": true, "k": true, "p": true, "term": true, "text": true,
+	"keywords": true, "searchterm": true, "wd": true, "search_term": true,
+}
+
+// templateFromSearchURL turns a URL copied from a results page into an
+// engine template by replacing the query parameter's value with %s. When
+// the query parameter can't be inferred the user is asked on stdin.
+func templateFromSearchURL(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("not a valid URL: %s", rawURL)
+	}
+
+	// Keep the raw pairs so the other parameters survive untouched
+	var pairs []string
+	if u.RawQuery != "" {
+		pairs = strings.Split(u.RawQuery, "&")
+	}
+	if len(pairs) == 0 {
+		return "", fmt.Errorf("URL has no query parameters to turn into %%s; add the engine with an explicit template instead")
+	}
+
+	queryIndex := -1
+	if len(pairs) == 1 {
+		queryIndex = 0
+	} else {
+		for i, pair := range pairs {
+			name, _, _ := strings.Cut(pair, "=")
+			if knownQueryParams[strings.ToLower(name)] {
+				if queryIndex != -1 {
+					queryIndex = -1 // more than one candidate, ask instead
+					break
+				}
+				queryIndex = i
+			}
+		}
+	}
+
+	if queryIndex == -1 {
+		queryIndex, err = askQueryParam(pairs)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	name, _, _ := strings.Cut(pairs[queryIndex], "=")
+	pairs[queryIndex] = name + "=%s"
+	u.RawQuery = strings.Join(pairs, "&")
+	return u.String(), nil
+}
+
+func askQueryParam(pairs []string) (int, error) {
+	fmt.Println("Which parameter holds the search query?")
+	for i, pair := range pairs {
+		name, value, _ := strings.Cut(pair, "=")
+		if decoded, err := url.QueryUnescape(value); err == nil {
+			value = decoded
+		}
+		fmt.Printf("  %d) %s = %s\n", i+1, name, value)
+	}
+	fmt.Print("Number: ")
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return -1, fmt.Errorf("failed to read answer: %w", err)
+	}
+	choice, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil || choice < 1 || choice > len(pairs) {
+		return -1, fmt.Errorf("invalid choice: %s", strings.TrimSpace(line))
+	}
+	return choice - 1, nil
+}
+
+// menuKey extracts the key from a selected menu line ("🎓 gs: Google
+// Scholar"), or returns what was typed.
+func menuKey(selected string) string {
+	key, _, _ := strings.Cut(selected, ":")
+	fields := strings.Fields(key)
+	if len(fields) == 0 {
+		return ""
+	}
+	// Keys have no spaces, so an emoji before one is a separate field
+	return fields[len(fields)-1]
+}
+
+// resolveEngineKey finds the engine input names: the one with input as
+// its key or an alias, or else the only one with a key or alias starting
+// with input, so "sch" finds an engine keyed "scholar".
+func resolveEngineKey(input string, engines []SearchEngine) (SearchEngine, error) {
+	var matches []SearchEngine
+	for _, engine := range engines {
+		for _, key := range engine.Keys() {
+			if key == input {
+				return engine, nil
+			}
+		}
+	}
+	for _, engine := range engines {
+		for _, key := range engine.Keys() {
+			if input != "" && strings.HasPrefix(key, input) {
+				matches = append(matches, engine)
+				break
+			}
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return SearchEngine{}, fmt.Errorf("no engine with key '%s'", input)
+	case 1:
+		return matches[0], nil
+	default:
+		names := make([]string, len(matches))
+		for i, engine := range matches {
+			names[i] = fmt.Sprintf("%s (%s)", engine.Name, engine.Key)
+		}
+		return SearchEngine{}, fmt.Errorf("'%s' could be %s; type more of the key", input, strings.Join(names, " or "))
+	}
+}
+
+// parseBang picks the engine from a DuckDuckGo-style bang at the start or
+// end of the query, as in "!gs rabbit holes" or "rabbit holes !gs", and
+// returns the query without it. Bangs that name no engine are left alone,
+// so "!important" stays part of the query.
+func parseBang(query string) (SearchEngine, string, bool) {
+	fields := strings.Fields(query)
+	if len(fields) < 2 {
+		return SearchEngine{}, query, false
+	}
+	for _, i := range []int{0, len(fields) - 1} {
+		bang := fields[i]
+		if len(bang) < 2 || bang[0] != '!' {
+			continue
+		}
+		engine, err := resolveEngineKey(bang[1:], menuEngines())
+		if err != nil {
+			continue
+		}
+		rest := append(append([]string{}, fields[:i]...), fields[i+1:]...)
+		return engine, strings.Join(rest, " "), true
+	}
+	return SearchEngine{}, query, false
+}
+
+// engineKeyProblem says what's wrong with key, or "" when it can be used.
+// Keys are typed in the menu and after "!", and listed before a colon.
+func engineKeyProblem(key string) string {
+	switch {
+	case key == "":
+		return "is empty"
+	case strings.ContainsAny(key, ": \t"):
+		return "contains a colon or space"
+	case strings.HasPrefix(key, "!"):
+		return "starts with !"
+	}
+	return ""
+}
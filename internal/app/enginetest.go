@@ -0,0 +1,149 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// defaultTestQuery is searched by test-engine when no query is given.
+const defaultTestQuery = "rabbit hole"
+
+var engineTestClient = &http.Client{Timeout: 10 * time.Second}
+
+// redirectHop is one redirect followed while testing an engine.
+type redirectHop struct {
+	Status   int    `json:"status"`
+	Location string `json:"location"`
+}
+
+// engineTestResult is what requesting an engine's search URL returned.
+type engineTestResult struct {
+	Engine    string        `json:"engine"`
+	Key       string        `json:"key"`
+	URL       string        `json:"url"`
+	Method    string        `json:"method"`
+	Status    int           `json:"status"`
+	Redirects []redirectHop `json:"redirects"`
+	FinalURL  string        `json:"final_url"`
+	// QueryKept is false when redirects dropped the query, which usually
+	// means the provider changed its URL format
+	QueryKept bool   `json:"query_kept"`
+	Error     string `json:"error,omitempty"`
+}
+
+func (r engineTestResult) ok() bool {
+	return r.Error == "" && r.Status >= 200 && r.Status < 300 && r.QueryKept
+}
+
+// testEngines resolves key to its engines (several for a bundle) and
+// requests each one's URL for query.
+func testEngines(key, query string) ([]engineTestResult, []SearchEngine, error) {
+	engine, err := engineByKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	engines := []SearchEngine{engine}
+	if bundle, ok := bundleByKey(key); ok {
+		if engines, err = resolveBundle(bundle); err != nil {
+			return nil, nil, err
+		}
+	}
+	for i, e := range engines {
+		if isTranslator(e) {
+			if engines[i], err = translationEngine(e, false); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	var results []engineTestResult
+	for _, e := range engines {
+		results = append(results, testEngine(e, query))
+	}
+	return results, engines, nil
+}
+
+// testEngine requests the engine's URL with HEAD, falling back to GET for
+// servers that don't answer HEAD, and records the redirects on the way.
+func testEngine(engine SearchEngine, query string) engineTestResult {
+	result := engineTestResult{Engine: engine.Name, Key: engine.Key, URL: buildSearchURL(engine.URL, query)}
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		result.Method = method
+		result.Redirects = []redirectHop{}
+		status, finalURL, err := requestFollowingRedirects(method, result.URL, &result.Redirects)
+		if err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		result.Status, result.FinalURL = status, finalURL
+		if status != http.StatusMethodNotAllowed && status != http.StatusNotImplemented && status != http.StatusForbidden {
+			break
+		}
+	}
+	result.QueryKept = urlKeepsQuery(result.FinalURL, query)
+	return result
+}
+
+func requestFollowingRedirects(method, rawURL string, hops *[]redirectHop) (int, string, error) {
+	req, err := http.NewRequest(method, rawURL, nil)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid URL: %w", err)
+	}
+	req.Header.Set("User-Agent", appName+"/"+appVersion)
+	client := *engineTestClient
+	client.CheckRedirect = func(next *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return fmt.Errorf("stopped after 10 redirects")
+		}
+		*hops = append(*hops, redirectHop{Status: next.Response.StatusCode, Location: next.URL.String()})
+		return nil
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, "", fmt.Errorf("request failed: %w", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode, resp.Request.URL.String(), nil
+}
+
+// urlKeepsQuery reports whether the query still appears in the URL, in
+// any of the ways search URLs encode it.
+func urlKeepsQuery(rawURL, query string) bool {
+	if strings.Contains(rawURL, url.QueryEscape(query)) || strings.Contains(rawURL, url.PathEscape(query)) {
+		return true
+	}
+	decoded, err := url.QueryUnescape(rawURL)
+	return err == nil && strings.Contains(strings.ToLower(decoded), strings.ToLower(query))
+}
+
+func printEngineTests(results []engineTestResult) error {
+	if jsonOutput {
+		return printJSON(results)
+	}
+	for i, r := range results {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("🔎 %s (%s)\n", r.Engine, r.Key)
+		fmt.Printf("   %s %s\n", r.Method, r.URL)
+		for _, hop := range r.Redirects {
+			fmt.Printf("   ↪ %d → %s\n", hop.Status, hop.Location)
+		}
+		switch {
+		case r.Error != "":
+			fmt.Printf("❌ %s\n", r.Error)
+		case r.Status >= 200 && r.Status < 300 && !r.QueryKept:
+			fmt.Printf("⚠️  %d, but the query is gone from %s - the URL format may have changed\n", r.Status, r.FinalURL)
+		case r.Status >= 200 && r.Status < 300:
+			fmt.Printf("✅ %d %s\n", r.Status, http.StatusText(r.Status))
+		case r.Status == http.StatusForbidden || r.Status == http.StatusTooManyRequests:
+			fmt.Printf("⚠️  %d %s - the site may be refusing scripts rather than broken (try --open)\n", r.Status, http.StatusText(r.Status))
+		default:
+			fmt.Printf("❌ %d %s\n", r.Status, http.StatusText(r.Status))
+		}
+	}
+	return nil
+}
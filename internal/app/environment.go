@@ -0,0 +1,53 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// searchEnvironment is a provenance fingerprint stored with each search so
+// old history can be interpreted after engines or tools change.
+type searchEnvironment struct {
+	AppVersion     string `json:"app_version"`
+	BrowserVersion string `json:"browser_version"`
+	EngineName     string `json:"engine_name"`
+	EngineURL      string `json:"engine_url"`
+	Launcher       string `json:"launcher"`
+	SessionType    string `json:"session_type"`
+	OS             string `json:"os"`
+}
+
+func captureEnvironment(engine SearchEngine) searchEnvironment {
+	env := searchEnvironment{
+		AppVersion:  appVersion,
+		EngineName:  engine.Name,
+		EngineURL:   engine.URL,
+		Launcher:    config.Interface.Launcher,
+		SessionType: os.Getenv("XDG_SESSION_TYPE"),
+		OS:          runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	if env.Launcher == "" {
+		env.Launcher = "dmenu"
+	}
+
+	if out, err := commandOutput(exec.Command("firefox", "--version")); err == nil {
+		env.BrowserVersion = strings.TrimSpace(string(out))
+	}
+	return env
+}
+
+// recordEnvironment stores the environment fingerprint for a search. It
+// runs after the research window is open since probing the browser version
+// is comparatively slow.
+func recordEnvironment(searchID int64, engine SearchEngine) error {
+	data, err := json.Marshal(captureEnvironment(engine))
+	if err != nil {
+		return fmt.Errorf("failed to marshal environment: %w", err)
+	}
+	_, err = db.Exec("UPDATE searches SET environment = ? WHERE id = ?", string(data), searchID)
+	return err
+}
@@ -0,0 +1,223 @@
+package app
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exportRow is one search with the fields analyses usually derive from it
+// already computed. Times are local, like the session ids.
+type exportRow struct {
+	ID              int64  `json:"id"`
+	Timestamp       string `json:"timestamp"`
+	Date            string `json:"date"`
+	Hour            int    `json:"hour"`
+	Weekday         string `json:"weekday"`
+	SessionID       string `json:"session_id"`
+	SessionSearches int    `json:"session_searches"`
+	// SessionMinutes is the time from the session's first search to its
+	// last
+	SessionMinutes int64  `json:"session_minutes"`
+	Query          string `json:"query"`
+	QueryWords     int    `json:"query_words"`
+	QueryChars     int    `json:"query_chars"`
+	EngineName     string `json:"engine_name"`
+	EngineCategory string `json:"engine_category"`
+	FinalURL       string `json:"final_url"`
+	PageTitle      string `json:"page_title"`
+	TriggerMethod  string `json:"trigger_method"`
+	Tags           string `json:"tags"`
+	OpenSeconds    int64  `json:"open_seconds"`
+	FocusSeconds   int64  `json:"focus_seconds"`
+	Pages          int    `json:"pages"`
+}
+
+var exportColumns = []string{
+	"id", "timestamp", "date", "hour", "weekday", "session_id", "session_searches", "session_minutes",
+	"query", "query_words", "query_chars", "engine_name", "engine_category", "final_url", "page_title",
+	"trigger_method", "tags", "open_seconds", "focus_seconds", "pages",
+}
+
+func (r exportRow) record() []string {
+	return []string{
+		strconv.FormatInt(r.ID, 10), r.Timestamp, r.Date, strconv.Itoa(r.Hour), r.Weekday, r.SessionID,
+		strconv.Itoa(r.SessionSearches), strconv.FormatInt(r.SessionMinutes, 10),
+		r.Query, strconv.Itoa(r.QueryWords), strconv.Itoa(r.QueryChars), r.EngineName, r.EngineCategory,
+		r.FinalURL, r.PageTitle, r.TriggerMethod, r.Tags,
+		strconv.FormatInt(r.OpenSeconds, 10), strconv.FormatInt(r.FocusSeconds, 10), strconv.Itoa(r.Pages),
+	}
+}
+
+// engineCategories guess an engine's category from its URL when it doesn't
+// set one, by a word in the host or path.
+var engineCategories = []struct{ category, words string }{
+	{"academic", "scholar arxiv pubmed ncbi semanticscholar jstor doi crossref researchgate openalex ssrn"},
+	{"code", "github gitlab stackoverflow stackexchange pkg.go.dev docs.rs crates npmjs pypi devdocs"},
+	{"reference", "wikipedia wiktionary dictionary merriam-webster oxford britannica thesaurus"},
+	{"translation", "translate deepl"},
+	{"video", "youtube vimeo"},
+	{"maps", "maps openstreetmap"},
+	{"shopping", "amazon ebay"},
+	{"social", "reddit twitter mastodon news.ycombinator"},
+}
+
+// engineCategory is the engine's configured category, or a guess from
+// its URL ("web" for general search engines).
+func engineCategory(name, engineURL string) string {
+	for _, engine := range config.SearchEngines {
+		if engine.Name == name && engine.Category != "" {
+			return engine.Category
+		}
+	}
+	u, err := url.Parse(strings.ReplaceAll(engineURL, "%s", ""))
+	if err != nil {
+		return "web"
+	}
+	where := strings.ToLower(u.Host + u.Path)
+	for _, c := range engineCategories {
+		for _, word := range strings.Fields(c.words) {
+			if strings.Contains(where, word) {
+				return c.category
+			}
+		}
+	}
+	return "web"
+}
+
+// loadExportRows reads the searches since the given time (all when zero),
+// oldest first.
+func loadExportRows(since time.Time) ([]exportRow, error) {
+	where, args := "", []any{}
+	if !since.IsZero() {
+		where, args = "WHERE s.timestamp >= ?", append(args, sqliteTime(since))
+	}
+	rows, err := db.Query(`
+		SELECT s.id, s.timestamp, s.session_id, s.query, s.engine_name, s.engine_url, s.final_url, s.page_title,
+			s.trigger_method, s.tags,
+			(SELECT COALESCE(SUM(`+openSecondsSQL+`), 0) FROM research_windows WHERE search_id = s.id),
+			(SELECT COALESCE(SUM(focus_seconds), 0) FROM research_windows WHERE search_id = s.id),
+			(SELECT COUNT(*) FROM navigations n JOIN research_windows w ON w.id = n.window_id WHERE w.search_id = s.id)
+		FROM searches s `+where+` ORDER BY s.timestamp, s.id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query searches: %w", err)
+	}
+	defer rows.Close()
+
+	var result []exportRow
+	var times []time.Time
+	for rows.Next() {
+		var r exportRow
+		var timestamp time.Time
+		var engineURL string
+		if err := rows.Scan(&r.ID, &timestamp, &r.SessionID, &r.Query, &r.EngineName, &engineURL, &r.FinalURL,
+			&r.PageTitle, &r.TriggerMethod, &r.Tags, &r.OpenSeconds, &r.FocusSeconds, &r.Pages); err != nil {
+			return nil, fmt.Errorf("failed to read search: %w", err)
+		}
+		unsealAll(&r.Query, &r.FinalURL, &r.PageTitle)
+		local := timestamp.Local()
+		r.Timestamp = local.Format(time.RFC3339)
+		r.Date = local.Format("2006-01-02")
+		r.Hour = local.Hour()
+		r.Weekday = local.Weekday().String()
+		r.QueryWords = len(strings.Fields(r.Query))
+		r.QueryChars = len([]rune(r.Query))
+		r.EngineCategory = engineCategory(r.EngineName, engineURL)
+		result = append(result, r)
+		times = append(times, timestamp)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read searches: %w", err)
+	}
+
+	// Session figures need every search of the session
+	type span struct {
+		first, last time.Time
+		searches    int
+	}
+	sessions := make(map[string]*span)
+	for i, r := range result {
+		s, ok := sessions[r.SessionID]
+		if !ok {
+			s = &span{first: times[i]}
+			sessions[r.SessionID] = s
+		}
+		s.last = times[i]
+		s.searches++
+	}
+	for i := range result {
+		s := sessions[result[i].SessionID]
+		result[i].SessionSearches = s.searches
+		result[i].SessionMinutes = int64(s.last.Sub(s.first).Minutes())
+	}
+	return result, nil
+}
+
+func writeExportCSV(w io.Writer, rows []exportRow) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(exportColumns); err != nil {
+		return err
+	}
+	for _, r := range rows {
+		if err := writer.Write(r.record()); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func writeExportJSONLines(w io.Writer, rows []exportRow) error {
+	encoder := json.NewEncoder(w)
+	for _, r := range rows {
+		if err := encoder.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeExportParquet has DuckDB convert a CSV export, as Parquet needs
+// more than the standard library has.
+func writeExportParquet(path string, rows []exportRow) error {
+	duckdb, err := exec.LookPath("duckdb")
+	if err != nil {
+		return fmt.Errorf("parquet export needs duckdb (https://duckdb.org) in $PATH; export CSV instead")
+	}
+	tmp, err := os.CreateTemp("", "rabbithole-export-*.csv")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := writeExportCSV(tmp, rows); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temporary CSV: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write temporary CSV: %w", err)
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	copySQL := fmt.Sprintf("COPY (SELECT * FROM read_csv(%s, header = true, auto_detect = true)) TO %s (FORMAT parquet)",
+		sqlString(tmp.Name()), sqlString(abs))
+	if out, err := combinedOutput(exec.Command(duckdb, "-c", copySQL)); err != nil {
+		return fmt.Errorf("duckdb failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// sqlString quotes a string literal for SQL.
+func sqlString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,138 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xproto"
+
+	"rabbithole/internal/x11"
+)
+
+// focusFlushInterval bounds how much focus time is lost if the daemon
+// stops while a research window has focus.
+const focusFlushInterval = time.Minute
+
+// openSecondsSQL is how long a closed research window was open. Windows
+// without closed_at are still open, or their tracker died, and don't count.
+const openSecondsSQL = "CAST(COALESCE((julianday(closed_at) - julianday(opened_at)) * 86400, 0) AS INTEGER)"
+
+// researchTime is the time spent down a rabbit hole.
+type researchTime struct {
+	OpenSeconds  int64 `json:"open_seconds"`
+	FocusSeconds int64 `json:"focus_seconds"`
+}
+
+func (t researchTime) String() string {
+	if t.FocusSeconds == 0 {
+		return formatSeconds(t.OpenSeconds) + " open"
+	}
+	return fmt.Sprintf("%s open, %s focused", formatSeconds(t.OpenSeconds), formatSeconds(t.FocusSeconds))
+}
+
+func formatSeconds(seconds int64) string {
+	d := time.Duration(seconds) * time.Second
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", seconds)
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	default:
+		return fmt.Sprintf("%dh %02dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+}
+
+// searchTime sums the time spent in a search's research windows.
+func searchTime(searchID int64) (researchTime, error) {
+	var t researchTime
+	err := db.QueryRow(
+		"SELECT COALESCE(SUM("+openSecondsSQL+"), 0), COALESCE(SUM(focus_seconds), 0) FROM research_windows WHERE search_id = ?",
+		searchID,
+	).Scan(&t.OpenSeconds, &t.FocusSeconds)
+	if err != nil {
+		return t, fmt.Errorf("failed to sum research time: %w", err)
+	}
+	return t, nil
+}
+
+// addFocusTime credits focus time to the newest research window row of a
+// window; in tab mode that's the newest tab. It also marks the window as
+// just focused, for the idle policy.
+func addFocusTime(windowID string, d time.Duration) {
+	seconds := int64(d.Round(time.Second) / time.Second)
+	_, err := execPrepared(`
+		UPDATE research_windows SET focus_seconds = focus_seconds + ?, focused_at = CURRENT_TIMESTAMP
+		WHERE id = (SELECT id FROM research_windows WHERE window_id = ? ORDER BY id DESC LIMIT 1)`,
+		seconds, windowID)
+	if err != nil {
+		slog.Error("Failed to record focus time", "window", windowID, "err", err)
+	}
+}
+
+// trackFocus follows _NET_ACTIVE_WINDOW and adds the time each research
+// window keeps focus to its focus_seconds.
+func (api *daemonAPI) trackFocus() error {
+	x, err := x11.Open()
+	if err != nil {
+		return err
+	}
+	defer x.Close()
+
+	err = x.WatchRoot()
+	if err != nil {
+		return fmt.Errorf("failed to watch the active window: %w", err)
+	}
+
+	events := make(chan xgb.Event)
+	lost := make(chan struct{})
+	go func() {
+		for {
+			event, xerr := x.Conn.WaitForEvent()
+			if event == nil && xerr == nil {
+				close(lost)
+				return
+			}
+			if event != nil {
+				events <- event
+			}
+		}
+	}()
+
+	focused := ""
+	since := time.Now()
+	// flush credits the time so far and starts counting again, switching
+	// to the research window that has focus now (or none)
+	flush := func() {
+		api.mu.Lock()
+		defer api.mu.Unlock()
+		now := time.Now()
+		if focused != "" {
+			addFocusTime(focused, now.Sub(since))
+		}
+		focused, since = "", now
+		if window, err := x.ActiveWindow(); err == nil {
+			if _, err := latestResearchWindow(x11.FormatWindowID(window)); err == nil {
+				focused = x11.FormatWindowID(window)
+				addFocusTime(focused, 0)
+			}
+		}
+	}
+	flush()
+
+	ticker := time.NewTicker(focusFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case event := <-events:
+			if event, ok := event.(xproto.PropertyNotifyEvent); ok && event.Atom == x.Atom("_NET_ACTIVE_WINDOW") {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-lost:
+			return fmt.Errorf("lost the X connection")
+		}
+	}
+}
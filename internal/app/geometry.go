@@ -0,0 +1,39 @@
+package app
+
+import (
+	"log/slog"
+
+	"rabbithole/internal/wm"
+)
+
+// saveEngineGeometry remembers where the user last put a window opened by
+// the search's engine.
+func saveEngineGeometry(searchID int64, g windowGeometry) {
+	_, err := db.Exec(`
+		INSERT INTO engine_geometry (engine_name, geometry)
+		SELECT engine_name, ? FROM searches WHERE id = ?
+		ON CONFLICT(engine_name) DO UPDATE SET geometry = excluded.geometry, updated_at = CURRENT_TIMESTAMP`,
+		g.String(), searchID)
+	if err != nil {
+		slog.Error("Failed to remember engine geometry", "err", err)
+		return
+	}
+	slog.Debug("Remembered window geometry", "search", searchID, "geometry", g.String())
+}
+
+// engineGeometry returns the remembered geometry for an engine, if any.
+func engineGeometry(engineName string) (windowGeometry, bool) {
+	conn := database()
+	if conn == nil {
+		return windowGeometry{}, false
+	}
+	var geometry string
+	if err := conn.QueryRow("SELECT geometry FROM engine_geometry WHERE engine_name = ?", engineName).Scan(&geometry); err != nil {
+		return windowGeometry{}, false
+	}
+	g, err := wm.ParseGeometry(geometry)
+	if err != nil || g.Width <= 0 || g.Height <= 0 {
+		return windowGeometry{}, false
+	}
+	return g, true
+}
@@ -0,0 +1,68 @@
+// Exposes window listing and placement to rabbithole over D-Bus, on
+// GNOME Shell's own connection (org.gnome.Shell). Installed by
+// `rabbithole setup --placement gnome`.
+
+import Gio from 'gi://Gio';
+import Meta from 'gi://Meta';
+import {Extension} from 'resource:///org/gnome/shell/extensions/extension.js';
+
+const PLACEMENT_PATH = '/org/rabbithole/Placement';
+
+const PLACEMENT_INTERFACE = `
+<node>
+  <interface name="org.rabbithole.Placement">
+    <method name="ListWindows">
+      <arg type="s" direction="out" name="windows"/>
+    </method>
+    <method name="MoveResize">
+      <arg type="t" direction="in" name="id"/>
+      <arg type="i" direction="in" name="x"/>
+      <arg type="i" direction="in" name="y"/>
+      <arg type="i" direction="in" name="width"/>
+      <arg type="i" direction="in" name="height"/>
+      <arg type="b" direction="out" name="found"/>
+    </method>
+  </interface>
+</node>`;
+
+class Placement {
+    _windows() {
+        return global.get_window_actors()
+            .map(actor => actor.meta_window)
+            .filter(window => window.get_window_type() === Meta.WindowType.NORMAL);
+    }
+
+    // ListWindows returns the normal windows as JSON: id, pid, class and
+    // title of each.
+    ListWindows() {
+        return JSON.stringify(this._windows().map(window => ({
+            id: window.get_id(),
+            pid: window.get_pid(),
+            class: window.get_wm_class() ?? '',
+            title: window.get_title() ?? '',
+        })));
+    }
+
+    // MoveResize un-maximizes the window and gives it the frame geometry.
+    MoveResize(id, x, y, width, height) {
+        const window = this._windows().find(w => w.get_id() === id);
+        if (!window)
+            return false;
+        if (window.get_maximized())
+            window.unmaximize(Meta.MaximizeFlags.BOTH);
+        window.move_resize_frame(true, x, y, width, height);
+        return true;
+    }
+}
+
+export default class RabbitholeExtension extends Extension {
+    enable() {
+        this._placement = Gio.DBusExportedObject.wrapJSObject(PLACEMENT_INTERFACE, new Placement());
+        this._placement.export(Gio.DBus.session, PLACEMENT_PATH);
+    }
+
+    disable() {
+        this._placement.unexport();
+        this._placement = null;
+    }
+}
@@ -0,0 +1,226 @@
+package app
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"rabbithole/internal/wm"
+)
+
+// GNOME Shell on Wayland lets no other program list or move windows, so a
+// small bundled extension does it for rabbithole over D-Bus.
+
+//go:embed gnome-extension
+var gnomeExtensionFiles embed.FS
+
+const (
+	gnomeExtensionUUID = "rabbithole@agustinfitipaldi.github.io"
+	gnomePlacementPath = "/org/rabbithole/Placement"
+	gnomePlacementName = "org.rabbithole.Placement"
+)
+
+func gnomeBackend() placementBackend {
+	backend := commandBackend(gnomeCommands)
+	backend.detect = detectWithGnome
+	return backend
+}
+
+func gnomeExtensionDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "gnome-shell", "extensions", gnomeExtensionUUID), nil
+}
+
+// gnomeCallArgs is the gdbus command line calling a method of the
+// extension.
+func gnomeCallArgs(method string, args ...string) []string {
+	return append([]string{"gdbus", "call", "--session", "--dest", "org.gnome.Shell",
+		"--object-path", gnomePlacementPath, "--method", gnomePlacementName + "." + method}, args...)
+}
+
+// gnomeWindow is a window as listed by the extension.
+type gnomeWindow struct {
+	ID    uint64 `json:"id"`
+	PID   int    `json:"pid"`
+	Class string `json:"class"`
+	Title string `json:"title"`
+}
+
+func gnomeWindows() ([]gnomeWindow, error) {
+	args := gnomeCallArgs("ListWindows")
+	out, err := commandOutput(exec.Command(args[0], args[1:]...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list GNOME windows (is the extension enabled? rabbithole setup --placement gnome): %w", err)
+	}
+	list, err := parseGVariantString(string(out))
+	if err != nil {
+		return nil, err
+	}
+	var windows []gnomeWindow
+	if err := json.Unmarshal([]byte(list), &windows); err != nil {
+		return nil, fmt.Errorf("failed to parse GNOME windows: %w", err)
+	}
+	return windows, nil
+}
+
+// parseGVariantString reads the string out of a gdbus reply like
+// ('text',). GVariant quotes with ' unless the text contains one and no ".
+func parseGVariantString(reply string) (string, error) {
+	reply = strings.TrimSpace(reply)
+	if !strings.HasPrefix(reply, "(") || len(reply) < 4 {
+		return "", fmt.Errorf("unexpected gdbus reply %q", reply)
+	}
+	quote := reply[1]
+	if quote != '\'' && quote != '"' {
+		return "", fmt.Errorf("unexpected gdbus reply %q", reply)
+	}
+	var b strings.Builder
+	for i := 2; i < len(reply); i++ {
+		c := reply[i]
+		switch {
+		case c == quote:
+			return b.String(), nil
+		case c == '\\' && i+1 < len(reply):
+			i++
+			switch reply[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case 'u':
+				if i+4 < len(reply) {
+					if r, err := strconv.ParseUint(reply[i+1:i+5], 16, 32); err == nil {
+						b.WriteRune(rune(r))
+						i += 4
+						continue
+					}
+				}
+				b.WriteByte('u')
+			default:
+				b.WriteByte(reply[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("unterminated string in gdbus reply")
+}
+
+// detectWithGnome launches the browser and polls the extension for its
+// new window, matched like X11 windows by PID and class.
+func detectWithGnome(class string, launch func() (int, error)) (string, error) {
+	before, err := gnomeWindows()
+	if err != nil {
+		return "", err
+	}
+	known := make(map[uint64]bool, len(before))
+	for _, w := range before {
+		known[w.ID] = true
+	}
+	m := wm.NewMatcher(class)
+
+	m.PID, err = launch()
+	if err != nil {
+		return "", err
+	}
+
+	deadline := time.Now().Add(windowDetectTimeout())
+	for time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+		windows, err := gnomeWindows()
+		if err != nil {
+			return "", err
+		}
+		for _, w := range windows {
+			if known[w.ID] {
+				continue
+			}
+			known[w.ID] = true
+			if m.Matches(w.PID, w.Class) {
+				return strconv.FormatUint(w.ID, 10), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("failed to detect new %s window: timeout waiting for it to open", class)
+}
+
+// gnomeCommands moves and sizes the window through the extension.
+func gnomeCommands(windowID string, g windowGeometry) ([][]string, error) {
+	if config.Placement.Mode != "" && config.Placement.Mode != "float" {
+		return nil, fmt.Errorf("unsupported placement mode %q for gnome (use float)", config.Placement.Mode)
+	}
+	return [][]string{gnomeCallArgs("MoveResize", windowID,
+		strconv.Itoa(g.X), strconv.Itoa(g.Y), strconv.Itoa(g.Width), strconv.Itoa(g.Height))}, nil
+}
+
+// installGnomeExtension copies the bundled extension into the user's
+// extensions and enables it. GNOME Shell on Wayland only finds new
+// extensions after logging in again.
+func installGnomeExtension() error {
+	dir, err := gnomeExtensionDir()
+	if err != nil {
+		return fmt.Errorf("couldn't determine the GNOME extensions directory: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	files, err := fs.Sub(gnomeExtensionFiles, "gnome-extension")
+	if err != nil {
+		return err
+	}
+	err = fs.WalkDir(files, ".", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(files, path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dir, path), data, 0644)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to install the GNOME extension: %w", err)
+	}
+	fmt.Printf("✅ Installed the GNOME Shell extension in %s\n", dir)
+
+	if out, err := combinedOutput(exec.Command("gnome-extensions", "enable", gnomeExtensionUUID)); err != nil {
+		reason := strings.TrimSpace(string(out))
+		if reason == "" {
+			reason = err.Error()
+		}
+		fmt.Printf("⚠️  Couldn't enable it yet (%s); log out and back in, then run: gnome-extensions enable %s\n",
+			reason, gnomeExtensionUUID)
+		return nil
+	}
+	fmt.Println("✅ Enabled it (on Wayland, log out and back in if GNOME Shell doesn't load it)")
+	return nil
+}
+
+func removeGnomeExtension() error {
+	dir, err := gnomeExtensionDir()
+	if err != nil {
+		return fmt.Errorf("couldn't determine the GNOME extensions directory: %w", err)
+	}
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		fmt.Println("The GNOME Shell extension isn't installed")
+		return nil
+	}
+	runCommand(exec.Command("gnome-extensions", "disable", gnomeExtensionUUID))
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", dir, err)
+	}
+	fmt.Printf("🗑️  Removed %s\n", dir)
+	return nil
+}
@@ -0,0 +1,233 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// followUpGap is how soon after a search the next one counts as following
+// it up, when they share no tag.
+const followUpGap = 10 * time.Minute
+
+// Layout of the JSON Canvas export, in canvas pixels.
+const (
+	canvasNodeWidth  = 280
+	canvasNodeHeight = 90
+	canvasColumn     = 340
+	canvasRow        = 120
+)
+
+// graphNode is a session or a search in the research graph.
+type graphNode struct {
+	ID    string
+	Kind  string // "session" or "search"
+	Label string
+	Depth int
+	Row   int
+}
+
+// graphEdge links a search to the search it followed up, or to its
+// session when it started a new rabbit hole.
+type graphEdge struct {
+	From, To string
+	// Label says why: a shared tag or the time since the earlier search
+	Label string
+}
+
+type researchGraph struct {
+	Nodes []graphNode
+	Edges []graphEdge
+}
+
+type graphSearch struct {
+	id        int64
+	query     string
+	engine    string
+	session   string
+	tags      []string
+	timestamp time.Time
+	pages     int
+}
+
+// loadResearchGraph builds the graph of the searches from the sessions
+// since (or of the one session when since is zero). Each search hangs off
+// the latest earlier search of its session sharing a tag, or else the one
+// right before it if that was at most followUpGap earlier; otherwise it
+// starts a branch from the session.
+func loadResearchGraph(session string, since time.Time) (researchGraph, error) {
+	where, arg := "session_id = ?", any(session)
+	if !since.IsZero() {
+		where, arg = "timestamp >= ?", sqliteTime(since)
+	}
+	rows, err := db.Query(`
+		SELECT s.id, s.query, s.engine_name, s.session_id, s.tags, s.timestamp,
+			(SELECT COUNT(*) FROM navigations n JOIN research_windows w ON w.id = n.window_id WHERE w.search_id = s.id)
+		FROM searches s WHERE `+where+` ORDER BY s.timestamp, s.id`, arg)
+	if err != nil {
+		return researchGraph{}, fmt.Errorf("failed to query searches: %w", err)
+	}
+	defer rows.Close()
+
+	var searches []graphSearch
+	for rows.Next() {
+		var s graphSearch
+		var tags string
+		if err := rows.Scan(&s.id, &s.query, &s.engine, &s.session, &tags, &s.timestamp, &s.pages); err != nil {
+			return researchGraph{}, fmt.Errorf("failed to read search: %w", err)
+		}
+		s.query = unseal(s.query)
+		s.tags = splitTags(tags)
+		searches = append(searches, s)
+	}
+	if err := rows.Err(); err != nil {
+		return researchGraph{}, fmt.Errorf("failed to read searches: %w", err)
+	}
+
+	var g researchGraph
+	depth := make(map[string]int)
+	for i, s := range searches {
+		sessionID := "session:" + s.session
+		if _, ok := depth[sessionID]; !ok {
+			depth[sessionID] = 0
+			g.Nodes = append(g.Nodes, graphNode{ID: sessionID, Kind: "session", Label: s.session, Row: i})
+		}
+
+		parent, label := sessionID, ""
+		if j, tag := lastSharingTag(searches[:i], s); j >= 0 {
+			parent, label = searchNodeID(searches[j]), "#"+tag
+		} else if i > 0 && searches[i-1].session == s.session && s.timestamp.Sub(searches[i-1].timestamp) <= followUpGap {
+			parent, label = searchNodeID(searches[i-1]), formatGap(s.timestamp.Sub(searches[i-1].timestamp))
+		}
+
+		id := searchNodeID(s)
+		depth[id] = depth[parent] + 1
+		g.Nodes = append(g.Nodes, graphNode{ID: id, Kind: "search", Label: searchNodeLabel(s), Depth: depth[id], Row: i})
+		g.Edges = append(g.Edges, graphEdge{From: parent, To: id, Label: label})
+	}
+	return g, nil
+}
+
+// lastSharingTag finds the latest of earlier in s's session that has one
+// of its tags, or -1.
+func lastSharingTag(earlier []graphSearch, s graphSearch) (int, string) {
+	for j := len(earlier) - 1; j >= 0; j-- {
+		if earlier[j].session != s.session {
+			break
+		}
+		for _, tag := range s.tags {
+			for _, other := range earlier[j].tags {
+				if tag == other {
+					return j, tag
+				}
+			}
+		}
+	}
+	return -1, ""
+}
+
+func searchNodeID(s graphSearch) string {
+	return fmt.Sprintf("search:%d", s.id)
+}
+
+func searchNodeLabel(s graphSearch) string {
+	label := fmt.Sprintf("%s\n%s · %s", s.query, s.engine, s.timestamp.Local().Format("15:04"))
+	if s.pages > 0 {
+		label += fmt.Sprintf(" · %d pages", s.pages)
+	}
+	return label
+}
+
+func formatGap(d time.Duration) string {
+	if d < time.Minute {
+		return fmt.Sprintf("+%ds", int(d.Seconds()))
+	}
+	return fmt.Sprintf("+%dm", int(d.Minutes()))
+}
+
+// writeDOT renders the graph for Graphviz, e.g. `dot -Tsvg`.
+func writeDOT(w io.Writer, g researchGraph) error {
+	var b strings.Builder
+	b.WriteString("digraph rabbithole {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box, style=rounded, fontname=\"sans-serif\"];\n")
+	b.WriteString("  edge [fontname=\"sans-serif\", fontsize=10];\n")
+	for _, n := range g.Nodes {
+		attrs := ""
+		if n.Kind == "session" {
+			attrs = ", shape=ellipse, style=filled, fillcolor=\"#eeeeee\""
+		}
+		fmt.Fprintf(&b, "  %s [label=%s%s];\n", dotQuote(n.ID), dotQuote(n.Label), attrs)
+	}
+	for _, e := range g.Edges {
+		if e.Label == "" {
+			fmt.Fprintf(&b, "  %s -> %s;\n", dotQuote(e.From), dotQuote(e.To))
+		} else {
+			fmt.Fprintf(&b, "  %s -> %s [label=%s];\n", dotQuote(e.From), dotQuote(e.To), dotQuote(e.Label))
+		}
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func dotQuote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+	return `"` + s + `"`
+}
+
+// canvasNode and canvasEdge follow the JSON Canvas format of Obsidian's
+// canvases (jsoncanvas.org).
+type canvasNode struct {
+	ID     string `json:"id"`
+	Type   string `json:"type"`
+	Text   string `json:"text"`
+	X      int    `json:"x"`
+	Y      int    `json:"y"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Color  string `json:"color,omitempty"`
+}
+
+type canvasEdge struct {
+	ID       string `json:"id"`
+	FromNode string `json:"fromNode"`
+	ToNode   string `json:"toNode"`
+	FromSide string `json:"fromSide"`
+	ToSide   string `json:"toSide"`
+	Label    string `json:"label,omitempty"`
+}
+
+// writeCanvas renders the graph as a JSON Canvas, laid out with depth
+// left to right and time top to bottom.
+func writeCanvas(w io.Writer, g researchGraph) error {
+	canvas := struct {
+		Nodes []canvasNode `json:"nodes"`
+		Edges []canvasEdge `json:"edges"`
+	}{Nodes: []canvasNode{}, Edges: []canvasEdge{}}
+	for _, n := range g.Nodes {
+		node := canvasNode{
+			ID: n.ID, Type: "text", Text: n.Label,
+			X: n.Depth * canvasColumn, Y: n.Row * canvasRow,
+			Width: canvasNodeWidth, Height: canvasNodeHeight,
+		}
+		if n.Kind == "session" {
+			node.Text, node.Color = "## "+n.Label, "4"
+		} else {
+			query, rest, _ := strings.Cut(n.Label, "\n")
+			node.Text = "**" + query + "**\n" + rest
+		}
+		canvas.Nodes = append(canvas.Nodes, node)
+	}
+	for i, e := range g.Edges {
+		canvas.Edges = append(canvas.Edges, canvasEdge{
+			ID: fmt.Sprintf("edge:%d", i), FromNode: e.From, ToNode: e.To,
+			FromSide: "right", ToSide: "left", Label: e.Label,
+		})
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(canvas)
+}
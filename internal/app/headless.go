@@ -0,0 +1,175 @@
+package app
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"rabbithole/internal/browser"
+)
+
+// headless is set by --headless: the whole search pipeline runs, including
+// logging, hooks and webhooks, but the launcher is answered from --answer
+// and stdin, and the browser and window tools are simulated. Selections are
+// still read for real, from a virtual display such as Xvfb when there's no
+// $DISPLAY, so CI can test capture too.
+var headless bool
+
+// headlessPrograms are never started in headless mode, besides the
+// launcher, browsers and rabbithole itself.
+var headlessPrograms = map[string]bool{
+	"wmctrl": true, "xdotool": true, "xprop": true,
+	"i3-msg": true, "swaymsg": true, "bspc": true, "herbstclient": true, "hyprctl": true,
+	"notify-send": true, "gdbus": true, "spd-say": true, "systemctl": true,
+}
+
+// headlessWindowBase is where simulated window IDs start, well above what
+// X servers hand out.
+const headlessWindowBase = 0x7f000000
+
+var (
+	headlessSimulated = &fakeRunner{respond: respondHeadless}
+	headlessAnswers   []string
+	headlessStdin     *bufio.Reader
+	headlessMu        sync.Mutex
+	headlessWindows   int
+)
+
+var displayArgPattern = regexp.MustCompile(`^:\d+(\.\d+)?$`)
+
+// startHeadless switches to headless mode with the given launcher answers.
+func startHeadless(answers []string) {
+	headless = true
+	headlessAnswers = answers
+	headlessStdin = bufio.NewReader(os.Stdin)
+	runner = headlessRunner{}
+
+	if os.Getenv("DISPLAY") == "" {
+		if display := findVirtualDisplay(); display != "" {
+			os.Setenv("DISPLAY", display)
+			slog.Info("Using virtual display", "display", display)
+		}
+	}
+}
+
+// findVirtualDisplay returns the display of a running Xvfb, e.g. ":99".
+func findVirtualDisplay() string {
+	comms, _ := filepath.Glob("/proc/[0-9]*/comm")
+	for _, comm := range comms {
+		name, err := os.ReadFile(comm)
+		if err != nil || strings.TrimSpace(string(name)) != "Xvfb" {
+			continue
+		}
+		cmdline, err := os.ReadFile(filepath.Join(filepath.Dir(comm), "cmdline"))
+		if err != nil {
+			continue
+		}
+		for _, arg := range strings.Split(string(cmdline), "\x00") {
+			if displayArgPattern.MatchString(arg) {
+				return arg
+			}
+		}
+	}
+	return ""
+}
+
+// headlessRunner simulates the programs that need a desktop and runs the
+// rest (selection tools, hooks, scripts) for real.
+type headlessRunner struct{}
+
+func (headlessRunner) pick(cmd *exec.Cmd) CommandRunner {
+	if simulatedInHeadless(filepath.Base(cmd.Args[0])) {
+		return headlessSimulated
+	}
+	return execRunner{}
+}
+
+func (r headlessRunner) Run(cmd *exec.Cmd) error   { return r.pick(cmd).Run(cmd) }
+func (r headlessRunner) Start(cmd *exec.Cmd) error { return r.pick(cmd).Start(cmd) }
+func (r headlessRunner) Wait(cmd *exec.Cmd) error  { return r.pick(cmd).Wait(cmd) }
+
+func simulatedInHeadless(name string) bool {
+	if headlessPrograms[name] || name == appName || isLauncherCommand(name) {
+		return true
+	}
+	if self, err := os.Executable(); err == nil && name == filepath.Base(self) {
+		return true
+	}
+	return isBrowserCommand(name)
+}
+
+func isLauncherCommand(name string) bool {
+	driver, err := currentLauncher()
+	return err == nil && filepath.Base(driver.Command) == name
+}
+
+func isBrowserCommand(name string) bool {
+	if name == browser.Default || name == filepath.Base(config.Behavior.Browser) {
+		return true
+	}
+	for _, engine := range config.SearchEngines {
+		if engine.Browser != "" && name == filepath.Base(engine.Browser) {
+			return true
+		}
+	}
+	return strings.Contains(name, "firefox") || browserLaunch{Browser: name}.Chromium()
+}
+
+// respondHeadless answers simulated commands. Only the launcher has
+// anything to say; window lists and the like come back empty.
+func respondHeadless(args []string, stdin string) (string, error) {
+	if !isLauncherCommand(filepath.Base(args[0])) {
+		return "", nil
+	}
+	answer, err := nextHeadlessAnswer()
+	if err != nil {
+		return "", err
+	}
+	slog.Debug("Answered launcher", "answer", answer)
+	return answer + "\n", nil
+}
+
+// nextHeadlessAnswer takes the next --answer, then the next line of stdin.
+func nextHeadlessAnswer() (string, error) {
+	headlessMu.Lock()
+	defer headlessMu.Unlock()
+	if len(headlessAnswers) > 0 {
+		answer := headlessAnswers[0]
+		headlessAnswers = headlessAnswers[1:]
+		return answer, nil
+	}
+	line, err := headlessStdin.ReadString('\n')
+	if errors.Is(err, io.EOF) && line == "" {
+		return "", fmt.Errorf("no answer left for the launcher (use --answer or stdin)")
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read launcher answer: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// detectHeadless "launches" the browser and makes up a window for it.
+func detectHeadless(class string, launch func() (int, error)) (string, error) {
+	if _, err := launch(); err != nil {
+		return "", err
+	}
+	headlessMu.Lock()
+	defer headlessMu.Unlock()
+	headlessWindows++
+	return fmt.Sprintf("0x%08x", headlessWindowBase+headlessWindows), nil
+}
+
+// printHeadlessCommands lists what was simulated, for tests to check.
+func printHeadlessCommands(w io.Writer) {
+	for _, line := range headlessSimulated.commands() {
+		fmt.Fprintf(w, "🧪 %s\n", line)
+	}
+}
@@ -0,0 +1,209 @@
+package app
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const headlessTestProfile = "headless-test"
+
+// setupHeadless points rabbithole at a throwaway config and database and
+// puts every global a headless run touches back afterwards. It returns the
+// database path.
+func setupHeadless(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_RUNTIME_DIR", home)
+	t.Setenv("RABBITHOLE_PROFILE", "")
+	// startHeadless may point DISPLAY at a running Xvfb
+	t.Setenv("DISPLAY", os.Getenv("DISPLAY"))
+
+	dbPath := filepath.Join(home, "searches.db")
+	cfg := map[string]any{
+		"search_engines": []map[string]string{
+			{"key": "w", "name": "Wikipedia", "url": "https://en.wikipedia.org/w/index.php?search=%s"},
+			{"key": "g", "name": "Google", "url": "https://www.google.com/search?q=%s", "browser": "chromium"},
+		},
+		"database":  map[string]string{"path": dbPath},
+		"placement": map[string]string{"backend": "wmctrl"},
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configDir := filepath.Join(home, ".config", "rabbithole", "profiles", headlessTestProfile)
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "config.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The log goes below the real home directory, into the test profile's
+	// directory; remove whatever of it the test creates
+	createdDir := ""
+	if path, err := logFilePath(); err == nil {
+		dir := filepath.Join(filepath.Dir(path), "profiles", headlessTestProfile)
+		for ; ; dir = filepath.Dir(dir) {
+			if _, err := os.Stat(dir); err == nil {
+				break
+			}
+			createdDir = dir
+		}
+	}
+
+	// Simulated window IDs count from the first one again
+	headlessWindows = 0
+	savedConfig, savedRunner, savedLogger, savedStdin := config, runner, slog.Default(), os.Stdin
+	t.Cleanup(func() {
+		closeDatabase()
+		config, runner, os.Stdin = savedConfig, savedRunner, savedStdin
+		slog.SetDefault(savedLogger)
+		headless, dryRun, jsonOutput, profile = false, false, false, ""
+		headlessAnswers, headlessWindows = nil, 0
+		headlessSimulated = &fakeRunner{respond: respondHeadless}
+		if createdDir != "" {
+			os.RemoveAll(createdDir)
+		}
+	})
+	return dbPath
+}
+
+// runHeadless runs rabbithole with --headless and the given arguments.
+func runHeadless(t *testing.T, args ...string) error {
+	t.Helper()
+	root := createRootCmd()
+	root.SilenceUsage, root.SilenceErrors = true, true
+	root.SetArgs(append([]string{"--headless", "--profile", headlessTestProfile}, args...))
+	return root.Execute()
+}
+
+// headlessStdinFrom makes stdin read the given lines.
+func headlessStdinFrom(t *testing.T, lines ...string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "stdin")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	os.Stdin = f
+}
+
+type loggedSearch struct {
+	Query, Engine, URL, Trigger, WindowID string
+}
+
+func loggedSearches(t *testing.T) []loggedSearch {
+	t.Helper()
+	if err := initDatabase(); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.Query(`
+		SELECT s.query, s.engine_name, s.final_url, s.trigger_method, COALESCE(w.window_id, '')
+		FROM searches s LEFT JOIN research_windows w ON w.search_id = s.id
+		ORDER BY s.id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var searches []loggedSearch
+	for rows.Next() {
+		var s loggedSearch
+		if err := rows.Scan(&s.Query, &s.Engine, &s.URL, &s.Trigger, &s.WindowID); err != nil {
+			t.Fatal(err)
+		}
+		s.Query, s.URL = unseal(s.Query), unseal(s.URL)
+		searches = append(searches, s)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return searches
+}
+
+func TestHeadlessSearch(t *testing.T) {
+	setupHeadless(t)
+	headlessStdinFrom(t)
+
+	if err := runHeadless(t, "search", "--empty", "--answer", "w: Wikipedia", "--answer", "rabbit holes"); err != nil {
+		t.Fatal(err)
+	}
+
+	searches := loggedSearches(t)
+	want := []loggedSearch{{
+		Query:    "rabbit holes",
+		Engine:   "Wikipedia",
+		URL:      "https://en.wikipedia.org/w/index.php?search=rabbit+holes",
+		Trigger:  "manual",
+		WindowID: "0x7f000001",
+	}}
+	if !slices.Equal(searches, want) {
+		t.Errorf("logged %+v\nwant %+v", searches, want)
+	}
+
+	commands := headlessSimulated.commands()
+	if len(commands) < 3 || !strings.HasPrefix(commands[0], "dmenu ") || !strings.HasPrefix(commands[1], "dmenu ") {
+		t.Fatalf("launcher wasn't asked twice: %q", commands)
+	}
+	for _, line := range []string{
+		"firefox --new-window https://en.wikipedia.org/w/index.php?search=rabbit+holes",
+		"wmctrl -i -r 0x7f000001 -b remove,maximized_vert,maximized_horz",
+	} {
+		if !slices.Contains(commands, line) {
+			t.Errorf("%q wasn't simulated in %q", line, commands)
+		}
+	}
+}
+
+func TestHeadlessAnswersFromStdin(t *testing.T) {
+	setupHeadless(t)
+	headlessStdinFrom(t, "g", "second window")
+
+	if err := runHeadless(t, "search", "--query", "first window", "--answer", "w"); err != nil {
+		t.Fatal(err)
+	}
+	if err := runHeadless(t, "search", "--empty"); err != nil {
+		t.Fatal(err)
+	}
+
+	searches := loggedSearches(t)
+	if len(searches) != 2 {
+		t.Fatalf("logged %+v, want 2 searches", searches)
+	}
+	if s := searches[0]; s.Query != "first window" || s.Engine != "Wikipedia" || s.Trigger != "argument" || s.WindowID != "0x7f000001" {
+		t.Errorf("first search %+v", s)
+	}
+	if s := searches[1]; s.Query != "second window" || s.Engine != "Google" || s.WindowID != "0x7f000002" {
+		t.Errorf("second search %+v", s)
+	}
+	if !slices.Contains(headlessSimulated.commands(), "chromium --new-window https://www.google.com/search?q=second+window") {
+		t.Errorf("chromium wasn't simulated in %q", headlessSimulated.commands())
+	}
+}
+
+func TestHeadlessNoAnswerLeft(t *testing.T) {
+	setupHeadless(t)
+	headlessStdinFrom(t)
+
+	if err := runHeadless(t, "search", "--query", "unanswered"); err == nil {
+		t.Fatal("expected the search to fail without an answer for the launcher")
+	}
+	if searches := loggedSearches(t); len(searches) != 0 {
+		t.Errorf("logged %+v for a cancelled search", searches)
+	}
+	for _, line := range headlessSimulated.commands() {
+		if strings.HasPrefix(line, "firefox ") {
+			t.Errorf("browser started for a cancelled search: %q", line)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	healthReportInterval = 7 * 24 * time.Hour
+	healthBackupsToKeep  = 4
+)
+
+type healthReport struct {
+	Timestamp  time.Time
+	SizeBytes  int64
+	Integrity  string
+	BackupPath string
+	BackupOK   bool
+	Issues     []string
+}
+
+func backupDir() string {
+	if config.Database.BackupDir != "" {
+		return config.Database.BackupDir
+	}
+	return filepath.Join(filepath.Dir(config.Database.Path), "backups")
+}
+
+func integrityCheck(conn *sql.DB) (string, error) {
+	var result string
+	if err := conn.QueryRow("PRAGMA integrity_check").Scan(&result); err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+// backupDatabase writes a consistent copy of the database with VACUUM INTO
+// and verifies it by running an integrity check and comparing row counts.
+func backupDatabase() (string, error) {
+	dir := backupDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	backupPath := filepath.Join(dir, fmt.Sprintf("searches-%s.db", time.Now().Format("2006-01-02")))
+	os.Remove(backupPath) // VACUUM INTO refuses to overwrite
+	if _, err := db.Exec("VACUUM INTO ?", backupPath); err != nil {
+		return backupPath, fmt.Errorf("backup failed: %w", err)
+	}
+
+	backup, err := sql.Open("sqlite", backupPath)
+	if err != nil {
+		return backupPath, fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer backup.Close()
+
+	if result, err := integrityCheck(backup); err != nil || result != "ok" {
+		return backupPath, fmt.Errorf("backup integrity check failed: %v %s", err, result)
+	}
+
+	var original, copied int
+	db.QueryRow("SELECT COUNT(*) FROM searches").Scan(&original)
+	if err := backup.QueryRow("SELECT COUNT(*) FROM searches").Scan(&copied); err != nil {
+		return backupPath, fmt.Errorf("failed to read backup: %w", err)
+	}
+	if copied != original {
+		return backupPath, fmt.Errorf("backup has %d searches, database has %d", copied, original)
+	}
+
+	pruneBackups(dir)
+	return backupPath, nil
+}
+
+func pruneBackups(dir string) {
+	matches, err := filepath.Glob(filepath.Join(dir, "searches-*.db"))
+	if err != nil || len(matches) <= healthBackupsToKeep {
+		return
+	}
+	sort.Strings(matches) // date-stamped names sort chronologically
+	for _, old := range matches[:len(matches)-healthBackupsToKeep] {
+		if err := os.Remove(old); err != nil {
+			slog.Warn("Failed to remove old backup", "path", old, "err", err)
+		}
+	}
+}
+
+func runHealthReport() (healthReport, error) {
+	report := healthReport{Timestamp: time.Now()}
+
+	info, err := os.Stat(config.Database.Path)
+	if err != nil {
+		return report, fmt.Errorf("failed to stat database: %w", err)
+	}
+	report.SizeBytes = info.Size()
+
+	report.Integrity, err = integrityCheck(db)
+	if err != nil {
+		report.Integrity = err.Error()
+	}
+	if report.Integrity != "ok" {
+		report.Issues = append(report.Issues, fmt.Sprintf("integrity check failed: %s", report.Integrity))
+	}
+
+	// Compare against the last report that is at least a week old
+	var previousSize int64
+	err = db.QueryRow(
+		"SELECT size_bytes FROM health_reports WHERE timestamp <= datetime('now', '-7 days') ORDER BY timestamp DESC LIMIT 1",
+	).Scan(&previousSize)
+	if err == nil && previousSize > 0 && report.SizeBytes >= 2*previousSize {
+		report.Issues = append(report.Issues, fmt.Sprintf("database grew from %d to %d bytes in a week", previousSize, report.SizeBytes))
+	}
+
+	report.BackupPath, err = backupDatabase()
+	report.BackupOK = err == nil
+	if err != nil {
+		report.Issues = append(report.Issues, err.Error())
+	}
+
+	_, err = db.Exec(
+		"INSERT INTO health_reports (size_bytes, integrity, backup_path, backup_ok, issues) VALUES (?, ?, ?, ?, ?)",
+		report.SizeBytes, report.Integrity, report.BackupPath, report.BackupOK, strings.Join(report.Issues, "; "),
+	)
+	if err != nil {
+		return report, fmt.Errorf("failed to record health report: %w", err)
+	}
+
+	return report, nil
+}
+
+// runHealthReportIfDue runs the weekly health report when the last one is
+// older than healthReportInterval. Problems are logged and surfaced as a
+// desktop notification; a healthy database stays silent.
+func runHealthReportIfDue() {
+	var recent int
+	err := db.QueryRow(
+		"SELECT COUNT(*) FROM health_reports WHERE timestamp > datetime('now', ?)",
+		fmt.Sprintf("-%d seconds", int(healthReportInterval.Seconds())),
+	).Scan(&recent)
+	if err != nil || recent > 0 {
+		return
+	}
+
+	report, err := runHealthReport()
+	if err != nil {
+		slog.Error("Health report failed", "err", err)
+		notifyUser("Rabbithole database health", err.Error())
+		return
+	}
+
+	if len(report.Issues) == 0 {
+		slog.Info("Health report OK", "size_bytes", report.SizeBytes, "backup", report.BackupPath)
+		return
+	}
+
+	slog.Warn("Health report found issues", "issues", strings.Join(report.Issues, "; "))
+	notifyUser("Rabbithole database needs attention", strings.Join(report.Issues, "\n"))
+}
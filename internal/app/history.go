@@ -0,0 +1,77 @@
+package app
+
+import (
+	"fmt"
+	"time"
+)
+
+type historyEntry struct {
+	ID            int64        `json:"id"`
+	Query         string       `json:"query"`
+	EngineName    string       `json:"engine_name"`
+	EngineURL     string       `json:"engine_url"`
+	FinalURL      string       `json:"final_url"`
+	PageTitle     string       `json:"page_title"`
+	TriggerMethod string       `json:"trigger_method"`
+	SessionID     string       `json:"session_id"`
+	Tags          []string     `json:"tags"`
+	Timestamp     time.Time    `json:"timestamp"`
+	Time          researchTime `json:"time"`
+}
+
+func loadHistory(limit int) ([]historyEntry, error) {
+	rows, err := db.Query(`
+		SELECT id, query, engine_name, engine_url, final_url, page_title, trigger_method, session_id, tags, timestamp,
+			(SELECT COALESCE(SUM(`+openSecondsSQL+`), 0) FROM research_windows w WHERE w.search_id = searches.id),
+			(SELECT COALESCE(SUM(focus_seconds), 0) FROM research_windows w WHERE w.search_id = searches.id)
+		FROM searches ORDER BY id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query history: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []historyEntry
+	for rows.Next() {
+		var e historyEntry
+		var tags string
+		err := rows.Scan(&e.ID, &e.Query, &e.EngineName, &e.EngineURL, &e.FinalURL,
+			&e.PageTitle, &e.TriggerMethod, &e.SessionID, &tags, &e.Timestamp, &e.Time.OpenSeconds, &e.Time.FocusSeconds)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read history: %w", err)
+		}
+		unsealAll(&e.Query, &e.FinalURL, &e.PageTitle)
+		e.Tags = splitTags(tags)
+		if e.Tags == nil {
+			e.Tags = []string{}
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func printHistory(entries []historyEntry) error {
+	if jsonOutput {
+		if entries == nil {
+			entries = []historyEntry{}
+		}
+		return printJSON(entries)
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No searches yet.")
+		return nil
+	}
+	for _, e := range entries {
+		fmt.Printf("%s  %-12s %s\n", e.Timestamp.Local().Format("2006-01-02 15:04"), e.EngineName, e.Query)
+		if e.PageTitle != "" {
+			fmt.Printf("    %s\n", e.PageTitle)
+		}
+		if e.FinalURL != "" {
+			fmt.Printf("    %s\n", e.FinalURL)
+		}
+		if e.Time.OpenSeconds > 0 {
+			fmt.Printf("    ⏱️  %s\n", e.Time)
+		}
+	}
+	return nil
+}
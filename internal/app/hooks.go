@@ -0,0 +1,39 @@
+package app
+
+import (
+	"log/slog"
+	"os"
+	"os/exec"
+
+	"rabbithole/internal/launcher"
+)
+
+// runHook starts a configured hook command for a lifecycle event, with
+// the event's data in RABBITHOLE_* environment variables given as name,
+// value pairs. Hooks run in the background and outlive the CLI, so a slow
+// script never delays a search.
+func runHook(event, command string, vars ...string) {
+	if command == "" || dryRun {
+		return
+	}
+	words := launcher.SplitCommandLine(command)
+	if len(words) == 0 {
+		return
+	}
+
+	env := append(os.Environ(), "RABBITHOLE_EVENT="+event)
+	for i := 0; i+1 < len(vars); i += 2 {
+		env = append(env, "RABBITHOLE_"+vars[i]+"="+vars[i+1])
+	}
+	cmd := exec.Command(expandHome(words[0]), words[1:]...)
+	cmd.Env = env
+	if err := startCommand(cmd); err != nil {
+		slog.Warn("Failed to run hook", "event", event, "command", words[0], "err", err)
+		return
+	}
+	go func() {
+		if err := waitCommand(cmd); err != nil {
+			slog.Warn("Hook failed", "event", event, "command", words[0], "err", err)
+		}
+	}()
+}
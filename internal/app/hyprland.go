@@ -0,0 +1,198 @@
+package app
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"rabbithole/internal/wm"
+)
+
+func hyprlandBackend() placementBackend {
+	backend := commandBackend(hyprlandCommands)
+	backend.detect = detectWithHyprland
+	return backend
+}
+
+// hyprlandSocket2 returns the path of Hyprland's event socket, which lives
+// under $XDG_RUNTIME_DIR/hypr on current versions and /tmp/hypr on older ones.
+func hyprlandSocket2() (string, error) {
+	signature := os.Getenv("HYPRLAND_INSTANCE_SIGNATURE")
+	if signature == "" {
+		return "", fmt.Errorf("HYPRLAND_INSTANCE_SIGNATURE is not set (is Hyprland running?)")
+	}
+	for _, dir := range []string{os.Getenv("XDG_RUNTIME_DIR"), "/tmp"} {
+		if dir == "" {
+			continue
+		}
+		path := filepath.Join(dir, "hypr", signature, ".socket2.sock")
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("couldn't find the Hyprland event socket for instance %s", signature)
+}
+
+// detectWithHyprland subscribes to the event stream before launching the
+// browser, so the openwindow event for its window can't be missed.
+func detectWithHyprland(class string, launch func() (int, error)) (string, error) {
+	path, err := hyprlandSocket2()
+	if err != nil {
+		return "", err
+	}
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to %s: %w", path, err)
+	}
+	defer conn.Close()
+
+	m := wm.NewMatcher(class)
+
+	m.PID, err = launch()
+	if err != nil {
+		return "", err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(windowDetectTimeout()))
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		// openwindow>>ADDRESS,WORKSPACE,CLASS,TITLE
+		event, data, ok := strings.Cut(scanner.Text(), ">>")
+		if !ok || event != "openwindow" {
+			continue
+		}
+		fields := strings.SplitN(data, ",", 4)
+		if len(fields) < 4 {
+			continue
+		}
+		address := "0x" + fields[0]
+		if m.Matches(hyprlandWindowPID(address), fields[2]) {
+			return address, nil
+		}
+	}
+	return "", fmt.Errorf("failed to detect new %s window: %v", class, scanner.Err())
+}
+
+// hyprlandClient is a window as listed by hyprctl clients -j.
+type hyprlandClient struct {
+	Address string `json:"address"`
+	PID     int    `json:"pid"`
+	Class   string `json:"class"`
+	Title   string `json:"title"`
+	At      [2]int `json:"at"`
+	Size    [2]int `json:"size"`
+}
+
+func hyprlandClients() ([]hyprlandClient, error) {
+	out, err := commandOutput(exec.Command("hyprctl", "clients", "-j"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Hyprland clients: %w", err)
+	}
+	var clients []hyprlandClient
+	if err := json.Unmarshal(out, &clients); err != nil {
+		return nil, fmt.Errorf("failed to parse Hyprland clients: %w", err)
+	}
+	return clients, nil
+}
+
+// hyprlandWindow looks up a window by its address.
+func hyprlandWindow(address string) (hyprlandClient, error) {
+	clients, err := hyprlandClients()
+	if err != nil {
+		return hyprlandClient{}, err
+	}
+	for _, c := range clients {
+		if c.Address == address {
+			return c, nil
+		}
+	}
+	return hyprlandClient{}, fmt.Errorf("window %s not found", address)
+}
+
+// hyprlandWindowPID looks up the process that owns a window, or 0.
+func hyprlandWindowPID(address string) int {
+	c, err := hyprlandWindow(address)
+	if err != nil {
+		return 0
+	}
+	return c.PID
+}
+
+// onHyprland reports whether research windows are Hyprland addresses,
+// which aren't X11 window IDs, so titles, geometry and focus have to come
+// from hyprctl too.
+func onHyprland() bool {
+	return config.Placement.Backend == "hyprland"
+}
+
+func hyprlandWindowTitle(address string) (string, error) {
+	c, err := hyprlandWindow(address)
+	return c.Title, err
+}
+
+func hyprlandWindowGeometry(address string) (windowGeometry, error) {
+	c, err := hyprlandWindow(address)
+	if err != nil {
+		return windowGeometry{}, err
+	}
+	return windowGeometry{X: c.At[0], Y: c.At[1], Width: c.Size[0], Height: c.Size[1]}, nil
+}
+
+func hyprlandWindowIDs() (map[string]bool, error) {
+	clients, err := hyprlandClients()
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]bool)
+	for _, c := range clients {
+		ids[c.Address] = true
+	}
+	return ids, nil
+}
+
+func hyprlandActiveWindow() (string, error) {
+	out, err := commandOutput(exec.Command("hyprctl", "activewindow", "-j"))
+	if err != nil {
+		return "", fmt.Errorf("failed to query the active Hyprland window: %w", err)
+	}
+	var active hyprlandClient
+	if err := json.Unmarshal(out, &active); err != nil || active.Address == "" {
+		return "", fmt.Errorf("no active Hyprland window")
+	}
+	return active.Address, nil
+}
+
+func hyprlandDispatch(dispatcher, address string) error {
+	return runCommand(exec.Command("hyprctl", "dispatch", dispatcher, "address:"+address))
+}
+
+// hyprlandCommands floats and sizes the window with dispatchers, or moves
+// it silently to the research workspace.
+func hyprlandCommands(windowID string, g windowGeometry) ([][]string, error) {
+	target := "address:" + windowID
+	switch config.Placement.Mode {
+	case "", "float":
+		return [][]string{
+			// setfloating, unlike togglefloating, leaves floating windows be
+			{"hyprctl", "dispatch", "setfloating", target},
+			{"hyprctl", "dispatch", "resizewindowpixel", fmt.Sprintf("exact %d %d,%s", g.Width, g.Height, target)},
+			{"hyprctl", "dispatch", "movewindowpixel", fmt.Sprintf("exact %d %d,%s", g.X, g.Y, target)},
+		}, nil
+	case "workspace":
+		dispatcher := "movetoworkspacesilent"
+		if config.Placement.SwitchToWorkspace {
+			dispatcher = "movetoworkspace"
+		}
+		return [][]string{
+			{"hyprctl", "dispatch", dispatcher, "name:" + placementWorkspace() + "," + target},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported placement mode %q for hyprland (use float or workspace)", config.Placement.Mode)
+	}
+}
@@ -0,0 +1,103 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"rabbithole/internal/wm"
+	"rabbithole/internal/x11"
+)
+
+const (
+	i3ResearchMark = "rabbithole"
+	i3DefaultSpace = "research"
+)
+
+// i3SocketPath finds the IPC socket of the running i3 or sway instance.
+func i3SocketPath() (string, error) {
+	env, binary := "I3SOCK", "i3"
+	if config.Placement.Backend == "sway" {
+		env, binary = "SWAYSOCK", "sway"
+	}
+	if path := os.Getenv(env); path != "" {
+		return path, nil
+	}
+	out, err := commandOutput(exec.Command(binary, "--get-socketpath"))
+	if err != nil {
+		return "", fmt.Errorf("couldn't find the %s IPC socket (is %s running?): %w", binary, binary, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// i3Command sends a command to the running i3 or sway instance.
+func i3Command(command string) error {
+	path, err := i3SocketPath()
+	if err != nil {
+		return err
+	}
+	return wm.I3Command(path, command)
+}
+
+// i3PlacementCommand builds the command list for the configured mode. The
+// newest research window always carries the "rabbithole" mark so bindings
+// like [con_mark="rabbithole"] focus can jump to it.
+func i3PlacementCommand(criteria string, g windowGeometry) (string, error) {
+	commands := []string{"mark --add " + i3ResearchMark}
+	geometry := []string{
+		fmt.Sprintf("resize set %d px %d px", g.Width, g.Height),
+		fmt.Sprintf("move position %d px %d px", g.X, g.Y),
+	}
+	switch config.Placement.Mode {
+	case "", "float":
+		commands = append(commands, "floating enable")
+		commands = append(commands, geometry...)
+	case "scratchpad":
+		commands = append(commands, "move scratchpad", "scratchpad show")
+		commands = append(commands, geometry...)
+	case "workspace":
+		workspace := config.Placement.Workspace
+		if workspace == "" {
+			workspace = i3DefaultSpace
+		}
+		commands = append(commands, "move container to workspace "+strconv.Quote(workspace))
+		if config.Placement.SwitchToWorkspace {
+			// ";" ends the criteria, which the workspace command ignores anyway
+			return criteria + " " + strings.Join(commands, ", ") + "; workspace " + strconv.Quote(workspace), nil
+		}
+	default:
+		return "", fmt.Errorf("unsupported placement mode %q (use float, scratchpad or workspace)", config.Placement.Mode)
+	}
+
+	return criteria + " " + strings.Join(commands, ", "), nil
+}
+
+func placeWithI3(windowID string, g windowGeometry) error {
+	// i3 and sway match X11 windows by their decimal ID
+	id, err := x11.ParseWindowID(windowID)
+	if err != nil {
+		return err
+	}
+	command, err := i3PlacementCommand(fmt.Sprintf("[id=%d]", id), g)
+	if err != nil {
+		return err
+	}
+	if err := i3Command(command); err != nil {
+		return err
+	}
+	slog.Debug("Placed research window over IPC", "window", windowID, "command", command)
+	return nil
+}
+
+func describeI3(msgCommand string) func(string, windowGeometry) []string {
+	return func(windowID string, g windowGeometry) []string {
+		command, err := i3PlacementCommand(fmt.Sprintf("[id=%s]", windowID), g)
+		if err != nil {
+			return []string{"# " + err.Error()}
+		}
+		return []string{shellJoin([]string{msgCommand, command})}
+	}
+}
@@ -0,0 +1,94 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const (
+	idleActionClose = "close"
+	idleActionPark  = "park"
+
+	idleCheckInterval = 5 * time.Minute
+)
+
+// idleWindows returns the open research windows that haven't had focus for
+// the given time. Parked windows were put aside on purpose and the focused
+// window is in use, so neither counts. Focus is only recorded by the
+// daemon; without it a window counts from when it was opened.
+func idleWindows(idleFor time.Duration) ([]researchWindow, error) {
+	windows, err := openResearchWindows()
+	if err != nil {
+		return nil, err
+	}
+	active, _ := activeWindowID()
+
+	var idle []researchWindow
+	for _, w := range windows {
+		if w.WindowID == active {
+			continue
+		}
+		var stale bool
+		err := db.QueryRow(`
+			SELECT MAX(COALESCE(focused_at, opened_at)) < datetime('now', ?)
+				AND NOT EXISTS (SELECT 1 FROM research_windows WHERE id = ? AND parked_at IS NOT NULL)
+			FROM research_windows WHERE window_id = ?`,
+			fmt.Sprintf("-%d seconds", int(idleFor.Seconds())), w.ID, w.WindowID,
+		).Scan(&stale)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check when %s last had focus: %w", w.WindowID, err)
+		}
+		if stale {
+			idle = append(idle, w)
+		}
+	}
+	return idle, nil
+}
+
+// collectIdleWindows closes or parks idle research windows and returns
+// the ones it handled.
+func collectIdleWindows(idleFor time.Duration, action string) ([]researchWindow, error) {
+	windows, err := idleWindows(idleFor)
+	if err != nil {
+		return nil, err
+	}
+	for i, w := range windows {
+		if action == idleActionPark {
+			err = parkWindow(w)
+		} else {
+			err = closeWindow(w.WindowID)
+		}
+		if err != nil {
+			return windows[:i], fmt.Errorf("failed to %s %s: %w", action, w.WindowID, err)
+		}
+		slog.Info("Collected idle research window", "action", action, "window", w.WindowID, "idle_for", idleFor.String())
+	}
+	return windows, nil
+}
+
+// collectIdleWindowsPeriodically applies behavior.idle_minutes from the
+// daemon. The setting is read on every round, so a config reload can turn
+// it on or off.
+func (api *daemonAPI) collectIdleWindowsPeriodically() {
+	for {
+		time.Sleep(idleCheckInterval)
+		api.mu.Lock()
+		if config.Behavior.IdleMinutes > 0 {
+			idleFor := time.Duration(config.Behavior.IdleMinutes) * time.Minute
+			windows, err := collectIdleWindows(idleFor, config.Behavior.IdleAction)
+			if err != nil {
+				slog.Warn("Failed to collect idle research windows", "err", err)
+			}
+			if len(windows) > 0 {
+				verb := "Closed"
+				if config.Behavior.IdleAction == idleActionPark {
+					verb = "Parked"
+				}
+				notifyUser("Idle research windows",
+					fmt.Sprintf("%s %d windows unused for %d minutes", verb, len(windows), config.Behavior.IdleMinutes))
+			}
+		}
+		api.mu.Unlock()
+	}
+}
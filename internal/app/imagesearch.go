@@ -0,0 +1,109 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"rabbithole/internal/launcher"
+)
+
+// defaultImageEngines search by the URL of an uploaded image.
+var defaultImageEngines = []SearchEngine{
+	{Name: "Google Lens", URL: "https://lens.google.com/uploadbyurl?url=%s", Key: "g"},
+	{Name: "TinEye", URL: "https://tineye.com/search?url=%s", Key: "t"},
+	{Name: "Yandex", URL: "https://yandex.com/images/search?rpt=imageview&url=%s", Key: "y"},
+	{Name: "Bing Visual Search", URL: "https://www.bing.com/images/search?view=detailv2&iss=sbi&q=imgurl:%s", Key: "b"},
+}
+
+// uploadImage runs the configured upload command on a PNG file and returns
+// the public URL it prints. There is deliberately no default host: the
+// screenshot leaves the machine, so the user has to pick where it goes.
+func uploadImage(image []byte) (string, error) {
+	// A blank or whitespace-only command has no program to run
+	words := launcher.SplitCommandLine(config.ImageSearch.UploadCommand)
+	if len(words) == 0 {
+		return "", fmt.Errorf("image_search.upload_command is not set in %s (e.g. \"curl -sF file=@{file} https://0x0.st\")", configPath)
+	}
+
+	file, err := os.CreateTemp("", "rabbithole-*.png")
+	if err != nil {
+		return "", fmt.Errorf("failed to save screenshot: %w", err)
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(image); err != nil {
+		file.Close()
+		return "", fmt.Errorf("failed to save screenshot: %w", err)
+	}
+	file.Close()
+
+	for i, word := range words {
+		words[i] = strings.ReplaceAll(word, "{file}", file.Name())
+	}
+	out, err := commandOutput(exec.Command(words[0], words[1:]...))
+	if err != nil {
+		return "", fmt.Errorf("upload command failed: %w", err)
+	}
+
+	imageURL := strings.TrimSpace(string(out))
+	if !strings.HasPrefix(imageURL, "http://") && !strings.HasPrefix(imageURL, "https://") {
+		return "", fmt.Errorf("upload command didn't print a URL: %q", imageURL)
+	}
+	return imageURL, nil
+}
+
+// imageSearch captures a screen region, uploads it and opens the chosen
+// image engine's results in a research window, logged like any search.
+func imageSearch() error {
+	image, err := captureScreenRegion()
+	if err != nil {
+		return err
+	}
+
+	lock, err := acquireMenuLock()
+	if err != nil {
+		return err
+	}
+	activeMenuLock = lock
+	engine, _, err := chooseEngine("Image search with:", config.ImageSearch.Engines)
+	activeMenuLock = nil
+	lock.release()
+	if err != nil {
+		return err
+	}
+
+	imageURL, err := uploadImage(image)
+	if err != nil {
+		return err
+	}
+	slog.Info("Uploaded screenshot for image search", "engine", engine.Name, "url", imageURL)
+
+	finalURL := buildSearchURL(engine.URL, imageURL)
+	// Before launchFor, which looks up remembered geometry
+	if err := initDatabase(); err != nil {
+		slog.Error("Failed to open database", "err", err)
+	}
+	launch := launchFor(engine, "", finalURL)
+	if dryRun {
+		printDryRun(engine, imageURL, launch)
+		return nil
+	}
+
+	loggedURL, loggedFinalURL := redactSearch(imageURL, engine.URL, finalURL)
+	searchLog := startSearchLog(loggedURL, engine.Name, engine.URL, loggedFinalURL, "image", "")
+
+	sendSearchWebhook(webhookPayload{
+		Event:     "image_search",
+		Query:     loggedURL,
+		Engine:    engine.Name,
+		URL:       loggedFinalURL,
+		Timestamp: time.Now(),
+		Session:   time.Now().Format("2006-01-02"),
+	})
+
+	_, err = openLoggedResearchWindow(searchLog, launch)
+	return err
+}
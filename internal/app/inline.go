@@ -0,0 +1,189 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// openFullResults is the last option of an inline answer menu; it opens the
+// engine's URL like a normal search.
+const openFullResults = "→ Open full results"
+
+const inlineLineLength = 100
+
+var inlineClient = &http.Client{Timeout: 5 * time.Second}
+
+// inlineProviders fetch a short answer for a query as launcher lines.
+var inlineProviders = map[string]func(query string) ([]string, error){
+	"duckduckgo": duckDuckGoAnswer,
+	"dictionary": dictionaryAnswer,
+	"units":      unitsAnswer,
+}
+
+func fetchJSON(rawURL string, v any) error {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", appName+"/"+appVersion)
+	resp, err := inlineClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch answer: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("answer request failed: %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to parse answer: %w", err)
+	}
+	return nil
+}
+
+func duckDuckGoAnswer(query string) ([]string, error) {
+	var answer struct {
+		// Usually text, but an object for some answer types (e.g. a
+		// calculator widget), which has nothing to show in the launcher
+		Answer        json.RawMessage
+		AbstractText  string
+		Definition    string
+		RelatedTopics []struct {
+			Text string
+		}
+	}
+	apiURL := "https://api.duckduckgo.com/?format=json&no_html=1&skip_disambig=1&q=" + url.QueryEscape(query)
+	if err := fetchJSON(apiURL, &answer); err != nil {
+		return nil, err
+	}
+
+	var text string
+	if json.Unmarshal(answer.Answer, &text) != nil {
+		text = ""
+	}
+	var lines []string
+	for _, text := range []string{text, answer.AbstractText, answer.Definition} {
+		lines = append(lines, wrapLine(text, inlineLineLength)...)
+	}
+	for i, topic := range answer.RelatedTopics {
+		if i == 5 {
+			break
+		}
+		lines = append(lines, wrapLine(topic.Text, inlineLineLength)...)
+	}
+	return lines, nil
+}
+
+func dictionaryAnswer(query string) ([]string, error) {
+	var entries []struct {
+		Word     string
+		Phonetic string
+		Meanings []struct {
+			PartOfSpeech string
+			Definitions  []struct {
+				Definition string
+			}
+		}
+	}
+	apiURL := "https://api.dictionaryapi.dev/api/v2/entries/en/" + url.PathEscape(query)
+	if err := fetchJSON(apiURL, &entries); err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	for _, entry := range entries {
+		if entry.Phonetic != "" {
+			lines = append(lines, entry.Word+" "+entry.Phonetic)
+		}
+		for _, meaning := range entry.Meanings {
+			for _, def := range meaning.Definitions {
+				lines = append(lines, wrapLine(meaning.PartOfSpeech+": "+def.Definition, inlineLineLength)...)
+			}
+		}
+	}
+	return lines, nil
+}
+
+// unitsAnswer converts between units, e.g. "10 km to mi", on this machine
+// without asking an API.
+func unitsAnswer(query string) ([]string, error) {
+	value, target, ok := convertUnits(query)
+	if !ok {
+		return nil, nil
+	}
+	return []string{formatNumber(value) + " " + target}, nil
+}
+
+// wrapLine splits text into lines of at most width characters at word
+// boundaries, since launchers show one line per option.
+func wrapLine(text string, width int) []string {
+	var lines []string
+	var line string
+	for _, word := range strings.Fields(text) {
+		if line != "" && len(line)+1+len(word) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// runInlineSearch shows an inline engine's answer in the launcher. Picking
+// a line copies it; only "Open full results" opens a research window.
+func runInlineSearch(engine SearchEngine, query string, triggerMethod string, tags []string) error {
+	provider, ok := inlineProviders[engine.Inline]
+	if !ok {
+		return fmt.Errorf("unknown inline provider %q (use duckduckgo, dictionary or units)", engine.Inline)
+	}
+	lines, err := provider(query)
+	if err != nil {
+		return err
+	}
+	slog.Info("Fetched inline answer", "engine", engine.Name, "lines", len(lines))
+	if len(lines) == 0 {
+		lines = []string{"No quick answer for " + query}
+	}
+
+	if dryRun {
+		for _, line := range lines {
+			fmt.Println(line)
+		}
+		fmt.Println()
+		return runSearch(engine, query, triggerMethod, tags, windowGeometry{})
+	}
+
+	lock, err := acquireMenuLock()
+	if err != nil {
+		return err
+	}
+	activeMenuLock = lock
+	selected, err := runLauncher(engine.Name+":", append(lines, openFullResults), config.Interface.Lines)
+	activeMenuLock = nil
+	lock.release()
+	if err != nil {
+		return err
+	}
+
+	switch selected {
+	case "":
+		return nil
+	case openFullResults:
+		return runSearch(engine, query, triggerMethod, tags, windowGeometry{})
+	default:
+		return copyToClipboard(selected)
+	}
+}
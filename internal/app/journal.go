@@ -0,0 +1,137 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// journalEvent is one line of the event journal. The format is kept stable
+// independently of the database schema, so fields are only ever added.
+type journalEvent struct {
+	Event            string    `json:"event"`
+	Timestamp        time.Time `json:"timestamp"`
+	SearchID         int64     `json:"search_id,omitempty"`
+	ResearchWindowID int64     `json:"research_window_id,omitempty"`
+	BookmarkID       int64     `json:"bookmark_id,omitempty"`
+	Query            string    `json:"query,omitempty"`
+	Engine           string    `json:"engine,omitempty"`
+	URL              string    `json:"url,omitempty"`
+	Title            string    `json:"title,omitempty"`
+	Trigger          string    `json:"trigger,omitempty"`
+	Session          string    `json:"session,omitempty"`
+	Tags             []string  `json:"tags,omitempty"`
+	Geometry         string    `json:"geometry,omitempty"`
+}
+
+func journalPath() string {
+	if config.Database.JournalPath != "" {
+		return expandHome(config.Database.JournalPath)
+	}
+	return filepath.Join(filepath.Dir(config.Database.Path), "events.jsonl")
+}
+
+// writeJournal appends an event when database.journal is on. Each event is
+// a single O_APPEND write, so the CLI, window trackers and the daemon can
+// append at the same time without interleaving lines. Failures are only
+// logged; the database stays the record of truth.
+func writeJournal(e journalEvent) {
+	if !config.Database.Journal || dryRun {
+		return
+	}
+	e.Timestamp = time.Now().UTC()
+	// Whatever the database encrypts stays encrypted here too
+	e.Query, e.URL, e.Title = seal(e.Query), seal(e.URL), seal(e.Title)
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		slog.Error("Failed to encode journal event", "event", e.Event, "err", err)
+		return
+	}
+	if err := appendJournal(append(line, '\n')); err != nil {
+		slog.Error("Failed to write journal event", "event", e.Event, "err", err)
+	}
+}
+
+func appendJournal(line []byte) error {
+	path := journalPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create journal directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// purgeJournal drops the events of purged searches from the journal, like
+// purge does in the database: bookmarks stay but no longer point at the
+// search. The journal is rewritten through a temporary file, so an event
+// appended in the meantime can be lost; purges are rare enough for that.
+func purgeJournal(searchIDs []int64) error {
+	if len(searchIDs) == 0 {
+		return nil
+	}
+	path := journalPath()
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read journal: %w", err)
+	}
+
+	purged := make(map[int64]bool, len(searchIDs))
+	for _, id := range searchIDs {
+		purged[id] = true
+	}
+	var kept bytes.Buffer
+	changed := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		var e journalEvent
+		if err := json.Unmarshal(line, &e); err != nil || !purged[e.SearchID] {
+			kept.Write(line)
+			kept.WriteByte('\n')
+			continue
+		}
+		changed = true
+		if e.Event != "bookmark" {
+			continue
+		}
+		e.SearchID, e.ResearchWindowID = 0, 0
+		unlinked, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("failed to encode journal event: %w", err)
+		}
+		kept.Write(append(unlinked, '\n'))
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read journal: %w", err)
+	}
+	if !changed {
+		return nil
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, kept.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/jezek/xgb/xproto"
+
+	"rabbithole/internal/x11"
+)
+
+// keysymNames covers the keys that make sense for rabbithole bindings;
+// single letters and digits map to their ASCII keysym.
+var keysymNames = map[string]xproto.Keysym{
+	"Escape": 0xff1b, "space": 0x20, "Return": 0xff0d, "Tab": 0xff09,
+	"BackSpace": 0xff08, "Delete": 0xffff, "Insert": 0xff63, "Home": 0xff50, "End": 0xff57,
+	"F1": 0xffbe, "F2": 0xffbf, "F3": 0xffc0, "F4": 0xffc1, "F5": 0xffc2, "F6": 0xffc3,
+	"F7": 0xffc4, "F8": 0xffc5, "F9": 0xffc6, "F10": 0xffc7, "F11": 0xffc8, "F12": 0xffc9,
+}
+
+var modifierMasks = map[string]uint16{
+	"ctrl":  xproto.ModMaskControl,
+	"shift": xproto.ModMaskShift,
+	"alt":   xproto.ModMask1,
+	"super": xproto.ModMask4,
+}
+
+// keycodeFor finds the keycode that produces the hotkey's keysym.
+func keycodeFor(x *x11.Session, h hotkey) (xproto.Keycode, error) {
+	keysym, ok := keysymNames[h.Key]
+	if c := h.Key[0]; !ok && len(h.Key) == 1 {
+		if c >= 'A' && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		if c >= 'a' && c <= 'z' || c >= '0' && c <= '9' {
+			keysym, ok = xproto.Keysym(c), true
+		}
+	}
+	if !ok {
+		return 0, fmt.Errorf("key %q can't be grabbed (use a letter, digit, F1-F12 or a key like Escape)", h.Key)
+	}
+
+	keycode, ok, err := x.Keycode(keysym)
+	if err != nil || ok {
+		return keycode, err
+	}
+	return 0, fmt.Errorf("no key on this keyboard produces %q", h.Key)
+}
+
+func (h hotkey) modifierMask() uint16 {
+	var mask uint16
+	for _, mod := range h.Mods {
+		mask |= modifierMasks[mod]
+	}
+	return mask
+}
+
+// watchScopedClose binds the close hotkey only while a research window has
+// focus, so e.g. a bare Escape still reaches every other application. It
+// follows _NET_ACTIVE_WINDOW and grabs or releases the key on each change,
+// along with Caps Lock and Num Lock so the key works with either on.
+func (api *daemonAPI) watchScopedClose(binding string) error {
+	h, err := parseHotkey(binding)
+	if err != nil {
+		return err
+	}
+	x, err := x11.Open()
+	if err != nil {
+		return err
+	}
+	defer x.Close()
+
+	keycode, err := keycodeFor(x, h)
+	if err != nil {
+		return err
+	}
+	mods := h.modifierMask()
+	err = x.WatchRoot()
+	if err != nil {
+		return fmt.Errorf("failed to watch the active window: %w", err)
+	}
+	slog.Info("Binding close while research windows have focus", "hotkey", h.label())
+
+	grabbed := false
+	update := func() {
+		api.mu.Lock()
+		research := false
+		if window, err := x.ActiveWindow(); err == nil {
+			_, err := latestResearchWindow(x11.FormatWindowID(window))
+			research = err == nil
+		}
+		api.mu.Unlock()
+
+		switch {
+		case research && !grabbed:
+			if err := x.GrabKey(keycode, mods); err != nil {
+				slog.Warn("Failed to bind close hotkey", "hotkey", h.label(), "err", err)
+				return
+			}
+			grabbed = true
+		case !research && grabbed:
+			x.UngrabKey(keycode, mods)
+			grabbed = false
+		}
+	}
+	update()
+
+	for {
+		event, xerr := x.Conn.WaitForEvent()
+		if event == nil && xerr == nil {
+			return fmt.Errorf("lost the X connection")
+		}
+		switch event := event.(type) {
+		case xproto.PropertyNotifyEvent:
+			if event.Atom == x.Atom("_NET_ACTIVE_WINDOW") {
+				update()
+			}
+		case xproto.KeyPressEvent:
+			api.mu.Lock()
+			if _, err := closeResearchWindows(false); err != nil {
+				slog.Warn("Failed to close research window", "err", err)
+			}
+			api.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"rabbithole/internal/wm"
+)
+
+// KWin on Wayland doesn't let clients move windows either, but it runs
+// JavaScript scripts loaded over D-Bus. rabbithole loads a one-shot script
+// before launching the browser that places the next browser window to
+// open, so there is nothing to install.
+
+const kwinScriptName = "rabbithole-placement"
+
+func kwinBackend() placementBackend {
+	return placementBackend{
+		// The script placed the window as it opened
+		place:    func(windowID string, g windowGeometry) error { return nil },
+		describe: describeKWin,
+		placeNew: placeNewWithKWin,
+	}
+}
+
+// kwinCallArgs is the gdbus command line calling a method of KWin's
+// scripting interface.
+func kwinCallArgs(method string, args ...string) []string {
+	return append([]string{"gdbus", "call", "--session", "--dest", "org.kde.KWin",
+		"--object-path", "/Scripting", "--method", "org.kde.kwin.Scripting." + method}, args...)
+}
+
+// kwinScript places the first normal window of one of the classes to open
+// and then stops listening. KWin 6 calls windows what KWin 5 called clients.
+func kwinScript(classes []string, g windowGeometry) (string, error) {
+	classesJSON, err := json.Marshal(classes)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`// Written by rabbithole to place its next research window
+const classes = %s;
+const geometry = {x: %d, y: %d, width: %d, height: %d};
+const added = workspace.windowAdded || workspace.clientAdded;
+
+function place(window) {
+    if (!window.normalWindow || classes.indexOf(String(window.resourceClass).toLowerCase()) < 0)
+        return;
+    added.disconnect(place);
+    window.setMaximize(false, false);
+    if ("frameGeometry" in window)
+        window.frameGeometry = geometry;
+    else
+        window.geometry = geometry;
+}
+
+added.connect(place);
+`, classesJSON, g.X, g.Y, g.Width, g.Height), nil
+}
+
+func kwinScriptPath() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "rabbithole", "kwin-placement.js"), nil
+}
+
+// kwinCommands replace the previous placement script, if KWin still has
+// it loaded, with a new one and start it.
+func kwinCommands(path string) [][]string {
+	return [][]string{
+		kwinCallArgs("unloadScript", kwinScriptName),
+		kwinCallArgs("loadScript", path, kwinScriptName),
+		kwinCallArgs("start"),
+	}
+}
+
+// placeNewWithKWin loads the placement script and launches the browser.
+// KWin reports no window IDs over D-Bus, so the class stands for the
+// window.
+func placeNewWithKWin(l browserLaunch, g windowGeometry, launch func() (int, error)) (string, error) {
+	if config.Placement.Mode != "" && config.Placement.Mode != "float" {
+		return "", fmt.Errorf("unsupported placement mode %q for kwin (use float)", config.Placement.Mode)
+	}
+	class := l.WindowClass()
+	script, err := kwinScript(wm.WindowClasses(class), g)
+	if err != nil {
+		return "", err
+	}
+	path, err := kwinScriptPath()
+	if err != nil {
+		return "", fmt.Errorf("couldn't determine the cache directory: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(script), 0644); err != nil {
+		return "", fmt.Errorf("failed to write KWin script: %w", err)
+	}
+
+	for i, args := range kwinCommands(path) {
+		out, err := combinedOutput(exec.Command(args[0], args[1:]...))
+		// Unloading fails harmlessly when no script was left loaded
+		if err != nil && i > 0 {
+			slog.Warn("Failed to load KWin placement script", "err", err, "output", strings.TrimSpace(string(out)))
+			break
+		}
+	}
+
+	if _, err := launch(); err != nil {
+		return "", err
+	}
+	return class, nil
+}
+
+func describeKWin(windowID string, g windowGeometry) []string {
+	path, err := kwinScriptPath()
+	if err != nil {
+		return []string{"# " + err.Error()}
+	}
+	lines := []string{fmt.Sprintf("# before launching, write %s placing the next browser window at %s", path, g)}
+	for _, args := range kwinCommands(path) {
+		lines = append(lines, shellJoin(args))
+	}
+	return lines
+}
+
+// kwinReachable reports whether KWin's scripting interface answers on the
+// session bus.
+func kwinReachable() error {
+	args := []string{"gdbus", "introspect", "--session", "--dest", "org.kde.KWin", "--object-path", "/Scripting"}
+	if out, err := combinedOutput(exec.Command(args[0], args[1:]...)); err != nil {
+		return fmt.Errorf("KWin scripting isn't reachable over D-Bus: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"rabbithole/internal/launcher"
+)
+
+func currentLauncher() (launcher.Driver, error) {
+	return launcher.Lookup(config.Interface.Launcher)
+}
+
+// launcherOptions are the config's settings for every menu.
+func launcherOptions() launcher.Options {
+	o := launcher.Options{Args: config.Interface.DmenuArgs}
+	if a := config.Interface.Accessibility; a.Enabled {
+		o.Font, o.FontSize = a.Font, a.FontSize
+	}
+	return o
+}
+
+// runLauncher shows options in the configured launcher and returns the
+// selected (or typed) line. An empty options slice gives a free-text prompt.
+func runLauncher(prompt string, options []string, lines int) (string, error) {
+	return runLauncherWithIcons(prompt, options, nil, lines)
+}
+
+// runLauncherWithIcons is runLauncher with an icon name or file for each
+// option ("" for none), shown by launchers that support icon rows and
+// ignored by the rest.
+func runLauncherWithIcons(prompt string, options, icons []string, lines int) (string, error) {
+	driver, err := currentLauncher()
+	if err != nil {
+		return "", err
+	}
+
+	if config.Interface.Accessibility.Enabled {
+		// Large fonts truncate a horizontal menu, so always list vertically
+		lines = max(lines, min(len(options), 15))
+		announceMenu(prompt, options)
+	}
+
+	rows, iconArgs := driver.IconRows(options, icons)
+	args := append(driver.Args(prompt, lines, launcherOptions()), iconArgs...)
+	return runLauncherCommand(driver, args, strings.Join(rows, "\n"))
+}
+
+// editInLauncher shows text in the launcher's input line, ready to be
+// edited, and returns it as submitted. Launchers that can't pre-fill their
+// input (dmenu, command templates) list it as the only option instead,
+// which Tab copies into the input.
+func editInLauncher(prompt, text string) (string, error) {
+	driver, err := currentLauncher()
+	if err != nil {
+		return "", err
+	}
+	args := driver.EditArgs(prompt, text, launcherOptions())
+	if args == nil {
+		return runLauncher(prompt, []string{text}, 0)
+	}
+	if config.Interface.Accessibility.Enabled {
+		announceMenu(prompt, []string{text})
+	}
+	return runLauncherCommand(driver, args, "")
+}
+
+func runLauncherCommand(driver launcher.Driver, args []string, input string) (string, error) {
+	var output bytes.Buffer
+	cmd := exec.Command(driver.Command, args...)
+	cmd.Stdin = strings.NewReader(input)
+	cmd.Stdout = &output
+
+	if err := startCommand(cmd); err != nil {
+		return "", fmt.Errorf("%s failed: %w", driver.Command, err)
+	}
+	if activeMenuLock != nil {
+		activeMenuLock.setLauncherPID(commandPID(cmd))
+	}
+	if err := waitCommand(cmd); err != nil {
+		return "", fmt.Errorf("%s failed: %w", driver.Command, err)
+	}
+	return strings.TrimSpace(output.String()), nil
+}
@@ -0,0 +1,89 @@
+package app
+
+import "testing"
+
+func echoLauncher(answer string) func(args []string, stdin string) (string, error) {
+	return func(args []string, stdin string) (string, error) {
+		return answer + "\n", nil
+	}
+}
+
+func TestRunLauncherDmenu(t *testing.T) {
+	useConfig(t)
+	config.Interface.DmenuArgs = []string{"-i", "-p", "ignored", "-nb", "#222"}
+	fake := useFakeRunner(t, echoLauncher("golang"))
+
+	selected, err := runLauncher("Search:", []string{"golang", "rust"}, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if selected != "golang" {
+		t.Errorf("selected %q, want golang", selected)
+	}
+	assertCommands(t, fake, "dmenu -i -p Search: -l 5 -nb #222")
+	if stdin := fake.calls[0].Stdin; stdin != "golang\nrust" {
+		t.Errorf("stdin %q", stdin)
+	}
+}
+
+func TestRunLauncherRofiIcons(t *testing.T) {
+	useConfig(t)
+	config.Interface.Launcher = "rofi"
+	fake := useFakeRunner(t, echoLauncher("Wikipedia"))
+
+	if _, err := runLauncherWithIcons("Engine:", []string{"Wikipedia", "Maps"}, []string{"wikipedia", ""}, 0); err != nil {
+		t.Fatal(err)
+	}
+	assertCommands(t, fake, "rofi -dmenu -i -p Engine: -show-icons")
+	if stdin := fake.calls[0].Stdin; stdin != "Wikipedia\x00icon\x1fwikipedia\nMaps" {
+		t.Errorf("stdin %q", stdin)
+	}
+}
+
+func TestRunLauncherTemplate(t *testing.T) {
+	useConfig(t)
+	config.Interface.Launcher = `walker --dmenu -p "{prompt}" --lines {lines}`
+	fake := useFakeRunner(t, echoLauncher("typed query"))
+
+	selected, err := runLauncher("Search the web:", nil, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if selected != "typed query" {
+		t.Errorf("selected %q", selected)
+	}
+	if got := fake.calls[0].Args; len(got) != 6 || got[3] != "Search the web:" || got[5] != "3" {
+		t.Errorf("args %q", got)
+	}
+}
+
+func TestEditInLauncher(t *testing.T) {
+	useConfig(t)
+	config.Interface.Launcher = "rofi"
+	fake := useFakeRunner(t, echoLauncher("edited"))
+
+	if _, err := editInLauncher("Edit:", "query"); err != nil {
+		t.Fatal(err)
+	}
+	config.Interface.Launcher = "dmenu"
+	if _, err := editInLauncher("Edit:", "query"); err != nil {
+		t.Fatal(err)
+	}
+
+	// dmenu can't pre-fill its input, so the text is offered as an option
+	assertCommands(t, fake, "rofi -dmenu -i -p Edit: -filter query", "dmenu -i -p Edit:")
+	if stdin := fake.calls[1].Stdin; stdin != "query" {
+		t.Errorf("dmenu stdin %q", stdin)
+	}
+}
+
+func TestUnsupportedLauncher(t *testing.T) {
+	useConfig(t)
+	config.Interface.Launcher = "nosuchmenu"
+	fake := useFakeRunner(t, nil)
+
+	if _, err := runLauncher("Search:", nil, 0); err == nil {
+		t.Error("expected an error for an unknown launcher")
+	}
+	assertCommands(t, fake)
+}
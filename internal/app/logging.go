@@ -0,0 +1,168 @@
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	logMaxSizeBytes = 5 * 1024 * 1024
+	logMaxBackups   = 3
+)
+
+func logFilePath() (string, error) {
+	usr, err := user.Current()
+	if err != nil {
+		return "", fmt.Errorf("couldn't determine user home directory for logging: %w", err)
+	}
+	return filepath.Join(usr.HomeDir, ".local", "share", "rabbithole", profileSubdir(), "rabbithole.log"), nil
+}
+
+// initLogging sends leveled, structured log records to the log file only
+// (no terminal spam), rotating it first when it has grown too large. The
+// file is opened in the background, off the hotkey path; the first record
+// waits for it.
+func initLogging(level slog.Level) error {
+	logFile, err := logFilePath()
+	if err != nil {
+		return err
+	}
+
+	w := &logWriter{path: logFile}
+	go w.open()
+	handler := slog.NewTextHandler(w, &slog.HandlerOptions{Level: level, ReplaceAttr: redactLogAttr})
+	slog.SetDefault(slog.New(handler).With("cmd", commandName()))
+	return nil
+}
+
+// logWriter writes to the log file once it's open.
+type logWriter struct {
+	path string
+	once sync.Once
+	file *os.File
+	err  error
+}
+
+func (w *logWriter) open() {
+	w.once.Do(func() {
+		w.file, w.err = openLogFile(w.path)
+		if w.err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  %v\n", w.err)
+		}
+	})
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.open()
+	if w.err != nil {
+		return 0, w.err
+	}
+	return w.file.Write(p)
+}
+
+func openLogFile(logFile string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(logFile), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	if info, err := os.Stat(logFile); err == nil && info.Size() >= logMaxSizeBytes {
+		rotateLogs(logFile)
+	}
+
+	file, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	return file, nil
+}
+
+// rotateLogs shifts rabbithole.log to rabbithole.log.1, .1 to .2 and so on,
+// dropping the oldest.
+func rotateLogs(logFile string) {
+	os.Remove(fmt.Sprintf("%s.%d", logFile, logMaxBackups))
+	for i := logMaxBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", logFile, i), fmt.Sprintf("%s.%d", logFile, i+1))
+	}
+	os.Rename(logFile, logFile+".1")
+}
+
+func commandName() string {
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		return os.Args[1]
+	}
+	return appName
+}
+
+func logLevelFromFlags(verbose, quiet bool) slog.Level {
+	switch {
+	case verbose:
+		return slog.LevelDebug
+	case quiet:
+		return slog.LevelWarn
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// tailLogs prints the last n lines of the log and, with follow, keeps
+// printing new lines as they are written (surviving rotation).
+func tailLogs(n int, follow bool) error {
+	logFile, err := logFilePath()
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(logFile)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		if len(lines) > n {
+			lines = lines[1:]
+		}
+	}
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+
+	if !follow {
+		return nil
+	}
+
+	offset, _ := file.Seek(0, io.SeekEnd)
+	for {
+		time.Sleep(500 * time.Millisecond)
+
+		info, err := os.Stat(logFile)
+		if err != nil {
+			continue
+		}
+		if info.Size() < offset {
+			// Rotated: start over on the new file
+			file.Close()
+			if file, err = os.Open(logFile); err != nil {
+				return fmt.Errorf("failed to reopen log file: %w", err)
+			}
+			offset = 0
+		}
+		if info.Size() > offset {
+			file.Seek(offset, io.SeekStart)
+			written, _ := io.Copy(os.Stdout, file)
+			offset += written
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"rabbithole/internal/browser"
+)
+
+// On macOS there is no X server: selections come from the pasteboard,
+// browsers are started through LaunchServices with open, and windows are
+// placed by yabai or, without it, System Events over AppleScript.
+
+func onMacOS() bool {
+	return runtime.GOOS == "darwin"
+}
+
+// readPasteboard reads the general pasteboard. macOS has no PRIMARY
+// selection, so highlighted text has to be copied first.
+func readPasteboard(selectionType string) (string, error) {
+	if selectionType != "clipboard" {
+		return "", fmt.Errorf("macOS has no %s selection", selectionType)
+	}
+	output, err := commandOutput(exec.Command("pbpaste", "-Prefer", "txt"))
+	if err != nil {
+		return "", fmt.Errorf("pbpaste failed: %w", err)
+	}
+	return string(output), nil
+}
+
+// macScreenSize reads the size of the main display from the Finder's
+// desktop bounds ("0, 0, 1728, 1117").
+func macScreenSize() (width, height int, err error) {
+	output, err := commandOutput(exec.Command("osascript", "-e", `tell application "Finder" to get bounds of window of desktop`))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read screen size: %w", err)
+	}
+	var left, top int
+	if _, err := fmt.Sscanf(strings.TrimSpace(string(output)), "%d, %d, %d, %d", &left, &top, &width, &height); err != nil {
+		return 0, 0, fmt.Errorf("failed to parse screen size %q: %w", output, err)
+	}
+	return width - left, height - top, nil
+}
+
+// captureMacScreenRegion lets the user drag out a region with the
+// built-in screencapture, which only writes to files.
+func captureMacScreenRegion() ([]byte, error) {
+	dir, err := os.MkdirTemp("", "rabbithole-ocr-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "region.png")
+	if err := runCommand(exec.Command("screencapture", "-i", "-x", "-t", "png", path)); err != nil {
+		return nil, fmt.Errorf("screenshot failed: %w", err)
+	}
+	image, err := os.ReadFile(path)
+	if err != nil {
+		// Escape cancels without writing the file
+		return nil, fmt.Errorf("region selection cancelled")
+	}
+	return image, nil
+}
+
+func yabaiBackend() placementBackend {
+	backend := commandBackend(yabaiCommands)
+	backend.detect = detectWithYabai
+	return backend
+}
+
+// yabaiWindow is a window as listed by yabai -m query --windows.
+type yabaiWindow struct {
+	ID       int    `json:"id"`
+	PID      int    `json:"pid"`
+	App      string `json:"app"`
+	Floating bool   `json:"is-floating"`
+}
+
+func yabaiWindows() ([]yabaiWindow, error) {
+	out, err := commandOutput(exec.Command("yabai", "-m", "query", "--windows"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list yabai windows: %w", err)
+	}
+	var windows []yabaiWindow
+	if err := json.Unmarshal(out, &windows); err != nil {
+		return nil, fmt.Errorf("failed to parse yabai windows: %w", err)
+	}
+	return windows, nil
+}
+
+// detectWithYabai launches the browser and polls yabai for a window of
+// its application that wasn't there before. The launched open process
+// exits right away, so only the application name can match.
+func detectWithYabai(class string, launch func() (int, error)) (string, error) {
+	before, err := yabaiWindows()
+	if err != nil {
+		return "", err
+	}
+	known := make(map[int]bool, len(before))
+	for _, w := range before {
+		known[w.ID] = true
+	}
+	app := browser.MacAppName(class)
+
+	if _, err := launch(); err != nil {
+		return "", err
+	}
+
+	deadline := time.Now().Add(windowDetectTimeout())
+	for time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+		windows, err := yabaiWindows()
+		if err != nil {
+			return "", err
+		}
+		for _, w := range windows {
+			if !known[w.ID] && strings.EqualFold(w.App, app) {
+				return strconv.Itoa(w.ID), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("failed to detect new %s window: timeout waiting for it to open", app)
+}
+
+// yabaiFloating reports whether yabai already floats the window, as
+// --toggle float would otherwise tile it again.
+func yabaiFloating(windowID string) bool {
+	out, err := commandOutput(exec.Command("yabai", "-m", "query", "--windows", "--window", windowID))
+	if err != nil {
+		return false
+	}
+	var w yabaiWindow
+	return json.Unmarshal(out, &w) == nil && w.Floating
+}
+
+// yabaiCommands floats and frames the window, or sends it to the research
+// space (a space label or index).
+func yabaiCommands(windowID string, g windowGeometry) ([][]string, error) {
+	switch config.Placement.Mode {
+	case "", "float":
+		var commands [][]string
+		if !yabaiFloating(windowID) {
+			commands = append(commands, []string{"yabai", "-m", "window", windowID, "--toggle", "float"})
+		}
+		return append(commands,
+			[]string{"yabai", "-m", "window", windowID, "--move", fmt.Sprintf("abs:%d:%d", g.X, g.Y)},
+			[]string{"yabai", "-m", "window", windowID, "--resize", fmt.Sprintf("abs:%d:%d", g.Width, g.Height)},
+		), nil
+	case "workspace":
+		commands := [][]string{{"yabai", "-m", "window", windowID, "--space", placementWorkspace()}}
+		if config.Placement.SwitchToWorkspace {
+			commands = append(commands, []string{"yabai", "-m", "space", "--focus", placementWorkspace()})
+		}
+		return commands, nil
+	default:
+		return nil, fmt.Errorf("unsupported placement mode %q for yabai (use float or workspace)", config.Placement.Mode)
+	}
+}
+
+func appleScriptBackend() placementBackend {
+	backend := commandBackend(appleScriptCommands)
+	backend.detect = detectWithAppleScript
+	return backend
+}
+
+// appleScriptString quotes a string for AppleScript source.
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// appleScriptProcess addresses the application's process in System Events.
+// Process names can differ in case from the application's ("firefox"),
+// which whose clauses ignore.
+func appleScriptProcess(app string) string {
+	return "(first process whose name is " + appleScriptString(app) + ")"
+}
+
+// appleScriptWindowCount counts the application's windows, which fails
+// while it isn't running.
+func appleScriptWindowCount(app string) int {
+	script := `tell application "System Events" to count windows of ` + appleScriptProcess(app)
+	out, err := commandOutput(exec.Command("osascript", "-e", script))
+	if err != nil {
+		return 0
+	}
+	count, _ := strconv.Atoi(strings.TrimSpace(string(out)))
+	return count
+}
+
+// detectWithAppleScript waits for the browser to have more windows than
+// before the launch. System Events has no window IDs, so the application
+// name stands for its window and placement moves the frontmost one, which
+// a new window is.
+func detectWithAppleScript(class string, launch func() (int, error)) (string, error) {
+	app := browser.MacAppName(class)
+	before := appleScriptWindowCount(app)
+	if _, err := launch(); err != nil {
+		return "", err
+	}
+
+	deadline := time.Now().Add(windowDetectTimeout())
+	for time.Now().Before(deadline) {
+		time.Sleep(150 * time.Millisecond)
+		if appleScriptWindowCount(app) > before {
+			return app, nil
+		}
+	}
+	return "", fmt.Errorf("failed to detect new %s window: timeout waiting for it to open", app)
+}
+
+// appleScriptCommands moves and sizes the application's front window. This
+// needs the accessibility permission for the terminal or hotkey daemon
+// running rabbithole.
+func appleScriptCommands(windowID string, g windowGeometry) ([][]string, error) {
+	if config.Placement.Mode != "" && config.Placement.Mode != "float" {
+		return nil, fmt.Errorf("unsupported placement mode %q for applescript (use float)", config.Placement.Mode)
+	}
+	return [][]string{{"osascript",
+		"-e", `tell application "System Events"`,
+		"-e", "tell " + appleScriptProcess(windowID),
+		"-e", fmt.Sprintf("set position of front window to {%d, %d}", g.X, g.Y),
+		"-e", fmt.Sprintf("set size of front window to {%d, %d}", g.Width, g.Height),
+		"-e", "end tell",
+		"-e", "end tell",
+	}}, nil
+}
@@ -0,0 +1,390 @@
+package app
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"rabbithole/internal/browser"
+)
+
+// The marionette backend controls Firefox through Marionette, its built-in
+// remote protocol, instead of the window manager: windows are opened,
+// listed, read and closed by their handle, so titles don't have to be
+// matched and the current URL is known. Firefox only listens when started
+// with --marionette, which rabbithole does when nothing answers on the
+// port. Research windows get IDs like "marionette:<handle>".
+
+const (
+	marionetteWindowPrefix = "marionette:"
+	defaultMarionettePort  = 2828
+)
+
+func marionetteBackend() placementBackend {
+	return placementBackend{place: placeWithMarionette, describe: describeMarionette, placeNew: placeNewWithMarionette}
+}
+
+func isMarionetteWindow(windowID string) bool {
+	return strings.HasPrefix(windowID, marionetteWindowPrefix)
+}
+
+func marionetteHandle(windowID string) string {
+	return strings.TrimPrefix(windowID, marionetteWindowPrefix)
+}
+
+func marionettePort() int {
+	if config.Placement.MarionettePort > 0 {
+		return config.Placement.MarionettePort
+	}
+	return defaultMarionettePort
+}
+
+// marionetteClient is a WebDriver session over a Marionette connection.
+// Firefox allows one session at a time, so sessions are serialised across
+// rabbithole processes with a lock file and only held for a few commands.
+type marionetteClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	lock   *os.File
+	nextID int
+}
+
+func marionetteLockPath() string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "rabbithole-marionette.lock")
+}
+
+// openMarionette connects to Firefox and starts a session.
+func openMarionette() (*marionetteClient, error) {
+	lock, err := os.OpenFile(marionetteLockPath(), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open marionette lock: %w", err)
+	}
+	if err := lockFile(lock, true); err != nil {
+		lock.Close()
+		return nil, fmt.Errorf("failed to acquire marionette lock: %w", err)
+	}
+
+	conn, err := net.DialTimeout("tcp", fmt.Sprintf("127.0.0.1:%d", marionettePort()), 2*time.Second)
+	if err != nil {
+		unlockFile(lock)
+		lock.Close()
+		return nil, fmt.Errorf("Firefox doesn't listen for Marionette on port %d: %w", marionettePort(), err)
+	}
+	c := &marionetteClient{conn: conn, reader: bufio.NewReader(conn), lock: lock}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// Firefox greets with {"applicationType":"gecko","marionetteProtocol":3}
+	var hello struct {
+		Protocol int `json:"marionetteProtocol"`
+	}
+	if err := c.read(&hello); err != nil || hello.Protocol < 3 {
+		c.release()
+		return nil, fmt.Errorf("unexpected Marionette greeting on port %d", marionettePort())
+	}
+	// A page load strategy of none keeps Navigate from waiting for the page
+	capabilities := map[string]any{"capabilities": map[string]any{"alwaysMatch": map[string]any{"pageLoadStrategy": "none"}}}
+	if err := c.call("WebDriver:NewSession", capabilities, nil); err != nil {
+		c.release()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *marionetteClient) release() {
+	c.conn.Close()
+	unlockFile(c.lock)
+	c.lock.Close()
+}
+
+// Close ends the session, so the next rabbithole process can start one.
+func (c *marionetteClient) Close() error {
+	err := c.call("WebDriver:DeleteSession", nil, nil)
+	c.release()
+	return err
+}
+
+// read reads one length-prefixed message: "<length>:<json>".
+func (c *marionetteClient) read(v any) error {
+	prefix, err := c.reader.ReadString(':')
+	if err != nil {
+		return err
+	}
+	length, err := strconv.Atoi(strings.TrimSuffix(prefix, ":"))
+	if err != nil || length < 0 || length > 64<<20 {
+		return fmt.Errorf("invalid Marionette message length %q", prefix)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, data); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// call sends [0, id, command, params] and reads [1, id, error, result].
+func (c *marionetteClient) call(command string, params any, result any) error {
+	c.nextID++
+	if params == nil {
+		params = map[string]any{}
+	}
+	data, err := json.Marshal([]any{0, c.nextID, command, params})
+	if err != nil {
+		return err
+	}
+	c.conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if _, err := fmt.Fprintf(c.conn, "%d:%s", len(data), data); err != nil {
+		return fmt.Errorf("%s failed: %w", command, err)
+	}
+	for {
+		var reply []json.RawMessage
+		if err := c.read(&reply); err != nil {
+			return fmt.Errorf("%s failed: %w", command, err)
+		}
+		var id int
+		if len(reply) != 4 || json.Unmarshal(reply[1], &id) != nil || id != c.nextID {
+			continue
+		}
+		var failure *struct {
+			Error   string `json:"error"`
+			Message string `json:"message"`
+		}
+		if err := json.Unmarshal(reply[2], &failure); err == nil && failure != nil {
+			return fmt.Errorf("%s failed: %s: %s", command, failure.Error, failure.Message)
+		}
+		if result == nil {
+			return nil
+		}
+		return json.Unmarshal(reply[3], result)
+	}
+}
+
+// value calls a command whose result is {"value": ...}.
+func (c *marionetteClient) value(command string, params any, v any) error {
+	var result struct {
+		Value json.RawMessage `json:"value"`
+	}
+	if err := c.call(command, params, &result); err != nil {
+		return err
+	}
+	return json.Unmarshal(result.Value, v)
+}
+
+// marionetteWindowCall runs fn on the window, switched to in a fresh
+// session.
+func marionetteWindowCall(windowID string, fn func(c *marionetteClient) error) error {
+	c, err := openMarionette()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if err := c.call("WebDriver:SwitchToWindow", map[string]any{"handle": marionetteHandle(windowID), "focus": false}, nil); err != nil {
+		return err
+	}
+	return fn(c)
+}
+
+// placeNewWithMarionette opens the URL in a new Firefox window and sizes
+// it. Without Marionette on the port, Firefox is started with it and the
+// URL, and its first window is the research window; a Firefox that is
+// already running without it has to be quit first.
+func placeNewWithMarionette(l browserLaunch, g windowGeometry, _ func() (int, error)) (string, error) {
+	if l.Chromium() {
+		return "", fmt.Errorf("the marionette placement backend needs Firefox, not %s", l.Browser)
+	}
+	if config.Placement.Mode != "" && config.Placement.Mode != "float" {
+		return "", fmt.Errorf("unsupported placement mode %q for marionette (use float)", config.Placement.Mode)
+	}
+
+	var handle string
+	c, err := openMarionette()
+	if err == nil {
+		var window struct {
+			Handle string `json:"handle"`
+		}
+		err = c.call("WebDriver:NewWindow", map[string]any{"type": "window"}, &window)
+		handle = window.Handle
+		if err == nil {
+			err = c.call("WebDriver:SwitchToWindow", map[string]any{"handle": handle, "focus": true}, nil)
+		}
+		if err == nil {
+			err = c.call("WebDriver:Navigate", map[string]any{"url": l.OpenURL()}, nil)
+		}
+	} else {
+		slog.Debug("Starting Firefox with Marionette", "port", marionettePort(), "reason", err)
+		c, handle, err = startMarionetteFirefox(l)
+	}
+	if c != nil {
+		defer c.Close()
+	}
+	if err != nil {
+		return "", err
+	}
+
+	windowID := marionetteWindowPrefix + handle
+	if err := setMarionetteWindowRect(c, g); err != nil {
+		slog.Warn("Failed to place research window", "window", windowID, "err", err)
+	}
+	return windowID, nil
+}
+
+// startMarionetteFirefox starts Firefox with Marionette and the URL and
+// waits for it to answer.
+func startMarionetteFirefox(l browserLaunch) (*marionetteClient, string, error) {
+	if err := l.Prepare(); err != nil {
+		return nil, "", err
+	}
+	args := []string{"--marionette"}
+	if marionettePort() != defaultMarionettePort {
+		// There's no flag for the port, only the preference
+		slog.Warn("Firefox only takes a Marionette port from its marionette.port preference", "port", marionettePort())
+	}
+	args = append(args, l.Args(false)...)
+
+	command := browser.StartCommand(l.Browser, args)
+	if err := startCommand(exec.Command(command[0], command[1:]...)); err != nil {
+		return nil, "", fmt.Errorf("failed to start %s (is it installed?): %w", l.Browser, err)
+	}
+
+	deadline := time.Now().Add(windowDetectTimeout())
+	for time.Now().Before(deadline) {
+		time.Sleep(200 * time.Millisecond)
+		c, err := openMarionette()
+		if err != nil {
+			continue
+		}
+		var handle string
+		if err := c.value("WebDriver:GetWindowHandle", nil, &handle); err == nil && handle != "" {
+			return c, handle, nil
+		}
+		c.Close()
+	}
+	return nil, "", fmt.Errorf("%s didn't answer on Marionette port %d in time (quit a Firefox started without --marionette first)",
+		l.Browser, marionettePort())
+}
+
+// setMarionetteWindowRect gives the current window the geometry; Firefox
+// restores a maximized window for it by itself.
+func setMarionetteWindowRect(c *marionetteClient, g windowGeometry) error {
+	return c.call("WebDriver:SetWindowRect", map[string]any{"x": g.X, "y": g.Y, "width": g.Width, "height": g.Height}, nil)
+}
+
+func placeWithMarionette(windowID string, g windowGeometry) error {
+	if !isMarionetteWindow(windowID) {
+		return fmt.Errorf("%s is not a window opened over Marionette", windowID)
+	}
+	return marionetteWindowCall(windowID, func(c *marionetteClient) error {
+		return setMarionetteWindowRect(c, g)
+	})
+}
+
+func describeMarionette(windowID string, g windowGeometry) []string {
+	return []string{
+		fmt.Sprintf("# over Marionette on port %d, starting Firefox with --marionette if needed", marionettePort()),
+		`WebDriver:NewWindow {"type": "window"}`,
+		`WebDriver:Navigate {"url": <url>}`,
+		fmt.Sprintf(`WebDriver:SetWindowRect {"x": %d, "y": %d, "width": %d, "height": %d}`, g.X, g.Y, g.Width, g.Height),
+	}
+}
+
+func marionetteWindowTitle(windowID string) (title string, err error) {
+	err = marionetteWindowCall(windowID, func(c *marionetteClient) error {
+		return c.value("WebDriver:GetTitle", nil, &title)
+	})
+	return title, err
+}
+
+func marionetteWindowURL(windowID string) (url string, err error) {
+	err = marionetteWindowCall(windowID, func(c *marionetteClient) error {
+		return c.value("WebDriver:GetCurrentURL", nil, &url)
+	})
+	return url, err
+}
+
+func marionetteWindowGeometry(windowID string) (g windowGeometry, err error) {
+	err = marionetteWindowCall(windowID, func(c *marionetteClient) error {
+		var rect struct {
+			X, Y, Width, Height float64
+		}
+		if err := c.call("WebDriver:GetWindowRect", nil, &rect); err != nil {
+			return err
+		}
+		g = windowGeometry{X: int(rect.X), Y: int(rect.Y), Width: int(rect.Width), Height: int(rect.Height)}
+		return nil
+	})
+	return g, err
+}
+
+func closeMarionetteWindow(windowID string) error {
+	return marionetteWindowCall(windowID, func(c *marionetteClient) error {
+		return c.call("WebDriver:CloseWindow", nil, nil)
+	})
+}
+
+func activateMarionetteWindow(windowID string) error {
+	c, err := openMarionette()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	return c.call("WebDriver:SwitchToWindow", map[string]any{"handle": marionetteHandle(windowID), "focus": true}, nil)
+}
+
+// marionetteWindowIDs lists Firefox's open tabs as window IDs.
+func marionetteWindowIDs() (map[string]bool, error) {
+	c, err := openMarionette()
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	// Lists come back bare, but other results are wrapped in a value
+	var reply json.RawMessage
+	if err := c.call("WebDriver:GetWindowHandles", nil, &reply); err != nil {
+		return nil, err
+	}
+	var handles []string
+	if err := json.Unmarshal(reply, &handles); err != nil {
+		var wrapped struct {
+			Value []string `json:"value"`
+		}
+		if err := json.Unmarshal(reply, &wrapped); err != nil {
+			return nil, fmt.Errorf("failed to parse Firefox window handles: %w", err)
+		}
+		handles = wrapped.Value
+	}
+	ids := make(map[string]bool)
+	for _, handle := range handles {
+		ids[marionetteWindowPrefix+handle] = true
+	}
+	return ids, nil
+}
+
+// marionetteActiveWindow is the selected tab of the Firefox window focused
+// last, read in the chrome context, where handles are the tabs' browser IDs.
+func marionetteActiveWindow() (string, error) {
+	c, err := openMarionette()
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+	if err := c.call("Marionette:SetContext", map[string]any{"value": "chrome"}, nil); err != nil {
+		return "", err
+	}
+	var handle string
+	script := `return String(Services.wm.getMostRecentWindow("navigator:browser").gBrowser.selectedBrowser.browserId);`
+	if err := c.value("WebDriver:ExecuteScript", map[string]any{"script": script, "args": []any{}}, &handle); err != nil {
+		return "", err
+	}
+	return marionetteWindowPrefix + handle, nil
+}
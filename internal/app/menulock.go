@@ -0,0 +1,91 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// menuLock serialises launcher menus across rabbithole invocations so two
+// hotkey presses don't end up with two menus fighting for keyboard focus.
+// The lock file holds the PID of the launcher currently on screen.
+type menuLock struct {
+	file *os.File
+}
+
+// activeMenuLock is held while this process shows menus; runLauncher
+// records each launcher's PID in it so a newer search can replace us.
+var activeMenuLock *menuLock
+
+func menuLockPath() string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "rabbithole-menu.lock")
+}
+
+// acquireMenuLock takes the menu lock. With the "queue" policy it waits for
+// the current menu to finish; with "replace" it closes the pending menu
+// first so the newest search wins.
+func acquireMenuLock() (*menuLock, error) {
+	file, err := os.OpenFile(menuLockPath(), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open menu lock: %w", err)
+	}
+
+	if err := lockFile(file, false); err != nil {
+		if config.Behavior.ConcurrentSearch == "replace" {
+			replacePendingMenu(file)
+		} else {
+			slog.Info("Another search menu is open, queueing")
+		}
+		if err := lockFile(file, true); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to acquire menu lock: %w", err)
+		}
+	}
+
+	return &menuLock{file: file}, nil
+}
+
+// replacePendingMenu closes the launcher recorded in the lock, unless its
+// PID now belongs to another process.
+func replacePendingMenu(file *os.File) {
+	data := make([]byte, 64)
+	n, _ := file.ReadAt(data, 0)
+	pidStr, recorded, _ := strings.Cut(strings.TrimSpace(string(data[:n])), " ")
+	pid, err := strconv.Atoi(pidStr)
+	if err != nil || pid <= 0 {
+		return
+	}
+	if start, err := processStartTime(pid); err != nil || start != recorded {
+		slog.Debug("Pending menu's launcher is gone, not replacing", "launcher_pid", pid)
+		return
+	}
+	slog.Info("Replacing pending search menu", "launcher_pid", pid)
+	if err := terminateProcess(pid); err != nil {
+		slog.Warn("Failed to close pending menu", "err", err)
+	}
+}
+
+// setLauncherPID records the launcher with its start time, which tells it
+// apart from a later process that got the same PID.
+func (l *menuLock) setLauncherPID(pid int) {
+	start, err := processStartTime(pid)
+	if err != nil {
+		slog.Debug("Failed to read launcher start time", "pid", pid, "err", err)
+		start = "unknown"
+	}
+	l.file.Truncate(0)
+	l.file.WriteAt([]byte(fmt.Sprintf("%d %s", pid, start)), 0)
+}
+
+func (l *menuLock) release() {
+	l.file.Truncate(0)
+	unlockFile(l.file)
+	l.file.Close()
+}
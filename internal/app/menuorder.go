@@ -0,0 +1,54 @@
+package app
+
+import (
+	"log/slog"
+	"sort"
+)
+
+// engineFrecency scores engines by how often and how recently they were
+// used, weighting each search by its age like Firefox's frecency buckets.
+func engineFrecency() map[string]int {
+	scores := make(map[string]int)
+	rows, err := db.Query(`
+		SELECT engine_name, SUM(CASE
+			WHEN timestamp > datetime('now', '-4 days') THEN 100
+			WHEN timestamp > datetime('now', '-14 days') THEN 70
+			WHEN timestamp > datetime('now', '-31 days') THEN 50
+			ELSE 30 END)
+		FROM searches
+		WHERE timestamp > datetime('now', '-90 days')
+		GROUP BY engine_name`)
+	if err != nil {
+		slog.Warn("Failed to compute engine frecency", "err", err)
+		return scores
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		var score int
+		if err := rows.Scan(&name, &score); err == nil {
+			scores[name] = score
+		}
+	}
+	return scores
+}
+
+// orderEngines sorts the menu by frecency unless interface.menu_order is
+// "config", then puts engines suggested for the query first. Ties,
+// including engines never used, keep config order.
+func orderEngines(engines []SearchEngine, query string) []SearchEngine {
+	if config.Interface.MenuOrder == "config" {
+		return suggestEngines(engines, query)
+	}
+	if err := initDatabase(); err != nil {
+		slog.Warn("Failed to open database, keeping config order", "err", err)
+		return suggestEngines(engines, query)
+	}
+
+	scores := engineFrecency()
+	sorted := append([]SearchEngine(nil), engines...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return scores[sorted[i].Name] > scores[sorted[j].Name]
+	})
+	return suggestEngines(sorted, query)
+}
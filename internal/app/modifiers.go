@@ -0,0 +1,180 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Search modifiers are typed with the query, e.g. "borrow checker :site
+// stackoverflow.com :lang de", and taken out of it before it's searched.
+// site is added to the query as "site:DOMAIN", which most web search
+// engines understand, unless the engine maps it to a parameter; the others
+// set the URL query parameter the engine maps them to.
+const siteModifier = "site"
+
+// wellKnownModifiers are the parameters of popular engines, used when an
+// engine doesn't configure its own, by the domain of the engine's URL.
+// "google." stands for Google on any top-level domain.
+var wellKnownModifiers = map[string]map[string]string{
+	"google.":     {"lang": "hl", "region": "gl"},
+	"youtube.com": {"lang": "hl", "region": "gl"},
+	"bing.com":    {"lang": "setlang", "region": "cc"},
+	"kagi.com":    {"region": "r"},
+}
+
+// modifierPattern finds ":name value" pairs; whether name is a modifier is
+// decided by the caller, so ":)" and ordinary colons stay in the query.
+var modifierPattern = regexp.MustCompile(`(?:^|[ \t]+):([a-z]+)[ \t]+(\S+)`)
+
+var modifierNamePattern = regexp.MustCompile(`^[a-z]+$`)
+
+// searchModifier is one modifier parsed out of a query.
+type searchModifier struct {
+	Name, Value string
+}
+
+// searchModifierNames are all modifiers that can be typed: site, those of the
+// well-known engines and every engine's own.
+func searchModifierNames() map[string]bool {
+	names := map[string]bool{siteModifier: true}
+	for _, params := range wellKnownModifiers {
+		for name := range params {
+			names[name] = true
+		}
+	}
+	for _, engine := range config.SearchEngines {
+		for name := range engine.Modifiers {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// parseModifiers takes the modifiers out of query. Line breaks are kept,
+// for batch searches.
+func parseModifiers(query string) (string, []searchModifier) {
+	names := searchModifierNames()
+	var modifiers []searchModifier
+	rest := modifierPattern.ReplaceAllStringFunc(query, func(match string) string {
+		m := modifierPattern.FindStringSubmatch(match)
+		if !names[m[1]] {
+			return match
+		}
+		// A later one overrides, e.g. a template's :site repeated per line
+		modifiers = slices.DeleteFunc(modifiers, func(other searchModifier) bool { return other.Name == m[1] })
+		modifiers = append(modifiers, searchModifier{Name: m[1], Value: m[2]})
+		return ""
+	})
+	if len(modifiers) == 0 {
+		return query, nil
+	}
+	return strings.TrimSpace(rest), modifiers
+}
+
+// engineModifiers are the parameters modifiers set for the engine: its own
+// mapping over the well-known one for its host.
+func engineModifiers(engine SearchEngine) map[string]string {
+	params := make(map[string]string)
+	if u, err := url.Parse(strings.ReplaceAll(engine.URL, "%s", "")); err == nil {
+		for pattern, known := range wellKnownModifiers {
+			if hostMatches(u.Hostname(), pattern) {
+				for name, param := range known {
+					params[name] = param
+				}
+			}
+		}
+	}
+	for name, param := range engine.Modifiers {
+		params[name] = param
+	}
+	return params
+}
+
+// hostMatches reports whether host is the domain pattern or one of its
+// subdomains. A pattern ending in "." matches any top-level domain.
+func hostMatches(host, pattern string) bool {
+	if strings.HasSuffix(pattern, ".") {
+		return strings.HasPrefix(host, pattern) || strings.Contains(host, "."+pattern)
+	}
+	return host == pattern || strings.HasSuffix(host, "."+pattern)
+}
+
+// withModifiers returns the engine with its URL template set up for the
+// modifiers. An unmapped site goes in front of the query's placeholder, so
+// the logged query stays as typed. Modifiers the engine has no parameter
+// for are returned as ignored.
+func withModifiers(engine SearchEngine, modifiers []searchModifier) (SearchEngine, []string) {
+	params := engineModifiers(engine)
+	var ignored []string
+	for _, m := range modifiers {
+		param, ok := params[m.Name]
+		switch {
+		case ok && param != "":
+			engine.URL = setURLParam(engine.URL, param, m.Value)
+		case m.Name == siteModifier:
+			engine.URL = strings.ReplaceAll(engine.URL, "%s", url.QueryEscape("site:"+m.Value+" ")+"%s")
+		default:
+			ignored = append(ignored, ":"+m.Name)
+		}
+	}
+	return engine, ignored
+}
+
+// setURLParam sets a query parameter in an engine URL template, replacing
+// the template's own value if it has one. The template's %s rules out
+// url.Parse, so this works on the string.
+func setURLParam(template, param, value string) string {
+	pair := url.QueryEscape(param) + "=" + url.QueryEscape(value)
+	existing := regexp.MustCompile(`([?&])` + regexp.QuoteMeta(url.QueryEscape(param)) + `=[^&#]*`)
+	if existing.MatchString(template) {
+		return existing.ReplaceAllString(template, "${1}"+pair)
+	}
+	base, fragment, hasFragment := strings.Cut(template, "#")
+	separator := "?"
+	if strings.Contains(base, "?") {
+		separator = "&"
+	}
+	base += separator + pair
+	if hasFragment {
+		base += "#" + fragment
+	}
+	return base
+}
+
+// modifySearch takes the modifiers out of the query and applies them to
+// each engine.
+func modifySearch(engines []SearchEngine, query string) ([]SearchEngine, string, error) {
+	rest, modifiers := parseModifiers(query)
+	if len(modifiers) == 0 {
+		return engines, query, nil
+	}
+	if rest == "" {
+		return nil, "", fmt.Errorf("nothing left to search for after the modifiers")
+	}
+
+	ignored := make(map[string]bool)
+	modified := make([]SearchEngine, len(engines))
+	for i, engine := range engines {
+		var skipped []string
+		modified[i], skipped = withModifiers(engine, modifiers)
+		for _, name := range skipped {
+			ignored[name] = true
+		}
+	}
+	slog.Debug("Applied search modifiers", "modifiers", len(modifiers))
+	if len(ignored) > 0 {
+		names := make([]string, 0, len(ignored))
+		for name := range ignored {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		slog.Warn("Search modifiers not supported by the engine", "modifiers", names)
+		notifyUser("Modifier ignored", strings.Join(names, ", ")+" isn't configured for this engine")
+	}
+	return modified, rest, nil
+}
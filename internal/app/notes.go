@@ -0,0 +1,29 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// addNote attaches a note to the current session and its latest search.
+func addNote(text string) error {
+	sessionID := time.Now().Format("2006-01-02")
+
+	var searchID sql.NullInt64
+	err := db.QueryRow(
+		"SELECT id FROM searches WHERE session_id = ? ORDER BY id DESC LIMIT 1", sessionID,
+	).Scan(&searchID)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to find latest search: %w", err)
+	}
+
+	_, err = db.Exec(
+		"INSERT INTO notes (session_id, search_id, text) VALUES (?, ?, ?)",
+		sessionID, searchID, seal(text),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save note: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package app
+
+import (
+	"log/slog"
+	"os/exec"
+	"strings"
+)
+
+// notifyUser shows a desktop notification when interface.notifications is
+// on. The CLI usually runs from a hotkey, where nobody sees its output or
+// log. It uses notify-send and falls back to calling the notification
+// service over D-Bus with gdbus, which ships with GLib on most desktops.
+func notifyUser(summary, body string) {
+	if !config.Interface.Notifications || dryRun {
+		return
+	}
+	var cmd *exec.Cmd
+	if _, err := exec.LookPath("notify-send"); err == nil {
+		cmd = exec.Command("notify-send", "-a", appName, summary, body)
+	} else {
+		cmd = exec.Command("gdbus", "call", "--session",
+			"--dest", "org.freedesktop.Notifications",
+			"--object-path", "/org/freedesktop/Notifications",
+			"--method", "org.freedesktop.Notifications.Notify",
+			appName, "0", "", gvariantString(summary), gvariantString(body), "[]", "{}", "-1")
+	}
+	if err := runCommand(cmd); err != nil {
+		slog.Warn("Failed to send notification", "err", err)
+	}
+}
+
+// gvariantString quotes text for gdbus, which parses its arguments as
+// GVariant text.
+func gvariantString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
@@ -0,0 +1,135 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	defaultObsidianDailyNote = "Daily/{date}.md"
+	defaultObsidianTopicDir  = "Rabbit Holes"
+	defaultObsidianTemplate  = "- {time} [[{date}]] **{query}** via {engine}: [{title}]({url}) {tags}"
+)
+
+// obsidianEntry is what gets rendered into the vault; it comes from either
+// the latest search or the latest bookmark.
+type obsidianEntry struct {
+	Query  string
+	Engine string
+	URL    string
+	Title  string
+	Tags   []string
+	When   time.Time
+}
+
+func latestSearchEntry() (obsidianEntry, error) {
+	var e obsidianEntry
+	var url, title sql.NullString
+	err := db.QueryRow(`
+		SELECT s.query, s.engine_name, s.timestamp, w.url, s.page_title FROM searches s
+		LEFT JOIN research_windows w ON w.search_id = s.id
+		ORDER BY s.id DESC LIMIT 1`).Scan(&e.Query, &e.Engine, &e.When, &url, &title)
+	if err == sql.ErrNoRows {
+		return e, fmt.Errorf("no searches to capture yet")
+	}
+	if err != nil {
+		return e, fmt.Errorf("failed to read latest search: %w", err)
+	}
+	e.URL, e.Title = url.String, title.String
+	unsealAll(&e.Query, &e.URL, &e.Title)
+	return e, nil
+}
+
+func latestBookmarkEntry() (obsidianEntry, error) {
+	var e obsidianEntry
+	var query, engine sql.NullString
+	var tags string
+	err := db.QueryRow(`
+		SELECT b.url, b.title, b.tags, b.created_at, s.query, s.engine_name FROM bookmarks b
+		LEFT JOIN searches s ON s.id = b.search_id
+		ORDER BY b.id DESC LIMIT 1`).Scan(&e.URL, &e.Title, &tags, &e.When, &query, &engine)
+	if err == sql.ErrNoRows {
+		return e, fmt.Errorf("no bookmarks to capture yet")
+	}
+	if err != nil {
+		return e, fmt.Errorf("failed to read latest bookmark: %w", err)
+	}
+	e.Query, e.Engine = unseal(query.String), engine.String
+	unsealAll(&e.URL, &e.Title)
+	if tags != "" {
+		e.Tags = strings.Split(tags, ",")
+	}
+	return e, nil
+}
+
+func (e obsidianEntry) render(template string) string {
+	title := e.Title
+	if title == "" {
+		title = e.URL
+	}
+	var tags []string
+	for _, tag := range e.Tags {
+		tags = append(tags, "#"+strings.ReplaceAll(tag, " ", "-"))
+	}
+	when := e.When.Local()
+	rendered := strings.NewReplacer(
+		"{query}", e.Query,
+		"{engine}", e.Engine,
+		"{url}", e.URL,
+		"{title}", title,
+		"{tags}", strings.Join(tags, " "),
+		"{date}", when.Format("2006-01-02"),
+		"{time}", when.Format("15:04"),
+	).Replace(template)
+	return strings.TrimRight(rendered, " ")
+}
+
+// captureToObsidian appends the entry to today's daily note, or to a
+// per-topic note (backlinked to the daily note) when topic is set.
+func captureToObsidian(e obsidianEntry, topic string) (string, error) {
+	cfg := config.Obsidian
+	if cfg.VaultPath == "" {
+		return "", fmt.Errorf("obsidian.vault_path is not set in %s", configPath)
+	}
+
+	vault := expandHome(cfg.VaultPath)
+	date := e.When.Local().Format("2006-01-02")
+	var notePath string
+	if topic != "" {
+		e.Tags = append(e.Tags, topic)
+		notePath = filepath.Join(vault, cfg.TopicFolder, sanitizeNoteName(topic)+".md")
+	} else {
+		notePath = filepath.Join(vault, strings.ReplaceAll(cfg.DailyNote, "{date}", date))
+	}
+
+	if err := os.MkdirAll(filepath.Dir(notePath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create note directory: %w", err)
+	}
+
+	file, err := os.OpenFile(notePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return "", fmt.Errorf("failed to open note: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := fmt.Fprintln(file, e.render(cfg.Template)); err != nil {
+		return "", fmt.Errorf("failed to write note: %w", err)
+	}
+	return notePath, nil
+}
+
+// expandHome resolves a leading ~ so paths can be written as in a shell.
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		return filepath.Join(os.Getenv("HOME"), path[1:])
+	}
+	return path
+}
+
+func sanitizeNoteName(name string) string {
+	return strings.NewReplacer("/", "-", "\\", "-", ":", "-").Replace(strings.TrimSpace(name))
+}
@@ -0,0 +1,64 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// captureScreenRegion lets the user drag out a screen region and returns
+// it as PNG: grim + slurp on Wayland, maim on X11, screencapture on macOS.
+func captureScreenRegion() ([]byte, error) {
+	var cmd *exec.Cmd
+	switch sessionType() {
+	case "macos":
+		return captureMacScreenRegion()
+	case "windows":
+		return nil, fmt.Errorf("search --ocr isn't supported on Windows")
+	case "wayland":
+		region, err := commandOutput(exec.Command("slurp"))
+		if err != nil {
+			return nil, fmt.Errorf("region selection cancelled or slurp missing: %w", err)
+		}
+		cmd = exec.Command("grim", "-g", strings.TrimSpace(string(region)), "-")
+	default:
+		cmd = exec.Command("maim", "--select", "--format", "png")
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	image, err := commandOutput(cmd)
+	if err != nil {
+		if detail := strings.TrimSpace(stderr.String()); detail != "" {
+			return nil, fmt.Errorf("screenshot failed: %w: %s", err, detail)
+		}
+		return nil, fmt.Errorf("screenshot failed (is %s installed?): %w", cmd.Args[0], err)
+	}
+	if len(image) == 0 {
+		return nil, fmt.Errorf("screenshot is empty")
+	}
+	return image, nil
+}
+
+// ocrScreenRegion captures a region and recognizes its text with
+// tesseract, joining lines into a single query.
+func ocrScreenRegion() (string, error) {
+	image, err := captureScreenRegion()
+	if err != nil {
+		return "", err
+	}
+
+	cmd := exec.Command("tesseract", "stdin", "stdout")
+	cmd.Stdin = bytes.NewReader(image)
+	out, err := commandOutput(cmd)
+	if err != nil {
+		return "", fmt.Errorf("tesseract failed (is it installed?): %w", err)
+	}
+
+	text := strings.Join(strings.Fields(string(out)), " ")
+	if text == "" {
+		return "", fmt.Errorf("no text recognized in the selected region")
+	}
+	return text, nil
+}
@@ -0,0 +1,17 @@
+package app
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// jsonOutput is set by the global --json flag; read commands print
+// machine-readable JSON instead of text so status bars and scripts can
+// consume them.
+var jsonOutput bool
+
+func printJSON(v any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
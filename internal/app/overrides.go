@@ -0,0 +1,63 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// envOverride maps a RABBITHOLE_* variable onto one config setting.
+type envOverride struct {
+	Name  string
+	Apply func(value string) error
+}
+
+var envOverrides = []envOverride{
+	{"RABBITHOLE_DB_PATH", setString(&config.Database.Path)},
+	{"RABBITHOLE_LAUNCHER", setString(&config.Interface.Launcher)},
+	{"RABBITHOLE_BROWSER", setString(&config.Behavior.Browser)},
+	{"RABBITHOLE_FIREFOX_PROFILE", setString(&config.Behavior.FirefoxProfile)},
+	{"RABBITHOLE_OPEN_MODE", setString(&config.Behavior.OpenMode)},
+	{"RABBITHOLE_SELECTION_METHOD", setString(&config.Behavior.SelectionMethod)},
+	{"RABBITHOLE_MAX_WINDOWS", setInt(&config.Behavior.MaxWindows)},
+	{"RABBITHOLE_PLACEMENT_BACKEND", setString(&config.Placement.Backend)},
+}
+
+// envOverridden lists the variables that changed the loaded config.
+var envOverridden []string
+
+func setString(field *string) func(string) error {
+	return func(value string) error {
+		*field = value
+		return nil
+	}
+}
+
+func setInt(field *int) func(string) error {
+	return func(value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		*field = n
+		return nil
+	}
+}
+
+// applyEnvOverrides lets RABBITHOLE_* variables win over the config files,
+// e.g. RABBITHOLE_DB_PATH=/tmp/test.db for a throwaway run. Empty variables
+// are ignored.
+func applyEnvOverrides() error {
+	envOverridden = nil
+	for _, override := range envOverrides {
+		value := os.Getenv(override.Name)
+		if value == "" {
+			continue
+		}
+		if err := override.Apply(value); err != nil {
+			return fmt.Errorf("invalid %s: %w", override.Name, err)
+		}
+		envOverridden = append(envOverridden, override.Name)
+	}
+	return nil
+}
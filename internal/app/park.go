@@ -0,0 +1,123 @@
+package app
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"rabbithole/internal/x11"
+)
+
+// parkActiveWindow minimizes the focused research window instead of
+// closing it, so the rabbit hole can be picked up again with unpark.
+func parkActiveWindow() (researchWindow, error) {
+	w, err := activeResearchWindow()
+	if err != nil {
+		return w, err
+	}
+	return w, parkWindow(w)
+}
+
+func parkWindow(w researchWindow) error {
+	if err := minimizeWindow(w.WindowID); err != nil {
+		return fmt.Errorf("failed to minimize window: %w", err)
+	}
+	if _, err := db.Exec("UPDATE research_windows SET parked_at = CURRENT_TIMESTAMP WHERE id = ?", w.ID); err != nil {
+		return fmt.Errorf("failed to record parked window: %w", err)
+	}
+	return nil
+}
+
+func minimizeWindow(windowID string) error {
+	if x, err := nativeX11(); err == nil {
+		window, err := x11.ParseWindowID(windowID)
+		if err != nil {
+			return err
+		}
+		return x.Iconify(window)
+	}
+	return runCommand(exec.Command("xdotool", "windowminimize", windowID))
+}
+
+// parkedWindows returns parked research windows that are still open,
+// most recently parked first.
+func parkedWindows() ([]researchWindow, error) {
+	open, err := openWindowIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`
+		SELECT id, search_id, window_id, url, title FROM research_windows
+		WHERE parked_at IS NOT NULL
+		AND id IN (SELECT MAX(id) FROM research_windows GROUP BY window_id)
+		ORDER BY parked_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query parked windows: %w", err)
+	}
+	defer rows.Close()
+
+	var windows []researchWindow
+	for rows.Next() {
+		var w researchWindow
+		if err := rows.Scan(&w.ID, &w.SearchID, &w.WindowID, &w.URL, &w.Title); err != nil {
+			return nil, fmt.Errorf("failed to read parked window: %w", err)
+		}
+		if open[w.WindowID] {
+			windows = append(windows, w)
+		}
+	}
+	return windows, rows.Err()
+}
+
+// unparkWindow restores a parked window, asking in the launcher which one
+// when several are parked.
+func unparkWindow() (researchWindow, error) {
+	windows, err := parkedWindows()
+	if err != nil {
+		return researchWindow{}, err
+	}
+	if len(windows) == 0 {
+		return researchWindow{}, fmt.Errorf("no parked research windows")
+	}
+
+	w := windows[0]
+	if len(windows) > 1 {
+		options := make([]string, len(windows))
+		for i, pw := range windows {
+			label := unseal(pw.Title)
+			if label == "" {
+				label = unseal(pw.URL)
+			}
+			options[i] = fmt.Sprintf("%d: %s", pw.ID, label)
+		}
+		selected, err := runLauncher("Unpark:", options, 15)
+		if err != nil {
+			return researchWindow{}, err
+		}
+		idStr, _, _ := strings.Cut(selected, ":")
+		id, err := strconv.ParseInt(strings.TrimSpace(idStr), 10, 64)
+		if err != nil {
+			return researchWindow{}, fmt.Errorf("invalid selection: %s", selected)
+		}
+		found := false
+		for _, pw := range windows {
+			if pw.ID == id {
+				w, found = pw, true
+			}
+		}
+		if !found {
+			return researchWindow{}, fmt.Errorf("invalid selection: %s", selected)
+		}
+	}
+
+	// Activating an iconified window maps it again under EWMH
+	if err := activateWindow(w.WindowID); err != nil {
+		return w, fmt.Errorf("failed to restore window: %w", err)
+	}
+	if _, err := db.Exec("UPDATE research_windows SET parked_at = NULL WHERE id = ?", w.ID); err != nil {
+		return w, fmt.Errorf("failed to record restored window: %w", err)
+	}
+	return w, nil
+}
@@ -0,0 +1,195 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"rabbithole/internal/wm"
+	"rabbithole/internal/x11"
+)
+
+type windowGeometry = wm.Geometry
+
+// windowDetectTimeout is how long to wait for the browser's new window.
+func windowDetectTimeout() time.Duration {
+	return time.Duration(config.Behavior.WindowTimeoutMs) * time.Millisecond
+}
+
+// placementBackend moves a freshly detected research window into place.
+// describe returns the equivalent commands for --dry-run. detect, when set,
+// replaces X11 detection: it runs launch, which returns the browser's PID,
+// and returns the ID of the new window it opened (see isLaunchedWindow).
+// placeNew, when set, replaces both for window managers that can only place
+// a window as it opens, or that open windows themselves: it arranges the
+// placement and opens the window, with launch or by other means.
+type placementBackend struct {
+	place    func(windowID string, g windowGeometry) error
+	describe func(windowID string, g windowGeometry) []string
+	detect   func(class string, launch func() (int, error)) (string, error)
+	placeNew func(l browserLaunch, g windowGeometry, launch func() (int, error)) (string, error)
+}
+
+var placementBackends = map[string]placementBackend{
+	"wmctrl": {place: placeWithWmctrl, describe: describeWmctrl},
+	"i3":     {place: placeWithI3, describe: describeI3("i3-msg")},
+	"sway":   {place: placeWithI3, describe: describeI3("swaymsg")},
+
+	"bspwm":        commandBackend(bspwmCommands),
+	"herbstluftwm": commandBackend(herbstluftwmCommands),
+	"hyprland":     hyprlandBackend(),
+	"gnome":        gnomeBackend(),
+	"kwin":         kwinBackend(),
+	"cdp":          cdpBackend(),
+	"marionette":   marionetteBackend(),
+
+	"yabai":       yabaiBackend(),
+	"applescript": appleScriptBackend(),
+	"win32":       win32Backend(),
+}
+
+// defaultPlacementBackend is used when placement.backend isn't set.
+func defaultPlacementBackend() string {
+	switch {
+	case onWindows():
+		return "win32"
+	case !onMacOS():
+		return "wmctrl"
+	}
+	if _, err := exec.LookPath("yabai"); err == nil {
+		return "yabai"
+	}
+	return "applescript"
+}
+
+func currentPlacement() (placementBackend, error) {
+	name := config.Placement.Backend
+	if name == "" {
+		name = defaultPlacementBackend()
+	}
+	backend, ok := placementBackends[name]
+	if !ok {
+		return placementBackend{}, fmt.Errorf("unsupported placement backend %q (use wmctrl, i3, sway, bspwm, herbstluftwm, hyprland, gnome, kwin, cdp, marionette, yabai, applescript or win32)", name)
+	}
+	return backend, nil
+}
+
+// sideWindowGeometry places the research window near the top right corner.
+func sideWindowGeometry() windowGeometry {
+	screenWidth, _ := getScreenDimensions()
+	rightMargin := 120
+	topMargin := 80
+	return windowGeometry{
+		X:      screenWidth - config.Behavior.WindowWidth - rightMargin,
+		Y:      topMargin,
+		Width:  config.Behavior.WindowWidth,
+		Height: config.Behavior.WindowHeight,
+	}
+}
+
+func unmaximizeArgs(windowID string) []string {
+	return []string{"-i", "-r", windowID, "-b", "remove,maximized_vert,maximized_horz"}
+}
+
+func positionArgs(windowID string, g windowGeometry) []string {
+	return []string{"-i", "-r", windowID, "-e", fmt.Sprintf("0,%d,%d,%d,%d", g.X, g.Y, g.Width, g.Height)}
+}
+
+// placeWithWmctrl positions the window with absolute EWMH geometry, which
+// works on stacking window managers but is ignored by most tiling ones. The
+// requests go straight to the X server; wmctrl is only run without one.
+func placeWithWmctrl(windowID string, g windowGeometry) error {
+	if err := positionWindow(windowID, g); err != nil {
+		return err
+	}
+	if config.Placement.Mode == "workspace" {
+		return moveToDesktop(windowID, placementWorkspace(), config.Placement.SwitchToWorkspace)
+	}
+	return nil
+}
+
+func positionWindow(windowID string, g windowGeometry) error {
+	if x, err := nativeX11(); err == nil {
+		window, err := x11.ParseWindowID(windowID)
+		if err != nil {
+			return err
+		}
+		if err := x.Unmaximize(window); err != nil {
+			slog.Warn("Failed to un-maximize window", "window", windowID, "err", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+		if err := x.MoveResize(window, x11.Geometry(g)); err != nil {
+			return fmt.Errorf("failed to position window: %w", err)
+		}
+		slog.Debug("Positioned Firefox window", "window", windowID, "x", g.X, "y", g.Y,
+			"width", g.Width, "height", g.Height)
+		return nil
+	}
+
+	// Un-maximize the window first, then position it
+	if err := runCommand(exec.Command("wmctrl", unmaximizeArgs(windowID)...)); err != nil {
+		slog.Warn("Failed to un-maximize window", "window", windowID, "err", err)
+	}
+
+	// Small delay to let the un-maximize take effect
+	time.Sleep(100 * time.Millisecond)
+
+	if err := runCommand(exec.Command("wmctrl", positionArgs(windowID, g)...)); err != nil {
+		return fmt.Errorf("failed to position window: %w", err)
+	}
+	slog.Debug("Positioned Firefox window", "window", windowID, "x", g.X, "y", g.Y,
+		"width", g.Width, "height", g.Height)
+	return nil
+}
+
+func describeWmctrl(windowID string, g windowGeometry) []string {
+	lines := []string{
+		shellJoin(append([]string{"wmctrl"}, unmaximizeArgs(windowID)...)),
+		shellJoin(append([]string{"wmctrl"}, positionArgs(windowID, g)...)),
+	}
+	if config.Placement.Mode == "workspace" {
+		desktop := placementWorkspace()
+		if index, err := desktopIndex(desktop); err == nil {
+			desktop = strconv.Itoa(index)
+		}
+		lines = append(lines, shellJoin([]string{"wmctrl", "-i", "-r", windowID, "-t", desktop}))
+		if config.Placement.SwitchToWorkspace {
+			lines = append(lines, shellJoin([]string{"wmctrl", "-s", desktop}))
+		}
+	}
+	return lines
+}
+
+// tiledGeometries splits the right half of the screen into a grid of n
+// cells, filled row by row, for windows opened together. A single window
+// keeps the usual side geometry.
+func tiledGeometries(n int) []windowGeometry {
+	if n <= 1 {
+		return []windowGeometry{sideWindowGeometry()}
+	}
+	screenWidth, screenHeight := getScreenDimensions()
+	margin := 40
+	left := screenWidth / 2
+	width := screenWidth - left - margin
+	height := screenHeight - 2*margin
+
+	cols := 1
+	for cols*cols < n {
+		cols++
+	}
+	rows := (n + cols - 1) / cols
+
+	tiles := make([]windowGeometry, n)
+	for i := range tiles {
+		row, col := i/cols, i%cols
+		tiles[i] = windowGeometry{
+			X:      left + col*width/cols,
+			Y:      margin + row*height/rows,
+			Width:  width / cols,
+			Height: height / rows,
+		}
+	}
+	return tiles
+}
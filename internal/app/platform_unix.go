@@ -0,0 +1,64 @@
+//go:build !windows
+
+package app
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"rabbithole/internal/wm"
+)
+
+// detachedProcAttr starts a background process in a session of its own,
+// so it outlives the hotkey invocation that started it.
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
+
+// lockFile takes an exclusive lock on file, or fails right away when it
+// is held and wait isn't set.
+func lockFile(file *os.File, wait bool) error {
+	how := syscall.LOCK_EX
+	if !wait {
+		how |= syscall.LOCK_NB
+	}
+	return syscall.Flock(int(file.Fd()), how)
+}
+
+func unlockFile(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
+
+// terminateProcess asks a process to exit.
+func terminateProcess(pid int) error {
+	return syscall.Kill(pid, syscall.SIGTERM)
+}
+
+// processStartTime identifies when a process started, so a PID that was
+// reused by another process can be told apart. macOS has no /proc.
+func processStartTime(pid int) (string, error) {
+	if stat, err := wm.ReadProcStat(pid); err == nil {
+		return stat.Start, nil
+	}
+	out, err := commandOutput(exec.Command("ps", "-o", "lstart=", "-p", strconv.Itoa(pid)))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// checkFileTrusted refuses files that another user owns or could have
+// changed, for settings picked up from the working directory.
+func checkFileTrusted(info os.FileInfo) error {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && int(stat.Uid) != os.Getuid() {
+		return fmt.Errorf("owned by another user (uid %d)", stat.Uid)
+	}
+	if info.Mode().Perm()&0022 != 0 {
+		return fmt.Errorf("writable by group or others (%s)", info.Mode().Perm())
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+//go:build windows
+
+package app
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	detachedProcess         = 0x00000008
+	lockfileFailImmediately = 0x00000001
+	lockfileExclusiveLock   = 0x00000002
+)
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+// detachedProcAttr starts a background process without a console, so it
+// outlives the hotkey invocation that started it.
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{CreationFlags: detachedProcess | syscall.CREATE_NEW_PROCESS_GROUP, HideWindow: true}
+}
+
+// lockRegion is the byte range locked: Windows locks are mandatory, so
+// the lock sits past the end of the file and readers of its content (the
+// launcher PID) aren't blocked.
+func lockRegion() *syscall.Overlapped {
+	return &syscall.Overlapped{Offset: ^uint32(0), OffsetHigh: ^uint32(0) >> 1}
+}
+
+// lockFile takes an exclusive lock on file, or fails right away when it
+// is held and wait isn't set.
+func lockFile(file *os.File, wait bool) error {
+	flags := uintptr(lockfileExclusiveLock)
+	if !wait {
+		flags |= lockfileFailImmediately
+	}
+	r, _, err := procLockFileEx.Call(file.Fd(), flags, 0, 1, 0, uintptr(unsafe.Pointer(lockRegion())))
+	if r == 0 {
+		return err
+	}
+	return nil
+}
+
+func unlockFile(file *os.File) error {
+	r, _, err := procUnlockFileEx.Call(file.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(lockRegion())))
+	if r == 0 {
+		return err
+	}
+	return nil
+}
+
+// terminateProcess ends a process; Windows has no SIGTERM to ask with.
+func terminateProcess(pid int) error {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return process.Kill()
+}
+
+// processStartTime identifies when a process started, so a PID that was
+// reused by another process can be told apart.
+func processStartTime(pid int) (string, error) {
+	handle, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		return "", err
+	}
+	defer syscall.CloseHandle(handle)
+	var creation, exit, kernel, user syscall.Filetime
+	if err := syscall.GetProcessTimes(handle, &creation, &exit, &kernel, &user); err != nil {
+		return "", err
+	}
+	return fmt.Sprint(creation.Nanoseconds()), nil
+}
+
+// checkFileTrusted accepts every file: Windows keeps other users out of the
+// profile directory, and its permissions don't map to mode bits.
+func checkFileTrusted(info os.FileInfo) error {
+	return nil
+}
@@ -0,0 +1,63 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+)
+
+// profile selects an isolated set of config, database and log files; empty
+// is the default setup. Set from --profile or RABBITHOLE_PROFILE.
+var profile string
+
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// selectProfile validates the profile and exports it, so detached helpers
+// like the window tracker work in the same profile.
+func selectProfile(name string) error {
+	if name == "" {
+		name = os.Getenv("RABBITHOLE_PROFILE")
+	}
+	if name == "" {
+		return nil
+	}
+	if !profileNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q (use letters, digits, - and _)", name)
+	}
+	profile = name
+	return os.Setenv("RABBITHOLE_PROFILE", name)
+}
+
+// profileSubdir is where a profile's files live below the usual
+// rabbithole directories; filepath.Join drops it for the default profile.
+func profileSubdir() string {
+	if profile == "" {
+		return ""
+	}
+	return filepath.Join("profiles", profile)
+}
+
+// executable is the rabbithole binary to start background commands
+// with. A program embedding rabbithole through its Go API is a different
+// binary, so it starts the one in PATH.
+func executable() (string, error) {
+	if embedded {
+		return exec.LookPath(appName)
+	}
+	return os.Executable()
+}
+
+// selfCommand is how hotkeys and services should invoke rabbithole to stay
+// in the current profile.
+func selfCommand() string {
+	execPath, err := executable()
+	if err != nil {
+		execPath = appName // Assume it's in PATH
+	}
+	if profile != "" {
+		return execPath + " --profile " + profile
+	}
+	return execPath
+}
@@ -0,0 +1,114 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+const projectConfigName = ".rabbithole.json"
+
+var (
+	// projectConfigPath is the overlay merged into the config, if any
+	projectConfigPath string
+	projectName       string
+	projectExtraTags  []string
+)
+
+// projectOverlay is what a project config may set. A repository can ship
+// one, so it's limited to settings that can't run commands or move the
+// database: no hooks, routing scripts, upload or archive commands.
+type projectOverlay struct {
+	Project       string          `json:"project"`
+	Tags          []string        `json:"tags"`
+	SearchEngines []SearchEngine  `json:"search_engines"`
+	Placement     json.RawMessage `json:"placement"`
+}
+
+// findProjectConfig looks for a project overlay in the working directory
+// and its parents, stopping at the home directory like a VCS root search.
+// Files someone else could have written, e.g. in /tmp, are skipped.
+func findProjectConfig() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	home := os.Getenv("HOME")
+	for {
+		path := filepath.Join(dir, projectConfigName)
+		if info, err := os.Stat(path); err == nil {
+			err := checkFileTrusted(info)
+			if err == nil {
+				return path
+			}
+			slog.Warn("Ignoring project config", "path", path, "reason", err)
+		}
+		parent := filepath.Dir(dir)
+		if dir == home || parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// applyProjectConfig merges the project overlay over the loaded config.
+// Its placement settings replace the global ones, and its engines come
+// first in the menu, replacing global engines with the same key. Searches
+// are tagged with the project name (default: the directory name) and its
+// tags.
+func applyProjectConfig() error {
+	projectConfigPath, projectName, projectExtraTags = "", "", nil
+	path := findProjectConfig()
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("can't read project config %s: %w", path, err)
+	}
+	var overlay projectOverlay
+	if err := json.Unmarshal(data, &overlay); err != nil {
+		return fmt.Errorf("failed to parse project config %s: %w", path, err)
+	}
+	if len(overlay.Placement) > 0 {
+		if err := json.Unmarshal(overlay.Placement, &config.Placement); err != nil {
+			return fmt.Errorf("failed to parse placement in project config %s: %w", path, err)
+		}
+	}
+
+	engines := overlay.SearchEngines
+	projectKeys := make(map[string]bool)
+	for i, engine := range engines {
+		// The browser is a program to run, which is the global config's call
+		if engine.Browser != "" {
+			slog.Warn("Ignoring browser of project engine", "engine", engine.Name, "browser", engine.Browser)
+			engines[i].Browser = ""
+		}
+		projectKeys[engine.Key] = true
+	}
+	for _, engine := range config.SearchEngines {
+		if !projectKeys[engine.Key] {
+			engines = append(engines, engine)
+		}
+	}
+	config.SearchEngines = engines
+
+	projectConfigPath = path
+	projectName = overlay.Project
+	if projectName == "" {
+		projectName = filepath.Base(filepath.Dir(path))
+	}
+	projectExtraTags = overlay.Tags
+	return nil
+}
+
+// projectTags returns the tags every search in the project gets.
+func projectTags() []string {
+	if projectName == "" {
+		return nil
+	}
+	return append([]string{projectName}, projectExtraTags...)
+}
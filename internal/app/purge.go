@@ -0,0 +1,208 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// purgeFilter selects the searches to delete. Zero fields don't filter.
+type purgeFilter struct {
+	Since  time.Time
+	Before time.Time
+	Engine string
+}
+
+// sqliteTime formats t like CURRENT_TIMESTAMP, which is UTC.
+func sqliteTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
+
+// parsePurgeTime accepts a date (local midnight) or an age like "30d".
+func parsePurgeTime(value string) (time.Time, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return time.Time{}, fmt.Errorf("invalid age %q, expected e.g. 30d", value)
+		}
+		return time.Now().AddDate(0, 0, -n), nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD or e.g. 30d", value)
+	}
+	return t, nil
+}
+
+// where builds the condition on a table's timestamp column, plus the
+// engine for searches.
+func (f purgeFilter) where(timeColumn string, withEngine bool) (string, []any) {
+	conditions := []string{"1"}
+	var args []any
+	if !f.Since.IsZero() {
+		conditions = append(conditions, timeColumn+" >= ?")
+		args = append(args, sqliteTime(f.Since))
+	}
+	if !f.Before.IsZero() {
+		conditions = append(conditions, timeColumn+" < ?")
+		args = append(args, sqliteTime(f.Before))
+	}
+	if withEngine && f.Engine != "" {
+		conditions = append(conditions, "engine_name = ?")
+		args = append(args, f.Engine)
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// purgeHistory deletes the matching searches with their research windows,
+// navigation trails, page archives and journal events. Bookmarks and notes
+// are kept but no longer point at the search. Clipboard history and snippets in the time
+// range go too, unless the purge is limited to one engine. It returns the
+// number of searches deleted.
+func purgeHistory(f purgeFilter) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to start purge: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Collect the ids once; the transaction keeps to one connection, which
+	// the temporary tables belong to
+	where, args := f.where("timestamp", true)
+	_, err = tx.Exec("CREATE TEMP TABLE purged_searches AS SELECT id FROM searches WHERE "+where, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to select searches to purge: %w", err)
+	}
+	purgedIDs, archives, err := deletePurgedSearches(tx)
+	if err != nil {
+		return 0, err
+	}
+
+	if f.Engine == "" {
+		for _, table := range []string{"clipboard_history", "snippets"} {
+			where, args := f.where("captured_at", false)
+			if _, err := tx.Exec("DELETE FROM "+table+" WHERE "+where, args...); err != nil {
+				return 0, fmt.Errorf("failed to purge %s: %w", table, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit purge: %w", err)
+	}
+	finishPurge(purgedIDs, archives)
+	return int64(len(purgedIDs)), nil
+}
+
+// deletePurgedSearches deletes the searches in temp.purged_searches with
+// their research windows, trails and archive records, and drops the table.
+// Synced searches leave a tombstone so sync deletes them elsewhere too. It
+// returns the ids of the deleted searches and the archived pages, for
+// finishPurge once the transaction is committed.
+func deletePurgedSearches(tx *sql.Tx) ([]int64, []string, error) {
+	ids, err := queryStringsTx(tx, "SELECT id FROM purged_searches")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to select searches to purge: %w", err)
+	}
+	var purgedIDs []int64
+	for _, id := range ids {
+		n, _ := strconv.ParseInt(id, 10, 64)
+		purgedIDs = append(purgedIDs, n)
+	}
+
+	_, err = tx.Exec("CREATE TEMP TABLE purged_windows AS SELECT id FROM research_windows WHERE search_id IN (SELECT id FROM purged_searches)")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to select windows to purge: %w", err)
+	}
+
+	archives, err := queryStringsTx(tx, `SELECT path FROM page_archives
+		WHERE search_id IN (SELECT id FROM purged_searches) OR window_id IN (SELECT id FROM purged_windows)`)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list archived pages: %w", err)
+	}
+
+	statements := []string{
+		`INSERT OR IGNORE INTO sync_deletions (sync_id)
+			SELECT sync_id FROM searches WHERE sync_id IS NOT NULL AND id IN (SELECT id FROM purged_searches)`,
+		"DELETE FROM navigations WHERE window_id IN (SELECT id FROM purged_windows)",
+		"DELETE FROM page_archives WHERE search_id IN (SELECT id FROM purged_searches) OR window_id IN (SELECT id FROM purged_windows)",
+		"UPDATE bookmarks SET search_id = NULL, window_id = NULL WHERE search_id IN (SELECT id FROM purged_searches) OR window_id IN (SELECT id FROM purged_windows)",
+		"UPDATE notes SET search_id = NULL WHERE search_id IN (SELECT id FROM purged_searches)",
+		"DELETE FROM research_windows WHERE id IN (SELECT id FROM purged_windows)",
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return nil, nil, fmt.Errorf("failed to purge: %w", err)
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM searches WHERE id IN (SELECT id FROM purged_searches)"); err != nil {
+		return nil, nil, fmt.Errorf("failed to purge searches: %w", err)
+	}
+
+	if _, err := tx.Exec("DROP TABLE temp.purged_searches; DROP TABLE temp.purged_windows"); err != nil {
+		return nil, nil, fmt.Errorf("failed to finish purge: %w", err)
+	}
+	return purgedIDs, archives, nil
+}
+
+// finishPurge removes what purged searches left outside the database: the
+// archived pages and their events in the journal.
+func finishPurge(searchIDs []int64, archives []string) {
+	for _, path := range archives {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			slog.Warn("Failed to remove archived page", "path", path, "err", err)
+		}
+	}
+	if err := purgeJournal(searchIDs); err != nil {
+		slog.Warn("Failed to purge the journal", "err", err)
+	}
+}
+
+func queryStringsTx(tx *sql.Tx, query string) ([]string, error) {
+	rows, err := tx.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
+
+// countPurge is what purgeHistory would delete, for --dry-run.
+func countPurge(f purgeFilter) (int, error) {
+	where, args := f.where("timestamp", true)
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM searches WHERE "+where, args...).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to count searches: %w", err)
+	}
+	return n, nil
+}
+
+// pruneHistory applies Behavior.HistoryRetentionDays. It runs when the
+// database is opened and only writes when something has expired.
+func pruneHistory() {
+	if config.Behavior.HistoryRetentionDays <= 0 || dryRun {
+		return
+	}
+	f := purgeFilter{Before: time.Now().AddDate(0, 0, -config.Behavior.HistoryRetentionDays)}
+	if n, err := countPurge(f); err != nil || n == 0 {
+		return
+	}
+	purged, err := purgeHistory(f)
+	if err != nil {
+		slog.Error("Failed to prune history", "err", err)
+		return
+	}
+	slog.Info("Pruned history", "searches", purged, "retention_days", config.Behavior.HistoryRetentionDays)
+}
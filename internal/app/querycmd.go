@@ -0,0 +1,137 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"rabbithole/internal/store"
+)
+
+// queryResult is what a statement run by the query command returned.
+type queryResult struct {
+	Columns []string
+	Rows    [][]any
+	// Changed is the number of rows a write changed
+	Changed int64
+}
+
+// runQuery runs one SQL statement against the database. Unless write is
+// set it runs on a handle of its own that SQLite opens read-only, so
+// nothing in the statement (not even PRAGMA query_only) can change the
+// database. Sealed values are decrypted.
+func runQuery(statement string, write bool) (queryResult, error) {
+	handle := db
+	if !write {
+		readOnly, err := store.OpenReadOnly(config.Database.Path)
+		if err != nil {
+			return queryResult{}, err
+		}
+		defer readOnly.Close()
+		handle = readOnly
+	}
+
+	// One connection, so changes() counts this statement's changes
+	ctx := context.Background()
+	conn, err := handle.Conn(ctx)
+	if err != nil {
+		return queryResult{}, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer conn.Close()
+
+	rows, err := conn.QueryContext(ctx, statement)
+	if err != nil {
+		if !write && strings.Contains(err.Error(), "readonly") {
+			return queryResult{}, fmt.Errorf("query failed: %w (use --write to change the database)", err)
+		}
+		return queryResult{}, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var result queryResult
+	if result.Columns, err = rows.Columns(); err != nil {
+		return queryResult{}, fmt.Errorf("failed to read columns: %w", err)
+	}
+	for rows.Next() {
+		values := make([]any, len(result.Columns))
+		pointers := make([]any, len(values))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return queryResult{}, fmt.Errorf("failed to read row: %w", err)
+		}
+		for i, value := range values {
+			switch v := value.(type) {
+			case []byte:
+				values[i] = unseal(string(v))
+			case string:
+				values[i] = unseal(v)
+			}
+		}
+		result.Rows = append(result.Rows, values)
+	}
+	if err := rows.Err(); err != nil {
+		return queryResult{}, fmt.Errorf("query failed: %w", err)
+	}
+	rows.Close()
+
+	if write && len(result.Columns) == 0 {
+		if err := conn.QueryRowContext(ctx, "SELECT changes()").Scan(&result.Changed); err != nil {
+			return queryResult{}, fmt.Errorf("failed to count changed rows: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// printQueryResult prints the rows as a table, or with --json as an array
+// of objects keyed by column.
+func printQueryResult(result queryResult) error {
+	if jsonOutput {
+		objects := make([]map[string]any, 0, len(result.Rows))
+		for _, row := range result.Rows {
+			object := make(map[string]any, len(row))
+			for i, value := range row {
+				object[result.Columns[i]] = value
+			}
+			objects = append(objects, object)
+		}
+		return printJSON(objects)
+	}
+
+	if len(result.Columns) == 0 {
+		fmt.Printf("✅ %d row(s) changed\n", result.Changed)
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(result.Columns, "\t"))
+	for _, row := range result.Rows {
+		cells := make([]string, len(row))
+		for i, value := range row {
+			cells[i] = formatQueryValue(value)
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("(%d row(s))\n", len(result.Rows))
+	return nil
+}
+
+// formatQueryValue renders a value on one table line.
+func formatQueryValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case time.Time:
+		return v.Local().Format("2006-01-02 15:04:05")
+	case string:
+		return strings.NewReplacer("\t", " ", "\n", " ", "\r", " ").Replace(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
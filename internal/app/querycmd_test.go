@@ -0,0 +1,62 @@
+package app
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func setupQueryDatabase(t *testing.T) {
+	t.Helper()
+	useConfig(t)
+	config.Database.Path = filepath.Join(t.TempDir(), "searches.db")
+	if err := initDatabase(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(closeDatabase)
+	if _, err := logSearch("rabbit holes", "Wikipedia", "https://en.wikipedia.org/?q=%s", "https://en.wikipedia.org/?q=rabbit+holes", "manual", ""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunQueryReadOnly(t *testing.T) {
+	setupQueryDatabase(t)
+
+	result, err := runQuery("SELECT query, engine_name FROM searches", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Rows) != 1 || result.Rows[0][0] != "rabbit holes" {
+		t.Errorf("got %v", result.Rows)
+	}
+
+	for _, statement := range []string{
+		"DELETE FROM searches",
+		"PRAGMA query_only = OFF; DELETE FROM searches",
+		"PRAGMA query_only = OFF",
+		"DROP TABLE notes",
+	} {
+		runQuery(statement, false)
+		var count int
+		if err := db.QueryRow("SELECT COUNT(*) FROM searches").Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		if count != 1 {
+			t.Fatalf("%q changed the database without --write", statement)
+		}
+	}
+	if _, err := runQuery("DROP TABLE notes", false); err == nil {
+		t.Error("expected an error for a write without --write")
+	}
+}
+
+func TestRunQueryWrite(t *testing.T) {
+	setupQueryDatabase(t)
+
+	result, err := runQuery("DELETE FROM searches", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Changed != 1 {
+		t.Errorf("changed %d rows, want 1", result.Changed)
+	}
+}
@@ -0,0 +1,71 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+)
+
+const defaultRedactionReplacement = "[redacted]"
+
+type compiledRedaction struct {
+	re          *regexp.Regexp
+	replacement string
+}
+
+// redactions are the compiled config rules, swapped in by loadConfig.
+var redactions []compiledRedaction
+
+// compileRedactions fails on a bad pattern instead of skipping it, since a
+// skipped rule would quietly let through what it was meant to mask.
+func compileRedactions() error {
+	var compiled []compiledRedaction
+	for _, rule := range config.Redactions {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid redaction pattern %q: %w", rule.Name, err)
+		}
+		replacement := rule.Replacement
+		if replacement == "" {
+			replacement = defaultRedactionReplacement
+		}
+		compiled = append(compiled, compiledRedaction{re: re, replacement: replacement})
+	}
+	redactions = compiled
+	return nil
+}
+
+func redact(text string) string {
+	for _, r := range redactions {
+		text = r.re.ReplaceAllLiteralString(text, r.replacement)
+	}
+	return text
+}
+
+// redactLogAttr runs every string and error in a log record through the
+// rules, so queries are masked whichever message mentions them.
+func redactLogAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(redactions) == 0 {
+		return a
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(redact(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			a.Value = slog.StringValue(redact(err.Error()))
+		}
+	}
+	return a
+}
+
+// redactSearch masks a search for storage and webhooks. When the query
+// changed, the URL is rebuilt from the masked query since the original
+// holds it URL-encoded, where the patterns may not match.
+func redactSearch(query, engineURL, finalURL string) (string, string) {
+	redacted := redact(query)
+	if redacted == query {
+		return query, finalURL
+	}
+	return redacted, buildSearchURL(engineURL, redacted)
+}
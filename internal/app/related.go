@@ -0,0 +1,103 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// relatedSearchLimit is how many similar past searches the engine menu
+// lists.
+const relatedSearchLimit = 3
+
+// relatedPrefix marks past searches in the engine menu.
+const relatedPrefix = "↺ "
+
+// relatedSearch is a past search similar to the current query.
+type relatedSearch struct {
+	Query      string
+	EngineName string
+	EngineURL  string
+	Timestamp  time.Time
+}
+
+func (r relatedSearch) menuOption() string {
+	return fmt.Sprintf("%s%s · %s · %s", relatedPrefix, r.Query, r.EngineName, r.Timestamp.Local().Format("Jan 2 2006"))
+}
+
+// engine is the configured engine the search was made with, or one made
+// from what was logged if it's gone from the config.
+func (r relatedSearch) engine() SearchEngine {
+	for _, engine := range config.SearchEngines {
+		if engine.Name == r.EngineName {
+			return engine
+		}
+	}
+	return SearchEngine{Name: r.EngineName, URL: r.EngineURL}
+}
+
+// ftsQuery turns a query into an FTS5 expression matching any of its
+// words, so quotes and operators in a selection can't break the match.
+func ftsQuery(query string) string {
+	words := strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	var terms []string
+	seen := make(map[string]bool)
+	for _, word := range words {
+		word = strings.ToLower(word)
+		// Short words match too much history to say anything
+		if len([]rune(word)) < 3 || seen[word] {
+			continue
+		}
+		seen[word] = true
+		terms = append(terms, `"`+word+`"`)
+	}
+	return strings.Join(terms, " OR ")
+}
+
+// relatedSearches returns a few past searches whose queries share words
+// with query, best matches first and one per query.
+func relatedSearches(query string) []relatedSearch {
+	limit := relatedSearchLimit
+	match := ftsQuery(query)
+	// Sealed queries can't be matched
+	if config.Behavior.DisableRelatedSearches || match == "" || config.Database.Encryption != "" {
+		return nil
+	}
+	if err := initDatabase(); err != nil {
+		return nil
+	}
+
+	// Repeats of a query rank the same, newest first; enough rows are read
+	// to skip them
+	rows, err := db.Query(`
+		SELECT s.query, s.engine_name, s.engine_url, s.timestamp
+		FROM searches_fts JOIN searches s ON s.id = searches_fts.rowid
+		WHERE searches_fts MATCH ?
+		ORDER BY searches_fts.rank, s.id DESC
+		LIMIT ?`, match, limit*20)
+	if err != nil {
+		slog.Warn("Failed to look up related searches", "err", err)
+		return nil
+	}
+	defer rows.Close()
+
+	var related []relatedSearch
+	seen := make(map[string]bool)
+	for rows.Next() && len(related) < limit {
+		var r relatedSearch
+		if err := rows.Scan(&r.Query, &r.EngineName, &r.EngineURL, &r.Timestamp); err != nil {
+			slog.Warn("Failed to read related search", "err", err)
+			return related
+		}
+		if seen[r.Query] {
+			continue
+		}
+		seen[r.Query] = true
+		related = append(related, r)
+	}
+	return related
+}
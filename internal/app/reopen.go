@@ -0,0 +1,72 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"rabbithole/internal/wm"
+)
+
+// recordClosedWindow marks every research window row of a closed window
+// as closed and stores its last geometry on the newest one.
+func recordClosedWindow(searchID, researchWindowID int64, windowID string, g windowGeometry) {
+	geometry := ""
+	if g.Width > 0 && g.Height > 0 {
+		geometry = g.String()
+	}
+	err := inTransaction(func(tx *sql.Tx) error {
+		if _, err := tx.Exec("UPDATE research_windows SET geometry = ? WHERE id = ?", geometry, researchWindowID); err != nil {
+			return fmt.Errorf("failed to record window geometry: %w", err)
+		}
+		_, err := tx.Exec(
+			"UPDATE research_windows SET closed_at = CURRENT_TIMESTAMP WHERE window_id = ? AND closed_at IS NULL",
+			windowID,
+		)
+		return err
+	})
+	if err != nil {
+		slog.Error("Failed to record closed window", "err", err)
+	}
+	writeJournal(journalEvent{Event: "close", SearchID: searchID, ResearchWindowID: researchWindowID, Geometry: geometry})
+	runHook("on_close", config.Hooks.OnClose,
+		"SEARCH_ID", strconv.FormatInt(searchID, 10), "RESEARCH_WINDOW_ID", strconv.FormatInt(researchWindowID, 10),
+		"WINDOW_ID", windowID, "GEOMETRY", geometry)
+}
+
+// reopenLastClosed relaunches the most recently closed research window
+// that hasn't been reopened yet, at its previous position. Only the URL it
+// was opened with is known, not where its trail ended.
+func reopenLastClosed() (researchWindow, error) {
+	var w researchWindow
+	var geometry string
+	var tags sql.NullString
+	err := db.QueryRow(`
+		SELECT w.id, w.search_id, w.url, w.title, w.geometry, s.tags FROM research_windows w
+		LEFT JOIN searches s ON s.id = w.search_id
+		WHERE w.closed_at IS NOT NULL AND w.reopened_at IS NULL
+		ORDER BY w.closed_at DESC, w.id DESC LIMIT 1`).Scan(&w.ID, &w.SearchID, &w.URL, &w.Title, &geometry, &tags)
+	if err == sql.ErrNoRows {
+		return w, fmt.Errorf("no closed research windows to reopen")
+	}
+	if err != nil {
+		return w, fmt.Errorf("failed to find the last closed window: %w", err)
+	}
+
+	unsealAll(&w.URL, &w.Title)
+	launch := launchFor(engineForSearch(w.SearchID), tags.String, w.URL)
+	if geometry != "" {
+		if g, err := wm.ParseGeometry(geometry); err == nil {
+			launch.Geometry = g
+		}
+	}
+	if err := openResearchWindow(w.SearchID, launch); err != nil {
+		return w, err
+	}
+
+	if _, err := db.Exec("UPDATE research_windows SET reopened_at = CURRENT_TIMESTAMP WHERE id = ?", w.ID); err != nil {
+		slog.Error("Failed to mark window as reopened", "err", err)
+	}
+	return w, nil
+}
@@ -0,0 +1,230 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+type reportSearch struct {
+	ID        int64
+	Query     string
+	Engine    string
+	Trigger   string
+	Timestamp time.Time
+	URLs      []string
+	Trail     []reportPage
+	Notes     []string
+	Time      researchTime
+}
+
+type reportPage struct {
+	Title string
+	URL   string
+}
+
+// writeMarkdownReport renders one session as a Markdown document suitable
+// for pasting into a lab notebook.
+func writeMarkdownReport(w io.Writer, sessionID string) error {
+	searches, err := loadReportSearches(sessionID)
+	if err != nil {
+		return err
+	}
+
+	bookmarks, err := sessionBookmarks(sessionID)
+	if err != nil {
+		return err
+	}
+
+	sessionNotes, err := loadNotes("session_id = ? AND search_id IS NULL", sessionID)
+	if err != nil {
+		return err
+	}
+
+	pages := 0
+	var total researchTime
+	for _, s := range searches {
+		pages += len(s.Trail)
+		total.OpenSeconds += s.Time.OpenSeconds
+		total.FocusSeconds += s.Time.FocusSeconds
+	}
+
+	fmt.Fprintf(w, "# Research session %s\n\n", sessionID)
+	fmt.Fprintf(w, "_%d searches, %d pages visited, %d bookmarks_\n", len(searches), pages, len(bookmarks))
+	if total.OpenSeconds > 0 {
+		fmt.Fprintf(w, "\n_Time down the rabbit hole: %s_\n", total)
+	}
+
+	for _, s := range searches {
+		fmt.Fprintf(w, "\n## %s — %s\n\n", s.Timestamp.Local().Format("15:04"), escapeMarkdown(s.Query))
+		fmt.Fprintf(w, "- **Engine:** %s (%s)\n", s.Engine, s.Trigger)
+		if s.Time.OpenSeconds > 0 {
+			fmt.Fprintf(w, "- **Time:** %s\n", s.Time)
+		}
+		for _, u := range s.URLs {
+			fmt.Fprintf(w, "- **Opened:** <%s>\n", u)
+		}
+		if len(s.Trail) > 0 {
+			fmt.Fprintln(w, "- **Trail:**")
+			for i, page := range s.Trail {
+				if page.URL != "" {
+					fmt.Fprintf(w, "  %d. [%s](%s)\n", i+1, escapeMarkdown(page.Title), page.URL)
+				} else {
+					fmt.Fprintf(w, "  %d. %s\n", i+1, escapeMarkdown(page.Title))
+				}
+			}
+		}
+		for _, note := range s.Notes {
+			fmt.Fprintf(w, "- **Note:** %s\n", note)
+		}
+	}
+
+	if len(bookmarks) > 0 {
+		fmt.Fprintln(w, "\n## Bookmarks")
+		fmt.Fprintln(w)
+		for _, b := range bookmarks {
+			title := b.Title
+			if title == "" {
+				title = b.URL
+			}
+			line := fmt.Sprintf("- [%s](%s)", escapeMarkdown(title), b.URL)
+			if b.WaybackURL != "" {
+				line += fmt.Sprintf(" ([archived](%s))", b.WaybackURL)
+			}
+			if b.Tags != "" {
+				line += " — " + b.Tags
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+
+	if len(sessionNotes) > 0 {
+		fmt.Fprintln(w, "\n## Notes")
+		fmt.Fprintln(w)
+		for _, note := range sessionNotes {
+			fmt.Fprintf(w, "- %s\n", note)
+		}
+	}
+
+	return nil
+}
+
+func loadReportSearches(sessionID string) ([]reportSearch, error) {
+	rows, err := db.Query(
+		"SELECT id, query, engine_name, trigger_method, timestamp FROM searches WHERE session_id = ? ORDER BY timestamp",
+		sessionID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query searches: %w", err)
+	}
+	defer rows.Close()
+
+	var searches []reportSearch
+	for rows.Next() {
+		var s reportSearch
+		if err := rows.Scan(&s.ID, &s.Query, &s.Engine, &s.Trigger, &s.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to read search: %w", err)
+		}
+		s.Query = unseal(s.Query)
+		searches = append(searches, s)
+	}
+	rows.Close()
+
+	for i := range searches {
+		s := &searches[i]
+		if s.URLs, err = queryStrings("SELECT url FROM research_windows WHERE search_id = ? ORDER BY id", s.ID); err != nil {
+			return nil, err
+		}
+		for j, url := range s.URLs {
+			s.URLs[j] = unseal(url)
+		}
+		if s.Trail, err = searchTrail(s.ID); err != nil {
+			return nil, err
+		}
+		if s.Time, err = searchTime(s.ID); err != nil {
+			return nil, err
+		}
+		if s.Notes, err = loadNotes("search_id = ?", s.ID); err != nil {
+			return nil, err
+		}
+	}
+	return searches, nil
+}
+
+func searchTrail(searchID int64) ([]reportPage, error) {
+	rows, err := db.Query(`
+		SELECT n.title, n.url FROM navigations n
+		JOIN research_windows w ON w.id = n.window_id
+		WHERE w.search_id = ?
+		ORDER BY n.id`, searchID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trail: %w", err)
+	}
+	defer rows.Close()
+
+	var trail []reportPage
+	for rows.Next() {
+		var p reportPage
+		if err := rows.Scan(&p.Title, &p.URL); err != nil {
+			return nil, fmt.Errorf("failed to read trail: %w", err)
+		}
+		unsealAll(&p.Title, &p.URL)
+		trail = append(trail, p)
+	}
+	return trail, rows.Err()
+}
+
+func sessionBookmarks(sessionID string) ([]bookmark, error) {
+	rows, err := db.Query(`
+		SELECT b.id, COALESCE(b.search_id, 0), b.url, b.title, b.tags, b.created_at, b.wayback_url FROM bookmarks b
+		JOIN searches s ON s.id = b.search_id
+		WHERE s.session_id = ?
+		ORDER BY b.created_at`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query bookmarks: %w", err)
+	}
+	defer rows.Close()
+
+	var bookmarks []bookmark
+	for rows.Next() {
+		var b bookmark
+		if err := rows.Scan(&b.ID, &b.SearchID, &b.URL, &b.Title, &b.Tags, &b.CreatedAt, &b.WaybackURL); err != nil {
+			return nil, fmt.Errorf("failed to read bookmark: %w", err)
+		}
+		unsealAll(&b.URL, &b.Title, &b.WaybackURL)
+		bookmarks = append(bookmarks, b)
+	}
+	return bookmarks, rows.Err()
+}
+
+func loadNotes(where string, arg any) ([]string, error) {
+	notes, err := queryStrings("SELECT text FROM notes WHERE "+where+" ORDER BY created_at", arg)
+	for i := range notes {
+		notes[i] = unseal(notes[i])
+	}
+	return notes, err
+}
+
+func queryStrings(query string, args ...any) ([]string, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var values []string
+	var v sql.NullString
+	for rows.Next() {
+		if err := rows.Scan(&v); err != nil {
+			return nil, fmt.Errorf("query failed: %w", err)
+		}
+		values = append(values, v.String)
+	}
+	return values, rows.Err()
+}
+
+func escapeMarkdown(s string) string {
+	return strings.NewReplacer("[", `\[`, "]", `\]`, "*", `\*`, "_", `\_`).Replace(s)
+}
@@ -0,0 +1,173 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	starlarkjson "go.starlark.net/lib/json"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+
+	"rabbithole/internal/wm"
+)
+
+// routingTimeout keeps a slow or stuck routing script from holding up the
+// hotkey; the search then goes ahead unrouted.
+const routingTimeout = 2 * time.Second
+
+// routeContext is what the routing script's route function gets, as a
+// dict with these keys.
+type routeContext struct {
+	Query       string   `json:"query"`
+	Trigger     string   `json:"trigger"`
+	Tags        []string `json:"tags"`
+	WindowClass string   `json:"window_class"`
+	Hour        int      `json:"hour"`
+	Weekday     string   `json:"weekday"`
+	Time        string   `json:"time"`
+	Engines     []string `json:"engines"`
+}
+
+// routeDecision is the dict route returns. Missing keys leave that part of
+// the search alone.
+type routeDecision struct {
+	Engine   string   `json:"engine"`
+	Query    string   `json:"query"`
+	Tags     []string `json:"tags"`
+	Geometry string   `json:"geometry"`
+}
+
+// activeWindowClass is the class of the window the search was started
+// from, e.g. "firefox" or "kitty".
+func activeWindowClass() string {
+	if x, err := nativeX11(); err == nil {
+		window, err := x.ActiveWindow()
+		if err != nil {
+			return ""
+		}
+		fields := strings.Fields(x.WindowClass(window))
+		if len(fields) == 0 {
+			return ""
+		}
+		return fields[len(fields)-1]
+	}
+
+	out, err := commandOutput(exec.Command("xdotool", "getactivewindow", "getwindowclassname"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// runRoutingScript runs routing.script, a Starlark file, in-process and
+// calls its route function with the search context. Both cross over as
+// JSON, so the struct tags above are the script's interface.
+func runRoutingScript(c routeContext) (routeDecision, error) {
+	var d routeDecision
+	path := expandHome(strings.TrimSpace(config.Routing.Script))
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return d, fmt.Errorf("failed to read routing script: %w", err)
+	}
+	input, err := json.Marshal(c)
+	if err != nil {
+		return d, fmt.Errorf("failed to encode routing context: %w", err)
+	}
+
+	thread := &starlark.Thread{
+		Name: "routing",
+		Print: func(_ *starlark.Thread, msg string) {
+			slog.Debug("Routing script", "msg", msg)
+		},
+	}
+	timer := time.AfterFunc(routingTimeout, func() {
+		thread.Cancel(fmt.Sprintf("took longer than %s", routingTimeout))
+	})
+	defer timer.Stop()
+
+	predeclared := starlark.StringDict{"json": starlarkjson.Module}
+	globals, err := starlark.ExecFileOptions(&syntax.FileOptions{}, thread, path, src, predeclared)
+	if err != nil {
+		return d, fmt.Errorf("routing script failed: %w", err)
+	}
+	route, ok := globals["route"].(starlark.Callable)
+	if !ok {
+		return d, fmt.Errorf("routing script doesn't define route(search)")
+	}
+	search, err := starlark.Call(thread, starlarkjson.Module.Members["decode"], starlark.Tuple{starlark.String(input)}, nil)
+	if err != nil {
+		return d, fmt.Errorf("failed to pass the search to the routing script: %w", err)
+	}
+	result, err := starlark.Call(thread, route, starlark.Tuple{search}, nil)
+	if err != nil {
+		return d, fmt.Errorf("routing script failed: %w", err)
+	}
+	if result == starlark.None {
+		return d, nil
+	}
+	if _, ok := result.(*starlark.Dict); !ok {
+		return d, fmt.Errorf("route returned %s, expected a dict or None", result.Type())
+	}
+	output, err := starlark.Call(thread, starlarkjson.Module.Members["encode"], starlark.Tuple{result}, nil)
+	if err != nil {
+		return d, fmt.Errorf("failed to read the routing decision: %w", err)
+	}
+	if err := json.Unmarshal([]byte(output.(starlark.String)), &d); err != nil {
+		return d, fmt.Errorf("invalid routing decision: %w", err)
+	}
+	return d, nil
+}
+
+// routeSearch lets routing.script choose the engine, rewrite the query,
+// add tags and place the window before any menu is shown. An engine given
+// with --engine wins over the script's. A failing script is logged and
+// the search goes on as if there were none.
+func routeSearch(query, triggerMethod string, opts searchOptions) (string, searchOptions) {
+	if config.Routing.Script == "" {
+		return query, opts
+	}
+	now := time.Now()
+	c := routeContext{
+		Query:       query,
+		Trigger:     triggerMethod,
+		Tags:        append([]string{}, splitTags(normalizeTags(append(projectTags(), opts.Tags...)))...),
+		WindowClass: activeWindowClass(),
+		Hour:        now.Hour(),
+		Weekday:     strings.ToLower(now.Weekday().String()),
+		Time:        now.Format(time.RFC3339),
+	}
+	for _, e := range menuEngines() {
+		c.Engines = append(c.Engines, e.Key)
+	}
+
+	d, err := runRoutingScript(c)
+	if err != nil {
+		slog.Warn("Ignoring routing script", "err", err)
+		return query, opts
+	}
+	if d.Engine != "" && opts.EngineKey == "" {
+		if _, err := engineByKey(d.Engine); err != nil {
+			slog.Warn("Routing script chose an unknown engine", "engine", d.Engine)
+		} else {
+			opts.EngineKey = d.Engine
+		}
+	}
+	if q := strings.TrimSpace(d.Query); q != "" {
+		query = q
+	}
+	opts.Tags = append(opts.Tags, d.Tags...)
+	if d.Geometry != "" {
+		if g, err := wm.ParseGeometry(d.Geometry); err != nil {
+			slog.Warn("Routing script chose an invalid geometry", "geometry", d.Geometry, "err", err)
+		} else {
+			opts.Geometry = g
+		}
+	}
+	slog.Debug("Routed search", "engine", opts.EngineKey, "query_rewritten", d.Query != "", "geometry", d.Geometry)
+	return query, opts
+}
@@ -0,0 +1,154 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// CommandRunner runs the external programs rabbithole depends on (xsel,
+// wmctrl, xdotool, the browser, the launcher). Commands are built with
+// exec.Command as usual and handed to runner, so a fake can stand in for
+// all of them and the capture, detection and placement code runs without
+// an X session.
+type CommandRunner interface {
+	// Run starts cmd and waits for it to finish
+	Run(cmd *exec.Cmd) error
+	// Start starts cmd without waiting for it
+	Start(cmd *exec.Cmd) error
+	// Wait waits for a command started with Start
+	Wait(cmd *exec.Cmd) error
+}
+
+var runner CommandRunner = execRunner{}
+
+// execRunner runs commands for real.
+type execRunner struct{}
+
+func (execRunner) Run(cmd *exec.Cmd) error   { return cmd.Run() }
+func (execRunner) Start(cmd *exec.Cmd) error { return cmd.Start() }
+func (execRunner) Wait(cmd *exec.Cmd) error  { return cmd.Wait() }
+
+func runCommand(cmd *exec.Cmd) error {
+	return runner.Run(cmd)
+}
+
+func startCommand(cmd *exec.Cmd) error {
+	return runner.Start(cmd)
+}
+
+func waitCommand(cmd *exec.Cmd) error {
+	return runner.Wait(cmd)
+}
+
+// commandOutput runs cmd and returns its standard output, like
+// exec.Cmd.Output.
+func commandOutput(cmd *exec.Cmd) ([]byte, error) {
+	if cmd.Stdout != nil {
+		return nil, errors.New("exec: Stdout already set")
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	captureStderr := cmd.Stderr == nil
+	if captureStderr {
+		cmd.Stderr = &stderr
+	}
+	err := runner.Run(cmd)
+	var exitErr *exec.ExitError
+	if captureStderr && errors.As(err, &exitErr) {
+		exitErr.Stderr = stderr.Bytes()
+	}
+	return stdout.Bytes(), err
+}
+
+// combinedOutput runs cmd and returns its standard output and standard
+// error together, like exec.Cmd.CombinedOutput.
+func combinedOutput(cmd *exec.Cmd) ([]byte, error) {
+	if cmd.Stdout != nil || cmd.Stderr != nil {
+		return nil, errors.New("exec: Stdout or Stderr already set")
+	}
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err := runner.Run(cmd)
+	return output.Bytes(), err
+}
+
+// startDetached starts a helper that outlives rabbithole.
+func startDetached(cmd *exec.Cmd) error {
+	if err := runner.Start(cmd); err != nil {
+		return err
+	}
+	if cmd.Process == nil {
+		return nil
+	}
+	return cmd.Process.Release()
+}
+
+// commandPID is the process id of a started command, or 0 when a fake
+// runner didn't start a process.
+func commandPID(cmd *exec.Cmd) int {
+	if cmd.Process == nil {
+		return 0
+	}
+	return cmd.Process.Pid
+}
+
+// fakeCall is a command a fakeRunner was asked to run.
+type fakeCall struct {
+	Args  []string
+	Stdin string
+}
+
+// fakeRunner records commands instead of running them. respond, when set,
+// answers each one with its standard output or an error; commands without
+// an answer succeed with no output.
+type fakeRunner struct {
+	respond func(args []string, stdin string) (string, error)
+
+	mu    sync.Mutex
+	calls []fakeCall
+}
+
+func (f *fakeRunner) Run(cmd *exec.Cmd) error {
+	var stdin string
+	if cmd.Stdin != nil {
+		data, err := io.ReadAll(cmd.Stdin)
+		if err != nil {
+			return err
+		}
+		stdin = string(data)
+	}
+
+	f.mu.Lock()
+	f.calls = append(f.calls, fakeCall{Args: cmd.Args, Stdin: stdin})
+	f.mu.Unlock()
+
+	if f.respond == nil {
+		return nil
+	}
+	stdout, err := f.respond(cmd.Args, stdin)
+	if cmd.Stdout != nil {
+		io.WriteString(cmd.Stdout, stdout)
+	}
+	return err
+}
+
+// Start runs the command to completion right away, so Wait has nothing
+// left to do.
+func (f *fakeRunner) Start(cmd *exec.Cmd) error { return f.Run(cmd) }
+func (f *fakeRunner) Wait(cmd *exec.Cmd) error  { return nil }
+
+// commands returns the recorded commands as command lines.
+func (f *fakeRunner) commands() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var lines []string
+	for _, call := range f.calls {
+		lines = append(lines, strings.Join(call.Args, " "))
+	}
+	return lines
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"errors"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// useFakeRunner swaps in a fakeRunner for the rest of the test.
+func useFakeRunner(t *testing.T, respond func(args []string, stdin string) (string, error)) *fakeRunner {
+	t.Helper()
+	fake := &fakeRunner{respond: respond}
+	saved := runner
+	runner = fake
+	t.Cleanup(func() { runner = saved })
+	return fake
+}
+
+// useConfig starts the test from an empty config and puts the real one
+// back afterwards.
+func useConfig(t *testing.T) {
+	t.Helper()
+	saved := config
+	config = Config{}
+	t.Cleanup(func() { config = saved })
+}
+
+// useHeadless simulates browser windows for the rest of the test, and
+// keeps placement off the real X server.
+func useHeadless(t *testing.T) {
+	t.Helper()
+	saved := headless
+	headless = true
+	t.Cleanup(func() { headless = saved })
+}
+
+var errExitStatus = errors.New("exit status 1")
+
+func assertCommands(t *testing.T, fake *fakeRunner, want ...string) {
+	t.Helper()
+	if got := fake.commands(); !reflect.DeepEqual(got, want) {
+		t.Errorf("commands:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestCommandOutput(t *testing.T) {
+	fake := useFakeRunner(t, func(args []string, stdin string) (string, error) {
+		if args[0] == "false" {
+			return "partial", errExitStatus
+		}
+		return "out:" + stdin, nil
+	})
+
+	cmd := exec.Command("cat", "-")
+	cmd.Stdin = strings.NewReader("hello")
+	out, err := commandOutput(cmd)
+	if err != nil || string(out) != "out:hello" {
+		t.Errorf("commandOutput = %q, %v", out, err)
+	}
+
+	out, err = combinedOutput(exec.Command("false"))
+	if err == nil || string(out) != "partial" {
+		t.Errorf("combinedOutput = %q, %v", out, err)
+	}
+
+	assertCommands(t, fake, "cat -", "false")
+}
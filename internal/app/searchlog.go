@@ -0,0 +1,271 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"rabbithole/internal/store"
+)
+
+// searchLogDeadline is how long a search may wait for the database before
+// it's spilled to disk instead. The browser is already starting by then.
+const searchLogDeadline = time.Second
+
+// searchLogQueueSize bounds the daemon's backlog of searches to log.
+const searchLogQueueSize = 64
+
+const insertSearchSQL = "INSERT INTO searches (query, engine_name, engine_url, final_url, trigger_method, session_id, tags, timestamp) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+
+// searchRecord is a search as written to the searches table, or to the
+// spill file when the database was too slow. Query and FinalURL are sealed
+// before either.
+type searchRecord struct {
+	Query         string    `json:"query"`
+	EngineName    string    `json:"engine_name"`
+	EngineURL     string    `json:"engine_url"`
+	FinalURL      string    `json:"final_url"`
+	TriggerMethod string    `json:"trigger_method"`
+	SessionID     string    `json:"session_id"`
+	Tags          string    `json:"tags"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+func newSearchRecord(query, engineName, engineURL, finalURL, triggerMethod, tags string) searchRecord {
+	now := time.Now()
+	return searchRecord{
+		Query:         query,
+		EngineName:    engineName,
+		EngineURL:     engineURL,
+		FinalURL:      finalURL,
+		TriggerMethod: triggerMethod,
+		// Simple session ID based on day
+		SessionID: now.Format("2006-01-02"),
+		Tags:      tags,
+		Timestamp: now.UTC(),
+	}
+}
+
+func insertSearch(r searchRecord) (int64, error) {
+	result, err := execPrepared(insertSearchSQL, r.Query, r.EngineName, r.EngineURL, r.FinalURL, r.TriggerMethod,
+		r.SessionID, r.Tags, sqliteTime(r.Timestamp))
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// insertSearchBefore inserts a search unless that takes past the context's
+// deadline. SQLite's busy handler doesn't watch the context, so the
+// connection's busy timeout is cut to the time left instead; either the
+// row is written or it certainly isn't.
+func insertSearchBefore(ctx context.Context, r searchRecord) (int64, error) {
+	deadline, _ := ctx.Deadline()
+	left := time.Until(deadline).Milliseconds()
+	if left <= 0 {
+		return 0, context.DeadlineExceeded
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("PRAGMA busy_timeout = %d", left)); err != nil {
+		return 0, err
+	}
+	defer conn.ExecContext(context.Background(), fmt.Sprintf("PRAGMA busy_timeout = %d", store.BusyTimeoutMs))
+
+	result, err := conn.ExecContext(ctx, insertSearchSQL, r.Query, r.EngineName, r.EngineURL, r.FinalURL, r.TriggerMethod,
+		r.SessionID, r.Tags, sqliteTime(r.Timestamp))
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func journalSearch(searchID int64, query, finalURL string, r searchRecord) {
+	writeJournal(journalEvent{
+		Event: "search", SearchID: searchID, Query: query, Engine: r.EngineName, URL: finalURL,
+		Trigger: r.TriggerMethod, Session: r.SessionID, Tags: splitTags(r.Tags),
+	})
+}
+
+// searchLog is a search being logged in the background while its browser
+// starts.
+type searchLog struct {
+	query, finalURL string
+	record          searchRecord
+	ctx             context.Context
+	cancel          context.CancelFunc
+	done            chan struct{}
+	id              int64
+}
+
+// searchLogQueue feeds the daemon's writer, which logs the searches of
+// concurrent API calls one after another. Nil outside the daemon.
+var searchLogQueue chan *searchLog
+
+// startSearchLog logs a search without holding up the caller. The
+// deadline starts now, so searches waiting in the daemon's queue don't get
+// extra time.
+func startSearchLog(query, engineName, engineURL, finalURL, triggerMethod, tags string) *searchLog {
+	l := &searchLog{
+		query:    query,
+		finalURL: finalURL,
+		record:   newSearchRecord(query, engineName, engineURL, finalURL, triggerMethod, tags),
+		done:     make(chan struct{}),
+	}
+	l.ctx, l.cancel = context.WithTimeout(context.Background(), searchLogDeadline)
+	if searchLogQueue != nil {
+		select {
+		case searchLogQueue <- l:
+			return l
+		default:
+			slog.Warn("Search log queue is full, logging directly")
+		}
+	}
+	go l.write()
+	return l
+}
+
+// wait returns the logged search's id, or 0 if it was spilled.
+func (l *searchLog) wait() int64 {
+	<-l.done
+	return l.id
+}
+
+func (l *searchLog) write() {
+	defer close(l.done)
+	defer l.cancel()
+
+	// Without the database there's no key to seal with; the search is
+	// spilled as it is and sealed when it's replayed
+	var err error
+	if database() == nil {
+		err = fmt.Errorf("database not initialized")
+	} else {
+		l.record.Query, l.record.FinalURL = seal(l.query), seal(l.finalURL)
+		l.id, err = insertSearchBefore(l.ctx, l.record)
+	}
+	if err == nil {
+		journalSearch(l.id, l.query, l.finalURL, l.record)
+		return
+	}
+
+	slog.Warn("Couldn't log search in time, spilling it to disk", "err", err)
+	if err := spillSearch(l.record); err != nil {
+		slog.Error("Failed to log search", "err", err)
+		return
+	}
+	journalSearch(0, l.query, l.finalURL, l.record)
+}
+
+// runSearchLogWriter logs queued searches until the daemon exits.
+func runSearchLogWriter() {
+	for l := range searchLogQueue {
+		l.write()
+	}
+}
+
+func spillPath() string {
+	return filepath.Join(filepath.Dir(config.Database.Path), "pending-searches.jsonl")
+}
+
+func spillSearch(r searchRecord) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to encode search: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(spillPath()), 0755); err != nil {
+		return fmt.Errorf("failed to create spill directory: %w", err)
+	}
+	f, err := os.OpenFile(spillPath(), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open spill file: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to spill search: %w", err)
+	}
+	return f.Close()
+}
+
+// replaySpilledSearches moves searches that were spilled to disk into the
+// database. The file is renamed first, so of several processes opening the
+// database at once only one replays it.
+func replaySpilledSearches() {
+	path := spillPath()
+	if _, err := os.Stat(path); err != nil {
+		return
+	}
+	claimed := path + "." + strconv.Itoa(os.Getpid())
+	if err := os.Rename(path, claimed); err != nil {
+		return
+	}
+
+	f, err := os.Open(claimed)
+	if err != nil {
+		slog.Error("Failed to read spilled searches", "err", err)
+		return
+	}
+	var records []searchRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var r searchRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			slog.Warn("Skipping unreadable spilled search", "err", err)
+			continue
+		}
+		records = append(records, r)
+	}
+	f.Close()
+
+	err = sealSpilledSearches(records)
+	if err == nil {
+		err = inTransaction(func(tx *sql.Tx) error {
+			for _, r := range records {
+				_, err := tx.Exec(insertSearchSQL, r.Query, r.EngineName, r.EngineURL, r.FinalURL, r.TriggerMethod,
+					r.SessionID, r.Tags, sqliteTime(r.Timestamp))
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	if err != nil {
+		// Put them back for the next try, after anything spilled meanwhile
+		slog.Error("Failed to replay spilled searches", "err", err)
+		for _, r := range records {
+			spillSearch(r)
+		}
+	} else {
+		slog.Info("Logged spilled searches", "searches", len(records))
+	}
+	os.Remove(claimed)
+}
+
+// sealSpilledSearches seals searches spilled while the database was closed,
+// which couldn't be sealed then. It runs while the database is being opened,
+// so it uses db directly.
+func sealSpilledSearches(records []searchRecord) error {
+	for i := range records {
+		r := &records[i]
+		var err error
+		if r.Query, err = sealIn(db, r.Query); err != nil {
+			return err
+		}
+		if r.FinalURL, err = sealIn(db, r.FinalURL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+package app
+
+import "rabbithole/internal/capture"
+
+// guardSelection checks captured text against the limits in the config.
+func guardSelection(text, selection string) (string, error) {
+	b := config.Behavior
+	guard := capture.Guard{
+		MaxLength:        b.SelectionMaxLength,
+		AllowPasswords:   b.SelectionAllowPasswords,
+		AllowBinary:      b.SelectionAllowBinary,
+		CollapseNewlines: b.SelectionCollapseNewlines,
+	}
+	return guard.Check(text, selection)
+}
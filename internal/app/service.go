@@ -0,0 +1,200 @@
+package app
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+)
+
+const (
+	daemonUnit  = appName + ".service"
+	socketUnit  = appName + ".socket"
+	hotkeysUnit = appName + "-hotkeys.service"
+
+	// First file descriptor passed by systemd socket activation (SD_LISTEN_FDS_START)
+	listenFDsStart = 3
+)
+
+func systemdUserDir() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "systemd", "user")
+	}
+	return filepath.Join(os.Getenv("HOME"), ".config", "systemd", "user")
+}
+
+// serviceUnits renders the unit files: the API daemon, the socket that
+// starts it on the first request, and sxhkd for the hotkeys. All of them
+// belong to the graphical session so they see DISPLAY.
+func serviceUnits(command, sxhkdPath string) map[string]string {
+	return map[string]string{
+		daemonUnit: fmt.Sprintf(`[Unit]
+Description=Rabbithole research daemon
+Documentation=man:rabbithole(1)
+PartOf=graphical-session.target
+After=graphical-session.target
+
+[Service]
+ExecStart=%s daemon
+Restart=on-failure
+
+[Install]
+WantedBy=graphical-session.target
+`, command),
+		socketUnit: fmt.Sprintf(`[Unit]
+Description=Rabbithole research daemon API socket
+Documentation=man:rabbithole(1)
+
+[Socket]
+ListenStream=%s
+
+[Install]
+WantedBy=sockets.target
+`, config.Daemon.Listen),
+		hotkeysUnit: fmt.Sprintf(`[Unit]
+Description=Rabbithole hotkeys (sxhkd)
+Documentation=man:rabbithole(1) man:sxhkd(1)
+PartOf=graphical-session.target
+After=graphical-session.target
+
+[Service]
+ExecStart=%s
+ExecReload=/bin/kill -USR1 $MAINPID
+Restart=on-failure
+
+[Install]
+WantedBy=graphical-session.target
+`, sxhkdPath),
+	}
+}
+
+func systemctl(args ...string) error {
+	cmd := exec.Command("systemctl", append([]string{"--user"}, args...)...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := runCommand(cmd); err != nil {
+		return fmt.Errorf("systemctl --user %v failed: %w", args, err)
+	}
+	return nil
+}
+
+// installedUnits returns the rabbithole units present in the user unit
+// directory, in start order.
+func installedUnits() []string {
+	var units []string
+	for _, unit := range []string{socketUnit, daemonUnit, hotkeysUnit} {
+		if _, err := os.Stat(filepath.Join(systemdUserDir(), unit)); err == nil {
+			units = append(units, unit)
+		}
+	}
+	return units
+}
+
+// installService writes the units and enables them. With socket
+// activation the daemon only starts when something talks to the API.
+func installService(socketActivation, hotkeys bool) error {
+	sxhkdPath, err := exec.LookPath("sxhkd")
+	if hotkeys && err != nil {
+		return fmt.Errorf("sxhkd not found (install it or pass --no-hotkeys): %w", err)
+	}
+
+	if socketActivation && config.Hotkeys.ScopedClose {
+		// The daemon binds the close hotkey, so it has to run from the start
+		fmt.Println("ℹ️  hotkeys.scoped_close is set, starting the daemon with the session")
+		socketActivation = false
+	}
+
+	// The socket unit listens in the daemon's place, so it gets the same
+	// loopback-only check
+	if socketActivation {
+		if err := checkListenAddress(config.Daemon.Listen); err != nil {
+			return fmt.Errorf("daemon.listen: %w", err)
+		}
+	}
+
+	dir := systemdUserDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	units := serviceUnits(selfCommand(), sxhkdPath)
+	enable := []string{daemonUnit}
+	if socketActivation {
+		enable = []string{socketUnit}
+	} else {
+		delete(units, socketUnit)
+	}
+	if hotkeys {
+		enable = append(enable, hotkeysUnit)
+	} else {
+		delete(units, hotkeysUnit)
+	}
+	for name, content := range units {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		fmt.Printf("✅ Wrote %s\n", path)
+	}
+
+	if err := systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	return systemctl(append([]string{"enable", "--now"}, enable...)...)
+}
+
+// removeService disables and deletes the units installService wrote.
+func removeService() error {
+	units := installedUnits()
+	if len(units) == 0 {
+		return fmt.Errorf("no rabbithole units installed in %s", systemdUserDir())
+	}
+	if err := systemctl(append([]string{"disable", "--now"}, units...)...); err != nil {
+		return err
+	}
+	for _, unit := range units {
+		if err := os.Remove(filepath.Join(systemdUserDir(), unit)); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", unit, err)
+		}
+		fmt.Printf("🗑️  Removed %s\n", unit)
+	}
+	return systemctl("daemon-reload")
+}
+
+// manageService runs start, stop or status on the installed units.
+func manageService(action string) error {
+	units := installedUnits()
+	if len(units) == 0 {
+		return fmt.Errorf("no rabbithole units installed, run 'rabbithole install-service' first")
+	}
+	if action == "status" {
+		// status exits non-zero for stopped units, which isn't an error here
+		cmd := exec.Command("systemctl", append([]string{"--user", "status", "--no-pager"}, units...)...)
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+		runCommand(cmd)
+		return nil
+	}
+	return systemctl(append([]string{action}, units...)...)
+}
+
+// activationListener returns the socket systemd passed in, if the daemon
+// was started by socket activation.
+func activationListener() (net.Listener, bool, error) {
+	if pid, _ := strconv.Atoi(os.Getenv("LISTEN_PID")); pid != os.Getpid() {
+		return nil, false, nil
+	}
+	if n, _ := strconv.Atoi(os.Getenv("LISTEN_FDS")); n < 1 {
+		return nil, false, nil
+	}
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	file := os.NewFile(listenFDsStart, "systemd-socket")
+	listener, err := net.FileListener(file)
+	file.Close()
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to use the activation socket: %w", err)
+	}
+	return listener, true, nil
+}
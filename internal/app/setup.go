@@ -0,0 +1,529 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// hotkey is a binding in WM-neutral form; each hotkeyTarget renders it in
+// its own syntax. Modifiers are ctrl, shift, alt and super.
+type hotkey struct {
+	Mods        []string
+	Key         string
+	Args        string
+	Description string
+}
+
+const (
+	defaultSearchHotkey       = "ctrl+space"
+	defaultManualSearchHotkey = "ctrl+shift+space"
+)
+
+// modifierNames maps the modifier spellings accepted in the config to the
+// canonical ones.
+var modifierNames = map[string]string{
+	"ctrl": "ctrl", "control": "ctrl",
+	"shift": "shift",
+	"alt":   "alt", "mod1": "alt",
+	"super": "super", "mod4": "super", "win": "super", "meta": "super",
+}
+
+// parseHotkey reads a binding like "ctrl+shift+space"; the last part is the
+// X keysym name.
+func parseHotkey(binding string) (hotkey, error) {
+	parts := strings.Split(binding, "+")
+	var h hotkey
+	for _, part := range parts[:len(parts)-1] {
+		mod, ok := modifierNames[strings.ToLower(strings.TrimSpace(part))]
+		if !ok {
+			return h, fmt.Errorf("unknown modifier %q in hotkey %q", part, binding)
+		}
+		h.Mods = append(h.Mods, mod)
+	}
+	h.Key = strings.TrimSpace(parts[len(parts)-1])
+	if h.Key == "" {
+		return h, fmt.Errorf("hotkey %q has no key", binding)
+	}
+	return h, nil
+}
+
+// configuredHotkeys returns the bindings from the hotkeys config section;
+// empty and "none" entries are left unbound.
+func configuredHotkeys() ([]hotkey, error) {
+	actions := []struct {
+		binding     string
+		args        string
+		description string
+	}{
+		{config.Hotkeys.Search, "search", "Search selected text"},
+		{config.Hotkeys.ManualSearch, "search --empty", "Manual search"},
+		{config.Hotkeys.Close, "close", "Close the focused research window"},
+		{config.Hotkeys.CloseAll, "close --all", "Close all research windows"},
+		{config.Hotkeys.Recall, "reopen-last", "Reopen the last closed research window"},
+	}
+
+	var hotkeys []hotkey
+	for _, action := range actions {
+		if action.binding == "" || action.binding == "none" {
+			continue
+		}
+		if action.args == "close" && config.Hotkeys.ScopedClose {
+			// rabbithole daemon binds it while research windows have focus
+			continue
+		}
+		h, err := parseHotkey(action.binding)
+		if err != nil {
+			return nil, err
+		}
+		h.Args, h.Description = action.args, action.description
+		hotkeys = append(hotkeys, h)
+	}
+	return hotkeys, nil
+}
+
+// hotkeyTarget describes where and how a hotkey daemon or WM takes its
+// bindings. Targets with an include line get a file of their own that the
+// main config pulls in, so user bindings are never touched.
+type hotkeyTarget struct {
+	Deps       []string
+	File       string   // relative to $XDG_CONFIG_HOME
+	MainConfig []string // candidates relative to $XDG_CONFIG_HOME, first existing wins
+	Include    string   // line that pulls File into the main config; {file} is substituted
+	// Standalone targets run File on its own, so it is neither included
+	// nor a managed block
+	Standalone bool
+	Comment    string // line comment marker, "#" if empty
+	Preamble   string // first lines of a bindings file of its own
+	Reload     string
+	Format     func(h hotkey, execPath string) string
+}
+
+var hotkeyTargets = map[string]hotkeyTarget{
+	"sxhkd": {
+		Deps:   []string{"sxhkd", "xdotool", "wmctrl", "xdpyinfo"},
+		File:   "sxhkd/sxhkdrc",
+		Reload: "pkill -USR1 -x sxhkd  (not running yet? rabbithole install-service)",
+		Format: func(h hotkey, execPath string) string {
+			return fmt.Sprintf("%s\n    %s %s", strings.Join(append(append([]string{}, h.Mods...), h.Key), " + "), execPath, h.Args)
+		},
+	},
+	"i3": {
+		Deps:       []string{"i3-msg"},
+		File:       "i3/rabbithole.conf",
+		MainConfig: []string{"i3/config", "../.i3/config"},
+		Include:    "include {file}",
+		Reload:     "i3-msg reload",
+		Format: func(h hotkey, execPath string) string {
+			return fmt.Sprintf("bindsym %s exec --no-startup-id %s %s", i3Keys(h), execPath, h.Args)
+		},
+	},
+	"sway": {
+		Deps:       []string{"swaymsg"},
+		File:       "sway/rabbithole.conf",
+		MainConfig: []string{"sway/config"},
+		Include:    "include {file}",
+		Reload:     "swaymsg reload",
+		Format: func(h hotkey, execPath string) string {
+			return fmt.Sprintf("bindsym %s exec %s %s", i3Keys(h), execPath, h.Args)
+		},
+	},
+	"hypr": {
+		Deps:       []string{"hyprctl"},
+		File:       "hypr/rabbithole.conf",
+		MainConfig: []string{"hypr/hyprland.conf"},
+		Include:    "source = {file}",
+		Reload:     "Hyprland reloads its config automatically",
+		Format: func(h hotkey, execPath string) string {
+			return fmt.Sprintf("bind = %s, %s, exec, %s %s", strings.ToUpper(strings.Join(h.Mods, " ")), h.Key, execPath, h.Args)
+		},
+	},
+	"ahk": {
+		File:       "rabbithole/rabbithole.ahk",
+		Standalone: true,
+		Comment:    ";",
+		Preamble:   "#Requires AutoHotkey v2.0\n#SingleInstance Force",
+		Reload:     "double-click the script to (re)load it; a shortcut to it in shell:startup starts it with Windows",
+		Format: func(h hotkey, execPath string) string {
+			return fmt.Sprintf("%s::Run(%s, , \"Hide\")", ahkKeys(h), ahkString(ahkCommand(execPath)+" "+h.Args))
+		},
+	},
+}
+
+// label renders a binding for people, e.g. Ctrl+Shift+space.
+func (h hotkey) label() string {
+	var parts []string
+	for _, mod := range h.Mods {
+		parts = append(parts, strings.ToUpper(mod[:1])+mod[1:])
+	}
+	return strings.Join(append(parts, h.Key), "+")
+}
+
+// i3Keys renders a binding in i3/sway bindsym syntax, e.g. Ctrl+Shift+space.
+func i3Keys(h hotkey) string {
+	names := map[string]string{"ctrl": "Ctrl", "shift": "Shift", "alt": "Mod1", "super": "Mod4"}
+	var parts []string
+	for _, mod := range h.Mods {
+		parts = append(parts, names[mod])
+	}
+	return strings.Join(append(parts, h.Key), "+")
+}
+
+// ahkKeys renders a binding as an AutoHotkey hotkey, e.g. ^+Space.
+func ahkKeys(h hotkey) string {
+	symbols := map[string]string{"ctrl": "^", "shift": "+", "alt": "!", "super": "#"}
+	// X keysyms whose AutoHotkey names differ
+	keys := map[string]string{"space": "Space", "return": "Enter", "escape": "Esc", "backspace": "Backspace", "delete": "Delete", "tab": "Tab"}
+	var b strings.Builder
+	for _, mod := range h.Mods {
+		b.WriteString(symbols[mod])
+	}
+	if key, ok := keys[strings.ToLower(h.Key)]; ok {
+		b.WriteString(key)
+	} else {
+		b.WriteString(h.Key)
+	}
+	return b.String()
+}
+
+// ahkCommand quotes the executable of selfCommand's command line, as
+// Windows paths often have spaces.
+func ahkCommand(execPath string) string {
+	path, rest, _ := strings.Cut(execPath, " --profile ")
+	command := `"` + path + `"`
+	if rest != "" {
+		command += " --profile " + rest
+	}
+	return command
+}
+
+// ahkString quotes a string for AutoHotkey v2, whose escape character is
+// the backtick.
+func ahkString(s string) string {
+	return "'" + strings.NewReplacer("`", "``", "'", "`'").Replace(s) + "'"
+}
+
+// comment is the target's line comment marker.
+func (t hotkeyTarget) comment() string {
+	if t.Comment == "" {
+		return "#"
+	}
+	return t.Comment
+}
+
+// Markers around the part of a config file that setup owns, so it can be
+// rewritten or removed without touching the user's own lines.
+const (
+	managedBlockBegin = "# >>> rabbithole hotkeys%s (managed by 'rabbithole setup', edits are overwritten) >>>"
+	managedBlockEnd   = "# <<< rabbithole hotkeys%s <<<"
+	legacyHeader      = "# Rabbit Hole Investigator hotkeys\n"
+)
+
+// setupHotkeys writes the rabbithole bindings for wm. sxhkd has no include
+// directive, so its bindings live in a managed block in sxhkdrc; the WMs get
+// a file of their own plus a managed include line in their main config.
+func setupHotkeys(wm string) error {
+	target, err := hotkeyTargetFor(wm)
+	if err != nil {
+		return err
+	}
+	hotkeys, err := configuredHotkeys()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("🔧 Rabbit Hole v%s - Setup (%s)\n", appVersion, wm)
+	fmt.Println("=============================")
+
+	var missing []string
+	for _, dep := range target.Deps {
+		if _, err := exec.LookPath(dep); err != nil {
+			missing = append(missing, dep)
+		}
+	}
+	if len(missing) > 0 {
+		fmt.Println("❌ Missing dependencies:")
+		fmt.Printf("   sudo apt install %s\n", strings.Join(missing, " "))
+		return fmt.Errorf("missing dependencies: %v", missing)
+	}
+
+	execPath := selfCommand()
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return fmt.Errorf("couldn't determine the config directory for hotkey setup: %w", err)
+	}
+
+	var lines []string
+	for _, h := range hotkeys {
+		lines = append(lines, target.comment()+" "+h.Description, target.Format(h, execPath))
+	}
+	bindings := strings.Join(lines, "\n")
+
+	path := filepath.Join(configDir, target.file())
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create hotkey config directory: %w", err)
+	}
+	if target.Include == "" && !target.Standalone {
+		if err := writeManagedBlock(path, bindings); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Updated rabbithole bindings in %s\n", path)
+	} else {
+		content := target.comment() + " Rabbit Hole Investigator hotkeys (generated by rabbithole setup)\n" + bindings + "\n"
+		if target.Preamble != "" {
+			content = target.Preamble + "\n" + content
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return fmt.Errorf("failed to write hotkey config: %w", err)
+		}
+		fmt.Printf("✅ Created %s config: %s\n", wm, path)
+
+		if !target.Standalone {
+			include := strings.ReplaceAll(target.Include, "{file}", path)
+			if err := addInclude(configDir, target.MainConfig, path, include); err != nil {
+				return err
+			}
+		}
+	}
+
+	fmt.Println("\n📋 Setup complete! Now:")
+	fmt.Printf("   %s\n", target.Reload)
+	fmt.Println("\n⌨️  Hotkeys:")
+	for _, h := range hotkeys {
+		fmt.Printf("  %s: %s\n", h.label(), h.Description)
+	}
+	return nil
+}
+
+// removeHotkeys undoes setupHotkeys for wm, leaving user bindings alone.
+func removeHotkeys(wm string) error {
+	target, err := hotkeyTargetFor(wm)
+	if err != nil {
+		return err
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return fmt.Errorf("couldn't determine the config directory for hotkey setup: %w", err)
+	}
+
+	path := filepath.Join(configDir, target.file())
+	removed := false
+	if target.Include == "" && !target.Standalone {
+		if removed, err = removeManagedBlock(path); err != nil {
+			return err
+		}
+	} else {
+		if err := os.Remove(path); err == nil {
+			fmt.Printf("🗑️  Removed %s\n", path)
+			removed = true
+		} else if !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		for _, candidate := range target.MainConfig {
+			ok, err := removeManagedBlock(filepath.Join(configDir, candidate))
+			if err != nil {
+				return err
+			}
+			removed = removed || ok
+		}
+	}
+
+	if !removed {
+		fmt.Printf("No rabbithole hotkeys were set up for %s\n", wm)
+		return nil
+	}
+	fmt.Printf("\n📋 Hotkeys removed. Now:\n   %s\n", target.Reload)
+	return nil
+}
+
+// setupPlacement prepares a placement backend that needs more than a
+// setting and makes it the configured one.
+func setupPlacement(backend string) error {
+	fmt.Printf("🔧 Rabbit Hole v%s - Setup (%s placement)\n", appVersion, backend)
+	switch backend {
+	case "gnome":
+		if err := installGnomeExtension(); err != nil {
+			return err
+		}
+	case "kwin":
+		// Scripts are loaded on every search, so KWin only has to answer
+		if err := kwinReachable(); err != nil {
+			fmt.Printf("⚠️  %v\n", err)
+		}
+	default:
+		return fmt.Errorf("unsupported placement backend %q for setup (use gnome or kwin)", backend)
+	}
+	return setConfigValue("placement.backend", backend)
+}
+
+// removePlacement undoes setupPlacement, going back to the default
+// backend.
+func removePlacement(backend string) error {
+	switch backend {
+	case "gnome":
+		if err := removeGnomeExtension(); err != nil {
+			return err
+		}
+	case "kwin":
+	default:
+		return fmt.Errorf("unsupported placement backend %q for setup (use gnome or kwin)", backend)
+	}
+	if config.Placement.Backend != backend {
+		return nil
+	}
+	return setConfigValue("placement.backend", "")
+}
+
+// managedMarkers returns the block markers; each profile has its own block.
+func managedMarkers() (begin, end string) {
+	name := ""
+	if profile != "" {
+		name = " [" + profile + "]"
+	}
+	return fmt.Sprintf(managedBlockBegin, name), fmt.Sprintf(managedBlockEnd, name)
+}
+
+// file is the target's bindings file; profiles get their own include file
+// or script.
+func (t hotkeyTarget) file() string {
+	if profile != "" && (t.Include != "" || t.Standalone) {
+		ext := filepath.Ext(t.File)
+		return strings.TrimSuffix(t.File, ext) + "-" + profile + ext
+	}
+	return t.File
+}
+
+func hotkeyTargetFor(wm string) (hotkeyTarget, error) {
+	if wm == "hyprland" {
+		wm = "hypr"
+	}
+	target, ok := hotkeyTargets[wm]
+	if !ok {
+		return target, fmt.Errorf("unsupported window manager %q (use sxhkd, i3, sway, hypr or ahk)", wm)
+	}
+	return target, nil
+}
+
+// defaultHotkeyTarget is where setup binds hotkeys without --wm.
+func defaultHotkeyTarget() string {
+	if onWindows() {
+		return "ahk"
+	}
+	return "sxhkd"
+}
+
+// addInclude puts the include line into a managed block of the WM's main
+// config, unless the user already includes the file themselves. A missing
+// main config isn't created, since an otherwise empty config would replace
+// the WM defaults.
+func addInclude(configDir string, candidates []string, file, include string) error {
+	var mainConfig string
+	for _, candidate := range candidates {
+		path := filepath.Join(configDir, candidate)
+		if _, err := os.Stat(path); err == nil {
+			mainConfig = path
+			break
+		}
+	}
+	if mainConfig == "" {
+		fmt.Printf("⚠️  No main config found, add this line to it yourself:\n   %s\n", include)
+		return nil
+	}
+
+	existing, err := os.ReadFile(mainConfig)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", mainConfig, err)
+	}
+	if _, managed := replaceManagedBlock(string(existing), ""); !managed && strings.Contains(string(existing), file) {
+		fmt.Printf("✅ %s already includes it\n", mainConfig)
+		return nil
+	}
+	if err := writeManagedBlock(mainConfig, include); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Added include to %s\n", mainConfig)
+	return nil
+}
+
+// replaceManagedBlock swaps the managed block in content for block (or
+// drops it when block is empty) and reports whether there was one.
+func replaceManagedBlock(content, block string) (string, bool) {
+	blockBegin, blockEnd := managedMarkers()
+	begin := strings.Index(content, blockBegin)
+	if begin < 0 {
+		return content, false
+	}
+	end := strings.Index(content[begin:], blockEnd)
+	if end < 0 {
+		return content, false
+	}
+	end += begin + len(blockEnd)
+	if end < len(content) && content[end] == '\n' {
+		end++
+	}
+
+	replacement := ""
+	if block != "" {
+		replacement = blockBegin + "\n" + block + "\n" + blockEnd + "\n"
+	} else {
+		// Also drop the blank line that separated the block
+		if strings.HasSuffix(content[:begin], "\n\n") {
+			begin--
+		}
+	}
+	return content[:begin] + replacement + content[end:], true
+}
+
+// writeManagedBlock replaces the managed block in path, or appends one.
+// An sxhkdrc written by earlier versions, which overwrote the whole file,
+// has its old rabbithole bindings taken out first.
+func writeManagedBlock(path, block string) error {
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	content := string(existing)
+	if strings.HasPrefix(content, legacyHeader) {
+		if i := strings.Index(content, " search --empty\n"); i >= 0 {
+			content = strings.TrimLeft(content[i+len(" search --empty\n"):], "\n")
+		}
+	}
+
+	content, replaced := replaceManagedBlock(content, block)
+	if !replaced {
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		if content != "" {
+			content += "\n"
+		}
+		blockBegin, blockEnd := managedMarkers()
+		content += blockBegin + "\n" + block + "\n" + blockEnd + "\n"
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// removeManagedBlock deletes the managed block from path, if it has one.
+func removeManagedBlock(path string) (bool, error) {
+	existing, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	content, removed := replaceManagedBlock(string(existing), "")
+	if !removed {
+		return false, nil
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	fmt.Printf("🗑️  Removed rabbithole bindings from %s\n", path)
+	return true, nil
+}
@@ -0,0 +1,110 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type snippet struct {
+	ID         int64     `json:"id"`
+	Text       string    `json:"text"`
+	Trigger    string    `json:"trigger"`
+	CapturedAt time.Time `json:"captured_at"`
+}
+
+// archiveSnippet keeps the full captured text of a search, before it was
+// collapsed or split into queries. Capturing the same text again moves it
+// to the top; sealed text never repeats, so loadSnippets drops the older
+// copies instead. The redaction rules apply as they do to the query.
+func archiveSnippet(text, triggerMethod string) error {
+	_, err := db.Exec("INSERT OR REPLACE INTO snippets (text, trigger_method) VALUES (?, ?)", seal(redact(text)), triggerMethod)
+	if err != nil {
+		return fmt.Errorf("failed to archive snippet: %w", err)
+	}
+	return nil
+}
+
+// loadSnippets returns archived snippets containing filter, newest first.
+// Sealed text can't be matched in SQL, so the filter runs after decrypting.
+func loadSnippets(filter string) ([]snippet, error) {
+	rows, err := db.Query("SELECT id, text, trigger_method, captured_at FROM snippets ORDER BY id DESC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query snippets: %w", err)
+	}
+	defer rows.Close()
+
+	var snippets []snippet
+	seen := make(map[string]bool)
+	for rows.Next() {
+		var s snippet
+		if err := rows.Scan(&s.ID, &s.Text, &s.Trigger, &s.CapturedAt); err != nil {
+			return nil, fmt.Errorf("failed to read snippet: %w", err)
+		}
+		s.Text = unseal(s.Text)
+		if seen[s.Text] || !strings.Contains(strings.ToLower(s.Text), strings.ToLower(filter)) {
+			continue
+		}
+		seen[s.Text] = true
+		snippets = append(snippets, s)
+	}
+	return snippets, rows.Err()
+}
+
+const (
+	copySnippet   = "Copy to clipboard"
+	searchSnippet = "Search again"
+)
+
+// browseSnippets picks an archived snippet in the launcher and copies it
+// back to the clipboard or searches it again.
+func browseSnippets(filter string) error {
+	snippets, err := loadSnippets(filter)
+	if err != nil {
+		return err
+	}
+	if len(snippets) == 0 {
+		return fmt.Errorf("no archived snippets (set behavior.archive_snippets to start archiving)")
+	}
+
+	options := make([]string, len(snippets))
+	byOption := make(map[string]snippet)
+	for i, s := range snippets {
+		options[i] = fmt.Sprintf("%s  %s", s.CapturedAt.Local().Format("2006-01-02"), snippetPreview(s.Text))
+		byOption[options[i]] = s
+	}
+	selected, err := runLauncher("Snippets:", options, 15)
+	if err != nil {
+		return err
+	}
+	s, ok := byOption[selected]
+	if !ok {
+		return fmt.Errorf("invalid selection: %s", selected)
+	}
+
+	action, err := runLauncher("Snippet:", []string{copySnippet, searchSnippet}, 0)
+	if err != nil {
+		return err
+	}
+	switch action {
+	case copySnippet:
+		return copyToClipboard(s.Text)
+	case searchSnippet:
+		return handleSearch(s.Text, "snippet", searchOptions{})
+	}
+	return nil
+}
+
+func printSnippets(snippets []snippet) error {
+	if jsonOutput {
+		return printJSON(snippets)
+	}
+	if len(snippets) == 0 {
+		fmt.Println("No archived snippets.")
+		return nil
+	}
+	for _, s := range snippets {
+		fmt.Printf("%s  %-10s %s\n", s.CapturedAt.Local().Format("2006-01-02 15:04"), s.Trigger, snippetPreview(s.Text))
+	}
+	return nil
+}
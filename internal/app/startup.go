@@ -0,0 +1,24 @@
+package app
+
+import (
+	"log/slog"
+	"time"
+)
+
+// processStart is when rabbithole started, for timing the hotkey path. The
+// target is the engine menu showing within 50ms of the hotkey; see bench.
+var processStart = time.Now()
+
+// openDatabaseInBackground starts opening the database so it's ready by
+// the time it's needed. The lock is taken before returning, so
+// initDatabase, database and closeDatabase wait for the open rather than
+// racing it.
+func openDatabaseInBackground() {
+	databaseMu.Lock()
+	go func() {
+		defer databaseMu.Unlock()
+		if err := openDatabase(); err != nil {
+			slog.Warn("Failed to open database", "err", err)
+		}
+	}()
+}
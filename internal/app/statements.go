@@ -0,0 +1,70 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+)
+
+// Statements on the write paths (searches, window trackers, the daemon's
+// focus tracking) are prepared on first use and kept for the life of the
+// process, so trackers that log for hours don't re-parse SQL every poll.
+var (
+	preparedMu    sync.Mutex
+	preparedStmts = make(map[string]*sql.Stmt)
+)
+
+func prepared(query string) (*sql.Stmt, error) {
+	preparedMu.Lock()
+	defer preparedMu.Unlock()
+	if stmt, ok := preparedStmts[query]; ok {
+		return stmt, nil
+	}
+	stmt, err := db.Prepare(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	preparedStmts[query] = stmt
+	return stmt, nil
+}
+
+// execPrepared runs a write through its prepared statement.
+func execPrepared(query string, args ...any) (sql.Result, error) {
+	stmt, err := prepared(query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.Exec(args...)
+}
+
+// inTransaction runs related writes in one transaction, so they land
+// together and SQLite commits once instead of once per statement.
+func inTransaction(fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// closeDatabase closes the database and drops its prepared statements, so
+// the next initDatabase opens it afresh.
+func closeDatabase() {
+	databaseMu.Lock()
+	defer databaseMu.Unlock()
+	if db == nil {
+		return
+	}
+	preparedMu.Lock()
+	for query, stmt := range preparedStmts {
+		stmt.Close()
+		delete(preparedStmts, query)
+	}
+	preparedMu.Unlock()
+	db.Close()
+	db = nil
+}
@@ -0,0 +1,132 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+type countEntry struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type searchStats struct {
+	TotalSearches int          `json:"total_searches"`
+	SearchesToday int          `json:"searches_today"`
+	Sessions      int          `json:"sessions"`
+	Bookmarks     int          `json:"bookmarks"`
+	TopEngines    []countEntry `json:"top_engines"`
+	TopQueries    []countEntry `json:"top_queries"`
+	Time          researchTime `json:"time"`
+}
+
+func loadStats() (searchStats, error) {
+	stats := searchStats{TopEngines: []countEntry{}, TopQueries: []countEntry{}}
+	today := time.Now().Format("2006-01-02")
+
+	err := db.QueryRow(`
+		SELECT COUNT(*), COUNT(DISTINCT session_id), COALESCE(SUM(session_id = ?), 0)
+		FROM searches`, today).Scan(&stats.TotalSearches, &stats.Sessions, &stats.SearchesToday)
+	if err != nil {
+		return stats, fmt.Errorf("failed to count searches: %w", err)
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM bookmarks").Scan(&stats.Bookmarks); err != nil {
+		return stats, fmt.Errorf("failed to count bookmarks: %w", err)
+	}
+	err = db.QueryRow("SELECT COALESCE(SUM("+openSecondsSQL+"), 0), COALESCE(SUM(focus_seconds), 0) FROM research_windows").
+		Scan(&stats.Time.OpenSeconds, &stats.Time.FocusSeconds)
+	if err != nil {
+		return stats, fmt.Errorf("failed to sum research time: %w", err)
+	}
+
+	if stats.TopEngines, err = topCounts("engine_name"); err != nil {
+		return stats, err
+	}
+	if stats.TopQueries, err = topQueries(); err != nil {
+		return stats, err
+	}
+	return stats, nil
+}
+
+// topCounts returns the five most frequent values of a searches column.
+func topCounts(column string) ([]countEntry, error) {
+	rows, err := db.Query(fmt.Sprintf(
+		"SELECT %s, COUNT(*) AS n FROM searches GROUP BY %s ORDER BY n DESC, %s LIMIT 5",
+		column, column, column))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s counts: %w", column, err)
+	}
+	defer rows.Close()
+
+	counts := []countEntry{}
+	for rows.Next() {
+		var c countEntry
+		if err := rows.Scan(&c.Name, &c.Count); err != nil {
+			return nil, fmt.Errorf("failed to read %s counts: %w", column, err)
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+// topQueries is topCounts("query"), except that encrypted queries can't be
+// grouped in SQL since every copy is sealed differently.
+func topQueries() ([]countEntry, error) {
+	var sealed bool
+	if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM searches WHERE query LIKE ?)", sealedPrefix+"%").Scan(&sealed); err != nil {
+		return nil, fmt.Errorf("failed to check for encrypted queries: %w", err)
+	}
+	if !sealed {
+		return topCounts("query")
+	}
+
+	queries, err := queryStrings("SELECT query FROM searches")
+	if err != nil {
+		return nil, err
+	}
+	byQuery := make(map[string]int)
+	for _, query := range queries {
+		byQuery[unseal(query)]++
+	}
+	counts := []countEntry{}
+	for name, count := range byQuery {
+		counts = append(counts, countEntry{Name: name, Count: count})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Name < counts[j].Name
+	})
+	if len(counts) > 5 {
+		counts = counts[:5]
+	}
+	return counts, nil
+}
+
+func printStats(stats searchStats) error {
+	if jsonOutput {
+		return printJSON(stats)
+	}
+
+	fmt.Printf("Searches:  %d total, %d today\n", stats.TotalSearches, stats.SearchesToday)
+	fmt.Printf("Sessions:  %d\n", stats.Sessions)
+	fmt.Printf("Bookmarks: %d\n", stats.Bookmarks)
+	if stats.Time.OpenSeconds > 0 {
+		fmt.Printf("Time:      %s\n", stats.Time)
+	}
+	if len(stats.TopEngines) > 0 {
+		fmt.Println("\nTop engines:")
+		for _, c := range stats.TopEngines {
+			fmt.Printf("  %4d  %s\n", c.Count, c.Name)
+		}
+	}
+	if len(stats.TopQueries) > 0 {
+		fmt.Println("\nTop queries:")
+		for _, c := range stats.TopQueries {
+			fmt.Printf("  %4d  %s\n", c.Count, c.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const statusPollInterval = 2 * time.Second
+
+// barStatus is a waybar custom module payload; polybar gets Text only.
+type barStatus struct {
+	Text    string `json:"text"`
+	Tooltip string `json:"tooltip"`
+	Class   string `json:"class"`
+}
+
+func currentStatus() (barStatus, error) {
+	session := time.Now().Format("2006-01-02")
+
+	var today int
+	if err := db.QueryRow("SELECT COUNT(*) FROM searches WHERE session_id = ?", session).Scan(&today); err != nil {
+		return barStatus{}, fmt.Errorf("failed to count searches: %w", err)
+	}
+
+	// Without wmctrl (e.g. no X session) there are simply no open windows
+	windows, err := openResearchWindows()
+	if err != nil {
+		slog.Debug("Couldn't list research windows", "err", err)
+	}
+
+	status := barStatus{
+		Text:  fmt.Sprintf("🐇 %d open · %d today · %s", len(windows), today, session),
+		Class: "idle",
+	}
+	if len(windows) > 0 {
+		status.Class = "active"
+	}
+
+	tooltip := []string{fmt.Sprintf("Session %s: %d searches", session, today)}
+	for _, w := range windows {
+		title := w.Title
+		if title == "" {
+			title = w.URL
+		}
+		// The bar polls in a new process each time, so never ask to unlock
+		if isSealed(title) {
+			title = sealedPlaceholder
+		}
+		tooltip = append(tooltip, "• "+title)
+	}
+	status.Tooltip = strings.Join(tooltip, "\n")
+	return status, nil
+}
+
+// printStatus prints one status line, or keeps printing a new one whenever
+// it changes when follow is set.
+func printStatus(follow bool) error {
+	last := ""
+	for {
+		status, err := currentStatus()
+		if err != nil {
+			return err
+		}
+
+		line := status.Text
+		if jsonOutput {
+			data, err := json.Marshal(status)
+			if err != nil {
+				return fmt.Errorf("failed to encode status: %w", err)
+			}
+			line = string(data)
+		}
+		if line != last {
+			fmt.Println(line)
+			last = line
+		}
+
+		if !follow {
+			return nil
+		}
+		time.Sleep(statusPollInterval)
+	}
+}
@@ -0,0 +1,141 @@
+package app
+
+import (
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// defaultSuggestions follow the configured rules unless
+// behavior.disable_suggestions is set.
+var defaultSuggestions = []suggestionRule{
+	{
+		Pattern: `(?i)\w(error|exception)\b|\berror:|traceback|panic:|segmentation fault|undefined reference|errno`,
+		Engine:  SearchEngine{Name: "Stack Overflow", URL: "https://stackoverflow.com/search?q=%s", Key: "*so"},
+	},
+	{
+		Pattern: `(?i)^(doi:\s*)?10\.\d{4,9}/\S+$`,
+		Engine:  SearchEngine{Name: "DOI", URL: "https://doi.org/%s", Key: "*doi"},
+	},
+	{
+		Pattern: `(?i)^CVE-\d{4}-\d{4,}$`,
+		Engine:  SearchEngine{Name: "NVD", URL: "https://nvd.nist.gov/vuln/detail/%s", Key: "*cve"},
+	},
+	{
+		Pattern: `(?i)^(isbn(-1[03])?:?\s*)?(97[89][- ]?)?\d[\d -]{7,12}[\dx]$`,
+		Engine:  SearchEngine{Name: "Open Library", URL: "https://openlibrary.org/search?isbn=%s", Key: "*isbn"},
+		Check:   validISBN,
+	},
+}
+
+var isbnPrefix = regexp.MustCompile(`(?i)^isbn(-1[03])?:?\s*`)
+
+// validISBN checks the check digit of an ISBN-10 or ISBN-13, so phone and
+// order numbers of the right length aren't taken for books.
+func validISBN(query string) bool {
+	digits := isbnPrefix.ReplaceAllString(strings.TrimSpace(query), "")
+	digits = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(digits))
+	sum := 0
+	switch len(digits) {
+	case 10:
+		for i, c := range digits {
+			switch {
+			case c >= '0' && c <= '9':
+				sum += (10 - i) * int(c-'0')
+			case c == 'X' && i == 9:
+				sum += 10
+			default:
+				return false
+			}
+		}
+		return sum%11 == 0
+	case 13:
+		for i, c := range digits {
+			if c < '0' || c > '9' {
+				return false
+			}
+			weight := 1
+			if i%2 == 1 {
+				weight = 3
+			}
+			sum += weight * int(c-'0')
+		}
+		return sum%10 == 0
+	}
+	return false
+}
+
+var doiPrefix = regexp.MustCompile(`(?i)^doi:\s*`)
+
+// siteQueries clean up queries for sites that only take one kind of
+// identifier, e.g. the "doi:" prefix copied along with a DOI, which
+// doi.org doesn't resolve.
+var siteQueries = map[string]func(query string) string{
+	"doi.org": func(query string) string { return doiPrefix.ReplaceAllString(strings.TrimSpace(query), "") },
+}
+
+func siteOf(engineURL string) string {
+	// %s isn't a valid escape, so the query placeholder would fail parsing
+	u, err := url.Parse(strings.ReplaceAll(engineURL, "%s", "query"))
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// suggestEngines moves the engines suggested for query to the front, in
+// rule order, keeping the rest as they were.
+func suggestEngines(engines []SearchEngine, query string) []SearchEngine {
+	if query == "" {
+		return engines
+	}
+	rules := config.Suggestions
+	if !config.Behavior.DisableSuggestions {
+		rules = append(append([]suggestionRule(nil), rules...), defaultSuggestions...)
+	}
+
+	var suggested []SearchEngine
+	taken := make(map[string]bool)
+	for _, rule := range rules {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			slog.Warn("Invalid suggestion pattern", "pattern", rule.Pattern, "err", err)
+			continue
+		}
+		if !re.MatchString(query) || (rule.Check != nil && !rule.Check(query)) {
+			continue
+		}
+
+		engine, ok := suggestedEngine(engines, rule)
+		if !ok || taken[engine.Key] {
+			continue
+		}
+		slog.Debug("Suggesting engine", "engine", engine.Name, "pattern", rule.Pattern)
+		taken[engine.Key] = true
+		suggested = append(suggested, engine)
+	}
+
+	for _, engine := range engines {
+		if !taken[engine.Key] {
+			suggested = append(suggested, engine)
+		}
+	}
+	return suggested
+}
+
+// suggestedEngine finds the menu engine for a matching rule.
+func suggestedEngine(engines []SearchEngine, rule suggestionRule) (SearchEngine, bool) {
+	for _, engine := range engines {
+		if rule.EngineKey != "" && engine.Key == rule.EngineKey {
+			return engine, true
+		}
+		if rule.EngineKey == "" && siteOf(engine.URL) == siteOf(rule.Engine.URL) {
+			return engine, true
+		}
+	}
+	if rule.EngineKey != "" {
+		return SearchEngine{}, false
+	}
+	return rule.Engine, true
+}
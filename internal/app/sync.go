@@ -0,0 +1,567 @@
+package app
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// syncFileSuffix names the merge files. Every machine only ever writes its
+// own file and reads the others, so a file synced with Syncthing or similar
+// never conflicts.
+const syncFileSuffix = ".rabbithole.json"
+
+var syncClient = &http.Client{Timeout: time.Minute}
+
+// syncFile is one machine's merge file: everything it knew about at its
+// last sync. Records are matched by sync_id, which is random and never
+// changes, so merging is a union and the order of syncs doesn't matter.
+// Text is stored decrypted, since every machine has its own key.
+type syncFile struct {
+	Machine    string         `json:"machine"`
+	ExportedAt time.Time      `json:"exported_at"`
+	Searches   []syncSearch   `json:"searches"`
+	Bookmarks  []syncBookmark `json:"bookmarks"`
+	Notes      []syncNote     `json:"notes"`
+	// DeletedSearches are the sync ids of purged searches
+	DeletedSearches []string `json:"deleted_searches,omitempty"`
+}
+
+type syncSearch struct {
+	SyncID        string    `json:"sync_id"`
+	Query         string    `json:"query"`
+	EngineName    string    `json:"engine_name"`
+	EngineURL     string    `json:"engine_url"`
+	FinalURL      string    `json:"final_url"`
+	PageTitle     string    `json:"page_title"`
+	TriggerMethod string    `json:"trigger_method"`
+	SessionID     string    `json:"session_id"`
+	Tags          string    `json:"tags"`
+	Environment   string    `json:"environment"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+type syncBookmark struct {
+	SyncID       string    `json:"sync_id"`
+	SearchSyncID string    `json:"search_sync_id,omitempty"`
+	URL          string    `json:"url"`
+	Title        string    `json:"title"`
+	Tags         string    `json:"tags"`
+	WaybackURL   string    `json:"wayback_url"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type syncNote struct {
+	SyncID       string    `json:"sync_id"`
+	SearchSyncID string    `json:"search_sync_id,omitempty"`
+	SessionID    string    `json:"session_id"`
+	Text         string    `json:"text"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// syncResult counts what a sync brought in from other machines.
+type syncResult struct {
+	Machines  []string
+	Searches  int64
+	Bookmarks int64
+	Notes     int64
+	Deleted   int64
+}
+
+// syncTarget is where the merge files of all machines live.
+type syncTarget interface {
+	// fetch returns the merge files of every machine, including this one.
+	fetch() ([]syncFile, error)
+	push(name string, data []byte) error
+	// String names the target for logs, without credentials.
+	String() string
+}
+
+// parseSyncTarget picks the transport from the target's form: an
+// http(s) URL is WebDAV, ssh://host/path or host:path is SSH, anything
+// else is a local directory, e.g. a Syncthing folder.
+func parseSyncTarget(target string) (syncTarget, error) {
+	if target == "" {
+		return nil, fmt.Errorf("no sync target, pass one or set sync.target in %s", configPath)
+	}
+	if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+		u, err := url.Parse(target)
+		if err != nil {
+			return nil, fmt.Errorf("invalid WebDAV URL %q: %w", target, err)
+		}
+		if !strings.HasSuffix(u.Path, "/") {
+			u.Path += "/"
+		}
+		return webdavTarget{u}, nil
+	}
+	if rest, ok := strings.CutPrefix(target, "ssh://"); ok {
+		host, dir, _ := strings.Cut(rest, "/")
+		if host == "" {
+			return nil, fmt.Errorf("invalid SSH target %q, expected ssh://host/path", target)
+		}
+		// Like scp, the path is relative to the remote home unless it
+		// starts with a second slash
+		if dir == "" {
+			dir = "."
+		}
+		return sshTarget{host: host, dir: dir}, nil
+	}
+	if host, dir, ok := strings.Cut(target, ":"); ok && host != "" && !strings.Contains(host, "/") {
+		if dir == "" {
+			dir = "."
+		}
+		return sshTarget{host: host, dir: dir}, nil
+	}
+	return dirTarget(expandHome(target)), nil
+}
+
+// syncMachine names this machine's merge file.
+func syncMachine() string {
+	if config.Sync.Machine != "" {
+		return config.Sync.Machine
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		return host
+	}
+	return "rabbithole"
+}
+
+// syncHistory merges every other machine's file into the database, then
+// writes this machine's file with the merged history.
+func syncHistory(target syncTarget) (syncResult, error) {
+	var result syncResult
+	if err := assignSyncIDs(); err != nil {
+		return result, err
+	}
+
+	machine := syncMachine()
+	files, err := target.fetch()
+	if err != nil {
+		return result, err
+	}
+	for _, f := range files {
+		if f.Machine == machine {
+			continue
+		}
+		if err := importSyncFile(f, &result); err != nil {
+			return result, fmt.Errorf("failed to merge history from %s: %w", f.Machine, err)
+		}
+		result.Machines = append(result.Machines, f.Machine)
+	}
+
+	own, err := exportSyncFile(machine)
+	if err != nil {
+		return result, err
+	}
+	data, err := json.Marshal(own)
+	if err != nil {
+		return result, fmt.Errorf("failed to encode merge file: %w", err)
+	}
+	// One document per line, so the SSH target can read all files in one go
+	data = append(data, '\n')
+	if err := target.push(machine+syncFileSuffix, data); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// assignSyncIDs gives rows created since the last sync their id.
+func assignSyncIDs() error {
+	for _, table := range []string{"searches", "bookmarks", "notes"} {
+		_, err := db.Exec("UPDATE " + table + " SET sync_id = lower(hex(randomblob(16))) WHERE sync_id IS NULL")
+		if err != nil {
+			return fmt.Errorf("failed to assign sync ids to %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+func exportSyncFile(machine string) (syncFile, error) {
+	f := syncFile{Machine: machine, ExportedAt: time.Now().UTC()}
+
+	deleted, err := queryStrings("SELECT sync_id FROM sync_deletions ORDER BY sync_id")
+	if err != nil {
+		return f, fmt.Errorf("failed to export deletions: %w", err)
+	}
+	f.DeletedSearches = deleted
+
+	rows, err := db.Query(`
+		SELECT sync_id, query, engine_name, engine_url, final_url, page_title, trigger_method,
+			session_id, tags, environment, timestamp
+		FROM searches ORDER BY id`)
+	if err != nil {
+		return f, fmt.Errorf("failed to export searches: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s syncSearch
+		err := rows.Scan(&s.SyncID, &s.Query, &s.EngineName, &s.EngineURL, &s.FinalURL, &s.PageTitle,
+			&s.TriggerMethod, &s.SessionID, &s.Tags, &s.Environment, &s.Timestamp)
+		if err != nil {
+			return f, fmt.Errorf("failed to export searches: %w", err)
+		}
+		if err := unsealForSync(&s.Query, &s.FinalURL, &s.PageTitle); err != nil {
+			return f, err
+		}
+		f.Searches = append(f.Searches, s)
+	}
+	if err := rows.Err(); err != nil {
+		return f, fmt.Errorf("failed to export searches: %w", err)
+	}
+	rows.Close()
+
+	rows, err = db.Query(`
+		SELECT b.sync_id, COALESCE(s.sync_id, ''), b.url, b.title, b.tags, b.wayback_url, b.created_at
+		FROM bookmarks b LEFT JOIN searches s ON s.id = b.search_id ORDER BY b.id`)
+	if err != nil {
+		return f, fmt.Errorf("failed to export bookmarks: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var b syncBookmark
+		if err := rows.Scan(&b.SyncID, &b.SearchSyncID, &b.URL, &b.Title, &b.Tags, &b.WaybackURL, &b.CreatedAt); err != nil {
+			return f, fmt.Errorf("failed to export bookmarks: %w", err)
+		}
+		if err := unsealForSync(&b.URL, &b.Title, &b.WaybackURL); err != nil {
+			return f, err
+		}
+		f.Bookmarks = append(f.Bookmarks, b)
+	}
+	if err := rows.Err(); err != nil {
+		return f, fmt.Errorf("failed to export bookmarks: %w", err)
+	}
+	rows.Close()
+
+	rows, err = db.Query(`
+		SELECT n.sync_id, COALESCE(s.sync_id, ''), n.session_id, n.text, n.created_at
+		FROM notes n LEFT JOIN searches s ON s.id = n.search_id ORDER BY n.id`)
+	if err != nil {
+		return f, fmt.Errorf("failed to export notes: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var n syncNote
+		if err := rows.Scan(&n.SyncID, &n.SearchSyncID, &n.SessionID, &n.Text, &n.CreatedAt); err != nil {
+			return f, fmt.Errorf("failed to export notes: %w", err)
+		}
+		if err := unsealForSync(&n.Text); err != nil {
+			return f, err
+		}
+		f.Notes = append(f.Notes, n)
+	}
+	return f, rows.Err()
+}
+
+// unsealForSync decrypts exported text, failing rather than exporting the
+// placeholder when the key can't be unlocked.
+func unsealForSync(fields ...*string) error {
+	for _, field := range fields {
+		if !isSealed(*field) {
+			continue
+		}
+		if _, err := encryptionPrivateKey(); err != nil {
+			return fmt.Errorf("failed to decrypt history for the merge file: %w", err)
+		}
+		*field = unseal(*field)
+	}
+	return nil
+}
+
+// importSyncFile adds the records this database doesn't have yet, encrypted
+// with this machine's key, and deletes the searches purged there. The only
+// thing that changes after a record is created is a bookmark's Wayback
+// snapshot, which is taken over when missing here.
+func importSyncFile(f syncFile, result *syncResult) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, syncID := range f.DeletedSearches {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO sync_deletions (sync_id) VALUES (?)", syncID); err != nil {
+			return err
+		}
+	}
+
+	for _, s := range f.Searches {
+		res, err := tx.Exec(`
+			INSERT OR IGNORE INTO searches (sync_id, query, engine_name, engine_url, final_url, page_title,
+				trigger_method, session_id, tags, environment, timestamp)
+			SELECT ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+			WHERE NOT EXISTS (SELECT 1 FROM sync_deletions WHERE sync_id = ?)`,
+			s.SyncID, seal(s.Query), s.EngineName, s.EngineURL, seal(s.FinalURL), seal(s.PageTitle),
+			s.TriggerMethod, s.SessionID, s.Tags, s.Environment, sqliteTime(s.Timestamp), s.SyncID)
+		if err != nil {
+			return err
+		}
+		n, _ := res.RowsAffected()
+		result.Searches += n
+	}
+
+	for _, b := range f.Bookmarks {
+		res, err := tx.Exec(`
+			INSERT OR IGNORE INTO bookmarks (sync_id, search_id, url, title, tags, wayback_url, created_at)
+			VALUES (?, (SELECT id FROM searches WHERE sync_id = ?), ?, ?, ?, ?, ?)`,
+			b.SyncID, nullIfEmpty(b.SearchSyncID), seal(b.URL), seal(b.Title), b.Tags, seal(b.WaybackURL), sqliteTime(b.CreatedAt))
+		if err != nil {
+			return err
+		}
+		n, _ := res.RowsAffected()
+		result.Bookmarks += n
+		if n == 0 && b.WaybackURL != "" {
+			_, err := tx.Exec("UPDATE bookmarks SET wayback_url = ? WHERE sync_id = ? AND wayback_url = ''", seal(b.WaybackURL), b.SyncID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, note := range f.Notes {
+		res, err := tx.Exec(`
+			INSERT OR IGNORE INTO notes (sync_id, search_id, session_id, text, created_at)
+			VALUES (?, (SELECT id FROM searches WHERE sync_id = ?), ?, ?, ?)`,
+			note.SyncID, nullIfEmpty(note.SearchSyncID), note.SessionID, seal(note.Text), sqliteTime(note.CreatedAt))
+		if err != nil {
+			return err
+		}
+		n, _ := res.RowsAffected()
+		result.Notes += n
+	}
+
+	// Searches synced before their purge still need deleting here
+	_, err = tx.Exec("CREATE TEMP TABLE purged_searches AS SELECT id FROM searches WHERE sync_id IN (SELECT sync_id FROM sync_deletions)")
+	if err != nil {
+		return fmt.Errorf("failed to select purged searches: %w", err)
+	}
+	deleted, archives, err := deletePurgedSearches(tx)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	result.Deleted += int64(len(deleted))
+	finishPurge(deleted, archives)
+	return nil
+}
+
+func nullIfEmpty(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// decodeSyncFiles reads concatenated merge files.
+func decodeSyncFiles(r io.Reader, source string) ([]syncFile, error) {
+	var files []syncFile
+	decoder := json.NewDecoder(r)
+	for {
+		var f syncFile
+		err := decoder.Decode(&f)
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse merge file from %s: %w", source, err)
+		}
+		if f.Machine == "" {
+			return nil, fmt.Errorf("merge file from %s has no machine name", source)
+		}
+		files = append(files, f)
+	}
+}
+
+// dirTarget is a local directory, typically kept in sync by Syncthing,
+// Nextcloud or a network share.
+type dirTarget string
+
+func (d dirTarget) fetch() ([]syncFile, error) {
+	paths, err := filepath.Glob(filepath.Join(string(d), "*"+syncFileSuffix))
+	if err != nil {
+		return nil, err
+	}
+	var files []syncFile
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read merge file: %w", err)
+		}
+		parsed, err := decodeSyncFiles(bytes.NewReader(data), p)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, parsed...)
+	}
+	return files, nil
+}
+
+func (d dirTarget) String() string { return string(d) }
+
+func (d dirTarget) push(name string, data []byte) error {
+	if err := os.MkdirAll(string(d), 0755); err != nil {
+		return fmt.Errorf("failed to create sync directory: %w", err)
+	}
+	// Write to a temporary name first so a sync tool never picks up half a
+	// file; a leading dot keeps Syncthing from syncing it in the meantime
+	tmp := filepath.Join(string(d), "."+name+".tmp")
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write merge file: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(string(d), name)); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write merge file: %w", err)
+	}
+	return nil
+}
+
+// sshTarget is a directory on another machine, reached with ssh(1) so the
+// user's keys, agent and ~/.ssh/config apply.
+type sshTarget struct {
+	host string
+	dir  string
+}
+
+func (t sshTarget) fetch() ([]syncFile, error) {
+	script := fmt.Sprintf("for f in %s/*%s; do [ -f \"$f\" ] && cat \"$f\"; done; true", shellQuote(t.dir), syncFileSuffix)
+	var stderr bytes.Buffer
+	cmd := exec.Command("ssh", "--", t.host, script)
+	cmd.Stderr = &stderr
+	output, err := commandOutput(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read merge files from %s: %w: %s", t.host, err, strings.TrimSpace(stderr.String()))
+	}
+	return decodeSyncFiles(bytes.NewReader(output), t.host)
+}
+
+func (t sshTarget) String() string { return t.host + ":" + t.dir }
+
+func (t sshTarget) push(name string, data []byte) error {
+	target := shellQuote(t.dir + "/" + name)
+	tmp := shellQuote(t.dir + "/." + name + ".tmp")
+	script := fmt.Sprintf("mkdir -p %s && cat > %s && mv %s %s", shellQuote(t.dir), tmp, tmp, target)
+	cmd := exec.Command("ssh", "--", t.host, script)
+	cmd.Stdin = bytes.NewReader(data)
+	if output, err := combinedOutput(cmd); err != nil {
+		return fmt.Errorf("failed to write merge file to %s: %w: %s", t.host, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// shellQuote quotes a word for the remote shell, leaving a leading ~/
+// outside the quotes so it still expands.
+func shellQuote(s string) string {
+	prefix := ""
+	if rest, ok := strings.CutPrefix(s, "~/"); ok {
+		prefix, s = "~/", rest
+	}
+	return prefix + "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// webdavTarget is a WebDAV collection, e.g. on Nextcloud. Credentials go
+// in the URL's user info.
+type webdavTarget struct {
+	url *url.URL
+}
+
+func (t webdavTarget) request(method string, u *url.URL, body io.Reader, header http.Header) (*http.Response, error) {
+	clean := *u
+	clean.User = nil
+	req, err := http.NewRequest(method, clean.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if t.url.User != nil {
+		password, _ := t.url.User.Password()
+		req.SetBasicAuth(t.url.User.Username(), password)
+	}
+	return syncClient.Do(req)
+}
+
+func (t webdavTarget) fetch() ([]syncFile, error) {
+	// Without a depth servers may list the whole tree, or refuse to
+	resp, err := t.request("PROPFIND", t.url, nil, http.Header{"Depth": {"1"}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", t.url.Redacted(), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		// Nothing synced yet, push creates the collection
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, fmt.Errorf("failed to list %s: %s", t.url.Redacted(), resp.Status)
+	}
+
+	var listing struct {
+		Responses []struct {
+			Href string `xml:"href"`
+		} `xml:"response"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&listing); err != nil {
+		return nil, fmt.Errorf("failed to parse listing of %s: %w", t.url.Redacted(), err)
+	}
+
+	var files []syncFile
+	for _, r := range listing.Responses {
+		href, err := url.Parse(r.Href)
+		if err != nil || !strings.HasSuffix(href.Path, syncFileSuffix) {
+			continue
+		}
+		fileURL := t.url.ResolveReference(&url.URL{Path: path.Base(href.Path)})
+		resp, err := t.request(http.MethodGet, fileURL, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download %s: %w", path.Base(href.Path), err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("failed to download %s: %s", path.Base(href.Path), resp.Status)
+		}
+		parsed, err := decodeSyncFiles(resp.Body, path.Base(href.Path))
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, parsed...)
+	}
+	return files, nil
+}
+
+func (t webdavTarget) String() string { return t.url.Redacted() }
+
+func (t webdavTarget) push(name string, data []byte) error {
+	fileURL := t.url.ResolveReference(&url.URL{Path: name})
+	resp, err := t.request(http.MethodPut, fileURL, bytes.NewReader(data), nil)
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", name, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusConflict {
+		// The collection doesn't exist yet
+		mkcol, err := t.request("MKCOL", t.url, nil, nil)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", t.url.Redacted(), err)
+		}
+		mkcol.Body.Close()
+		if mkcol.StatusCode != http.StatusCreated {
+			return fmt.Errorf("failed to create %s: %s", t.url.Redacted(), mkcol.Status)
+		}
+		return t.push(name, data)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("failed to upload %s: %s", name, resp.Status)
+	}
+	return nil
+}
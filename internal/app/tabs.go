@@ -0,0 +1,64 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// researchContainerPath holds the ID of the window that collects research
+// tabs in "tab" open mode. It lives in the runtime dir since windows don't
+// survive the session anyway.
+func researchContainerPath() string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	if profile != "" {
+		return filepath.Join(dir, "rabbithole-research-window-"+profile)
+	}
+	return filepath.Join(dir, "rabbithole-research-window")
+}
+
+// researchContainer returns the container window ID if it is still open.
+func researchContainer() (string, bool) {
+	data, err := os.ReadFile(researchContainerPath())
+	if err != nil {
+		return "", false
+	}
+	windowID := strings.TrimSpace(string(data))
+	open, err := openWindowIDs()
+	if err != nil || !open[windowID] {
+		return "", false
+	}
+	return windowID, true
+}
+
+// openResearchTab opens the URL as a new tab in the research container window,
+// opening (and positioning) the container first if there isn't one. It
+// reports whether a new window was created.
+func openResearchTab(l browserLaunch) (string, bool, error) {
+	if windowID, ok := researchContainer(); ok {
+		// Firefox puts new tabs in its most recently focused window
+		if err := activateWindow(windowID); err != nil {
+			slog.Warn("Failed to focus research window", "window", windowID, "err", err)
+		}
+		command := l.Command(true)
+		if err := startCommand(exec.Command(command[0], command[1:]...)); err != nil {
+			return "", false, fmt.Errorf("failed to start %s (is it installed?): %w", l.Browser, err)
+		}
+		return windowID, false, nil
+	}
+
+	windowID, err := openBrowserInSideWindow(l)
+	if err != nil {
+		return "", false, err
+	}
+	if err := os.WriteFile(researchContainerPath(), []byte(windowID), 0600); err != nil {
+		slog.Warn("Failed to remember research window", "err", err)
+	}
+	return windowID, true, nil
+}
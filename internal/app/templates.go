@@ -0,0 +1,69 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	appconfig "rabbithole/internal/config"
+)
+
+// templatesOption ends the engine menu when templates are configured, and
+// leads to the list of them.
+const templatesOption = "→ Templates"
+
+// templateByName finds a configured template, ignoring case.
+func templateByName(name string) (*queryTemplate, error) {
+	for i, t := range config.Templates {
+		if strings.EqualFold(t.Name, name) {
+			return &config.Templates[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no template named %q", name)
+}
+
+// chooseTemplate lists the templates in the launcher. Typing a pattern
+// with {q} instead uses it once.
+func chooseTemplate() (*queryTemplate, error) {
+	options := make([]string, len(config.Templates))
+	for i, t := range config.Templates {
+		options[i] = t.MenuOption()
+	}
+	selected, err := runLauncher("Template:", options, min(len(options), 15))
+	if err != nil {
+		return nil, err
+	}
+	if selected == "" {
+		return nil, fmt.Errorf("no template selected")
+	}
+	for i, t := range config.Templates {
+		if selected == t.MenuOption() {
+			return &config.Templates[i], nil
+		}
+	}
+	if t, err := templateByName(selected); err == nil {
+		return t, nil
+	}
+	if strings.Contains(selected, appconfig.TemplateVariable) {
+		return &queryTemplate{Name: "Custom", Query: selected}, nil
+	}
+	return nil, fmt.Errorf("no template named %q", selected)
+}
+
+// validateTemplates reports templates that can't be used.
+func validateTemplates() []string {
+	var problems []string
+	for _, t := range config.Templates {
+		if t.Name == "" {
+			problems = append(problems, fmt.Sprintf("template %q has no name", t.Query))
+		}
+		if !strings.Contains(t.Query, appconfig.TemplateVariable) {
+			problems = append(problems, fmt.Sprintf("template %q has no %s placeholder", t.Name, appconfig.TemplateVariable))
+		}
+		if t.EngineKey != "" {
+			if _, err := engineByKey(t.EngineKey); err != nil {
+				problems = append(problems, fmt.Sprintf("template %q: %v", t.Name, err))
+			}
+		}
+	}
+	return problems
+}
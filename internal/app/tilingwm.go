@@ -0,0 +1,111 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strconv"
+)
+
+const (
+	defaultPreselectDirection = "east"
+	defaultHerbstluftwmFrame  = "1"
+)
+
+// commandBackend builds a placement backend from a function that returns
+// the command lines to run, so --dry-run can print exactly what would run.
+func commandBackend(build func(windowID string, g windowGeometry) ([][]string, error)) placementBackend {
+	return placementBackend{
+		place: func(windowID string, g windowGeometry) error {
+			commands, err := build(windowID, g)
+			if err != nil {
+				return err
+			}
+			for _, args := range commands {
+				if out, err := combinedOutput(exec.Command(args[0], args[1:]...)); err != nil {
+					return fmt.Errorf("%s failed: %w: %s", shellJoin(args), err, out)
+				}
+			}
+			slog.Debug("Placed research window", "window", windowID, "commands", len(commands))
+			return nil
+		},
+		describe: func(windowID string, g windowGeometry) []string {
+			commands, err := build(windowID, g)
+			if err != nil {
+				return []string{"# " + err.Error()}
+			}
+			lines := make([]string, len(commands))
+			for i, args := range commands {
+				lines[i] = shellJoin(args)
+			}
+			return lines
+		},
+	}
+}
+
+func placementWorkspace() string {
+	if config.Placement.Workspace == "" {
+		return i3DefaultSpace
+	}
+	return config.Placement.Workspace
+}
+
+// bspwmCommands sends the window to a desktop, floats it at the usual
+// geometry, or splits the previously focused window towards placement.region
+// and moves the research window into the new area.
+func bspwmCommands(windowID string, g windowGeometry) ([][]string, error) {
+	switch config.Placement.Mode {
+	case "", "float":
+		return [][]string{
+			{"bspc", "node", windowID, "--state", "floating"},
+			append([]string{"wmctrl"}, positionArgs(windowID, g)...),
+		}, nil
+	case "workspace":
+		move := []string{"bspc", "node", windowID, "--to-desktop", placementWorkspace()}
+		if config.Placement.SwitchToWorkspace {
+			move = append(move, "--follow")
+		}
+		return [][]string{move}, nil
+	case "preselect":
+		direction := config.Placement.Region
+		if direction == "" {
+			direction = defaultPreselectDirection
+		}
+		screenWidth, _ := getScreenDimensions()
+		ratio := 1 - float64(g.Width)/float64(screenWidth)
+		return [][]string{
+			{"bspc", "node", "last", "--presel-dir", direction, "--presel-ratio", strconv.FormatFloat(ratio, 'f', 2, 64)},
+			{"bspc", "node", windowID, "--to-node", "last"},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported placement mode %q for bspwm (use float, workspace or preselect)", config.Placement.Mode)
+	}
+}
+
+// herbstluftwmCommands applies one-off rule consequences to the window:
+// a tag for workspace mode, a frame index for preselect mode, or floating
+// with an explicit geometry.
+func herbstluftwmCommands(windowID string, g windowGeometry) ([][]string, error) {
+	switch config.Placement.Mode {
+	case "", "float":
+		return [][]string{
+			{"herbstclient", "apply_tmp_rule", windowID, "floating=on"},
+			{"herbstclient", "set_attr", "clients." + windowID + ".floating_geometry",
+				fmt.Sprintf("%dx%d%+d%+d", g.Width, g.Height, g.X, g.Y)},
+		}, nil
+	case "workspace":
+		commands := [][]string{{"herbstclient", "apply_tmp_rule", windowID, "tag=" + placementWorkspace()}}
+		if config.Placement.SwitchToWorkspace {
+			commands = append(commands, []string{"herbstclient", "use", placementWorkspace()})
+		}
+		return commands, nil
+	case "preselect":
+		frame := config.Placement.Region
+		if frame == "" {
+			frame = defaultHerbstluftwmFrame
+		}
+		return [][]string{{"herbstclient", "apply_tmp_rule", windowID, "index=" + frame}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported placement mode %q for herbstluftwm (use float, workspace or preselect)", config.Placement.Mode)
+	}
+}
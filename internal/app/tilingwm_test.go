@@ -0,0 +1,77 @@
+package app
+
+import "testing"
+
+func TestCommandBackendPlacement(t *testing.T) {
+	g := windowGeometry{X: 1000, Y: 80, Width: 800, Height: 900}
+	tests := []struct {
+		backend string
+		mode    string
+		want    []string
+	}{
+		{"bspwm", "float", []string{
+			"bspc node 0x01200003 --state floating",
+			"wmctrl -i -r 0x01200003 -e 0,1000,80,800,900",
+		}},
+		{"bspwm", "workspace", []string{
+			"bspc node 0x01200003 --to-desktop research --follow",
+		}},
+		{"herbstluftwm", "", []string{
+			"herbstclient apply_tmp_rule 0x01200003 floating=on",
+			"herbstclient set_attr clients.0x01200003.floating_geometry 800x900+1000+80",
+		}},
+		{"herbstluftwm", "workspace", []string{
+			"herbstclient apply_tmp_rule 0x01200003 tag=research",
+			"herbstclient use research",
+		}},
+		{"herbstluftwm", "preselect", []string{
+			"herbstclient apply_tmp_rule 0x01200003 index=01",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.backend+" "+tt.mode, func(t *testing.T) {
+			useConfig(t)
+			config.Placement.Mode = tt.mode
+			config.Placement.Workspace = "research"
+			config.Placement.SwitchToWorkspace = true
+			config.Placement.Region = "01"
+			fake := useFakeRunner(t, nil)
+
+			backend := placementBackends[tt.backend]
+			if err := backend.place("0x01200003", g); err != nil {
+				t.Fatal(err)
+			}
+			assertCommands(t, fake, tt.want...)
+
+			// --dry-run prints the same commands
+			if got := backend.describe("0x01200003", g); len(got) != len(tt.want) {
+				t.Errorf("describe gave %q", got)
+			}
+		})
+	}
+}
+
+func TestCommandBackendUnsupportedMode(t *testing.T) {
+	useConfig(t)
+	config.Placement.Mode = "sideways"
+	fake := useFakeRunner(t, nil)
+
+	if err := placementBackends["bspwm"].place("0x01200003", windowGeometry{}); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+	assertCommands(t, fake)
+}
+
+func TestCommandBackendFailure(t *testing.T) {
+	useConfig(t)
+	fake := useFakeRunner(t, func(args []string, stdin string) (string, error) {
+		return "no such window", errExitStatus
+	})
+
+	err := placementBackends["herbstluftwm"].place("0x01200003", windowGeometry{Width: 800, Height: 600})
+	if err == nil {
+		t.Fatal("expected the failing command to stop placement")
+	}
+	// The first failure stops the rest
+	assertCommands(t, fake, "herbstclient apply_tmp_rule 0x01200003 floating=on")
+}
@@ -0,0 +1,200 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	trailPollInterval = time.Second
+	trailDebounce     = 3 * time.Second // a page must stay this long to count as visited
+)
+
+// startWindowTracker launches a detached `rabbithole track-window` process
+// so the hotkey invocation can exit while the trail keeps being recorded.
+func startWindowTracker(researchWindowID int64) error {
+	execPath, err := executable()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(execPath, "track-window", fmt.Sprint(researchWindowID))
+	cmd.SysProcAttr = detachedProcAttr()
+	return startDetached(cmd)
+}
+
+// trackWindow follows a research window until it closes, recording every
+// page that stays in the title for at least trailDebounce as a child of the
+// previous one.
+func trackWindow(researchWindowID int64) error {
+	var searchID int64
+	var windowID, url string
+	err := db.QueryRow(
+		"SELECT search_id, window_id, url FROM research_windows WHERE id = ?", researchWindowID,
+	).Scan(&searchID, &windowID, &url)
+	if err != nil {
+		return fmt.Errorf("unknown research window %d: %w", researchWindowID, err)
+	}
+
+	recordPageTitle(searchID, researchWindowID, windowID)
+
+	var parentID sql.NullInt64
+	var geometry windowGeometry
+	recorded := ""
+	candidate := ""
+	candidateSince := time.Now()
+
+	for {
+		windowTitle, err := getWindowTitle(windowID)
+		if err != nil {
+			slog.Debug("Window closed, trail complete", "window", windowID)
+			recordClosedWindow(searchID, researchWindowID, windowID, geometry)
+			return nil
+		}
+		// Remember where the window was, for reopen-last, and where the
+		// user moved it to, for the engine's next window
+		if g, err := getWindowGeometry(windowID); err == nil {
+			if geometry.Width > 0 && g != geometry && config.Behavior.RememberGeometry {
+				saveEngineGeometry(searchID, g)
+			}
+			geometry = g
+		}
+
+		// In tab mode later searches open in this same window; follow the
+		// newest one and start a fresh trail for it
+		if latest, err := latestResearchWindow(windowID); err == nil && latest.ID != researchWindowID {
+			researchWindowID, searchID, url = latest.ID, latest.SearchID, latest.URL
+			recordPageTitle(searchID, researchWindowID, windowID)
+			parentID = sql.NullInt64{}
+			recorded = ""
+			continue
+		}
+
+		title := pageTitle(windowTitle)
+		if title != candidate {
+			candidate = title
+			candidateSince = time.Now()
+		}
+
+		if candidate != "" && candidate != recorded && time.Since(candidateSince) >= trailDebounce {
+			// Only the first page is known to be at the URL we opened,
+			// unless the backend can read the window's URL
+			navURL := ""
+			if current, err := getWindowURL(windowID); err == nil {
+				navURL = current
+			} else if !parentID.Valid {
+				navURL = url
+			}
+			result, err := execPrepared(
+				"INSERT INTO navigations (window_id, parent_id, title, url) VALUES (?, ?, ?, ?)",
+				researchWindowID, parentID, seal(candidate), seal(navURL),
+			)
+			if err != nil {
+				slog.Error("Failed to record navigation", "err", err)
+			} else if id, err := result.LastInsertId(); err == nil {
+				parentID = sql.NullInt64{Int64: id, Valid: true}
+				recorded = candidate
+			}
+		}
+
+		time.Sleep(trailPollInterval)
+	}
+}
+
+// printTree prints every search of a session with the trail of pages that
+// followed it, indented one level per hop.
+func printTree(sessionID string) error {
+	rows, err := db.Query(
+		"SELECT id, query, engine_name, timestamp FROM searches WHERE session_id = ? ORDER BY timestamp",
+		sessionID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to query searches: %w", err)
+	}
+	defer rows.Close()
+
+	type search struct {
+		id        int64
+		query     string
+		engine    string
+		timestamp time.Time
+	}
+	var searches []search
+	for rows.Next() {
+		var s search
+		if err := rows.Scan(&s.id, &s.query, &s.engine, &s.timestamp); err != nil {
+			return fmt.Errorf("failed to read search: %w", err)
+		}
+		s.query = unseal(s.query)
+		searches = append(searches, s)
+	}
+	rows.Close()
+
+	if len(searches) == 0 {
+		fmt.Printf("No searches in session %s.\n", sessionID)
+		return nil
+	}
+
+	fmt.Printf("Session %s\n", sessionID)
+	for _, s := range searches {
+		fmt.Printf("\n%s  %s (%s)\n", s.timestamp.Local().Format("15:04"), s.query, s.engine)
+
+		titles, err := trailTitles(s.id)
+		if err != nil {
+			return err
+		}
+		for depth, title := range titles {
+			fmt.Printf("%s└─ %s\n", strings.Repeat("   ", depth), title)
+		}
+	}
+	return nil
+}
+
+// trailTitles returns the page titles visited from a search, following
+// parent links from the first page of each of its windows.
+func trailTitles(searchID int64) ([]string, error) {
+	rows, err := db.Query(`
+		SELECT n.id, n.parent_id, n.title FROM navigations n
+		JOIN research_windows w ON w.id = n.window_id
+		WHERE w.search_id = ?
+		ORDER BY n.id`, searchID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trail: %w", err)
+	}
+	defer rows.Close()
+
+	children := make(map[int64][]int64)
+	titles := make(map[int64]string)
+	var roots []int64
+	for rows.Next() {
+		var id int64
+		var parent sql.NullInt64
+		var title string
+		if err := rows.Scan(&id, &parent, &title); err != nil {
+			return nil, fmt.Errorf("failed to read trail: %w", err)
+		}
+		titles[id] = unseal(title)
+		if parent.Valid {
+			children[parent.Int64] = append(children[parent.Int64], id)
+		} else {
+			roots = append(roots, id)
+		}
+	}
+
+	var trail []string
+	for _, id := range roots {
+		for {
+			trail = append(trail, titles[id])
+			next := children[id]
+			if len(next) == 0 {
+				break
+			}
+			id = next[0]
+		}
+	}
+	return trail, nil
+}
@@ -0,0 +1,100 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+)
+
+// Translation engines have {source} and {target} placeholders in their URL,
+// e.g. "https://www.deepl.com/translator#{source}/{target}/%s". The
+// languages are asked for after the engine, defaulting to the pair last
+// used with that engine.
+const (
+	defaultSourceLanguage = "auto"
+	defaultTargetLanguage = "en"
+)
+
+var defaultLanguages = []string{"en", "de", "fr", "es", "it", "pt", "nl", "pl", "ru", "uk", "ja", "zh", "ko", "ar"}
+
+func isTranslator(engine SearchEngine) bool {
+	return strings.Contains(engine.URL, "{target}")
+}
+
+// engineLanguages returns the language pair last used with an engine.
+func engineLanguages(engineName string) (source, target string) {
+	source, target = defaultSourceLanguage, defaultTargetLanguage
+	conn := database()
+	if conn == nil {
+		return source, target
+	}
+	conn.QueryRow("SELECT source, target FROM engine_languages WHERE engine_name = ?", engineName).Scan(&source, &target)
+	return source, target
+}
+
+func saveEngineLanguages(engineName, source, target string) {
+	_, err := db.Exec(`
+		INSERT INTO engine_languages (engine_name, source, target) VALUES (?, ?, ?)
+		ON CONFLICT(engine_name) DO UPDATE SET source = excluded.source, target = excluded.target, updated_at = CURRENT_TIMESTAMP`,
+		engineName, source, target)
+	if err != nil {
+		slog.Error("Failed to remember languages", "err", err)
+	}
+}
+
+// chooseLanguage asks for a language, listing the remembered one first.
+// Anything typed that isn't in the list is used as is.
+func chooseLanguage(prompt, current string, extra ...string) (string, error) {
+	options := []string{current}
+	for _, lang := range append(extra, defaultLanguages...) {
+		if lang != current {
+			options = append(options, lang)
+		}
+	}
+	lang, err := runLauncher(prompt, options, config.Interface.Lines)
+	if err != nil {
+		return "", err
+	}
+	lang = strings.TrimSpace(lang)
+	if lang == "" {
+		return "", fmt.Errorf("no language selected")
+	}
+	return lang, nil
+}
+
+// translationEngine fills in a translator's languages. With ask it prompts
+// for them and remembers the choice; otherwise the remembered pair is used.
+func translationEngine(engine SearchEngine, ask bool) (SearchEngine, error) {
+	if err := initDatabase(); err != nil {
+		slog.Error("Failed to open database", "err", err)
+	}
+	source, target := engineLanguages(engine.Name)
+
+	if ask {
+		lock, err := acquireMenuLock()
+		if err != nil {
+			return engine, err
+		}
+		activeMenuLock = lock
+		source, err = chooseLanguage("From:", source, defaultSourceLanguage)
+		if err == nil {
+			target, err = chooseLanguage("To:", target)
+		}
+		activeMenuLock = nil
+		lock.release()
+		if err != nil {
+			return engine, err
+		}
+		if database() != nil && !dryRun {
+			saveEngineLanguages(engine.Name, source, target)
+		}
+	}
+
+	slog.Debug("Translating", "engine", engine.Name, "source", source, "target", target)
+	engine.URL = strings.NewReplacer(
+		"{source}", url.QueryEscape(source),
+		"{target}", url.QueryEscape(target),
+	).Replace(engine.URL)
+	return engine, nil
+}
@@ -0,0 +1,76 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const waybackSaveURL = "https://web.archive.org/save/"
+
+// Save Page Now often takes tens of seconds, which is why snapshots are
+// taken by a detached process rather than the bookmark hotkey itself.
+var waybackClient = &http.Client{Timeout: 2 * time.Minute}
+
+// startWaybackSnapshot snapshots a bookmark in the background.
+func startWaybackSnapshot(bookmarkID int64) error {
+	execPath, err := executable()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(execPath, "wayback-bookmark", fmt.Sprint(bookmarkID))
+	cmd.SysProcAttr = detachedProcAttr()
+	return startDetached(cmd)
+}
+
+// saveToWayback asks the Internet Archive's Save Page Now to capture
+// rawURL and returns the snapshot's URL.
+func saveToWayback(rawURL string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, waybackSaveURL+rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", appName+"/"+appVersion)
+	resp, err := waybackClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to reach the Wayback Machine: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("save page now failed: %s", resp.Status)
+	}
+
+	// The snapshot is named in Content-Location, or reached by redirect
+	if location := resp.Header.Get("Content-Location"); strings.HasPrefix(location, "/web/") {
+		return "https://web.archive.org" + location, nil
+	}
+	if final := resp.Request.URL.String(); strings.Contains(final, "/web/") {
+		return final, nil
+	}
+	return "", fmt.Errorf("save page now didn't return a snapshot")
+}
+
+// snapshotBookmark saves a bookmark's page to the Wayback Machine and
+// stores the snapshot URL with it.
+func snapshotBookmark(bookmarkID int64) error {
+	var url string
+	if err := db.QueryRow("SELECT url FROM bookmarks WHERE id = ?", bookmarkID).Scan(&url); err != nil {
+		return fmt.Errorf("unknown bookmark %d: %w", bookmarkID, err)
+	}
+	url = unseal(url)
+
+	snapshot, err := saveToWayback(url)
+	if err != nil {
+		slog.Error("Failed to snapshot bookmark", "bookmark", bookmarkID, "url", url, "err", err)
+		return err
+	}
+	if _, err := db.Exec("UPDATE bookmarks SET wayback_url = ? WHERE id = ?", seal(snapshot), bookmarkID); err != nil {
+		return fmt.Errorf("failed to store snapshot URL: %w", err)
+	}
+	slog.Info("Saved bookmark to the Wayback Machine", "bookmark", bookmarkID, "snapshot", snapshot)
+	return nil
+}
@@ -0,0 +1,96 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const webhookAttempts = 3
+
+var webhookClient = &http.Client{Timeout: 5 * time.Second}
+
+// pendingWebhooks lets the one-shot CLI finish deliveries before exiting.
+var pendingWebhooks sync.WaitGroup
+
+type webhookPayload struct {
+	Event     string    `json:"event"`
+	Query     string    `json:"query"`
+	Engine    string    `json:"engine"`
+	URL       string    `json:"url"`
+	Timestamp time.Time `json:"timestamp"`
+	Session   string    `json:"session"`
+	Tags      []string  `json:"tags"`
+}
+
+// sendSearchWebhook posts the search to Behavior.WebhookURL in the
+// background, retrying with backoff on failure.
+func sendSearchWebhook(payload webhookPayload) {
+	if config.Behavior.WebhookURL == "" {
+		return
+	}
+	if payload.Tags == nil {
+		payload.Tags = []string{}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		slog.Error("Failed to encode webhook payload", "err", err)
+		return
+	}
+
+	pendingWebhooks.Add(1)
+	go func() {
+		defer pendingWebhooks.Done()
+		backoff := time.Second
+		for attempt := 1; attempt <= webhookAttempts; attempt++ {
+			err := postWebhook(config.Behavior.WebhookURL, body)
+			if err == nil {
+				return
+			}
+			slog.Warn("Webhook delivery failed", "attempt", attempt, "of", webhookAttempts, "err", err)
+			if attempt < webhookAttempts {
+				time.Sleep(backoff)
+				backoff *= 2
+			}
+		}
+	}()
+}
+
+func postWebhook(url string, body []byte) error {
+	resp, err := webhookClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// waitForWebhooks blocks until pending deliveries finish or timeout passes.
+func waitForWebhooks(timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		pendingWebhooks.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		slog.Warn("Gave up waiting for webhook delivery", "timeout", timeout)
+	}
+}
+
+func splitTags(tags string) []string {
+	if tags == "" {
+		return nil
+	}
+	return strings.Split(tags, ",")
+}
@@ -0,0 +1,179 @@
+package app
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// wsConn is just enough of a WebSocket client (RFC 6455) to talk to a
+// browser's DevTools endpoint on localhost: text messages, no extensions.
+type wsConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+func dialWebSocket(rawURL string, timeout time.Duration) (*wsConn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WebSocket URL %q: %w", rawURL, err)
+	}
+	if u.Scheme != "ws" {
+		return nil, fmt.Errorf("unsupported WebSocket URL %q (only ws:// is)", rawURL)
+	}
+	conn, err := net.DialTimeout("tcp", u.Host, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+	request := fmt.Sprintf("GET %s HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: %s\r\nSec-WebSocket-Version: 13\r\n\r\n", u.RequestURI(), u.Host, key)
+	conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := io.WriteString(conn, request); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("WebSocket handshake failed: %w", err)
+	}
+	response.Body.Close()
+	accept := sha1.Sum([]byte(key + wsGUID))
+	if response.StatusCode != http.StatusSwitchingProtocols ||
+		response.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(accept[:]) {
+		conn.Close()
+		return nil, fmt.Errorf("WebSocket handshake failed: %s", response.Status)
+	}
+	conn.SetDeadline(time.Time{})
+	return &wsConn{conn: conn, reader: reader}, nil
+}
+
+func (ws *wsConn) Close() error {
+	ws.writeFrame(wsClose, nil)
+	return ws.conn.Close()
+}
+
+func (ws *wsConn) SetDeadline(t time.Time) error {
+	return ws.conn.SetDeadline(t)
+}
+
+// writeFrame sends one final frame; clients have to mask what they send.
+func (ws *wsConn) writeFrame(opcode byte, payload []byte) error {
+	header := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, 0x80|byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 0x80|126, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(n))
+	default:
+		header = append(header, 0x80|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(n))
+	}
+	mask := make([]byte, 4)
+	if _, err := rand.Read(mask); err != nil {
+		return err
+	}
+	frame := append(header, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	_, err := ws.conn.Write(frame)
+	return err
+}
+
+func (ws *wsConn) WriteText(message []byte) error {
+	return ws.writeFrame(wsText, message)
+}
+
+// ReadText returns the next text message, joining fragments and answering
+// pings on the way.
+func (ws *wsConn) ReadText() ([]byte, error) {
+	var message []byte
+	for {
+		header := make([]byte, 2)
+		if _, err := io.ReadFull(ws.reader, header); err != nil {
+			return nil, err
+		}
+		final, opcode := header[0]&0x80 != 0, header[0]&0x0F
+		length := uint64(header[1] & 0x7F)
+		switch length {
+		case 126:
+			ext := make([]byte, 2)
+			if _, err := io.ReadFull(ws.reader, ext); err != nil {
+				return nil, err
+			}
+			length = uint64(binary.BigEndian.Uint16(ext))
+		case 127:
+			ext := make([]byte, 8)
+			if _, err := io.ReadFull(ws.reader, ext); err != nil {
+				return nil, err
+			}
+			length = binary.BigEndian.Uint64(ext)
+		}
+		var mask []byte
+		if header[1]&0x80 != 0 {
+			mask = make([]byte, 4)
+			if _, err := io.ReadFull(ws.reader, mask); err != nil {
+				return nil, err
+			}
+		}
+		if length > 64<<20 {
+			return nil, fmt.Errorf("WebSocket frame too large (%d bytes)", length)
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(ws.reader, payload); err != nil {
+			return nil, err
+		}
+		if mask != nil {
+			for i := range payload {
+				payload[i] ^= mask[i%4]
+			}
+		}
+
+		switch opcode {
+		case wsPing:
+			if err := ws.writeFrame(wsPong, payload); err != nil {
+				return nil, err
+			}
+		case wsPong:
+		case wsClose:
+			return nil, errors.New("WebSocket closed by the browser")
+		case wsText, wsContinuation:
+			message = append(message, payload...)
+			if final {
+				return message, nil
+			}
+		default:
+			return nil, fmt.Errorf("unexpected WebSocket opcode %d", opcode)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package app
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"rabbithole/internal/wm"
+)
+
+// On Windows the clipboard is read through the Win32 API (PowerShell when
+// that fails), browsers are started with cmd's start, which also finds
+// them through App Paths when they aren't in PATH, and windows are placed
+// with SetWindowPos.
+
+func onWindows() bool {
+	return runtime.GOOS == "windows"
+}
+
+// win32Window is a top-level window and the executable that owns it.
+type win32Window struct {
+	Handle     uintptr
+	PID        int
+	Executable string
+}
+
+// readWindowsClipboard reads the clipboard. Windows has no PRIMARY
+// selection, so highlighted text has to be copied first.
+func readWindowsClipboard(selectionType string) (string, error) {
+	if selectionType != "clipboard" {
+		return "", fmt.Errorf("Windows has no %s selection", selectionType)
+	}
+	text, err := readWin32Clipboard()
+	if err == nil {
+		return text, nil
+	}
+	output, psErr := commandOutput(exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command",
+		"[Console]::OutputEncoding = [Text.Encoding]::UTF8; Get-Clipboard -Raw"))
+	if psErr != nil {
+		return "", fmt.Errorf("failed to read the clipboard: %w (PowerShell: %v)", err, psErr)
+	}
+	return string(output), nil
+}
+
+// windowsCopyCommand puts its stdin on the clipboard.
+func windowsCopyCommand() *exec.Cmd {
+	return exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command",
+		"[Console]::InputEncoding = [Text.Encoding]::UTF8; Set-Clipboard -Value ([Console]::In.ReadToEnd())")
+}
+
+func win32Backend() placementBackend {
+	return placementBackend{place: placeWithWin32, describe: describeWin32, detect: detectWithWin32}
+}
+
+func parseWindowHandle(windowID string) (uintptr, error) {
+	handle, err := strconv.ParseUint(strings.TrimPrefix(windowID, "0x"), 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid window handle %q: %w", windowID, err)
+	}
+	return uintptr(handle), nil
+}
+
+// detectWithWin32 launches the browser and polls the top-level windows
+// for a new one of the browser's executable. start exits right away, and a
+// running browser takes the URL over, so the executable has to match.
+func detectWithWin32(class string, launch func() (int, error)) (string, error) {
+	before, err := win32Windows()
+	if err != nil {
+		return "", err
+	}
+	known := make(map[uintptr]bool, len(before))
+	for _, w := range before {
+		known[w.Handle] = true
+	}
+	class = strings.TrimSuffix(class, ".exe")
+
+	if _, err := launch(); err != nil {
+		return "", err
+	}
+
+	deadline := time.Now().Add(windowDetectTimeout())
+	for time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+		windows, err := win32Windows()
+		if err != nil {
+			return "", err
+		}
+		for _, w := range windows {
+			if !known[w.Handle] && wm.MatchesWindowClass(w.Executable, class) {
+				return fmt.Sprintf("0x%x", w.Handle), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("failed to detect new %s window: timeout waiting for it to open", class)
+}
+
+func placeWithWin32(windowID string, g windowGeometry) error {
+	if config.Placement.Mode != "" && config.Placement.Mode != "float" {
+		return fmt.Errorf("unsupported placement mode %q for win32 (use float)", config.Placement.Mode)
+	}
+	handle, err := parseWindowHandle(windowID)
+	if err != nil {
+		return err
+	}
+	return setWin32WindowPos(handle, g)
+}
+
+func describeWin32(windowID string, g windowGeometry) []string {
+	return []string{
+		fmt.Sprintf("ShowWindow(%s, SW_RESTORE)", windowID),
+		fmt.Sprintf("SetWindowPos(%s, %d, %d, %d, %d)", windowID, g.X, g.Y, g.Width, g.Height),
+	}
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+
+package app
+
+import "errors"
+
+var errNotWindows = errors.New("the Win32 API is only available on Windows")
+
+func readWin32Clipboard() (string, error) {
+	return "", errNotWindows
+}
+
+func win32Windows() ([]win32Window, error) {
+	return nil, errNotWindows
+}
+
+func setWin32WindowPos(hwnd uintptr, g windowGeometry) error {
+	return errNotWindows
+}
+
+func win32ScreenSize() (width, height int, err error) {
+	return 0, 0, errNotWindows
+}
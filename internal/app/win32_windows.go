@@ -0,0 +1,171 @@
+//go:build windows
+
+package app
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf16"
+	"unsafe"
+)
+
+const (
+	cfUnicodeText                  = 13
+	gwOwner                        = 4
+	smCxScreen                     = 0
+	smCyScreen                     = 1
+	swRestore                      = 9
+	swpNoZOrder                    = 0x0004
+	swpNoActivate                  = 0x0010
+	processQueryLimitedInformation = 0x1000
+)
+
+var (
+	user32                         = syscall.NewLazyDLL("user32.dll")
+	procOpenClipboard              = user32.NewProc("OpenClipboard")
+	procCloseClipboard             = user32.NewProc("CloseClipboard")
+	procGetClipboardData           = user32.NewProc("GetClipboardData")
+	procEnumWindows                = user32.NewProc("EnumWindows")
+	procIsWindowVisible            = user32.NewProc("IsWindowVisible")
+	procGetWindow                  = user32.NewProc("GetWindow")
+	procGetWindowTextLengthW       = user32.NewProc("GetWindowTextLengthW")
+	procGetWindowThreadProcessID   = user32.NewProc("GetWindowThreadProcessId")
+	procShowWindow                 = user32.NewProc("ShowWindow")
+	procSetWindowPos               = user32.NewProc("SetWindowPos")
+	procGetSystemMetrics           = user32.NewProc("GetSystemMetrics")
+	procGlobalLock                 = kernel32.NewProc("GlobalLock")
+	procGlobalUnlock               = kernel32.NewProc("GlobalUnlock")
+	procQueryFullProcessImageNameW = kernel32.NewProc("QueryFullProcessImageNameW")
+)
+
+// readWin32Clipboard reads the clipboard's Unicode text, "" if it holds
+// none.
+func readWin32Clipboard() (string, error) {
+	// The program that just copied may still hold the clipboard open
+	opened := false
+	for attempt := 0; attempt < 10 && !opened; attempt++ {
+		if r, _, _ := procOpenClipboard.Call(0); r != 0 {
+			opened = true
+		} else {
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+	if !opened {
+		return "", fmt.Errorf("failed to open the clipboard")
+	}
+	defer procCloseClipboard.Call()
+
+	handle, _, _ := procGetClipboardData.Call(cfUnicodeText)
+	if handle == 0 {
+		return "", nil
+	}
+	locked, _, err := procGlobalLock.Call(handle)
+	if locked == 0 {
+		return "", fmt.Errorf("failed to read the clipboard: %w", err)
+	}
+	defer procGlobalUnlock.Call(handle)
+	return utf16String(*(*unsafe.Pointer)(unsafe.Pointer(&locked))), nil
+}
+
+// utf16String reads a NUL-terminated UTF-16 string.
+func utf16String(p unsafe.Pointer) string {
+	var chars []uint16
+	for i := uintptr(0); ; i++ {
+		c := *(*uint16)(unsafe.Add(p, i*2))
+		if c == 0 {
+			break
+		}
+		chars = append(chars, c)
+	}
+	return string(utf16.Decode(chars))
+}
+
+// EnumWindows calls back into Go, and a process can only create so many
+// callbacks, so one is shared.
+var (
+	enumMu       sync.Mutex
+	enumWindows  []uintptr
+	enumCallback = syscall.NewCallback(func(hwnd, _ uintptr) uintptr {
+		enumWindows = append(enumWindows, hwnd)
+		return 1
+	})
+)
+
+// win32Windows lists the visible, unowned top-level windows with a title,
+// the ones taskbars show, with the executable that owns each.
+func win32Windows() ([]win32Window, error) {
+	enumMu.Lock()
+	enumWindows = nil
+	r, _, err := procEnumWindows.Call(enumCallback, 0)
+	handles := enumWindows
+	enumMu.Unlock()
+	if r == 0 {
+		return nil, fmt.Errorf("failed to list windows: %w", err)
+	}
+
+	executables := make(map[uint32]string)
+	var windows []win32Window
+	for _, hwnd := range handles {
+		if visible, _, _ := procIsWindowVisible.Call(hwnd); visible == 0 {
+			continue
+		}
+		if owner, _, _ := procGetWindow.Call(hwnd, gwOwner); owner != 0 {
+			continue
+		}
+		if length, _, _ := procGetWindowTextLengthW.Call(hwnd); length == 0 {
+			continue
+		}
+		var pid uint32
+		procGetWindowThreadProcessID.Call(hwnd, uintptr(unsafe.Pointer(&pid)))
+		executable, ok := executables[pid]
+		if !ok {
+			executable = processExecutable(pid)
+			executables[pid] = executable
+		}
+		windows = append(windows, win32Window{Handle: hwnd, PID: int(pid), Executable: executable})
+	}
+	return windows, nil
+}
+
+// processExecutable is the lowercase file name of a process's executable
+// without .exe, like "firefox", or "" if it can't be queried.
+func processExecutable(pid uint32) string {
+	process, err := syscall.OpenProcess(processQueryLimitedInformation, false, pid)
+	if err != nil {
+		return ""
+	}
+	defer syscall.CloseHandle(process)
+	buf := make([]uint16, syscall.MAX_PATH)
+	size := uint32(len(buf))
+	if r, _, _ := procQueryFullProcessImageNameW.Call(uintptr(process), 0,
+		uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&size))); r == 0 {
+		return ""
+	}
+	name := filepath.Base(syscall.UTF16ToString(buf[:size]))
+	return strings.TrimSuffix(strings.ToLower(name), ".exe")
+}
+
+// setWin32WindowPos restores a maximized or minimized window and gives it
+// the geometry.
+func setWin32WindowPos(hwnd uintptr, g windowGeometry) error {
+	procShowWindow.Call(hwnd, swRestore)
+	r, _, err := procSetWindowPos.Call(hwnd, 0, uintptr(g.X), uintptr(g.Y), uintptr(g.Width), uintptr(g.Height),
+		swpNoZOrder|swpNoActivate)
+	if r == 0 {
+		return fmt.Errorf("SetWindowPos failed: %w", err)
+	}
+	return nil
+}
+
+func win32ScreenSize() (width, height int, err error) {
+	w, _, _ := procGetSystemMetrics.Call(smCxScreen)
+	h, _, _ := procGetSystemMetrics.Call(smCyScreen)
+	if w == 0 || h == 0 {
+		return 0, 0, fmt.Errorf("failed to read screen size")
+	}
+	return int(w), int(h), nil
+}
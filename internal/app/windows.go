@@ -0,0 +1,492 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"rabbithole/internal/x11"
+)
+
+const (
+	titlePollInterval = 500 * time.Millisecond
+	titlePollTimeout  = 10 * time.Second
+)
+
+// openResearchWindow opens the URL in a positioned research window and,
+// when it belongs to a logged search, records it and starts following its
+// trail.
+func openResearchWindow(searchID int64, l browserLaunch) error {
+	windowID, newWindow, err := openBrowserWindow(l)
+	if err != nil {
+		return err
+	}
+	trackResearchWindow(searchID, windowID, newWindow, l.URL)
+	return nil
+}
+
+// openLoggedResearchWindow is openResearchWindow for a search that's still
+// being logged, and returns its id once it is (0 if it was spilled). The
+// window is recorded with the search's redacted URL.
+func openLoggedResearchWindow(search *searchLog, l browserLaunch) (int64, error) {
+	windowID, newWindow, err := openBrowserWindow(l)
+	searchID := search.wait()
+	if err != nil {
+		return searchID, err
+	}
+	trackResearchWindow(searchID, windowID, newWindow, search.finalURL)
+	return searchID, nil
+}
+
+// openBrowserWindow opens the URL in a new side window, or a tab of the
+// research window in tab mode. newWindow is false for a reused window.
+func openBrowserWindow(l browserLaunch) (windowID string, newWindow bool, err error) {
+	newWindow = true
+	if config.Behavior.OpenMode == "tab" {
+		windowID, newWindow, err = openResearchTab(l)
+	} else {
+		windowID, err = openBrowserInSideWindow(l)
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to open browser: %w", err)
+	}
+	return windowID, newWindow, nil
+}
+
+// trackResearchWindow records the window of a logged search and starts
+// following its trail. url is the URL as stored, after redactions.
+func trackResearchWindow(searchID int64, windowID string, newWindow bool, url string) {
+	if searchID == 0 {
+		return
+	}
+
+	researchWindowID, err := recordResearchWindow(searchID, windowID, url)
+	if err != nil {
+		slog.Error("Failed to record research window", "err", err)
+		return
+	}
+	runHook("post_window_open", config.Hooks.PostWindowOpen,
+		"SEARCH_ID", strconv.FormatInt(searchID, 10), "RESEARCH_WINDOW_ID", strconv.FormatInt(researchWindowID, 10),
+		"WINDOW_ID", windowID, "URL", url)
+	// A reused tab container already has a tracker, which picks up the
+	// new search by itself
+	if !newWindow {
+		return
+	}
+	if err := startWindowTracker(researchWindowID); err != nil {
+		slog.Error("Failed to start window tracker", "err", err)
+	}
+}
+
+type researchWindow struct {
+	ID       int64  `json:"id"`
+	SearchID int64  `json:"search_id"`
+	WindowID string `json:"window_id"`
+	URL      string `json:"url"`
+	Title    string `json:"title"`
+}
+
+// activeResearchWindow returns the tracked research window that currently
+// has focus.
+func activeResearchWindow() (researchWindow, error) {
+	windowID, err := activeWindowID()
+	if err != nil {
+		return researchWindow{}, fmt.Errorf("couldn't determine the active window: %w", err)
+	}
+
+	w, err := latestResearchWindow(windowID)
+	if err != nil {
+		return researchWindow{}, fmt.Errorf("the active window (%s) is not a research window", windowID)
+	}
+
+	// Prefer the live title, the stored one is from when the page first loaded
+	if windowTitle, err := getWindowTitle(windowID); err == nil {
+		w.Title = pageTitle(windowTitle)
+	}
+	return w, nil
+}
+
+// activateWindow focuses and raises a window.
+func activateWindow(windowID string) error {
+	if isCDPWindow(windowID) {
+		return activateCDPWindow(windowID)
+	}
+	if isMarionetteWindow(windowID) {
+		return activateMarionetteWindow(windowID)
+	}
+	if onHyprland() {
+		return hyprlandDispatch("focuswindow", windowID)
+	}
+	if x, err := nativeX11(); err == nil {
+		window, err := x11.ParseWindowID(windowID)
+		if err != nil {
+			return err
+		}
+		return x.Activate(window)
+	}
+	return runCommand(exec.Command("wmctrl", "-i", "-a", windowID))
+}
+
+// closeWindow closes a window gracefully.
+func closeWindow(windowID string) error {
+	if isCDPWindow(windowID) {
+		return closeCDPWindow(windowID)
+	}
+	if isMarionetteWindow(windowID) {
+		return closeMarionetteWindow(windowID)
+	}
+	if onHyprland() {
+		return hyprlandDispatch("closewindow", windowID)
+	}
+	if x, err := nativeX11(); err == nil {
+		window, err := x11.ParseWindowID(windowID)
+		if err != nil {
+			return err
+		}
+		return x.CloseWindow(window)
+	}
+	return runCommand(exec.Command("wmctrl", "-i", "-c", windowID))
+}
+
+// closeResearchWindows closes the focused research window, or with all
+// every open one, and returns what it closed.
+func closeResearchWindows(all bool) ([]researchWindow, error) {
+	var windows []researchWindow
+	if all {
+		var err error
+		if windows, err = openResearchWindows(); err != nil {
+			return nil, err
+		}
+	} else {
+		w, err := activeResearchWindow()
+		if err != nil {
+			return nil, err
+		}
+		windows = []researchWindow{w}
+	}
+
+	for i, w := range windows {
+		if err := closeWindow(w.WindowID); err != nil {
+			return windows[:i], fmt.Errorf("failed to close %s: %w", w.WindowID, err)
+		}
+	}
+	return windows, nil
+}
+
+// latestResearchWindow returns the newest research window recorded for a
+// window ID; in tab mode that is the search of the newest tab.
+func latestResearchWindow(windowID string) (researchWindow, error) {
+	var w researchWindow
+	err := db.QueryRow(
+		"SELECT id, search_id, window_id, url, title FROM research_windows WHERE window_id = ? ORDER BY id DESC LIMIT 1",
+		windowID,
+	).Scan(&w.ID, &w.SearchID, &w.WindowID, &w.URL, &w.Title)
+	return w, err
+}
+
+// getWindowGeometry returns where a window is on screen.
+func getWindowGeometry(windowID string) (windowGeometry, error) {
+	if isCDPWindow(windowID) {
+		return cdpWindowGeometry(windowID)
+	}
+	if isMarionetteWindow(windowID) {
+		return marionetteWindowGeometry(windowID)
+	}
+	if onHyprland() {
+		return hyprlandWindowGeometry(windowID)
+	}
+	if x, err := nativeX11(); err == nil {
+		window, err := x11.ParseWindowID(windowID)
+		if err != nil {
+			return windowGeometry{}, err
+		}
+		g, err := x.Geometry(window)
+		return windowGeometry(g), err
+	}
+
+	out, err := commandOutput(exec.Command("xdotool", "getwindowgeometry", "--shell", windowID))
+	if err != nil {
+		return windowGeometry{}, err
+	}
+	var g windowGeometry
+	for _, line := range strings.Split(string(out), "\n") {
+		key, value, _ := strings.Cut(line, "=")
+		n, _ := strconv.Atoi(value)
+		switch key {
+		case "X":
+			g.X = n
+		case "Y":
+			g.Y = n
+		case "WIDTH":
+			g.Width = n
+		case "HEIGHT":
+			g.Height = n
+		}
+	}
+	// Like the native path, report where the frame is
+	if left, top, ok := frameExtents(windowID); ok {
+		g.X -= left
+		g.Y -= top
+	}
+	return g, nil
+}
+
+// frameExtents reads the left and top window decoration sizes with xprop,
+// from a line like "_NET_FRAME_EXTENTS(CARDINAL) = 1, 1, 37, 1".
+func frameExtents(windowID string) (left, top int, ok bool) {
+	out, err := commandOutput(exec.Command("xprop", "-id", windowID, "_NET_FRAME_EXTENTS"))
+	if err != nil {
+		return 0, 0, false
+	}
+	_, values, found := strings.Cut(string(out), "=")
+	fields := strings.Split(values, ",")
+	if !found || len(fields) != 4 {
+		return 0, 0, false
+	}
+	left, errLeft := strconv.Atoi(strings.TrimSpace(fields[0]))
+	top, errTop := strconv.Atoi(strings.TrimSpace(fields[2]))
+	return left, top, errLeft == nil && errTop == nil
+}
+
+// activeWindowID returns the focused window. With the marionette backend
+// that is the selected tab of the Firefox window focused last.
+func activeWindowID() (string, error) {
+	if config.Placement.Backend == "marionette" {
+		if windowID, err := marionetteActiveWindow(); err == nil {
+			return windowID, nil
+		}
+	}
+	if onHyprland() {
+		return hyprlandActiveWindow()
+	}
+	if x, err := nativeX11(); err == nil {
+		window, err := x.ActiveWindow()
+		if err != nil {
+			return "", err
+		}
+		return x11.FormatWindowID(window), nil
+	}
+
+	out, err := commandOutput(exec.Command("xdotool", "getactivewindow"))
+	if err != nil {
+		return "", err
+	}
+	return normalizeWindowID(strings.TrimSpace(string(out))), nil
+}
+
+// openWindowIDs returns the IDs of all windows currently on screen. With
+// the cdp and marionette backends those are the browser's pages, plus any
+// other windows that can still be listed.
+func openWindowIDs() (map[string]bool, error) {
+	var ids map[string]bool
+	var err error
+	switch config.Placement.Backend {
+	case "cdp":
+		ids, err = cdpWindowIDs()
+	case "marionette":
+		ids, err = marionetteWindowIDs()
+	default:
+		return wmWindowIDs()
+	}
+	if err != nil {
+		// Without the browser none of its pages are open
+		ids = make(map[string]bool)
+	}
+	if windows, err := wmWindowIDs(); err == nil {
+		for id := range windows {
+			ids[id] = true
+		}
+	}
+	return ids, nil
+}
+
+func wmWindowIDs() (map[string]bool, error) {
+	if onHyprland() {
+		return hyprlandWindowIDs()
+	}
+	if x, err := nativeX11(); err == nil {
+		clients, err := x.ClientList()
+		if err != nil {
+			return nil, err
+		}
+		ids := make(map[string]bool)
+		for window := range clients {
+			ids[x11.FormatWindowID(window)] = true
+		}
+		return ids, nil
+	}
+
+	out, err := commandOutput(exec.Command("wmctrl", "-l"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list windows: %w", err)
+	}
+	ids := make(map[string]bool)
+	for _, line := range strings.Split(string(out), "\n") {
+		if parts := strings.Fields(line); len(parts) > 0 {
+			ids[normalizeWindowID(parts[0])] = true
+		}
+	}
+	return ids, nil
+}
+
+// openResearchWindows returns the research windows that are still open,
+// newest first.
+func openResearchWindows() ([]researchWindow, error) {
+	open, err := openWindowIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`
+		SELECT id, search_id, window_id, url, title FROM research_windows
+		WHERE id IN (SELECT MAX(id) FROM research_windows GROUP BY window_id)
+		ORDER BY id DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query research windows: %w", err)
+	}
+	defer rows.Close()
+
+	var windows []researchWindow
+	for rows.Next() {
+		var w researchWindow
+		if err := rows.Scan(&w.ID, &w.SearchID, &w.WindowID, &w.URL, &w.Title); err != nil {
+			return nil, fmt.Errorf("failed to read research window: %w", err)
+		}
+		if open[w.WindowID] {
+			windows = append(windows, w)
+		}
+	}
+	return windows, rows.Err()
+}
+
+func recordResearchWindow(searchID int64, windowID, url string) (int64, error) {
+	result, err := execPrepared(
+		"INSERT INTO research_windows (search_id, window_id, url) VALUES (?, ?, ?)",
+		searchID, windowID, seal(url),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func getWindowTitle(windowID string) (string, error) {
+	if isCDPWindow(windowID) {
+		return cdpWindowTitle(windowID)
+	}
+	if isMarionetteWindow(windowID) {
+		return marionetteWindowTitle(windowID)
+	}
+	if onHyprland() {
+		return hyprlandWindowTitle(windowID)
+	}
+	if x, err := nativeX11(); err == nil {
+		window, err := x11.ParseWindowID(windowID)
+		if err != nil {
+			return "", err
+		}
+		return x.WindowTitle(window)
+	}
+
+	out, err := commandOutput(exec.Command("xdotool", "getwindowname", windowID))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// getWindowURL returns the URL of the page a window shows, which only the
+// cdp and marionette backends can tell.
+func getWindowURL(windowID string) (string, error) {
+	switch {
+	case isCDPWindow(windowID):
+		return cdpWindowURL(windowID)
+	case isMarionetteWindow(windowID):
+		return marionetteWindowURL(windowID)
+	}
+	return "", fmt.Errorf("the URL of window %s is unknown", windowID)
+}
+
+// currentPageURL returns the URL of the page a research window shows now,
+// which is only known where the backend can read it, or while the window
+// still shows the page it was opened with.
+func currentPageURL(w researchWindow) (string, error) {
+	if url, err := getWindowURL(w.WindowID); err == nil && url != "" {
+		return url, nil
+	}
+	stored, err := latestResearchWindow(w.WindowID)
+	if err != nil {
+		return "", err
+	}
+	unsealAll(&stored.URL, &stored.Title)
+	if stored.Title != "" && stored.Title == w.Title {
+		return stored.URL, nil
+	}
+	return "", fmt.Errorf("window %s has left the page it opened with and its URL can't be read (the cdp and marionette placement backends can)", w.WindowID)
+}
+
+// pageTitle strips the browser suffix from a window title, e.g.
+// "Rabbit - Wikipedia — Mozilla Firefox" becomes "Rabbit - Wikipedia".
+func pageTitle(windowTitle string) string {
+	for _, suffix := range []string{" — Mozilla Firefox", " - Mozilla Firefox", "Mozilla Firefox", " - Chromium", " - Google Chrome", " - Brave"} {
+		if strings.HasSuffix(windowTitle, suffix) {
+			return strings.TrimSpace(strings.TrimSuffix(windowTitle, suffix))
+		}
+	}
+	return windowTitle
+}
+
+// waitForPageTitle polls the window title until the page has loaded, i.e.
+// the title is no longer the bare browser name and stayed the same for two
+// consecutive polls.
+func waitForPageTitle(windowID string) (string, error) {
+	deadline := time.Now().Add(titlePollTimeout)
+	previous := ""
+	for time.Now().Before(deadline) {
+		time.Sleep(titlePollInterval)
+
+		windowTitle, err := getWindowTitle(windowID)
+		if err != nil {
+			return "", fmt.Errorf("window %s went away: %w", windowID, err)
+		}
+		title := pageTitle(windowTitle)
+		if title != "" && title == previous {
+			return title, nil
+		}
+		previous = title
+	}
+
+	if previous == "" {
+		return "", fmt.Errorf("timeout waiting for page title")
+	}
+	return previous, nil
+}
+
+// recordPageTitle waits for the research window to finish loading and
+// stores its title on both the window and the search that opened it.
+func recordPageTitle(searchID, researchWindowID int64, windowID string) {
+	title, err := waitForPageTitle(windowID)
+	if err != nil {
+		slog.Warn("Couldn't read page title", "window", windowID, "err", err)
+		return
+	}
+
+	sealed := seal(title)
+	err = inTransaction(func(tx *sql.Tx) error {
+		if _, err := tx.Exec("UPDATE research_windows SET title = ? WHERE id = ?", sealed, researchWindowID); err != nil {
+			return fmt.Errorf("failed to record window title: %w", err)
+		}
+		if _, err := tx.Exec("UPDATE searches SET page_title = ? WHERE id = ?", sealed, searchID); err != nil {
+			return fmt.Errorf("failed to record page title: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed to record page title", "err", err)
+	}
+	slog.Debug("Window loaded", "window", windowID, "title", title)
+}
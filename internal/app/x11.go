@@ -0,0 +1,116 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xproto"
+
+	"rabbithole/internal/wm"
+	"rabbithole/internal/x11"
+)
+
+var (
+	sharedX11     *x11.Session
+	sharedX11Err  error
+	sharedX11Once sync.Once
+)
+
+// nativeX11 returns a connection shared by the one-shot queries (titles,
+// active window, geometry) so a command connects to X at most once. Callers
+// fall back to wmctrl/xdotool/xdpyinfo when it returns an error.
+func nativeX11() (*x11.Session, error) {
+	if headless {
+		// Simulated windows only exist for the external tools
+		return nil, fmt.Errorf("native X11 is off in headless mode")
+	}
+	sharedX11Once.Do(func() {
+		sharedX11, sharedX11Err = x11.Open()
+		if sharedX11Err != nil {
+			slog.Debug("Native X11 unavailable, using external tools", "err", sharedX11Err)
+		}
+	})
+	return sharedX11, sharedX11Err
+}
+
+// isBrowserWindow matches the launched process by PID, or a running
+// instance it handed the URL to by PID and WM_CLASS.
+func isBrowserWindow(x *x11.Session, window xproto.Window, m *wm.Matcher) bool {
+	return m.Matches(x.WindowPID(window), x.WindowClass(window))
+}
+
+// detectWithX11 watches _NET_CLIENT_LIST for the browser's new window
+// instead of polling, falling back to wmctrl when X isn't reachable.
+func detectWithX11(class string, launch func() (int, error)) (string, error) {
+	x, err := x11.Open()
+	if err != nil {
+		slog.Debug("Falling back to wmctrl window detection", "err", err)
+		return detectWithWmctrl(class, launch)
+	}
+	defer x.Close()
+
+	// Subscribe before launching so the new window can't slip past
+	err = x.WatchRoot()
+	if err != nil {
+		return "", fmt.Errorf("failed to watch the client list: %w", err)
+	}
+	known, err := x.ClientList()
+	if err != nil {
+		return "", err
+	}
+	m := wm.NewMatcher(class)
+
+	m.PID, err = launch()
+	if err != nil {
+		return "", err
+	}
+
+	events := make(chan xgb.Event)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			event, xerr := x.Conn.WaitForEvent()
+			if event == nil && xerr == nil {
+				return
+			}
+			if event == nil {
+				continue
+			}
+			select {
+			case events <- event:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	timeout := time.After(windowDetectTimeout())
+	for {
+		select {
+		case event := <-events:
+			notify, ok := event.(xproto.PropertyNotifyEvent)
+			if !ok || notify.Atom != x.Atom("_NET_CLIENT_LIST") {
+				continue
+			}
+			clients, err := x.ClientList()
+			if err != nil {
+				return "", err
+			}
+			for window := range clients {
+				if known[window] {
+					continue
+				}
+				known[window] = true
+				if isBrowserWindow(x, window, m) {
+					return x11.FormatWindowID(window), nil
+				}
+			}
+		case <-timeout:
+			return "", fmt.Errorf("failed to detect new %s window: timeout waiting for it to be mapped", class)
+		}
+	}
+}
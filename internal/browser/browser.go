@@ -0,0 +1,157 @@
+// Package browser builds the command lines that open research windows in
+// Firefox or a Chromium-based browser, and sets up the minimal Firefox
+// profile. Which browser, profile and container a search gets is decided by
+// the caller's config.
+package browser
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"rabbithole/internal/wm"
+)
+
+// Default is the browser used when the config names none.
+const Default = "firefox"
+
+// Launch describes how one URL is opened: which browser, which profile and
+// which Firefox container, if any. A zero Geometry means the usual side
+// window position. Minimal windows show just the page. Zoom is the page
+// zoom factor (0 leaves it alone) and Reader opens Firefox's reader view.
+type Launch struct {
+	Browser   string
+	Profile   string
+	Container string
+	URL       string
+	Geometry  wm.Geometry
+	Minimal   bool
+	Zoom      float64
+	Reader    bool
+}
+
+// ContainerURL wraps a URL in the ext+container: scheme understood by the
+// "Open external links in a container" Firefox extension, so the page
+// opens in the named Multi-Account Container.
+func ContainerURL(container, rawURL string) string {
+	return "ext+container:name=" + url.QueryEscape(container) + "&url=" + url.QueryEscape(rawURL)
+}
+
+// OpenURL is what the browser is given: the URL itself, or wrapped for the
+// container or reader view. Containers and reader view only exist in
+// Firefox, and a container takes precedence.
+func (l Launch) OpenURL() string {
+	// The minimal Firefox profile has no container extension
+	if l.Container != "" && !l.Chromium() && !l.Minimal {
+		return ContainerURL(l.Container, l.URL)
+	}
+	if l.Reader && !l.Chromium() && l.Container == "" {
+		// %20 rather than +, which reader view's decoding would keep
+		return "about:reader?url=" + strings.ReplaceAll(url.QueryEscape(l.URL), "+", "%20")
+	}
+	return l.URL
+}
+
+// Chromium reports whether the browser is Chromium-based, going by its
+// command name.
+func (l Launch) Chromium() bool {
+	name := strings.ToLower(filepath.Base(l.Browser))
+	for _, family := range []string{"chrom", "brave", "vivaldi", "edge"} {
+		if strings.Contains(name, family) {
+			return true
+		}
+	}
+	return false
+}
+
+// Args builds the browser's command line (without size hints - they're
+// unreliable). Firefox profiles given as a path use --profile, bare names
+// use -P.
+func (l Launch) Args(newTab bool) []string {
+	var args []string
+	if l.Chromium() {
+		if l.Profile != "" {
+			args = append(args, "--profile-directory="+l.Profile)
+		}
+		// An app window has neither tabs nor an address bar
+		if l.Minimal && !newTab {
+			return append(args, "--app="+l.OpenURL())
+		}
+		if !newTab {
+			args = append([]string{"--new-window"}, args...)
+		}
+		return append(args, l.OpenURL())
+	}
+
+	if newTab {
+		args = append(args, "--new-tab")
+	} else {
+		args = append(args, "--new-window")
+	}
+	if strings.Contains(l.Profile, "/") {
+		args = append(args, "--profile", l.Profile)
+	} else if l.Profile != "" {
+		args = append(args, "-P", l.Profile)
+	}
+	return append(args, l.OpenURL())
+}
+
+// Command is the full command that opens the URL, started the way this
+// platform starts browsers.
+func (l Launch) Command(newTab bool) []string {
+	return StartCommand(l.Browser, l.Args(newTab))
+}
+
+// WindowClass is the WM_CLASS (or Wayland app_id) fragment the browser's
+// windows carry, used to recognise the window it opens.
+func (l Launch) WindowClass() string {
+	return strings.ToLower(filepath.Base(l.Browser))
+}
+
+// Firefox can't hide its UI for one window (--kiosk goes full screen), so
+// minimal windows open in a profile of their own whose userChrome.css hides
+// the toolbars.
+const (
+	minimalFirefoxPrefs = `// Written by rabbithole for minimal research windows
+user_pref("toolkit.legacyUserProfileCustomizations.stylesheets", true);
+user_pref("browser.shell.checkDefaultBrowser", false);
+user_pref("browser.aboutwelcome.enabled", false);
+user_pref("datareporting.policy.dataSubmissionPolicyBypassNotification", true);
+user_pref("browser.tabs.inTitlebar", 0);
+user_pref("layout.css.devPixelsPerPx", "%s");
+`
+	minimalFirefoxChrome = `/* Written by rabbithole: minimal research windows show just the page */
+#TabsToolbar, #nav-bar, #PersonalToolbar, #titlebar, #sidebar-box, #sidebar-main {
+  visibility: collapse !important;
+}
+`
+)
+
+// Prepare sets up what the launch needs before the browser starts: the
+// minimal Firefox profile in the Profile directory, rewritten every time so
+// it follows rabbithole's version and the zoom. Firefox reads it when it
+// starts, so the zoom is the one of the window that starts it.
+func (l Launch) Prepare() error {
+	if !l.Minimal || l.Chromium() {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Join(l.Profile, "chrome"), 0755); err != nil {
+		return fmt.Errorf("failed to create minimal Firefox profile: %w", err)
+	}
+	// -1 is Firefox's default, the screen's own scale
+	scale := "-1.0"
+	if l.Zoom > 0 {
+		scale = strconv.FormatFloat(l.Zoom, 'f', -1, 64)
+	}
+	prefs := fmt.Sprintf(minimalFirefoxPrefs, scale)
+	if err := os.WriteFile(filepath.Join(l.Profile, "user.js"), []byte(prefs), 0644); err != nil {
+		return fmt.Errorf("failed to write minimal Firefox profile: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(l.Profile, "chrome", "userChrome.css"), []byte(minimalFirefoxChrome), 0644); err != nil {
+		return fmt.Errorf("failed to write minimal Firefox profile: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package browser
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestBrowserCommandLines(t *testing.T) {
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		t.Skip("browsers are started through open/start here")
+	}
+
+	const page = "https://example.com/a b"
+	tests := []struct {
+		name   string
+		launch Launch
+		newTab bool
+		want   string
+	}{
+		{"firefox", Launch{Browser: "firefox", URL: page}, false,
+			"firefox --new-window " + page},
+		{"firefox tab", Launch{Browser: "firefox", URL: page}, true,
+			"firefox --new-tab " + page},
+		{"firefox profile name", Launch{Browser: "firefox", Profile: "research", URL: page}, false,
+			"firefox --new-window -P research " + page},
+		{"firefox profile path", Launch{Browser: "firefox", Profile: "/tmp/minimal", URL: page, Minimal: true}, false,
+			"firefox --new-window --profile /tmp/minimal " + page},
+		{"firefox container", Launch{Browser: "firefox", Container: "Work", URL: page}, false,
+			"firefox --new-window ext+container:name=Work&url=https%3A%2F%2Fexample.com%2Fa+b"},
+		{"firefox reader", Launch{Browser: "firefox", URL: page, Reader: true}, false,
+			"firefox --new-window about:reader?url=https%3A%2F%2Fexample.com%2Fa%20b"},
+		{"chromium", Launch{Browser: "chromium", Profile: "Profile 1", Container: "Work", URL: page}, false,
+			"chromium --new-window --profile-directory=Profile 1 " + page},
+		{"chromium tab", Launch{Browser: "/usr/bin/brave-browser", URL: page}, true,
+			"/usr/bin/brave-browser " + page},
+		{"chromium app", Launch{Browser: "google-chrome", URL: page, Minimal: true}, false,
+			"google-chrome --app=" + page},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.Join(tt.launch.Command(tt.newTab), " "); got != tt.want {
+				t.Errorf("got  %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package browser
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// StartCommand is the command that starts browser with args: directly on
+// Linux, through open on macOS and through start on Windows.
+func StartCommand(browser string, args []string) []string {
+	switch runtime.GOOS {
+	case "darwin":
+		return macOpenCommand(browser, args)
+	case "windows":
+		return windowsStartCommand(browser, args)
+	}
+	return append([]string{browser}, args...)
+}
+
+// macBrowserApps maps browser commands to the names of their macOS
+// applications.
+var macBrowserApps = map[string]string{
+	"firefox":                   "Firefox",
+	"firefox-esr":               "Firefox",
+	"firefox-developer-edition": "Firefox Developer Edition",
+	"firefox-nightly":           "Firefox Nightly",
+	"librewolf":                 "LibreWolf",
+	"chromium":                  "Chromium",
+	"chromium-browser":          "Chromium",
+	"chrome":                    "Google Chrome",
+	"google-chrome":             "Google Chrome",
+	"google-chrome-stable":      "Google Chrome",
+	"brave":                     "Brave Browser",
+	"brave-browser":             "Brave Browser",
+	"microsoft-edge":            "Microsoft Edge",
+	"microsoft-edge-stable":     "Microsoft Edge",
+	"vivaldi":                   "Vivaldi",
+}
+
+// MacAppName is the application a browser command stands for, which is
+// also what yabai and System Events call its windows' owner. A path to an
+// .app bundle or an application name is taken as it is.
+func MacAppName(browser string) string {
+	name := filepath.Base(browser)
+	if strings.HasSuffix(name, ".app") {
+		return strings.TrimSuffix(name, ".app")
+	}
+	if app, ok := macBrowserApps[strings.ToLower(name)]; ok {
+		return app
+	}
+	return name
+}
+
+// macOpenCommand starts the browser with open. -n starts another instance
+// even when the browser runs, so the arguments reach it; like on Linux,
+// the instance hands the URL to the running one and exits.
+func macOpenCommand(browser string, args []string) []string {
+	app := browser
+	if !strings.Contains(browser, "/") {
+		app = MacAppName(browser)
+	}
+	return append([]string{"open", "-na", app, "--args"}, args...)
+}
+
+// windowsStartCommand starts the browser with start, whose empty first
+// argument is the console title.
+func windowsStartCommand(browser string, args []string) []string {
+	command := []string{"cmd", "/c", "start", "", browser}
+	for _, arg := range args {
+		command = append(command, cmdEscape(arg))
+	}
+	return command
+}
+
+// cmdEscape keeps cmd from reading &, |, < and > in URLs as operators.
+// Arguments with spaces are quoted when the command line is built, and
+// inside quotes cmd takes them literally already. Percent escapes survive,
+// as cmd leaves undefined variables alone.
+func cmdEscape(arg string) string {
+	if strings.ContainsAny(arg, " \t\"") {
+		return arg
+	}
+	return strings.NewReplacer("^", "^^", "&", "^&", "|", "^|", "<", "^<", ">", "^>").Replace(arg)
+}
@@ -0,0 +1,108 @@
+// Package capture decides which selections to read and whether captured
+// text may become a query. Reading them is up to the caller, which runs the
+// commands given here.
+package capture
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"rabbithole/internal/x11"
+)
+
+// DefaultMaxLength is the longest selection searched without changing the
+// config.
+const DefaultMaxLength = 2000
+
+// Password managers like KeePassXC and KWallet mark what they copy with this
+// target so clipboard tools leave it alone.
+const passwordManagerHint = "x-kde-passwordManagerHint"
+
+// ErrRefused marks text that was captured but may not be used, so the auto
+// selection method asks instead of trying the next selection.
+var ErrRefused = errors.New("selection refused")
+
+// Command is the command that prints a selection on Linux: "primary" and
+// "clipboard" through xsel, "tmux" as the most recent paste buffer, i.e.
+// the last copy-mode selection.
+func Command(selection string) ([]string, error) {
+	switch selection {
+	case "primary":
+		return []string{"xsel", "-p"}, nil
+	case "clipboard":
+		return []string{"xsel", "-c"}, nil
+	case "tmux":
+		return []string{"tmux", "show-buffer"}, nil
+	}
+	return nil, fmt.Errorf("invalid selection type: %s", selection)
+}
+
+// Guard holds the limits captured text is checked against.
+type Guard struct {
+	MaxLength        int
+	AllowPasswords   bool
+	AllowBinary      bool
+	CollapseNewlines bool
+}
+
+// Check decides whether captured text may become a query. Huge accidental
+// selections, binary data and copied passwords are refused so they never
+// end up in the searches table or the log.
+func (g Guard) Check(text, selection string) (string, error) {
+	if !g.AllowPasswords && selection != "tmux" && FromPasswordManager(selection) {
+		return "", fmt.Errorf("%w: %s selection comes from a password manager", ErrRefused, selection)
+	}
+	if !g.AllowBinary && LooksBinary(text) {
+		return "", fmt.Errorf("%w: %s selection doesn't look like text", ErrRefused, selection)
+	}
+	if g.CollapseNewlines {
+		text = strings.Join(strings.Fields(text), " ")
+	}
+	if length := utf8.RuneCountInString(text); length > g.MaxLength {
+		return "", fmt.Errorf("%w: %s selection is %d characters, more than behavior.selection_max_length (%d)",
+			ErrRefused, selection, length, g.MaxLength)
+	}
+	return text, nil
+}
+
+// FromPasswordManager checks the selection owner's targets for the password
+// manager hint. Without X (e.g. no DISPLAY) the text is let through.
+func FromPasswordManager(selection string) bool {
+	x, err := x11.Open()
+	if err != nil {
+		slog.Debug("Can't check selection for a password manager", "err", err)
+		return false
+	}
+	defer x.Close()
+
+	targets, err := x.SelectionTargets(selection)
+	if err != nil {
+		slog.Debug("Can't check selection for a password manager", "err", err)
+		return false
+	}
+	for _, target := range targets {
+		if target == passwordManagerHint {
+			return true
+		}
+	}
+	return false
+}
+
+// LooksBinary catches text that isn't UTF-8, contains NUL bytes or is
+// mostly control characters, like a copied chunk of a binary file.
+func LooksBinary(text string) bool {
+	if !utf8.ValidString(text) || strings.ContainsRune(text, 0) {
+		return true
+	}
+	control := 0
+	for _, r := range text {
+		if unicode.IsControl(r) && !unicode.IsSpace(r) {
+			control++
+		}
+	}
+	return control*10 > utf8.RuneCountInString(text)
+}
@@ -0,0 +1,277 @@
+// Package config describes rabbithole's config file: the search engines,
+// bundles, templates and the settings of every part of the program. Loading
+// it, with profiles, project configs and environment overrides, is up to the
+// command.
+package config
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// SearchEngine is an engine as configured, with the browser and window
+// overrides of its searches.
+type SearchEngine struct {
+	Name    string   `json:"name"`
+	URL     string   `json:"url"`
+	Key     string   `json:"key"`
+	Aliases []string `json:"aliases,omitempty"`
+	// Icon is an emoji shown before the menu entry, or an icon name or
+	// image file for launchers with icon rows (rofi, fuzzel)
+	Icon      string `json:"icon,omitempty"`
+	Browser   string `json:"browser,omitempty"`
+	Profile   string `json:"profile,omitempty"`
+	Container string `json:"container,omitempty"`
+	// WindowUI is "minimal" for research windows without browser UI;
+	// defaults to behavior.window_ui
+	WindowUI string `json:"window_ui,omitempty"`
+	// Zoom and ReaderMode override behavior.zoom and behavior.reader_mode
+	Zoom       float64 `json:"zoom,omitempty"`
+	ReaderMode bool    `json:"reader_mode,omitempty"`
+	Inline     string  `json:"inline,omitempty"`
+	// Modifiers maps search modifiers (":lang de") to URL query
+	// parameters, e.g. {"lang": "hl"}
+	Modifiers map[string]string `json:"modifiers,omitempty"`
+	// Category groups the engine in exports ("academic", "code", ...);
+	// guessed from the URL when empty
+	Category string `json:"category,omitempty"`
+}
+
+// Config is the whole config file.
+type Config struct {
+	SearchEngines []SearchEngine   `json:"search_engines"`
+	Bundles       []EngineBundle   `json:"bundles"`
+	Suggestions   []SuggestionRule `json:"suggestions"`
+	Redactions    []RedactionRule  `json:"redactions"`
+	Templates     []Template       `json:"templates"`
+	Interface     struct {
+		Launcher      string   `json:"launcher"`
+		DmenuArgs     []string `json:"dmenu_args"`
+		Lines         int      `json:"lines"`
+		MenuOrder     string   `json:"menu_order"`
+		Notifications bool     `json:"notifications"`
+		Accessibility struct {
+			Enabled  bool   `json:"enabled"`
+			Font     string `json:"font"`
+			FontSize int    `json:"font_size"`
+			Speak    bool   `json:"speak"`
+		} `json:"accessibility"`
+	} `json:"interface"`
+	Database struct {
+		Path        string `json:"path"`
+		BackupDir   string `json:"backup_dir"`
+		Encryption  string `json:"encryption"`
+		Journal     bool   `json:"journal"`
+		JournalPath string `json:"journal_path"`
+	} `json:"database"`
+	Behavior struct {
+		AutoCopyDelayMs           int               `json:"auto_copy_delay_ms"`
+		MaxWindows                int               `json:"max_windows"`
+		WindowWidth               int               `json:"window_width"`
+		WindowHeight              int               `json:"window_height"`
+		Browser                   string            `json:"browser"`
+		FirefoxProfile            string            `json:"firefox_profile"`
+		SelectionMethod           string            `json:"selection_method"`
+		SelectionTimeoutMs        int               `json:"selection_timeout_ms"`
+		WindowTimeoutMs           int               `json:"window_timeout_ms"`
+		SelectionMaxLength        int               `json:"selection_max_length"`
+		SelectionCollapseNewlines bool              `json:"selection_collapse_newlines"`
+		SelectionAllowBinary      bool              `json:"selection_allow_binary"`
+		SelectionAllowPasswords   bool              `json:"selection_allow_passwords"`
+		LogSelections             bool              `json:"log_selections"`
+		ConcurrentSearch          string            `json:"concurrent_search"`
+		WebhookURL                string            `json:"webhook_url"`
+		OpenMode                  string            `json:"open_mode"`
+		WindowUI                  string            `json:"window_ui"`
+		Zoom                      float64           `json:"zoom"`
+		ReaderMode                bool              `json:"reader_mode"`
+		TagContainers             map[string]string `json:"tag_containers"`
+		RememberGeometry          bool              `json:"remember_geometry"`
+		DisableCalculator         bool              `json:"disable_calculator"`
+		EditQuery                 bool              `json:"edit_query"`
+		DisableSuggestions        bool              `json:"disable_suggestions"`
+		DisableRelatedSearches    bool              `json:"disable_related_searches"`
+		ArchiveSnippets           bool              `json:"archive_snippets"`
+		CaptureEnvironment        bool              `json:"capture_environment"`
+		HistoryRetentionDays      int               `json:"history_retention_days"`
+		IdleMinutes               int               `json:"idle_minutes"`
+		IdleAction                string            `json:"idle_action"`
+		DuplicateSearch           string            `json:"duplicate_search"`
+		DuplicateMinutes          int               `json:"duplicate_minutes"`
+	} `json:"behavior"`
+	Placement struct {
+		Backend           string `json:"backend"`
+		Mode              string `json:"mode"`
+		Workspace         string `json:"workspace"`
+		Region            string `json:"region"`
+		SwitchToWorkspace bool   `json:"switch_to_workspace"`
+		DebuggingPort     int    `json:"debugging_port"`
+		MarionettePort    int    `json:"marionette_port"`
+	} `json:"placement"`
+	ImageSearch struct {
+		UploadCommand string         `json:"upload_command"`
+		Engines       []SearchEngine `json:"engines"`
+	} `json:"image_search"`
+	Cite struct {
+		Style             string `json:"style"`
+		Bibliography      string `json:"bibliography"`
+		TranslationServer string `json:"translation_server"`
+	} `json:"cite"`
+	Archive struct {
+		Dir               string `json:"dir"`
+		Command           string `json:"command"`
+		WaybackOnBookmark bool   `json:"wayback_on_bookmark"`
+	} `json:"archive"`
+	Daemon struct {
+		Listen string `json:"listen"`
+	} `json:"daemon"`
+	Hotkeys struct {
+		Search       string `json:"search"`
+		ManualSearch string `json:"manual_search"`
+		Close        string `json:"close"`
+		CloseAll     string `json:"close_all"`
+		Recall       string `json:"recall"`
+		ScopedClose  bool   `json:"scoped_close"`
+	} `json:"hotkeys"`
+	Anki struct {
+		URL   string `json:"url"`
+		Deck  string `json:"deck"`
+		Model string `json:"model"`
+	} `json:"anki"`
+	Obsidian struct {
+		VaultPath   string `json:"vault_path"`
+		DailyNote   string `json:"daily_note"`
+		TopicFolder string `json:"topic_folder"`
+		Template    string `json:"template"`
+	} `json:"obsidian"`
+	Sync struct {
+		Target  string `json:"target"`
+		Machine string `json:"machine"`
+	} `json:"sync"`
+	Digest struct {
+		Dir    string `json:"dir"`
+		Weekly bool   `json:"weekly"`
+	} `json:"digest"`
+	Hooks struct {
+		PreSearch      string `json:"pre_search"`
+		PostWindowOpen string `json:"post_window_open"`
+		OnClose        string `json:"on_close"`
+		OnError        string `json:"on_error"`
+	} `json:"hooks"`
+	Routing struct {
+		Script string `json:"script"`
+	} `json:"routing"`
+}
+
+// Keys returns the engine's key followed by its aliases.
+func (e SearchEngine) Keys() []string {
+	return append([]string{e.Key}, e.Aliases...)
+}
+
+// MenuOption is how the engine is listed in the launcher, after its emoji
+// if it has one. Aliases are shown too, so typing one filters the menu
+// down to the engine.
+func (e SearchEngine) MenuOption() string {
+	option := fmt.Sprintf("%s: %s", e.Key, e.Name)
+	if len(e.Aliases) > 0 {
+		option += fmt.Sprintf(" (%s)", strings.Join(e.Aliases, ", "))
+	}
+	if e.Icon != "" && !IsIconName(e.Icon) {
+		option = e.Icon + " " + option
+	}
+	return option
+}
+
+// MenuIcon is the theme icon or image file launchers with icon rows show
+// next to the engine, or "".
+func (e SearchEngine) MenuIcon() string {
+	if IsIconName(e.Icon) {
+		return e.Icon
+	}
+	return ""
+}
+
+// IsIconName tells an icon theme name ("accessories-dictionary") or image
+// path, made of plain ASCII, from an emoji to put in front of the entry.
+func IsIconName(icon string) bool {
+	if icon == "" {
+		return false
+	}
+	for _, r := range icon {
+		if r > unicode.MaxASCII || unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// EngineBundle searches several engines at once under one menu key, e.g.
+// "p" for arXiv, Google Scholar and Semantic Scholar.
+type EngineBundle struct {
+	Name    string   `json:"name"`
+	Key     string   `json:"key"`
+	Engines []string `json:"engines"`
+	Icon    string   `json:"icon,omitempty"`
+}
+
+// MenuEntry shows the bundle in the engine menu. It has no URL of its own;
+// the command expands it into its engines.
+func (b EngineBundle) MenuEntry() SearchEngine {
+	return SearchEngine{Name: b.Name + " (" + strings.Join(b.Engines, "+") + ")", Key: b.Key, Icon: b.Icon}
+}
+
+// TemplateVariable is replaced by the query in a template.
+const TemplateVariable = "{q}"
+
+// Template is a reusable query pattern, e.g. "{q} filetype:pdf",
+// optionally tied to an engine.
+type Template struct {
+	Name      string `json:"name"`
+	Query     string `json:"query"`
+	EngineKey string `json:"engine,omitempty"`
+}
+
+func (t Template) Apply(query string) string {
+	return strings.ReplaceAll(t.Query, TemplateVariable, query)
+}
+
+// Fill applies the template to the query, or to each of its lines for a
+// batch search.
+func (t Template) Fill(query string, batch bool) string {
+	if !batch {
+		return t.Apply(query)
+	}
+	lines := strings.Split(query, "\n")
+	for i, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			lines[i] = t.Apply(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (t Template) MenuOption() string {
+	return fmt.Sprintf("%s: %s", t.Name, t.Query)
+}
+
+// SuggestionRule puts an engine first in the menu when the query matches
+// Pattern. User rules name a configured engine by EngineKey; built-in rules
+// carry their own engine, used unless a configured engine points at the
+// same site.
+type SuggestionRule struct {
+	Pattern   string       `json:"pattern"`
+	EngineKey string       `json:"engine"`
+	Engine    SearchEngine `json:"-"`
+	// Check rejects matches a pattern can't rule out, like a wrong ISBN
+	// check digit
+	Check func(query string) bool `json:"-"`
+}
+
+// RedactionRule masks the parts of queries matching Pattern before they
+// are stored or logged, e.g. email addresses or API tokens.
+type RedactionRule struct {
+	Name        string `json:"name"`
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement"`
+}
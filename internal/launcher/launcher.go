@@ -0,0 +1,243 @@
+// Package launcher builds the command lines of dmenu-like menus: dmenu,
+// rofi, wofi, fuzzel, bemenu, or any command that speaks the dmenu protocol
+// (options on stdin, selection on stdout). Running them is up to the caller.
+package launcher
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Driver maps the generic menu options (prompt, case-insensitivity, line
+// count) onto the flags understood by a specific dmenu-like program.
+type Driver struct {
+	// Command is the program to run
+	Command         string
+	baseArgs        []string // always passed, e.g. to enable dmenu mode
+	promptFlag      string
+	insensitiveFlag string // empty if the launcher is case-insensitive already
+	linesFlag       string
+	fontArgs        func(font string, size int) []string // accessibility font override
+	template        []string                             // custom command line with {prompt}/{lines} placeholders
+	// iconRows is set for launchers that read rofi's icon row protocol
+	// ("text\0icon\x1fNAME"); iconFlag turns icons on if they're off by
+	// default
+	iconRows bool
+	iconFlag string
+	// filterFlag pre-fills the input line, for editing a query
+	filterFlag string
+}
+
+// Options are what every menu of a launcher shares.
+type Options struct {
+	// Font and FontSize replace the launcher's font when Font is set, for
+	// accessibility
+	Font     string
+	FontSize int
+	// Args are extra arguments from the config (interface.dmenu_args)
+	Args []string
+}
+
+var drivers = map[string]Driver{
+	"dmenu": {
+		Command:         "dmenu",
+		promptFlag:      "-p",
+		insensitiveFlag: "-i",
+		linesFlag:       "-l",
+		fontArgs: func(font string, size int) []string {
+			return []string{"-fn", fmt.Sprintf("%s:size=%d", font, size)}
+		},
+	},
+	"rofi": {
+		Command:         "rofi",
+		baseArgs:        []string{"-dmenu"},
+		promptFlag:      "-p",
+		insensitiveFlag: "-i",
+		linesFlag:       "-l",
+		fontArgs: func(font string, size int) []string {
+			return []string{"-theme-str", fmt.Sprintf("configuration { font: \"%s %d\"; }", font, size)}
+		},
+		iconRows:   true,
+		iconFlag:   "-show-icons",
+		filterFlag: "-filter",
+	},
+	"wofi": {
+		Command:         "wofi",
+		baseArgs:        []string{"--dmenu"},
+		promptFlag:      "--prompt",
+		insensitiveFlag: "--insensitive",
+		linesFlag:       "--lines",
+		fontArgs:        wofiFontArgs,
+		filterFlag:      "--search",
+	},
+	"fuzzel": {
+		Command:    "fuzzel",
+		baseArgs:   []string{"--dmenu"},
+		promptFlag: "--prompt",
+		linesFlag:  "--lines",
+		fontArgs: func(font string, size int) []string {
+			return []string{"--font", fmt.Sprintf("%s:size=%d", font, size)}
+		},
+		iconRows:   true,
+		filterFlag: "--search",
+	},
+	"bemenu": {
+		Command:         "bemenu",
+		promptFlag:      "-p",
+		insensitiveFlag: "-i",
+		linesFlag:       "-l",
+		fontArgs: func(font string, size int) []string {
+			return []string{"--fn", fmt.Sprintf("%s %d", font, size)}
+		},
+		filterFlag: "--filter",
+	},
+}
+
+// wofiFontArgs writes a small stylesheet since wofi only takes fonts via CSS.
+func wofiFontArgs(font string, size int) []string {
+	path := filepath.Join(os.TempDir(), "rabbithole-wofi-accessibility.css")
+	css := fmt.Sprintf("* { font-family: %q; font-size: %dpt; }\n", font, size)
+	if err := os.WriteFile(path, []byte(css), 0644); err != nil {
+		slog.Warn("Failed to write wofi accessibility style", "err", err)
+		return nil
+	}
+	return []string{"--style", path}
+}
+
+// Lookup finds the driver of a launcher by name ("" for dmenu). Any other
+// name is taken as a command template for a tool that speaks the dmenu
+// protocol.
+func Lookup(name string) (Driver, error) {
+	if name == "" {
+		name = "dmenu"
+	}
+	if driver, ok := drivers[name]; ok {
+		return driver, nil
+	}
+
+	template := SplitCommandLine(name)
+	if len(template) < 2 && !strings.Contains(name, "{") {
+		return Driver{}, fmt.Errorf("unsupported launcher %q (use dmenu, rofi, wofi, fuzzel, bemenu or a command template like \"walker --dmenu -p {prompt}\")", name)
+	}
+	return Driver{Command: template[0], template: template[1:]}, nil
+}
+
+// SplitCommandLine splits a command template into words, honouring single
+// and double quotes so prompts and theme strings can contain spaces.
+func SplitCommandLine(s string) []string {
+	var words []string
+	var current strings.Builder
+	var quote rune
+	inWord := false
+
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, current.String())
+				current.Reset()
+				inWord = false
+			}
+		default:
+			current.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, current.String())
+	}
+	return words
+}
+
+// Args builds the full argument list for one launcher invocation. Custom
+// args from the config are appended last, minus any prompt or
+// case-insensitivity flags we already set ourselves. Command templates are
+// used as-is after placeholder substitution.
+func (d Driver) Args(prompt string, lines int, o Options) []string {
+	if d.template != nil {
+		replacer := strings.NewReplacer("{prompt}", prompt, "{lines}", strconv.Itoa(lines))
+		args := make([]string, len(d.template))
+		for i, word := range d.template {
+			args[i] = replacer.Replace(word)
+		}
+		return args
+	}
+
+	args := append([]string{}, d.baseArgs...)
+	if d.insensitiveFlag != "" {
+		args = append(args, d.insensitiveFlag)
+	}
+	if prompt != "" {
+		args = append(args, d.promptFlag, prompt)
+	}
+	if lines > 0 && d.linesFlag != "" {
+		args = append(args, d.linesFlag, strconv.Itoa(lines))
+	}
+	if o.Font != "" && d.fontArgs != nil {
+		args = append(args, d.fontArgs(o.Font, o.FontSize)...)
+	}
+
+	for i := 0; i < len(o.Args); i++ {
+		switch o.Args[i] {
+		case "-i", d.insensitiveFlag:
+			continue
+		case "-p", d.promptFlag:
+			i++ // skip the prompt value too
+			continue
+		}
+		args = append(args, o.Args[i])
+	}
+	return args
+}
+
+// IconRows gives each option its icon name or file ("" for none) in rofi's
+// icon row protocol, with the flag that turns icons on. Launchers without
+// icon rows get the options as they are.
+func (d Driver) IconRows(options, icons []string) (rows, args []string) {
+	if !d.iconRows || !hasIcons(icons) {
+		return options, nil
+	}
+	rows = make([]string, len(options))
+	for i, option := range options {
+		rows[i] = option
+		if i < len(icons) && icons[i] != "" {
+			rows[i] += "\x00icon\x1f" + icons[i]
+		}
+	}
+	if d.iconFlag != "" {
+		args = []string{d.iconFlag}
+	}
+	return rows, args
+}
+
+func hasIcons(icons []string) bool {
+	for _, icon := range icons {
+		if icon != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// EditArgs builds the arguments that show text in the input line, ready to
+// be edited. It returns nil for launchers that can't pre-fill their input
+// (dmenu, command templates).
+func (d Driver) EditArgs(prompt, text string, o Options) []string {
+	if d.filterFlag == "" || d.template != nil {
+		return nil
+	}
+	return append(d.Args(prompt, 0, o), d.filterFlag, text)
+}
@@ -0,0 +1,416 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// migrate brings the schema up to date, which only runs when the database
+// is older than this binary, keeping a dozen CREATE/PRAGMA statements off
+// the hotkey path.
+func migrate(conn *sql.DB) error {
+	var version int
+	if err := conn.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if version >= schemaVersion {
+		return nil
+	}
+	if err := migrateSchema(conn); err != nil {
+		return err
+	}
+	if _, err := conn.Exec(fmt.Sprintf("PRAGMA user_version = %d", schemaVersion)); err != nil {
+		return fmt.Errorf("failed to record schema version: %w", err)
+	}
+	return nil
+}
+
+// schemaVersion must be bumped whenever migrateSchema changes.
+const schemaVersion = 18
+
+func migrateSchema(conn *sql.DB) error {
+	createSearchesTable := `
+	CREATE TABLE IF NOT EXISTS searches (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		query TEXT NOT NULL,
+		engine_name TEXT NOT NULL,
+		engine_url TEXT NOT NULL,
+		trigger_method TEXT NOT NULL DEFAULT 'selection',
+		timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+		session_id TEXT DEFAULT ''
+	);
+	`
+
+	if _, err := conn.Exec(createSearchesTable); err != nil {
+		return fmt.Errorf("failed to create searches table: %w", err)
+	}
+
+	if err := addColumnIfMissing(conn, "searches", "environment", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+
+	// History, stats and digests filter and group by these
+	if _, err := conn.Exec("CREATE INDEX IF NOT EXISTS idx_searches_timestamp_engine ON searches(timestamp, engine_name)"); err != nil {
+		return fmt.Errorf("failed to create searches index: %w", err)
+	}
+
+	if err := initWindowTables(conn); err != nil {
+		return err
+	}
+
+	if err := initTrailTable(conn); err != nil {
+		return err
+	}
+
+	if err := initBookmarksTable(conn); err != nil {
+		return err
+	}
+
+	if err := initNotesTable(conn); err != nil {
+		return err
+	}
+
+	if err := initHealthTable(conn); err != nil {
+		return err
+	}
+
+	if err := initGeometryTable(conn); err != nil {
+		return err
+	}
+
+	if err := initLanguagesTable(conn); err != nil {
+		return err
+	}
+
+	if err := initClipboardTable(conn); err != nil {
+		return err
+	}
+
+	if err := initSnippetsTable(conn); err != nil {
+		return err
+	}
+
+	if err := initArchiveTable(conn); err != nil {
+		return err
+	}
+
+	if err := initEncryptionTable(conn); err != nil {
+		return err
+	}
+
+	if err := addColumnIfMissing(conn, "bookmarks", "wayback_url", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+
+	if err := addColumnIfMissing(conn, "searches", "final_url", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+
+	if err := addColumnIfMissing(conn, "searches", "tags", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+
+	if err := initSyncColumns(conn); err != nil {
+		return err
+	}
+
+	if err := initSearchIndex(conn); err != nil {
+		return err
+	}
+
+	// Searches from before final_url existed get it from their window
+	_, err := conn.Exec(`
+		UPDATE searches SET final_url = (
+			SELECT url FROM research_windows w WHERE w.search_id = searches.id ORDER BY w.id LIMIT 1
+		)
+		WHERE final_url = '' AND EXISTS (SELECT 1 FROM research_windows w WHERE w.search_id = searches.id)`)
+	if err != nil {
+		return fmt.Errorf("failed to backfill final URLs: %w", err)
+	}
+
+	return nil
+}
+
+// addColumnIfMissing migrates older databases by adding a column that
+// newer versions expect.
+func addColumnIfMissing(conn *sql.DB, table, column, definition string) error {
+	rows, err := conn.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to inspect %s table: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return fmt.Errorf("failed to inspect %s table: %w", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	rows.Close()
+
+	if _, err := conn.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add %s.%s: %w", table, column, err)
+	}
+	return nil
+}
+
+func initWindowTables(conn *sql.DB) error {
+	createWindowsTable := `
+	CREATE TABLE IF NOT EXISTS research_windows (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		search_id INTEGER REFERENCES searches(id),
+		window_id TEXT NOT NULL,
+		url TEXT NOT NULL,
+		title TEXT DEFAULT '',
+		opened_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	if _, err := conn.Exec(createWindowsTable); err != nil {
+		return fmt.Errorf("failed to create research_windows table: %w", err)
+	}
+
+	if err := addColumnIfMissing(conn, "searches", "page_title", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+	for _, column := range []struct{ name, definition string }{
+		{"parked_at", "DATETIME"},
+		{"closed_at", "DATETIME"},
+		{"reopened_at", "DATETIME"},
+		{"geometry", "TEXT DEFAULT ''"},
+		{"focus_seconds", "INTEGER DEFAULT 0"},
+		{"focused_at", "DATETIME"},
+	} {
+		if err := addColumnIfMissing(conn, "research_windows", column.name, column.definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func initTrailTable(conn *sql.DB) error {
+	createNavigationsTable := `
+	CREATE TABLE IF NOT EXISTS navigations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		window_id INTEGER NOT NULL REFERENCES research_windows(id),
+		parent_id INTEGER REFERENCES navigations(id),
+		title TEXT NOT NULL,
+		url TEXT DEFAULT '',
+		timestamp DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	if _, err := conn.Exec(createNavigationsTable); err != nil {
+		return fmt.Errorf("failed to create navigations table: %w", err)
+	}
+	return nil
+}
+
+func initBookmarksTable(conn *sql.DB) error {
+	createBookmarksTable := `
+	CREATE TABLE IF NOT EXISTS bookmarks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		search_id INTEGER REFERENCES searches(id),
+		window_id INTEGER REFERENCES research_windows(id),
+		url TEXT NOT NULL,
+		title TEXT DEFAULT '',
+		tags TEXT DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	if _, err := conn.Exec(createBookmarksTable); err != nil {
+		return fmt.Errorf("failed to create bookmarks table: %w", err)
+	}
+	return nil
+}
+
+func initNotesTable(conn *sql.DB) error {
+	createNotesTable := `
+	CREATE TABLE IF NOT EXISTS notes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		session_id TEXT NOT NULL,
+		search_id INTEGER REFERENCES searches(id),
+		text TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	if _, err := conn.Exec(createNotesTable); err != nil {
+		return fmt.Errorf("failed to create notes table: %w", err)
+	}
+	return nil
+}
+
+func initHealthTable(conn *sql.DB) error {
+	createHealthTable := `
+	CREATE TABLE IF NOT EXISTS health_reports (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+		size_bytes INTEGER NOT NULL,
+		integrity TEXT NOT NULL,
+		backup_path TEXT DEFAULT '',
+		backup_ok BOOLEAN DEFAULT 0,
+		issues TEXT DEFAULT ''
+	);
+	`
+	if _, err := conn.Exec(createHealthTable); err != nil {
+		return fmt.Errorf("failed to create health_reports table: %w", err)
+	}
+	return nil
+}
+
+func initGeometryTable(conn *sql.DB) error {
+	createGeometryTable := `
+	CREATE TABLE IF NOT EXISTS engine_geometry (
+		engine_name TEXT PRIMARY KEY,
+		geometry TEXT NOT NULL,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	if _, err := conn.Exec(createGeometryTable); err != nil {
+		return fmt.Errorf("failed to create engine_geometry table: %w", err)
+	}
+	return nil
+}
+
+func initLanguagesTable(conn *sql.DB) error {
+	createLanguagesTable := `
+	CREATE TABLE IF NOT EXISTS engine_languages (
+		engine_name TEXT PRIMARY KEY,
+		source TEXT NOT NULL,
+		target TEXT NOT NULL,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	if _, err := conn.Exec(createLanguagesTable); err != nil {
+		return fmt.Errorf("failed to create engine_languages table: %w", err)
+	}
+	return nil
+}
+
+func initClipboardTable(conn *sql.DB) error {
+	createClipboardTable := `
+	CREATE TABLE IF NOT EXISTS clipboard_history (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		text TEXT NOT NULL UNIQUE,
+		source TEXT NOT NULL,
+		captured_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	if _, err := conn.Exec(createClipboardTable); err != nil {
+		return fmt.Errorf("failed to create clipboard_history table: %w", err)
+	}
+	return nil
+}
+
+func initSnippetsTable(conn *sql.DB) error {
+	createSnippetsTable := `
+	CREATE TABLE IF NOT EXISTS snippets (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		text TEXT NOT NULL UNIQUE,
+		trigger_method TEXT NOT NULL,
+		captured_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	if _, err := conn.Exec(createSnippetsTable); err != nil {
+		return fmt.Errorf("failed to create snippets table: %w", err)
+	}
+	return nil
+}
+
+func initArchiveTable(conn *sql.DB) error {
+	createArchiveTable := `
+	CREATE TABLE IF NOT EXISTS page_archives (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		search_id INTEGER REFERENCES searches(id),
+		window_id INTEGER REFERENCES research_windows(id),
+		url TEXT NOT NULL,
+		title TEXT DEFAULT '',
+		path TEXT NOT NULL,
+		archived_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	if _, err := conn.Exec(createArchiveTable); err != nil {
+		return fmt.Errorf("failed to create page_archives table: %w", err)
+	}
+	return nil
+}
+
+func initEncryptionTable(conn *sql.DB) error {
+	createEncryptionTable := `
+	CREATE TABLE IF NOT EXISTS encryption_keys (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		public_key TEXT NOT NULL,
+		private_key TEXT DEFAULT '',
+		salt TEXT DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	if _, err := conn.Exec(createEncryptionTable); err != nil {
+		return fmt.Errorf("failed to create encryption_keys table: %w", err)
+	}
+	return nil
+}
+
+func initSyncColumns(conn *sql.DB) error {
+	for _, table := range []string{"searches", "bookmarks", "notes"} {
+		if err := addColumnIfMissing(conn, table, "sync_id", "TEXT"); err != nil {
+			return err
+		}
+		index := fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS %s_sync_id ON %s(sync_id)", table, table)
+		if _, err := conn.Exec(index); err != nil {
+			return fmt.Errorf("failed to index %s.sync_id: %w", table, err)
+		}
+	}
+
+	// Tombstones of purged searches, so the purge reaches other machines
+	createDeletionsTable := `
+	CREATE TABLE IF NOT EXISTS sync_deletions (
+		sync_id TEXT PRIMARY KEY,
+		deleted_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	if _, err := conn.Exec(createDeletionsTable); err != nil {
+		return fmt.Errorf("failed to create sync_deletions table: %w", err)
+	}
+	return nil
+}
+
+// initSearchIndex creates the full-text index of past queries. Triggers
+// keep it in step with the searches table, whatever writes to it (purge,
+// sync, encryption).
+func initSearchIndex(conn *sql.DB) error {
+	var exists int
+	if err := conn.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'searches_fts'").Scan(&exists); err != nil {
+		return fmt.Errorf("failed to inspect search index: %w", err)
+	}
+	statements := []string{
+		`CREATE VIRTUAL TABLE IF NOT EXISTS searches_fts USING fts5(
+			query, content='searches', content_rowid='id', tokenize='unicode61 remove_diacritics 2'
+		)`,
+		`CREATE TRIGGER IF NOT EXISTS searches_fts_insert AFTER INSERT ON searches BEGIN
+			INSERT INTO searches_fts (rowid, query) VALUES (new.id, new.query);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS searches_fts_delete AFTER DELETE ON searches BEGIN
+			INSERT INTO searches_fts (searches_fts, rowid, query) VALUES ('delete', old.id, old.query);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS searches_fts_update AFTER UPDATE OF query ON searches BEGIN
+			INSERT INTO searches_fts (searches_fts, rowid, query) VALUES ('delete', old.id, old.query);
+			INSERT INTO searches_fts (rowid, query) VALUES (new.id, new.query);
+		END`,
+	}
+	for _, statement := range statements {
+		if _, err := conn.Exec(statement); err != nil {
+			return fmt.Errorf("failed to create search index: %w", err)
+		}
+	}
+	if exists == 0 {
+		// Index the history from before the index existed
+		if _, err := conn.Exec("INSERT INTO searches_fts (searches_fts) VALUES ('rebuild')"); err != nil {
+			return fmt.Errorf("failed to build search index: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+// Package store opens rabbithole's SQLite database and keeps its schema up
+// to date. The queries stay with the features that make them.
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	_ "modernc.org/sqlite"
+)
+
+// BusyTimeoutMs is how long a statement waits for another process's lock.
+const BusyTimeoutMs = 5000
+
+// Open opens the database at path, creating its directory, and migrates the
+// schema. A database that fails to migrate is closed again, so no
+// half-migrated handle is left behind.
+func Open(path string) (*sql.DB, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create database directory: %w", err)
+	}
+
+	// Window trackers write concurrently with searches, so wait on locks
+	// instead of failing immediately
+	conn, err := sql.Open("sqlite", fmt.Sprintf("%s?_pragma=busy_timeout(%d)", path, BusyTimeoutMs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if err := migrate(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// OpenReadOnly opens the database at path as a read-only SQLite URI,
+// without migrating it.
+func OpenReadOnly(path string) (*sql.DB, error) {
+	uri := url.URL{Scheme: "file", Path: path,
+		RawQuery: fmt.Sprintf("mode=ro&_pragma=busy_timeout(%d)", BusyTimeoutMs)}
+	conn, err := sql.Open("sqlite", uri.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database read-only: %w", err)
+	}
+	return conn, nil
+}
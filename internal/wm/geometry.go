@@ -0,0 +1,22 @@
+package wm
+
+import "fmt"
+
+// Geometry is a window's position and size in pixels.
+type Geometry struct {
+	X, Y, Width, Height int
+}
+
+// String formats the geometry as stored in the database, "x,y,width,height".
+func (g Geometry) String() string {
+	return fmt.Sprintf("%d,%d,%d,%d", g.X, g.Y, g.Width, g.Height)
+}
+
+// ParseGeometry reads a geometry formatted by String.
+func ParseGeometry(s string) (Geometry, error) {
+	var g Geometry
+	if _, err := fmt.Sscanf(s, "%d,%d,%d,%d", &g.X, &g.Y, &g.Width, &g.Height); err != nil {
+		return g, fmt.Errorf("invalid geometry %q: %w", s, err)
+	}
+	return g, nil
+}
@@ -0,0 +1,63 @@
+package wm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+const (
+	i3IPCMagic       = "i3-ipc"
+	i3RunCommand     = 0
+	i3IPCDialTimeout = time.Second
+)
+
+// I3Command sends a RUN_COMMAND message over the i3 or sway IPC socket at
+// path and reports the first failing command, if any.
+func I3Command(path, command string) error {
+	conn, err := net.DialTimeout("unix", path, i3IPCDialTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", path, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	var msg bytes.Buffer
+	msg.WriteString(i3IPCMagic)
+	binary.Write(&msg, binary.LittleEndian, uint32(len(command)))
+	binary.Write(&msg, binary.LittleEndian, uint32(i3RunCommand))
+	msg.WriteString(command)
+	if _, err := conn.Write(msg.Bytes()); err != nil {
+		return fmt.Errorf("failed to send IPC command: %w", err)
+	}
+
+	header := make([]byte, len(i3IPCMagic)+8)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return fmt.Errorf("failed to read IPC reply: %w", err)
+	}
+	if string(header[:len(i3IPCMagic)]) != i3IPCMagic {
+		return fmt.Errorf("unexpected IPC reply from %s", path)
+	}
+	payload := make([]byte, binary.LittleEndian.Uint32(header[len(i3IPCMagic):]))
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return fmt.Errorf("failed to read IPC reply: %w", err)
+	}
+
+	var results []struct {
+		Success bool   `json:"success"`
+		Error   string `json:"error"`
+	}
+	if err := json.Unmarshal(payload, &results); err != nil {
+		return fmt.Errorf("failed to parse IPC reply: %w", err)
+	}
+	for _, r := range results {
+		if !r.Success {
+			return fmt.Errorf("IPC command failed: %s", r.Error)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+// Package wm describes research windows: their geometry, which new window
+// a browser launch opened, and the i3/sway IPC protocol. Placing them is up
+// to the command's placement backends.
+package wm
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// browserWindowClasses lists the WM_CLASS names browsers give their windows
+// when they differ from the command's name, e.g. google-chrome-stable opens
+// google-chrome windows.
+var browserWindowClasses = map[string][]string{
+	"firefox-esr":               {"firefox", "firefox-esr"},
+	"firefox-developer-edition": {"firefoxdeveloperedition", "firefox-aurora"},
+	"firefox-nightly":           {"firefox-nightly", "firefox"},
+	"librewolf":                 {"librewolf"},
+	"google-chrome-stable":      {"google-chrome"},
+	"google-chrome-beta":        {"google-chrome-beta"},
+	"chromium-browser":          {"chromium", "chromium-browser"},
+	"brave":                     {"brave-browser"},
+	"brave-browser-stable":      {"brave-browser"},
+	"microsoft-edge-stable":     {"microsoft-edge"},
+	"vivaldi":                   {"vivaldi-stable"},
+	// Flatpak exports, named by application ID
+	"org.mozilla.firefox":           {"firefox", "org.mozilla.firefox"},
+	"io.gitlab.librewolf-community": {"librewolf", "io.gitlab.librewolf-community"},
+	"org.chromium.chromium":         {"chromium", "org.chromium.chromium"},
+	"com.google.chrome":             {"google-chrome", "com.google.chrome"},
+	"com.brave.browser":             {"brave-browser", "com.brave.browser"},
+	"com.microsoft.edge":            {"microsoft-edge", "com.microsoft.edge"},
+	"com.vivaldi.vivaldi":           {"vivaldi-stable", "com.vivaldi.vivaldi"},
+}
+
+// WindowClasses lists the WM_CLASS names windows of the browser command
+// class can carry, starting with class itself.
+func WindowClasses(class string) []string {
+	return append([]string{class}, browserWindowClasses[class]...)
+}
+
+// MatchesWindowClass reports whether either part of a window's WM_CLASS
+// (instance and class, as windowClass joins them) is one the browser
+// command class uses. Parts are compared whole, so "firefox" doesn't match
+// a "firefox-devtools" helper window.
+func MatchesWindowClass(wmClass, class string) bool {
+	names := WindowClasses(class)
+	for _, part := range strings.Fields(strings.ToLower(wmClass)) {
+		for _, name := range names {
+			if part == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Matcher recognises the window a browser launch opens. Browsers
+// that are already running reuse their process: firefox --new-window and
+// chromium hand the URL to the running instance over their remote protocol
+// and exit, so the new window belongs to that instance instead.
+type Matcher struct {
+	Class string
+	// PID is the launched process, once known
+	PID int
+}
+
+func NewMatcher(class string) *Matcher {
+	return &Matcher{Class: class}
+}
+
+// handoffTimeout is how long a launched browser gets to exit after handing
+// its URL to a running instance.
+const handoffTimeout = 2 * time.Second
+
+// Matches decides whether a new window belongs to the launch. A window
+// whose _NET_WM_PID is the launched process or one of its children is the
+// one. Another window needs the browser's WM_CLASS, and is only taken when
+// its PID can't tell: the launched process handed the URL over and exited,
+// or it started a Flatpak browser, whose PIDs come from a sandbox of its
+// own.
+func (m *Matcher) Matches(windowPID int, wmClass string) bool {
+	if windowPID != 0 && m.PID != 0 && DescendsFrom(windowPID, m.PID) {
+		return true
+	}
+	if !MatchesWindowClass(wmClass, m.Class) {
+		return false
+	}
+	if windowPID == 0 || m.PID == 0 {
+		return true
+	}
+	return sandboxed(m.PID) || waitForExit(m.PID, handoffTimeout)
+}
+
+// DescendsFrom reports whether pid is ancestor or one of its descendants,
+// following parent PIDs through /proc.
+func DescendsFrom(pid, ancestor int) bool {
+	for depth := 0; pid > 1 && depth < 32; depth++ {
+		if pid == ancestor {
+			return true
+		}
+		stat, err := ReadProcStat(pid)
+		if err != nil {
+			return false
+		}
+		pid = stat.PPID
+	}
+	return false
+}
+
+// waitForExit reports whether pid exits within timeout. A zombie waiting
+// to be reaped has exited too.
+func waitForExit(pid int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		stat, err := ReadProcStat(pid)
+		if err != nil || stat.State == "Z" || stat.State == "X" {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// sandboxed reports whether pid or one of its descendants runs in another
+// PID namespace than ours, as bubblewrap sets up for Flatpak apps.
+func sandboxed(pid int) bool {
+	own, err := os.Readlink("/proc/self/ns/pid")
+	if err != nil {
+		return false
+	}
+	pids := []int{pid}
+	for depth := 0; len(pids) > 0 && depth < 8; depth++ {
+		var children []int
+		for _, p := range pids {
+			for _, ns := range []string{"pid", "pid_for_children"} {
+				link, err := os.Readlink(fmt.Sprintf("/proc/%d/ns/%s", p, ns))
+				if err == nil && link != own {
+					return true
+				}
+			}
+			children = append(children, procChildren(p)...)
+		}
+		pids = children
+	}
+	return false
+}
+
+// procChildren lists the children of pid's main thread.
+func procChildren(pid int) []int {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/task/%d/children", pid, pid))
+	if err != nil {
+		return nil
+	}
+	var children []int
+	for _, field := range strings.Fields(string(data)) {
+		if child, err := strconv.Atoi(field); err == nil {
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
+// ProcStat is what ReadProcStat reads about a process.
+type ProcStat struct {
+	State string
+	PPID  int
+	// Start is when the process started, in clock ticks since boot
+	Start string
+}
+
+// ReadProcStat reads the state, parent PID and start time from
+// /proc/PID/stat. The command name can contain spaces and parentheses, so
+// fields are counted from its closing parenthesis.
+func ReadProcStat(pid int) (ProcStat, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return ProcStat{}, err
+	}
+	s := string(data)
+	end := strings.LastIndexByte(s, ')')
+	if end < 0 {
+		return ProcStat{}, fmt.Errorf("malformed /proc/%d/stat", pid)
+	}
+	fields := strings.Fields(s[end+1:])
+	if len(fields) < 20 {
+		return ProcStat{}, fmt.Errorf("malformed /proc/%d/stat", pid)
+	}
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return ProcStat{}, fmt.Errorf("malformed /proc/%d/stat: %w", pid, err)
+	}
+	return ProcStat{State: fields[0], PPID: ppid, Start: fields[19]}, nil
+}
@@ -0,0 +1,378 @@
+// Package x11 talks to the X server directly for the window queries and
+// EWMH requests that would otherwise take a wmctrl, xdotool or xprop run
+// each.
+package x11
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xproto"
+)
+
+// EWMH source indication for requests from pagers and other tools, which
+// window managers honour more readily than application requests.
+const ewmhSourcePager = 2
+
+var atomNames = []string{
+	"_NET_CLIENT_LIST", "_NET_WM_PID", "_NET_ACTIVE_WINDOW", "_NET_WM_NAME", "UTF8_STRING",
+	"_NET_WM_STATE", "_NET_WM_STATE_MAXIMIZED_VERT", "_NET_WM_STATE_MAXIMIZED_HORZ",
+	"_NET_MOVERESIZE_WINDOW", "WM_CHANGE_STATE",
+	"_NET_DESKTOP_NAMES", "_NET_WM_DESKTOP", "_NET_CURRENT_DESKTOP", "_NET_CLOSE_WINDOW",
+	"_NET_FRAME_EXTENTS",
+	"CLIPBOARD", "TARGETS", "RABBITHOLE_SELECTION",
+}
+
+// Geometry is a window's position and size in pixels.
+type Geometry struct {
+	X, Y, Width, Height int
+}
+
+// Session is a direct connection to the X server with the EWMH atoms
+// rabbithole needs already interned.
+type Session struct {
+	// Conn is for waiting on events and requests not covered here
+	Conn  *xgb.Conn
+	Root  xproto.Window
+	atoms map[string]xproto.Atom
+}
+
+// Open connects to $DISPLAY.
+func Open() (*Session, error) {
+	conn, err := xgb.NewConn()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to X server: %w", err)
+	}
+
+	x := &Session{
+		Conn:  conn,
+		Root:  xproto.Setup(conn).DefaultScreen(conn).Root,
+		atoms: make(map[string]xproto.Atom),
+	}
+	for _, name := range atomNames {
+		reply, err := xproto.InternAtom(conn, false, uint16(len(name)), name).Reply()
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to intern %s: %w", name, err)
+		}
+		x.atoms[name] = reply.Atom
+	}
+	return x, nil
+}
+
+func (x *Session) Close() {
+	x.Conn.Close()
+}
+
+// Atom returns one of the interned atoms by name.
+func (x *Session) Atom(name string) xproto.Atom {
+	return x.atoms[name]
+}
+
+// WatchRoot subscribes to property changes on the root window, such as
+// _NET_ACTIVE_WINDOW and _NET_CLIENT_LIST, which then arrive as
+// PropertyNotify events on Conn.
+func (x *Session) WatchRoot() error {
+	return xproto.ChangeWindowAttributesChecked(x.Conn, x.Root, xproto.CwEventMask,
+		[]uint32{xproto.EventMaskPropertyChange}).Check()
+}
+
+func (x *Session) Property(window xproto.Window, atom xproto.Atom) ([]byte, error) {
+	reply, err := xproto.GetProperty(x.Conn, false, window, atom, xproto.GetPropertyTypeAny, 0, 1<<16).Reply()
+	if err != nil {
+		return nil, err
+	}
+	return reply.Value, nil
+}
+
+// SelectionTargets asks the owner of PRIMARY or CLIPBOARD which formats it
+// offers. Besides the text types these include hints like
+// x-kde-passwordManagerHint.
+func (x *Session) SelectionTargets(selection string) ([]string, error) {
+	selectionAtom := xproto.Atom(xproto.AtomPrimary)
+	if selection == "clipboard" {
+		selectionAtom = x.atoms["CLIPBOARD"]
+	}
+
+	// The owner delivers the answer to a window of ours
+	window, err := xproto.NewWindowId(x.Conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to allocate a window: %w", err)
+	}
+	err = xproto.CreateWindowChecked(x.Conn, 0, window, x.Root, 0, 0, 1, 1, 0,
+		xproto.WindowClassInputOnly, 0, 0, nil).Check()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a window: %w", err)
+	}
+	defer xproto.DestroyWindow(x.Conn, window)
+
+	property := x.atoms["RABBITHOLE_SELECTION"]
+	xproto.ConvertSelection(x.Conn, window, selectionAtom, x.atoms["TARGETS"], property, xproto.TimeCurrentTime)
+
+	notified := make(chan xproto.SelectionNotifyEvent, 1)
+	go func() {
+		for {
+			event, xerr := x.Conn.WaitForEvent()
+			if event == nil && xerr == nil {
+				return
+			}
+			if notify, ok := event.(xproto.SelectionNotifyEvent); ok && notify.Requestor == window {
+				notified <- notify
+				return
+			}
+		}
+	}()
+
+	select {
+	case notify := <-notified:
+		if notify.Property == xproto.AtomNone {
+			return nil, nil // Nobody owns the selection
+		}
+	case <-time.After(500 * time.Millisecond):
+		return nil, fmt.Errorf("timeout waiting for the %s owner", selection)
+	}
+
+	value, err := x.Property(window, property)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the %s targets: %w", selection, err)
+	}
+	var targets []string
+	for i := 0; i+4 <= len(value); i += 4 {
+		reply, err := xproto.GetAtomName(x.Conn, xproto.Atom(xgb.Get32(value[i:]))).Reply()
+		if err == nil {
+			targets = append(targets, reply.Name)
+		}
+	}
+	return targets, nil
+}
+
+// ClientList returns the managed windows from _NET_CLIENT_LIST.
+func (x *Session) ClientList() (map[xproto.Window]bool, error) {
+	value, err := x.Property(x.Root, x.atoms["_NET_CLIENT_LIST"])
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client list: %w", err)
+	}
+	clients := make(map[xproto.Window]bool)
+	for i := 0; i+4 <= len(value); i += 4 {
+		clients[xproto.Window(xgb.Get32(value[i:]))] = true
+	}
+	return clients, nil
+}
+
+func (x *Session) WindowPID(window xproto.Window) int {
+	value, err := x.Property(window, x.atoms["_NET_WM_PID"])
+	if err != nil || len(value) < 4 {
+		return 0
+	}
+	return int(xgb.Get32(value))
+}
+
+// WindowClass returns both parts of WM_CLASS ("instance\x00class\x00").
+func (x *Session) WindowClass(window xproto.Window) string {
+	value, err := x.Property(window, xproto.AtomWmClass)
+	if err != nil {
+		return ""
+	}
+	return strings.ReplaceAll(strings.TrimRight(string(value), "\x00"), "\x00", " ")
+}
+
+func (x *Session) ActiveWindow() (xproto.Window, error) {
+	value, err := x.Property(x.Root, x.atoms["_NET_ACTIVE_WINDOW"])
+	if err != nil {
+		return 0, fmt.Errorf("failed to read the active window: %w", err)
+	}
+	if len(value) < 4 || xgb.Get32(value) == 0 {
+		return 0, fmt.Errorf("no window has focus")
+	}
+	return xproto.Window(xgb.Get32(value)), nil
+}
+
+// WindowTitle prefers the UTF-8 _NET_WM_NAME over the legacy WM_NAME. An
+// error means the window no longer exists.
+func (x *Session) WindowTitle(window xproto.Window) (string, error) {
+	value, err := x.Property(window, x.atoms["_NET_WM_NAME"])
+	if err != nil {
+		return "", err
+	}
+	if len(value) == 0 {
+		if value, err = x.Property(window, xproto.AtomWmName); err != nil {
+			return "", err
+		}
+	}
+	return strings.TrimSpace(string(value)), nil
+}
+
+func (x *Session) ScreenSize() (width, height int) {
+	screen := xproto.Setup(x.Conn).DefaultScreen(x.Conn)
+	return int(screen.WidthInPixels), int(screen.HeightInPixels)
+}
+
+// SendRootMessage sends an EWMH client message about window to the window
+// manager.
+func (x *Session) SendRootMessage(window xproto.Window, messageType string, data ...uint32) error {
+	values := make([]uint32, 5)
+	copy(values, data)
+	event := xproto.ClientMessageEvent{
+		Format: 32,
+		Window: window,
+		Type:   x.atoms[messageType],
+		Data:   xproto.ClientMessageDataUnionData32New(values),
+	}
+	mask := uint32(xproto.EventMaskSubstructureNotify | xproto.EventMaskSubstructureRedirect)
+	return xproto.SendEventChecked(x.Conn, false, x.Root, mask, string(event.Bytes())).Check()
+}
+
+func (x *Session) Unmaximize(window xproto.Window) error {
+	const removeState = 0
+	return x.SendRootMessage(window, "_NET_WM_STATE", removeState,
+		uint32(x.atoms["_NET_WM_STATE_MAXIMIZED_VERT"]), uint32(x.atoms["_NET_WM_STATE_MAXIMIZED_HORZ"]), ewmhSourcePager)
+}
+
+// MoveResize is the native equivalent of wmctrl -e 0,x,y,w,h.
+func (x *Session) MoveResize(window xproto.Window, g Geometry) error {
+	flags := uint32(1<<8 | 1<<9 | 1<<10 | 1<<11 | ewmhSourcePager<<12)
+	return x.SendRootMessage(window, "_NET_MOVERESIZE_WINDOW", flags,
+		uint32(g.X), uint32(g.Y), uint32(g.Width), uint32(g.Height))
+}
+
+func (x *Session) Activate(window xproto.Window) error {
+	return x.SendRootMessage(window, "_NET_ACTIVE_WINDOW", ewmhSourcePager, xproto.TimeCurrentTime)
+}
+
+// Iconify asks the window manager to minimize the window (ICCCM
+// WM_CHANGE_STATE to IconicState).
+func (x *Session) Iconify(window xproto.Window) error {
+	const iconicState = 3
+	return x.SendRootMessage(window, "WM_CHANGE_STATE", iconicState)
+}
+
+// CloseWindow asks the window manager to close the window as if its close
+// button was clicked, so the browser can save its session.
+func (x *Session) CloseWindow(window xproto.Window) error {
+	return x.SendRootMessage(window, "_NET_CLOSE_WINDOW", xproto.TimeCurrentTime, ewmhSourcePager)
+}
+
+// Geometry returns the window's size and the position of its frame on the
+// root window, which is what _NET_MOVERESIZE_WINDOW places, so a saved
+// geometry restores to the same spot instead of drifting by the title bar.
+func (x *Session) Geometry(window xproto.Window) (Geometry, error) {
+	geom, err := xproto.GetGeometry(x.Conn, xproto.Drawable(window)).Reply()
+	if err != nil {
+		return Geometry{}, err
+	}
+	pos, err := xproto.TranslateCoordinates(x.Conn, window, x.Root, 0, 0).Reply()
+	if err != nil {
+		return Geometry{}, err
+	}
+	g := Geometry{X: int(pos.DstX), Y: int(pos.DstY), Width: int(geom.Width), Height: int(geom.Height)}
+	// _NET_FRAME_EXTENTS is left, right, top, bottom
+	if value, err := x.Property(window, x.atoms["_NET_FRAME_EXTENTS"]); err == nil && len(value) >= 16 {
+		g.X -= int(xgb.Get32(value))
+		g.Y -= int(xgb.Get32(value[8:]))
+	}
+	return g, nil
+}
+
+// DesktopNames returns the EWMH desktop names in index order.
+func (x *Session) DesktopNames() ([]string, error) {
+	value, err := x.Property(x.Root, x.atoms["_NET_DESKTOP_NAMES"])
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimRight(string(value), "\x00"), "\x00"), nil
+}
+
+func (x *Session) MoveToDesktop(window xproto.Window, desktop int) error {
+	return x.SendRootMessage(window, "_NET_WM_DESKTOP", uint32(desktop), ewmhSourcePager)
+}
+
+func (x *Session) SwitchDesktop(desktop int) error {
+	return x.SendRootMessage(x.Root, "_NET_CURRENT_DESKTOP", uint32(desktop), xproto.TimeCurrentTime)
+}
+
+func ParseWindowID(windowID string) (xproto.Window, error) {
+	id, err := strconv.ParseUint(strings.TrimPrefix(windowID, "0x"), 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid window ID %q: %w", windowID, err)
+	}
+	return xproto.Window(id), nil
+}
+
+func FormatWindowID(window xproto.Window) string {
+	return fmt.Sprintf("0x%08x", uint32(window))
+}
+
+// numLockKeysym is XK_Num_Lock.
+const numLockKeysym xproto.Keysym = 0xff7f
+
+// Keycode finds the keycode that produces keysym on this keyboard; ok is
+// false if none does.
+func (x *Session) Keycode(keysym xproto.Keysym) (keycode xproto.Keycode, ok bool, err error) {
+	setup := xproto.Setup(x.Conn)
+	count := byte(setup.MaxKeycode - setup.MinKeycode + 1)
+	mapping, err := xproto.GetKeyboardMapping(x.Conn, setup.MinKeycode, count).Reply()
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read the keyboard mapping: %w", err)
+	}
+	perKeycode := int(mapping.KeysymsPerKeycode)
+	for i := 0; i < int(count); i++ {
+		for j := 0; j < perKeycode; j++ {
+			if mapping.Keysyms[i*perKeycode+j] == keysym {
+				return setup.MinKeycode + xproto.Keycode(i), true, nil
+			}
+		}
+	}
+	return 0, false, nil
+}
+
+// lockMasks are the combinations of lock modifiers (Caps Lock, Num Lock)
+// that must not stop a grab from matching, so each grab is made once per
+// combination.
+func (x *Session) lockMasks() []uint16 {
+	num := x.numLockMask()
+	return []uint16{0, xproto.ModMaskLock, num, xproto.ModMaskLock | num}
+}
+
+// numLockMask finds the modifier Num Lock is mapped to, which is Mod2 on
+// nearly every keyboard and the fallback when it can't be read.
+func (x *Session) numLockMask() uint16 {
+	keycode, ok, err := x.Keycode(numLockKeysym)
+	if err != nil || !ok {
+		return xproto.ModMask2
+	}
+	mapping, err := xproto.GetModifierMapping(x.Conn).Reply()
+	if err != nil {
+		return xproto.ModMask2
+	}
+	perModifier := int(mapping.KeycodesPerModifier)
+	for mod := 0; mod < 8; mod++ {
+		for _, k := range mapping.Keycodes[mod*perModifier : (mod+1)*perModifier] {
+			if k == keycode {
+				return 1 << mod
+			}
+		}
+	}
+	return xproto.ModMask2
+}
+
+// GrabKey grabs the key on the root window, whatever the lock modifiers.
+func (x *Session) GrabKey(keycode xproto.Keycode, mods uint16) error {
+	for _, lock := range x.lockMasks() {
+		err := xproto.GrabKeyChecked(x.Conn, true, x.Root, mods|lock, keycode,
+			xproto.GrabModeAsync, xproto.GrabModeAsync).Check()
+		if err != nil {
+			x.UngrabKey(keycode, mods)
+			return fmt.Errorf("failed to grab key (is it bound elsewhere?): %v", err)
+		}
+	}
+	return nil
+}
+
+func (x *Session) UngrabKey(keycode xproto.Keycode, mods uint16) {
+	for _, lock := range x.lockMasks() {
+		xproto.UngrabKey(x.Conn, keycode, x.Root, mods|lock)
+	}
+}
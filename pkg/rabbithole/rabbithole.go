@@ -0,0 +1,144 @@
+// Package rabbithole lets other Go programs log searches and manage
+// research windows with the same config and database as the rabbithole
+// command, e.g. a status bar widget or an editor plugin.
+//
+//	if err := rabbithole.Open(""); err != nil {
+//		log.Fatal(err)
+//	}
+//	err := rabbithole.Search("g", "quine relay", "ci")
+//
+// Background work (trail tracking, Wayback snapshots) is handed to the
+// rabbithole binary in PATH, so it has to be installed.
+package rabbithole
+
+import (
+	"time"
+
+	"rabbithole/internal/app"
+)
+
+// Trigger is recorded as how searches made through this package started.
+const Trigger = "api"
+
+// Engine is a configured search engine or bundle.
+type Engine struct {
+	Name string
+	Key  string
+	// URL is the engine's template, with %s where the query goes
+	URL string
+}
+
+// HistoryEntry is a logged search.
+type HistoryEntry struct {
+	ID      int64
+	Query   string
+	Engine  string
+	URL     string
+	Trigger string
+	Session string
+	Tags    []string
+	Time    time.Time
+	// OpenSeconds and FocusSeconds are the time spent in its windows
+	OpenSeconds  int64
+	FocusSeconds int64
+}
+
+// Window is a browser window opened for a search.
+type Window struct {
+	ID       int64
+	SearchID int64
+	// WindowID is the X11 window id, e.g. "0x04a00003"
+	WindowID string
+	URL      string
+	Title    string
+}
+
+// Open loads the config and opens the database of a profile ("" for the
+// default one). It must be called before anything else.
+func Open(profile string) error {
+	return app.Open(profile)
+}
+
+// Engines lists the configured engines followed by the bundles.
+func Engines() []Engine {
+	var engines []Engine
+	for _, e := range app.Engines() {
+		engines = append(engines, Engine{Name: e.Name, Key: e.Key, URL: e.URL})
+	}
+	return engines
+}
+
+// SearchURL is the URL engineKey's engine would open for query.
+func SearchURL(engineKey, query string) (string, error) {
+	engine, err := app.EngineByKey(engineKey)
+	if err != nil {
+		return "", err
+	}
+	return app.BuildSearchURL(engine, query), nil
+}
+
+// Search logs query and opens it in a research window, like
+// rabbithole search --no-menu --engine engineKey.
+func Search(engineKey, query string, tags ...string) error {
+	return app.Search(engineKey, query, Trigger, tags)
+}
+
+// LogSearch records a search that was opened some other way and returns
+// its id.
+func LogSearch(engineKey, query string, tags ...string) (int64, error) {
+	return app.LogSearch(engineKey, query, Trigger, tags)
+}
+
+// History returns the last limit searches, newest first.
+func History(limit int) ([]HistoryEntry, error) {
+	entries, err := app.History(limit)
+	if err != nil {
+		return nil, err
+	}
+	searches := make([]HistoryEntry, 0, len(entries))
+	for _, e := range entries {
+		searches = append(searches, HistoryEntry{
+			ID:           e.ID,
+			Query:        e.Query,
+			Engine:       e.EngineName,
+			URL:          e.FinalURL,
+			Trigger:      e.TriggerMethod,
+			Session:      e.SessionID,
+			Tags:         e.Tags,
+			Time:         e.Timestamp,
+			OpenSeconds:  e.Time.OpenSeconds,
+			FocusSeconds: e.Time.FocusSeconds,
+		})
+	}
+	return searches, nil
+}
+
+// Windows lists the research windows that are still open.
+func Windows() ([]Window, error) {
+	open, err := app.OpenWindows()
+	if err != nil {
+		return nil, err
+	}
+	windows := make([]Window, 0, len(open))
+	for _, w := range open {
+		windows = append(windows, Window{ID: w.ID, SearchID: w.SearchID, WindowID: w.WindowID, URL: w.URL, Title: w.Title})
+	}
+	return windows, nil
+}
+
+// CloseActive closes the focused research window.
+func CloseActive() error {
+	_, err := app.CloseWindows(false)
+	return err
+}
+
+// CloseAll closes every open research window.
+func CloseAll() error {
+	_, err := app.CloseWindows(true)
+	return err
+}
+
+// Park minimizes an open research window so unpark can bring it back.
+func Park(w Window) error {
+	return app.ParkWindow(app.ResearchWindow{ID: w.ID, SearchID: w.SearchID, WindowID: w.WindowID, URL: w.URL, Title: w.Title})
+}